@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/start"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Commands for working with the bundler config",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Checks a config file and env vars without starting the bundler",
+	Long: `The validate command loads the config the same way as start and reports every invalid value. The file
+can be passed as an argument or with --config. Checks that depend on the mode, such as the builder urls for
+searcher mode, only run if --mode is set. Without --mode, the module pipelines of private, searcher, and shadow
+mode are checked. With --mode multichain, each entry in the chains list is checked.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			viper.Set("config_file", args[0])
+		}
+		if m, _ := cmd.Flags().GetString("mode"); m != "" {
+			viper.Set("mode", m)
+		}
+		if err := start.ValidateConfig(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configValidateCmd.Flags().String("mode", "", "Optional. The mode to validate for.")
+}
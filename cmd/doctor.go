@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/start"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks that the configured nodes support the features required by the bundler",
+	Long: `The doctor command uses the same config as start and checks each node for:
+	
+	1. The expected chain ID and sync status.
+	2. Deployed AiMiddleware code.
+	3. State overrides in eth_call, which are required for gas estimation.
+	4. debug_traceCall, JS tracer support, and a synthetic simulateValidation trace.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := start.Doctor(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "\nFatal error:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/AO-Metaplayer/aiops-bundler/internal/start"
 	"github.com/spf13/cobra"
@@ -14,15 +15,27 @@ var startCmd = &cobra.Command{
 	Long: `The start command has the following modes:
 	
 	1. private: A bundler backed by a private mempool and compatible with all EVM networks.
-	2. searcher: A bundler backed by the P2P mempool and integrated with a Block Builder API.`,
+	2. searcher: A bundler backed by the P2P mempool and integrated with a Block Builder API.
+	3. shadow: A private bundler that builds bundles without sending them and compares them with on-chain events.
+	4. multichain: A private bundler for each chain in the config file, routed by chain ID at /rpc/{chainId}.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		var err error
 		if viper.GetString("mode") == "private" {
-			start.PrivateMode()
+			err = start.PrivateMode()
 		} else if viper.GetString("mode") == "searcher" {
-			start.SearcherMode()
+			err = start.SearcherMode()
+		} else if viper.GetString("mode") == "shadow" {
+			err = start.ShadowMode()
+		} else if viper.GetString("mode") == "multichain" {
+			err = start.MultiChainMode()
 		} else {
 			panic(fmt.Sprintf("Fatal flag error: \"%s\" mode not supported", viper.GetString("mode")))
 		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Fatal error:", err)
+			os.Exit(1)
+		}
 	},
 }
 
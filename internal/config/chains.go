@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const chainsKey = envPrefix + "chains"
+
+// globalKeys are values shared by every chain in multichain mode. They can only be set at the top level.
+var globalKeys = []string{
+	"aiops_bundler_port",
+	"aiops_bundler_shutdown_timeout_seconds",
+	"aiops_bundler_gin_mode",
+	"aiops_bundler_otel_service_name",
+	"aiops_bundler_otel_collector_headers",
+	"aiops_bundler_otel_collector_url",
+	"aiops_bundler_otel_insecure_mode",
+	"aiops_bundler_prometheus_enabled",
+	"aiops_bundler_prometheus_port",
+}
+
+// chains returns each entry in the chains list with keys prefixed to match the env var names.
+func (l *loader) chains() []map[string]any {
+	l.seen[chainsKey] = true
+	v, ok := l.file[chainsKey]
+	if !ok {
+		l.fail(chainsKey, "not set")
+		return nil
+	}
+	items, ok := v.([]any)
+	if !ok {
+		l.fail(chainsKey, "must be a list")
+		return nil
+	}
+
+	out := []map[string]any{}
+	for i, item := range items {
+		raw, ok := item.(map[string]any)
+		if !ok {
+			l.fail(chainsKey, "item %d: must be a map", i)
+			continue
+		}
+
+		entry := map[string]any{}
+		for k, v := range raw {
+			entry[envPrefix+strings.TrimPrefix(strings.ToLower(k), envPrefix)] = v
+		}
+		out = append(out, entry)
+	}
+	if len(out) == 0 && !l.failed(chainsKey) {
+		l.fail(chainsKey, "not set")
+	}
+	return out
+}
+
+// checkChainKeys adds an error for any key in a chain entry that is unknown or can only be set at the top
+// level.
+func (l *loader) checkChainKeys() {
+	keys := []string{}
+	for k := range l.chain {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !l.seen[k] || k == chainsKey {
+			l.fail(k, "unknown key")
+			continue
+		}
+		for _, g := range globalKeys {
+			if k == g {
+				l.fail(k, "is shared by all chains and must be set at the top level")
+			}
+		}
+	}
+}
+
+// LoadChains returns the config for each chain in multichain mode. Chains are defined in the chains list of
+// the config file. Each entry accepts the same keys as the top level and any value that is not set in an
+// entry is read the same way as Load. Errors in an entry are reported with the path of the entry, such as
+// chains[0].eth_client_url.
+func LoadChains() ([]*Values, error) {
+	file, err := readSources()
+	if err != nil {
+		return nil, err
+	}
+
+	l := newLoader(file)
+	entries := l.chains()
+	if len(entries) == 0 {
+		return nil, &ValidationError{Fields: l.errs}
+	}
+
+	out := []*Values{}
+	ids := map[string]int{}
+	for i, entry := range entries {
+		cl := newChainLoader(l, fmt.Sprintf("chains[%d]", i), entry)
+		values := cl.values()
+		cl.checkChainKeys()
+		if id := values.EthClientChainID; id != nil {
+			if j, ok := ids[id.String()]; ok {
+				cl.fail("aiops_bundler_eth_client_chain_id", "%s is already used by chains[%d]", id, j)
+			} else {
+				ids[id.String()] = i
+			}
+		}
+		l.errs = append(l.errs, cl.errs...)
+		out = append(out, values)
+	}
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
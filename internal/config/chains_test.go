@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestLoadChainsInheritsTopLevel verifies that each chain entry overrides the top level values and inherits
+// the rest.
+func TestLoadChainsInheritsTopLevel(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+private_key: "`+testPrivateKey+`"
+port: 5000
+max_batch_gas_limit: 12000000
+chains:
+  - eth_client_url: http://a
+  - eth_client_url: http://b
+    max_batch_gas_limit: 100
+    private_batch_modules: [relay.send_aiop]
+`)
+
+	chains, err := LoadChains()
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	if len(chains) != 2 {
+		t.Fatalf("got %d chains, want 2", len(chains))
+	}
+	if chains[0].EthClientUrl != "http://a" || chains[1].EthClientUrl != "http://b" {
+		t.Fatalf("got eth client urls %s and %s", chains[0].EthClientUrl, chains[1].EthClientUrl)
+	}
+	if chains[0].MaxBatchGasLimit.Uint64() != 12000000 || chains[1].MaxBatchGasLimit.Uint64() != 100 {
+		t.Fatalf(
+			"got max batch gas limits %s and %s, want 12000000 and 100",
+			chains[0].MaxBatchGasLimit,
+			chains[1].MaxBatchGasLimit,
+		)
+	}
+	if chains[0].Port != 5000 || chains[1].Port != 5000 {
+		t.Fatal("port must be shared by all chains")
+	}
+	if len(chains[0].Pipelines["private"].Batch) == 1 || len(chains[1].Pipelines["private"].Batch) != 1 {
+		t.Fatal("pipelines must be set per chain")
+	}
+}
+
+// TestLoadChainsFieldErrors verifies that errors in a chain entry include the path of the entry.
+func TestLoadChainsFieldErrors(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+private_key: "`+testPrivateKey+`"
+chains:
+  - eth_client_url: http://a
+    eth_client_chain_id: 1
+    port: 6000
+  - eth_client_chain_id: 1
+    not_a_key: true
+`)
+
+	_, err := LoadChains()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got err %v, want ValidationError", err)
+	}
+	got := map[string]bool{}
+	for _, f := range verr.Fields {
+		got[f.Path+"."+f.Key] = true
+	}
+	for _, want := range []string{
+		"chains[0].port",
+		"chains[1].eth_client_url",
+		"chains[1].eth_client_chain_id",
+		"chains[1].not_a_key",
+	} {
+		if !got[want] {
+			t.Fatalf("got errors %v, want %s", got, want)
+		}
+	}
+}
+
+// TestLoadChainsNotSet verifies that multichain mode requires at least one chain.
+func TestLoadChainsNotSet(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+eth_client_url: http://a
+private_key: "`+testPrivateKey+`"
+`)
+
+	_, err := LoadChains()
+	if keys := fieldErrors(t, err); !keys["chains"] {
+		t.Fatalf("got errors %v, want chains", keys)
+	}
+}
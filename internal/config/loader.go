@@ -0,0 +1,380 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "aiops_bundler_"
+
+// FieldError is a config value that failed validation. Key is the name of the value in a config file. Path
+// is set if the value is in an entry of the chains list, such as chains[0].
+type FieldError struct {
+	Path string
+	Key  string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s.%s: %s", e.Path, e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %s", e.Key, strings.ToUpper(envPrefix+e.Key), e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError holds every FieldError found while loading the config so that all invalid values can be
+// fixed at once.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := []string{}
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return "invalid config:\n  " + strings.Join(msgs, "\n  ")
+}
+
+// readFile returns the top level values in a YAML or TOML config file. Keys are lowercased and prefixed to
+// match the env var names. Nested values are kept as is.
+func readFile(path string) (map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q, must be .yaml, .yml, or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	out := map[string]any{}
+	for k, v := range raw {
+		out[envPrefix+strings.TrimPrefix(strings.ToLower(k), envPrefix)] = v
+	}
+	return out, nil
+}
+
+// loader reads values in order of env vars, the .env file, the config file, and defaults. Invalid values
+// are collected as a FieldError instead of stopping at the first one.
+type loader struct {
+	file map[string]any
+	seen map[string]bool
+	errs []*FieldError
+
+	// deprecations are the messages for each deprecated value that is set.
+	deprecations []string
+
+	// chain holds the values of an entry in the chains list. They take precedence over all other sources.
+	chain map[string]any
+	path  string
+}
+
+func newLoader(file map[string]any) *loader {
+	return &loader{file: file, seen: make(map[string]bool)}
+}
+
+// newChainLoader returns a loader for an entry in the chains list. Values that are not set in the entry are
+// read from the parent.
+func newChainLoader(parent *loader, path string, chain map[string]any) *loader {
+	return &loader{file: parent.file, seen: parent.seen, chain: chain, path: path}
+}
+
+func (l *loader) fail(key string, format string, args ...any) {
+	l.errs = append(l.errs, &FieldError{
+		Path: l.path,
+		Key:  strings.TrimPrefix(key, envPrefix),
+		Err:  fmt.Errorf(format, args...),
+	})
+}
+
+// deprecate adds a message for a deprecated key that is set with the value that replaces it.
+func (l *loader) deprecate(key string, replacement string) {
+	name := strings.TrimPrefix(key, envPrefix)
+	l.deprecations = append(l.deprecations, fmt.Sprintf(
+		"%s (%s) is deprecated, use %s instead",
+		name,
+		strings.ToUpper(key),
+		replacement,
+	))
+}
+
+// failed returns true if a FieldError was already added for the key.
+func (l *loader) failed(key string) bool {
+	for _, e := range l.errs {
+		if e.Path == l.path && envPrefix+e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns a ValidationError if any value failed validation or if the config file has unknown keys.
+func (l *loader) err() error {
+	unknown := []string{}
+	for k := range l.file {
+		if !l.seen[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		l.fail(k, "unknown key")
+	}
+
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: l.errs}
+}
+
+func (l *loader) get(key string) any {
+	l.seen[key] = true
+	if v, ok := l.chain[key]; ok {
+		return v
+	}
+	_ = viper.BindEnv(key)
+	if _, ok := os.LookupEnv(strings.ToUpper(key)); ok || viper.InConfig(key) {
+		return viper.Get(key)
+	}
+	if v, ok := l.file[key]; ok {
+		return v
+	}
+	return viper.Get(key)
+}
+
+func (l *loader) isSet(key string) bool {
+	v := l.get(key)
+	if v == nil {
+		return false
+	}
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s) != ""
+	}
+	return true
+}
+
+func (l *loader) string(key string) string {
+	switch v := l.get(key).(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case int, int64, uint64, bool:
+		return fmt.Sprint(v)
+	default:
+		l.fail(key, "must be a string")
+		return ""
+	}
+}
+
+// toBigInt parses integers from strings in base 10 or with a 0x prefix. Floats are only accepted if they can
+// be represented exactly since YAML decodes large unquoted numbers as a float.
+func toBigInt(v any) (*big.Int, error) {
+	switch n := v.(type) {
+	case int:
+		return big.NewInt(int64(n)), nil
+	case int64:
+		return big.NewInt(n), nil
+	case uint64:
+		return big.NewInt(0).SetUint64(n), nil
+	case float64:
+		if n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+			return nil, fmt.Errorf("%v cannot be represented exactly, use a quoted string", n)
+		}
+		return big.NewInt(int64(n)), nil
+	case string:
+		s := strings.TrimSpace(n)
+		base := 10
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+			s, base = s[2:], 16
+		}
+		out, ok := big.NewInt(0).SetString(s, base)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a valid integer", n)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("must be an integer")
+	}
+}
+
+func (l *loader) bigInt(key string) *big.Int {
+	out, err := toBigInt(l.get(key))
+	if err != nil {
+		l.fail(key, "%s", err)
+		return big.NewInt(0)
+	}
+	return out
+}
+
+func (l *loader) uint(key string) *big.Int {
+	out := l.bigInt(key)
+	if out.Sign() < 0 {
+		l.fail(key, "must not be negative")
+		return big.NewInt(0)
+	}
+	return out
+}
+
+func (l *loader) int(key string) int {
+	out, err := toBigInt(l.get(key))
+	if err != nil {
+		l.fail(key, "%s", err)
+		return 0
+	}
+	if !out.IsInt64() || out.Int64() > math.MaxInt32 || out.Int64() < math.MinInt32 {
+		l.fail(key, "%s is out of range", out)
+		return 0
+	}
+	return int(out.Int64())
+}
+
+func (l *loader) uint64(key string) uint64 {
+	out := l.uint(key)
+	if !out.IsUint64() {
+		l.fail(key, "%s is out of range", out)
+		return 0
+	}
+	return out.Uint64()
+}
+
+func (l *loader) seconds(key string) time.Duration {
+	sec := l.uint(key)
+	if !sec.IsInt64() || sec.Int64() > math.MaxInt64/int64(time.Second) {
+		l.fail(key, "%s is out of range", sec)
+		return 0
+	}
+	return time.Duration(sec.Int64()) * time.Second
+}
+
+func (l *loader) bool(key string) bool {
+	switch v := l.get(key).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			l.fail(key, "%q is not a valid boolean", v)
+		}
+		return b
+	default:
+		l.fail(key, "must be a boolean")
+		return false
+	}
+}
+
+// stringSlice accepts a comma separated string or a list.
+func (l *loader) stringSlice(key string) []string {
+	out := []string{}
+	switch v := l.get(key).(type) {
+	case nil:
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	case []any:
+		for _, s := range v {
+			switch s.(type) {
+			case string, int, int64, uint64:
+				out = append(out, strings.TrimSpace(fmt.Sprint(s)))
+			default:
+				l.fail(key, "must be a list of strings")
+				return []string{}
+			}
+		}
+	default:
+		l.fail(key, "must be a list or a comma separated string")
+	}
+	return out
+}
+
+func (l *loader) addressSlice(key string) []common.Address {
+	out := []common.Address{}
+	for _, s := range l.stringSlice(key) {
+		if !common.IsHexAddress(s) {
+			l.fail(key, "%q is not a valid address", s)
+			continue
+		}
+		out = append(out, common.HexToAddress(s))
+	}
+	return out
+}
+
+// rawMap accepts a string in the form key1=value1&key2=value2 or a map. Values in a map keep their type.
+func (l *loader) rawMap(key string) map[string]any {
+	out := map[string]any{}
+	switch v := l.get(key).(type) {
+	case nil:
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return out
+		}
+		for _, pair := range strings.Split(v, "&") {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok || k == "" {
+				l.fail(key, "%q must be in the form key=value", pair)
+				continue
+			}
+			out[k] = val
+		}
+	case map[string]any:
+		for k, val := range v {
+			out[k] = val
+		}
+	default:
+		l.fail(key, "must be a map or in the form key1=value1&key2=value2")
+	}
+	return out
+}
+
+// keyValMap accepts a string in the form key1=value1&key2=value2 or a map.
+func (l *loader) keyValMap(key string) map[string]string {
+	out := map[string]string{}
+	for k, v := range l.rawMap(key) {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
+
+func (l *loader) durationMap(key string) map[string]time.Duration {
+	out := map[string]time.Duration{}
+	for k, v := range l.keyValMap(key) {
+		sec, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+		if err != nil {
+			l.fail(key, "%q is not a valid number of seconds for %s", v, k)
+			continue
+		}
+		out[k] = time.Duration(sec) * time.Second
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testPrivateKey = "0000000000000000000000000000000000000000000000000000000000000001"
+
+func writeConfigFile(t *testing.T, name string, content string) {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("config_file", path)
+}
+
+func fieldErrors(t *testing.T, err error) map[string]bool {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got err %v, want ValidationError", err)
+	}
+	keys := map[string]bool{}
+	for _, f := range verr.Fields {
+		keys[f.Key] = true
+	}
+	return keys
+}
+
+// TestLoadFileWithEnvOverride verifies that values are read from a config file and that env vars take
+// precedence.
+func TestLoadFileWithEnvOverride(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+eth_client_url: [http://a, http://b]
+private_key: "`+testPrivateKey+`"
+port: 5000
+max_batch_gas_limit: 12000000
+min_stake_value: "100000000000000000000000000"
+rpc_method_timeouts:
+  eth_estimateAiOperationGas: 60
+private_batch_modules:
+  - relay.send_aiop
+  - name: batch.maintain_gas_limit
+    config:
+      max_batch_gas_limit: 100
+`)
+	t.Setenv("AIOPS_BUNDLER_PORT", "6000")
+
+	conf, err := Load()
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	if conf.Port != 6000 {
+		t.Fatalf("got port %d, want 6000", conf.Port)
+	}
+	if len(conf.EthClientUrls) != 2 || conf.EthClientUrl != "http://a" {
+		t.Fatalf("got eth client urls %v, want [http://a http://b]", conf.EthClientUrls)
+	}
+	if conf.MaxBatchGasLimit.Cmp(big.NewInt(12000000)) != 0 {
+		t.Fatalf("got max batch gas limit %s, want 12000000", conf.MaxBatchGasLimit)
+	}
+	want, _ := big.NewInt(0).SetString("100000000000000000000000000", 10)
+	if conf.ReputationConstants.MinStakeValue.Cmp(want) != 0 {
+		t.Fatalf("got min stake value %s, want %s", conf.ReputationConstants.MinStakeValue, want)
+	}
+	if _, ok := conf.RPCTimeouts.Methods["eth_estimateAiOperationGas"]; !ok {
+		t.Fatal("method timeout keys must keep their case")
+	}
+	if b := conf.Pipelines["private"].Batch; len(b) != 2 || b[1].Config["max_batch_gas_limit"] != 100 {
+		t.Fatalf("got private batch pipeline %+v", b)
+	}
+}
+
+// TestLoadReportsEveryFieldError verifies that invalid values are returned as field errors instead of being
+// silently replaced with a zero value.
+func TestLoadReportsEveryFieldError(t *testing.T) {
+	writeConfigFile(t, "config.toml", `
+eth_client_url = "http://a"
+private_key = "`+testPrivateKey+`"
+port = "abc"
+max_verification_gas = "1e6"
+otel_collector_headers = "a=1&bad"
+supported_ai_middleware = ["0xnot"]
+prometheus_enabled = "maybe"
+typo_key = 1
+`)
+
+	_, err := Load()
+	keys := fieldErrors(t, err)
+	for _, want := range []string{
+		"port",
+		"max_verification_gas",
+		"otel_collector_headers",
+		"supported_ai_middleware",
+		"prometheus_enabled",
+		"typo_key",
+	} {
+		if !keys[want] {
+			t.Fatalf("got errors %v, want error for %s", err, want)
+		}
+	}
+}
+
+// TestLoadRequiredValues verifies that missing required values are reported without a panic.
+func TestLoadRequiredValues(t *testing.T) {
+	writeConfigFile(t, "config.yml", "signer_type: remote\n")
+
+	_, err := Load()
+	keys := fieldErrors(t, err)
+	for _, want := range []string{"eth_client_url", "remote_signer_url", "remote_signer_addresses"} {
+		if !keys[want] {
+			t.Fatalf("got errors %v, want error for %s", err, want)
+		}
+	}
+}
+
+func TestToBigInt(t *testing.T) {
+	huge, _ := big.NewInt(0).SetString("100000000000000000000", 10)
+	tests := []struct {
+		in   any
+		want *big.Int
+	}{
+		{"100000000000000000000", huge},
+		{"0x10", big.NewInt(16)},
+		{int64(7), big.NewInt(7)},
+		{float64(18000000), big.NewInt(18000000)},
+		{float64(1e20), nil},
+		{"1.5", nil},
+		{"", nil},
+		{true, nil},
+	}
+	for _, tc := range tests {
+		got, err := toBigInt(tc.in)
+		if tc.want == nil {
+			if err == nil {
+				t.Fatalf("%v: got %s, want err", tc.in, got)
+			}
+		} else if err != nil || got.Cmp(tc.want) != 0 {
+			t.Fatalf("%v: got %s, %v, want %s", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+// TestLoadReplacementPolicy verifies that replacements are disabled by default and that a bump below the node's
+// minimum price bump is rejected.
+func TestLoadReplacementPolicy(t *testing.T) {
+	writeConfigFile(t, "config.yml", "eth_client_url: http://a\nprivate_key: \""+testPrivateKey+"\"\n")
+
+	conf, err := Load()
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if conf.ReplacementPolicy.Interval != 0 {
+		t.Fatalf("got interval %s, want 0", conf.ReplacementPolicy.Interval)
+	}
+
+	viper.Set("aiops_bundler_replacement_bump_percent", 5)
+	_, err = Load()
+	if keys := fieldErrors(t, err); !keys["replacement_bump_percent"] {
+		t.Fatalf("got errors %v, want error for replacement_bump_percent", err)
+	}
+}
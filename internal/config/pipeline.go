@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Pipeline is the ordered list of modules for each stage of a bundler mode. The settled stage runs once
+// AiOperations leave the mempool, either because their bundle was included on-chain or they were dropped.
+type Pipeline struct {
+	Client       []modules.ModuleSpec
+	Batch        []modules.ModuleSpec
+	Revalidation []modules.ModuleSpec
+	Settled      []modules.ModuleSpec
+}
+
+func specs(names ...string) []modules.ModuleSpec {
+	out := []modules.ModuleSpec{}
+	for _, name := range names {
+		out = append(out, modules.ModuleSpec{Name: name})
+	}
+	return out
+}
+
+func defaultPipeline(sendModule string) *Pipeline {
+	return &Pipeline{
+		Client: specs(
+			"reputation.check_status",
+			"reputation.validate_op_limit",
+			"checks.validate_op_values",
+			"checks.simulate_op",
+			"reputation.check_aggregator",
+			"reputation.inc_ops_seen",
+		),
+		Batch: specs(
+			"expire.drop_expired",
+			"gasprice.sort_by_gas_price",
+			"gasprice.filter_underpriced",
+			"batch.sort_by_nonce",
+			"batch.maintain_gas_limit",
+			"checks.code_hashes",
+			"checks.paymaster_deposit",
+			"checks.group_by_aggregator",
+			"checks.simulate_batch",
+			sendModule,
+		),
+		Revalidation: specs(
+			"checks.simulate_op",
+			"reputation.check_aggregator",
+		),
+		Settled: specs(
+			"reputation.inc_ops_included",
+			"checks.clean",
+		),
+	}
+}
+
+// DefaultPipelines returns the pipeline used by each mode if it is not set in the config. Shadow mode uses
+// the private pipeline.
+func DefaultPipelines() map[string]*Pipeline {
+	return map[string]*Pipeline{
+		"private":  defaultPipeline("relay.send_aiop"),
+		"searcher": defaultPipeline("builder.send_aiop"),
+	}
+}
+
+// parseModuleSpec parses a module name that can be followed by its config in the form
+// name?key1=value1&key2=value2.
+func parseModuleSpec(s string) (modules.ModuleSpec, error) {
+	name, query, _ := strings.Cut(strings.TrimSpace(s), "?")
+	spec := modules.ModuleSpec{Name: strings.TrimSpace(name), Config: map[string]any{}}
+	if spec.Name == "" {
+		return spec, fmt.Errorf("%q has no module name", s)
+	}
+	if query != "" {
+		for _, pair := range strings.Split(query, "&") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok || k == "" {
+				return spec, fmt.Errorf("%q must be in the form key=value for module %s", pair, spec.Name)
+			}
+			spec.Config[k] = v
+		}
+	}
+	return spec, nil
+}
+
+// modules accepts a comma separated string of modules or a list. Each item in a list is either a string or
+// a map with a name and config.
+func (l *loader) modules(key string, defaults []modules.ModuleSpec) []modules.ModuleSpec {
+	if !l.isSet(key) {
+		return defaults
+	}
+
+	items := []any{}
+	switch v := l.get(key).(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if strings.TrimSpace(item) != "" {
+				items = append(items, item)
+			}
+		}
+	case []any:
+		items = v
+	default:
+		l.fail(key, "must be a list or a comma separated string")
+		return defaults
+	}
+
+	out := []modules.ModuleSpec{}
+	for i, item := range items {
+		switch v := item.(type) {
+		case string:
+			spec, err := parseModuleSpec(v)
+			if err != nil {
+				l.fail(key, "item %d: %s", i, err)
+				continue
+			}
+			out = append(out, spec)
+		case map[string]any:
+			var spec modules.ModuleSpec
+			dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: &spec})
+			if err == nil {
+				err = dec.Decode(v)
+			}
+			if err != nil {
+				l.fail(key, "item %d: %s", i, err)
+				continue
+			} else if spec.Name == "" {
+				l.fail(key, "item %d: has no module name", i)
+				continue
+			}
+			out = append(out, spec)
+		default:
+			l.fail(key, "item %d: must be a string or a map with a name and config", i)
+		}
+	}
+	return out
+}
+
+// pipeline returns the pipeline for a mode with any stage that is set replacing the default.
+func (l *loader) pipeline(mode string, defaults *Pipeline) *Pipeline {
+	return &Pipeline{
+		Client:       l.modules(fmt.Sprintf("aiops_bundler_%s_client_modules", mode), defaults.Client),
+		Batch:        l.modules(fmt.Sprintf("aiops_bundler_%s_batch_modules", mode), defaults.Batch),
+		Revalidation: l.modules(fmt.Sprintf("aiops_bundler_%s_revalidation_modules", mode), defaults.Revalidation),
+		Settled:      l.modules(fmt.Sprintf("aiops_bundler_%s_settled_modules", mode), defaults.Settled),
+	}
+}
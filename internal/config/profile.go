@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/chains"
+)
+
+const chainProfileKey = envPrefix + "chain_profile"
+
+func profileBool(v any) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		out, err := strconv.ParseBool(strings.TrimSpace(b))
+		if err != nil {
+			return false, fmt.Errorf("%q is not a valid boolean", b)
+		}
+		return out, nil
+	default:
+		return false, fmt.Errorf("must be a boolean")
+	}
+}
+
+func profileDuration(v any) (time.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("must be a duration such as 2s or 250ms")
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a valid duration", s)
+	}
+	return d, nil
+}
+
+func profileUint(v any, max uint64) (uint64, error) {
+	n, err := toBigInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if n.Sign() < 0 || !n.IsUint64() || n.Uint64() > max {
+		return 0, fmt.Errorf("%s is out of range", n)
+	}
+	return n.Uint64(), nil
+}
+
+// setProfileField parses a single chain_profile value into the overrides.
+func setProfileField(o *chains.Overrides, field string, v any) error {
+	switch field {
+	case "rollup":
+		r, err := chains.ParseRollup(strings.TrimSpace(fmt.Sprint(v)))
+		if err != nil {
+			return err
+		}
+		o.Rollup = &r
+	case "l1_fee_model":
+		m, err := chains.ParseL1FeeModel(strings.TrimSpace(fmt.Sprint(v)))
+		if err != nil {
+			return err
+		}
+		o.L1FeeModel = &m
+	case "pvg_buffer_factor":
+		n, err := profileUint(v, math.MaxInt32)
+		if err != nil {
+			return err
+		}
+		f := int64(n)
+		o.PVGBufferFactor = &f
+	case "legacy_fees":
+		b, err := profileBool(v)
+		if err != nil {
+			return err
+		}
+		o.LegacyFees = &b
+	case "min_priority_fee":
+		n, err := toBigInt(v)
+		if err != nil {
+			return err
+		} else if n.Sign() < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		o.MinPriorityFee = n
+	case "block_time":
+		d, err := profileDuration(v)
+		if err != nil {
+			return err
+		}
+		o.BlockTime = &d
+	case "max_block_age":
+		d, err := profileDuration(v)
+		if err != nil {
+			return err
+		}
+		o.MaxBlockAge = &d
+	case "min_poll_interval":
+		d, err := profileDuration(v)
+		if err != nil {
+			return err
+		}
+		o.MinPollInterval = &d
+	case "max_log_range":
+		n, err := profileUint(v, math.MaxUint32)
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("must be greater than 0")
+		}
+		o.MaxLogRange = &n
+	case "builder_compatible":
+		b, err := profileBool(v)
+		if err != nil {
+			return err
+		}
+		o.BuilderCompatible = &b
+	default:
+		return fmt.Errorf("unknown field")
+	}
+	return nil
+}
+
+// chainProfile returns the overrides for the built-in profile of the chain. The deprecated
+// is_op_stack_network and is_arb_stack_network values set the rollup if it is not in chain_profile and are
+// reported in Deprecations.
+func (l *loader) chainProfile() *chains.Overrides {
+	o := &chains.Overrides{}
+	raw := l.rawMap(chainProfileKey)
+	fields := []string{}
+	for k := range raw {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		if err := setProfileField(o, f, raw[f]); err != nil {
+			l.fail(chainProfileKey, "%s: %s", f, err)
+		}
+	}
+
+	isOpStack := l.bool("aiops_bundler_is_op_stack_network")
+	isArbStack := l.bool("aiops_bundler_is_arb_stack_network")
+	if isOpStack {
+		l.deprecate("aiops_bundler_is_op_stack_network", "chain_profile rollup=op_stack")
+	}
+	if isArbStack {
+		l.deprecate("aiops_bundler_is_arb_stack_network", "chain_profile rollup=arb_stack")
+	}
+	if o.Rollup == nil {
+		if isOpStack {
+			r := chains.OpStack
+			o.Rollup = &r
+		} else if isArbStack {
+			r := chains.ArbStack
+			o.Rollup = &r
+		}
+	}
+	return o
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/chains"
+)
+
+// TestLoadChainProfile verifies that chain_profile is read from a map in the config file.
+func TestLoadChainProfile(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+eth_client_url: http://a
+private_key: "`+testPrivateKey+`"
+chain_profile:
+  rollup: arb_stack
+  legacy_fees: true
+  min_priority_fee: 1000000
+  block_time: 500ms
+  min_poll_interval: 250ms
+  max_log_range: 5000
+`)
+
+	conf, err := Load()
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	p := chains.Lookup(big.NewInt(999999)).Apply(conf.ChainProfile)
+	if p.Rollup != chains.ArbStack || p.L1FeeModel != chains.ArbL1Fee || !p.LegacyFees {
+		t.Fatalf("got profile %+v", p)
+	}
+	if p.MinPriorityFee.Cmp(big.NewInt(1000000)) != 0 || p.BlockTime != 500*time.Millisecond {
+		t.Fatalf("got profile %+v", p)
+	}
+	if p.MaxLogRange != 5000 || p.MinPollInterval != 250*time.Millisecond {
+		t.Fatalf("got max log range %d and min poll interval %s, want 5000 and 250ms", p.MaxLogRange, p.MinPollInterval)
+	}
+}
+
+// TestLoadChainProfileFromEnv verifies the env var form and that the deprecated rollup flags are only used if
+// the rollup is not set in chain_profile.
+func TestLoadChainProfileFromEnv(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+eth_client_url: http://a
+private_key: "`+testPrivateKey+`"
+is_op_stack_network: true
+`)
+	t.Setenv("AIOPS_BUNDLER_CHAIN_PROFILE", "pvg_buffer_factor=5&builder_compatible=true")
+
+	conf, err := Load()
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	p := chains.Lookup(nil).Apply(conf.ChainProfile)
+	if p.Rollup != chains.OpStack || p.PVGBufferFactor != 5 || !p.BuilderCompatible {
+		t.Fatalf("got profile %+v", p)
+	}
+	if len(conf.Deprecations) != 1 || !strings.HasPrefix(conf.Deprecations[0], "is_op_stack_network") {
+		t.Fatalf("got deprecations %v, want is_op_stack_network", conf.Deprecations)
+	}
+}
+
+// TestLoadChainProfileErrors verifies that each invalid field of chain_profile is reported.
+func TestLoadChainProfileErrors(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+eth_client_url: http://a
+private_key: "`+testPrivateKey+`"
+chain_profile:
+  rollup: zk
+  block_time: 2
+  max_log_range: 0
+  unknown: 1
+`)
+
+	_, err := Load()
+	if keys := fieldErrors(t, err); !keys["chain_profile"] {
+		t.Fatalf("got errors %v, want chain_profile", keys)
+	}
+	for _, want := range []string{"rollup", "block_time", "max_log_range", "unknown"} {
+		if !strings.Contains(err.Error(), "chain_profile (AIOPS_BUNDLER_CHAIN_PROFILE): "+want+":") {
+			t.Fatalf("got err %v, want error for %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+)
+
+// SignerType determines where the bundler's EOA keys are held.
+type SignerType string
+
+const (
+	PrivateKeySigner SignerType = "private_key"
+	KeystoreSigner   SignerType = "keystore"
+	RemoteSigner     SignerType = "remote"
+)
+
+// NewSigners returns a Signer for each bundler EOA based on the configured SignerType. The first Signer is
+// the primary EOA. A remote Signer holds a connection that the caller must close once it is no longer used.
+func NewSigners(conf *Values) ([]signer.Signer, error) {
+	signers := []signer.Signer{}
+	switch conf.SignerType {
+	case PrivateKeySigner:
+		for _, pk := range conf.PrivateKeys {
+			s, err := signer.New(pk)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, s)
+		}
+	case KeystoreSigner:
+		for _, f := range conf.KeystoreFiles {
+			s, err := signer.NewFromKeystore(f, conf.KeystorePassphraseFile)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+			signers = append(signers, s)
+		}
+	case RemoteSigner:
+		for _, addr := range conf.RemoteSignerAddresses {
+			s, err := signer.NewRemote(conf.RemoteSignerUrl, addr)
+			if err != nil {
+				for _, prev := range signers {
+					prev.(*signer.Remote).Close()
+				}
+				return nil, err
+			}
+			signers = append(signers, s)
+		}
+	default:
+		return nil, fmt.Errorf("config: unsupported signer type %s", conf.SignerType)
+	}
+
+	return signers, nil
+}
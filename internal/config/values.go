@@ -1,13 +1,18 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
-	"strings"
 	"time"
 
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/transaction"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/chains"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/jsonrpc"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/entities"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/pool"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -16,7 +21,15 @@ import (
 type Values struct {
 	// Documented variables.
 	PrivateKey                   string
+	PrivateKeys                  []string
 	EthClientUrl                 string
+	EthClientUrls                []string
+	EthClientTraceUrls           []string
+	EthClientSubmitUrls          []string
+	EthClientMaxBlockLag         uint64
+	EthClientChainID             *big.Int
+	MaxBlockAge                  time.Duration
+	SkipNodeProbe                bool
 	Port                         int
 	DataDirectory                string
 	SupportedAiMiddlewares       []common.Address
@@ -28,6 +41,26 @@ type Values struct {
 	NativeBundlerCollectorTracer string
 	NativeBundlerExecutorTracer  string
 	ReputationConstants          *entities.ReputationConstants
+	ReplacementPolicy            *transaction.ReplacementPolicy
+	SignerStrategy               pool.Strategy
+	SignerBalanceFloor           *big.Int
+	SignerType                   SignerType
+	BalanceWarningThreshold      *big.Int
+	BalanceCriticalThreshold     *big.Int
+	BalanceTopUp                 bool
+	CircuitBreakerThreshold      int
+	CircuitBreakerBaseBackoff    time.Duration
+	CircuitBreakerMaxBackoff     time.Duration
+	ShutdownTimeout              time.Duration
+	RPCTimeouts                  *jsonrpc.Timeouts
+	BlockCacheTrackEvents        bool
+	KeystoreFiles                []string
+	KeystorePassphraseFile       string
+	RemoteSignerUrl              string
+	RemoteSignerAddresses        []common.Address
+
+	// Module pipeline variables.
+	Pipelines map[string]*Pipeline
 
 	// Searcher mode variables.
 	EthBuilderUrls    []string
@@ -38,57 +71,27 @@ type Values struct {
 	OTELCollectorHeaders map[string]string
 	OTELCollectorUrl     string
 	OTELInsecureMode     bool
+	PrometheusEnabled    bool
+	PrometheusPort       int
 
 	// Alternative mempool variables.
 	AltMempoolIPFSGateway string
 	AltMempoolIds         []string
 
 	// Rollup related variables.
-	IsOpStackNetwork   bool
+	ChainProfile       *chains.Overrides
 	IsRIP7212Supported bool
-	IsArbStackNetwork  bool
+
+	// Deprecations describe each deprecated value that is set and what replaces it. They are logged on
+	// startup.
+	Deprecations []string
 
 	// Undocumented variables.
 	DebugMode bool
 	GinMode   string
 }
 
-func envKeyValStringToMap(s string) map[string]string {
-	out := map[string]string{}
-	for _, pair := range strings.Split(s, "&") {
-		kv := strings.Split(pair, "=")
-		if len(kv) != 2 {
-			break
-		}
-		out[kv[0]] = kv[1]
-	}
-	return out
-}
-
-func envArrayToAddressSlice(s string) []common.Address {
-	env := strings.Split(s, ",")
-	slc := []common.Address{}
-	for _, ep := range env {
-		slc = append(slc, common.HexToAddress(strings.TrimSpace(ep)))
-	}
-
-	return slc
-}
-
-func envArrayToStringSlice(s string) []string {
-	if s == "" {
-		return []string{}
-	}
-	return strings.Split(s, ",")
-}
-
-func variableNotSetOrIsNil(env string) bool {
-	return !viper.IsSet(env) || viper.GetString(env) == ""
-}
-
-// GetValues returns config for the bundler that has been read in from env vars. See
-func GetValues() *Values {
-	// Default variables
+func setDefaults() {
 	viper.SetDefault("aiops_bundler_port", 4337)
 	viper.SetDefault("aiops_bundler_data_directory", "/tmp/aiops_bundler")
 	viper.SetDefault("aiops_bundler_supported_ai_middleware", "0xB05F71ca5E12e14eC73eCdeB98f335843cb47b2f")
@@ -96,142 +99,266 @@ func GetValues() *Values {
 	viper.SetDefault("aiops_bundler_max_batch_gas_limit", 18000000)
 	viper.SetDefault("aiops_bundler_max_op_ttl_seconds", 180)
 	viper.SetDefault("aiops_bundler_op_lookup_limit", 2000)
+	viper.SetDefault("aiops_bundler_replacement_interval_seconds", 0)
+	viper.SetDefault("aiops_bundler_replacement_bump_percent", transaction.DefaultBumpPercent)
+	viper.SetDefault("aiops_bundler_replacement_fee_ceiling_percent", transaction.DefaultFeeCeilingPercent)
+	viper.SetDefault("aiops_bundler_signer_strategy", string(pool.RoundRobin))
+	viper.SetDefault("aiops_bundler_signer_balance_floor", "0")
+	viper.SetDefault("aiops_bundler_signer_type", string(PrivateKeySigner))
+	viper.SetDefault("aiops_bundler_balance_warning_threshold", "0")
+	viper.SetDefault("aiops_bundler_balance_critical_threshold", "0")
+	viper.SetDefault("aiops_bundler_balance_top_up", false)
+	viper.SetDefault("aiops_bundler_circuit_breaker_threshold", bundler.DefaultBreakerThreshold)
+	viper.SetDefault("aiops_bundler_circuit_breaker_base_backoff_seconds", 5)
+	viper.SetDefault("aiops_bundler_circuit_breaker_max_backoff_seconds", 300)
+	viper.SetDefault("aiops_bundler_shutdown_timeout_seconds", 30)
+	viper.SetDefault("aiops_bundler_rpc_timeout_seconds", 30)
+	viper.SetDefault("aiops_bundler_block_cache_track_events", false)
+	viper.SetDefault("aiops_bundler_eth_client_max_block_lag", 5)
+	viper.SetDefault("aiops_bundler_max_block_age_seconds", 0)
+	viper.SetDefault("aiops_bundler_skip_node_probe", false)
 	viper.SetDefault("aiops_bundler_blocks_in_the_future", 6)
 	viper.SetDefault("aiops_bundler_otel_insecure_mode", false)
+	viper.SetDefault("aiops_bundler_prometheus_enabled", false)
+	viper.SetDefault("aiops_bundler_prometheus_port", 0)
 	viper.SetDefault("aiops_bundler_is_op_stack_network", false)
 	viper.SetDefault("aiops_bundler_is_arb_stack_network", false)
 	viper.SetDefault("aiops_bundler_is_rip7212_supported", false)
 	viper.SetDefault("aiops_bundler_debug_mode", false)
 	viper.SetDefault("aiops_bundler_gin_mode", gin.ReleaseMode)
+}
+
+// Load returns config for the bundler that has been read in from env vars, a .env file in the working
+// directory, and a YAML or TOML config file set with the --config flag or AIOPS_BUNDLER_CONFIG_FILE. Env
+// vars override values in the config file. If any value is invalid, a ValidationError is returned with
+// every invalid field. See docs/index.md for the full list of values.
+func Load() (*Values, error) {
+	file, err := readSources()
+	if err != nil {
+		return nil, err
+	}
+
+	// The chains list is only used in multichain mode.
+	l := newLoader(file)
+	l.seen[chainsKey] = true
+	values := l.values()
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// readSources reads in the .env file and returns the values in the config file if one is set.
+func readSources() (map[string]any, error) {
+	setDefaults()
 
 	// Read in from .env file if available
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 	viper.AddConfigPath(".")
 	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// Config file not found
-			// Can ignore
-		} else {
-			panic(fmt.Errorf("fatal error config file: %w", err))
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("config file .env: %w", err)
 		}
 	}
 
-	// Read in from environment variables
-	_ = viper.BindEnv("aiops_bundler_eth_client_url")
-	_ = viper.BindEnv("aiops_bundler_private_key")
-	_ = viper.BindEnv("aiops_bundler_port")
-	_ = viper.BindEnv("aiops_bundler_data_directory")
-	_ = viper.BindEnv("aiops_bundler_supported_ai_middleware")
-	_ = viper.BindEnv("aiops_bundler_beneficiary")
-	_ = viper.BindEnv("aiops_bundler_native_bundler_collector_tracer")
-	_ = viper.BindEnv("aiops_bundler_native_bundler_executor_tracer")
-	_ = viper.BindEnv("aiops_bundler_max_verification_gas")
-	_ = viper.BindEnv("aiops_bundler_max_batch_gas_limit")
-	_ = viper.BindEnv("aiops_bundler_max_op_ttl_seconds")
-	_ = viper.BindEnv("aiops_bundler_op_lookup_limit")
-	_ = viper.BindEnv("aiops_bundler_eth_builder_urls")
-	_ = viper.BindEnv("aiops_bundler_blocks_in_the_future")
-	_ = viper.BindEnv("aiops_bundler_otel_service_name")
-	_ = viper.BindEnv("aiops_bundler_otel_collector_headers")
-	_ = viper.BindEnv("aiops_bundler_otel_collector_url")
-	_ = viper.BindEnv("aiops_bundler_otel_insecure_mode")
-	_ = viper.BindEnv("aiops_bundler_alt_mempool_ipfs_gateway")
-	_ = viper.BindEnv("aiops_bundler_alt_mempool_ids")
-	_ = viper.BindEnv("aiops_bundler_is_op_stack_network")
-	_ = viper.BindEnv("aiops_bundler_is_arb_stack_network")
-	_ = viper.BindEnv("aiops_bundler_is_rip7212_supported")
-	_ = viper.BindEnv("aiops_bundler_debug_mode")
-	_ = viper.BindEnv("aiops_bundler_gin_mode")
+	// Read in from a config file if set
+	_ = viper.BindEnv("config_file", "AIOPS_BUNDLER_CONFIG_FILE")
+	if path := viper.GetString("config_file"); path != "" {
+		return readFile(path)
+	}
+	return nil, nil
+}
 
+// values reads every config value. Invalid values are added to the loader's errors.
+func (l *loader) values() *Values {
 	// Validate required variables
-	if variableNotSetOrIsNil("aiops_bundler_eth_client_url") {
-		panic("Fatal config error: aiops_bundler_eth_client_url not set")
+	if !l.isSet("aiops_bundler_eth_client_url") {
+		l.fail("aiops_bundler_eth_client_url", "not set")
 	}
 
-	if variableNotSetOrIsNil("aiops_bundler_private_key") {
-		panic("Fatal config error: aiops_bundler_private_key not set")
-	}
+	// Validate signer variables
+	privateKeys := l.stringSlice("aiops_bundler_private_key")
+	keystoreFiles := l.stringSlice("aiops_bundler_keystore_files")
+	keystorePassphraseFile := l.string("aiops_bundler_keystore_passphrase_file")
+	remoteSignerUrl := l.string("aiops_bundler_remote_signer_url")
+	remoteSignerAddresses := l.addressSlice("aiops_bundler_remote_signer_addresses")
+	beneficiary := l.string("aiops_bundler_beneficiary")
+	signerType := SignerType(l.string("aiops_bundler_signer_type"))
+	switch signerType {
+	case PrivateKeySigner:
+		if len(privateKeys) == 0 {
+			l.fail("aiops_bundler_private_key", "not set")
+		} else if beneficiary == "" {
+			s, err := signer.New(privateKeys[0])
+			if err != nil {
+				l.fail("aiops_bundler_private_key", "%s", err)
+			} else {
+				beneficiary = s.Address().String()
+			}
+		}
+	case KeystoreSigner:
+		if len(keystoreFiles) == 0 {
+			l.fail("aiops_bundler_keystore_files", "not set")
+		}
+
+		if keystorePassphraseFile == "" {
+			l.fail("aiops_bundler_keystore_passphrase_file", "not set")
+		}
+	case RemoteSigner:
+		if remoteSignerUrl == "" {
+			l.fail("aiops_bundler_remote_signer_url", "not set")
+		}
 
-	if !viper.IsSet("aiops_bundler_beneficiary") {
-		s, err := signer.New(viper.GetString("aiops_bundler_private_key"))
-		if err != nil {
-			panic(err)
+		if len(remoteSignerAddresses) == 0 {
+			l.fail("aiops_bundler_remote_signer_addresses", "not set")
+		} else if beneficiary == "" {
+			beneficiary = remoteSignerAddresses[0].String()
 		}
-		viper.SetDefault("aiops_bundler_beneficiary", s.Address.String())
+	default:
+		l.fail("aiops_bundler_signer_type", "%q not supported", signerType)
+	}
+	if beneficiary != "" && !common.IsHexAddress(beneficiary) {
+		l.fail("aiops_bundler_beneficiary", "%q is not a valid address", beneficiary)
+	}
+
+	signerStrategy := pool.Strategy(l.string("aiops_bundler_signer_strategy"))
+	if signerStrategy != pool.RoundRobin && signerStrategy != pool.LeastPending {
+		l.fail("aiops_bundler_signer_strategy", "%q not supported", signerStrategy)
 	}
 
+	ethBuilderUrls := l.stringSlice("aiops_bundler_eth_builder_urls")
 	switch viper.GetString("mode") {
 	case "searcher":
-		if variableNotSetOrIsNil("aiops_bundler_eth_builder_urls") {
-			panic("Fatal config error: aiops_bundler_eth_builder_urls not set")
+		if len(ethBuilderUrls) == 0 {
+			l.fail("aiops_bundler_eth_builder_urls", "not set")
 		}
 	}
 
 	// Validate O11Y variables
-	if viper.IsSet("aiops_bundler_otel_service_name") &&
-		variableNotSetOrIsNil("aiops_bundler_otel_collector_url") {
-		panic("Fatal config error: aiops_bundler_otel_service_name is set without a collector URL")
+	otelServiceName := l.string("aiops_bundler_otel_service_name")
+	otelCollectorUrl := l.string("aiops_bundler_otel_collector_url")
+	if otelServiceName != "" && otelCollectorUrl == "" {
+		l.fail("aiops_bundler_otel_service_name", "is set without a collector URL")
 	}
 
 	// Validate Alternative mempool variables
-	if viper.IsSet("aiops_bundler_alt_mempool_ids") &&
-		variableNotSetOrIsNil("aiops_bundler_alt_mempool_ipfs_gateway") {
-		panic("Fatal config error: aiops_bundler_alt_mempool_ids is set without specifying an IPFS gateway")
+	altMempoolIPFSGateway := l.string("aiops_bundler_alt_mempool_ipfs_gateway")
+	altMempoolIds := l.stringSlice("aiops_bundler_alt_mempool_ids")
+	if len(altMempoolIds) > 0 && altMempoolIPFSGateway == "" {
+		l.fail("aiops_bundler_alt_mempool_ids", "is set without specifying an IPFS gateway")
+	}
+
+	signerBalanceFloor := l.uint("aiops_bundler_signer_balance_floor")
+	balanceWarningThreshold := l.uint("aiops_bundler_balance_warning_threshold")
+	balanceCriticalThreshold := l.uint("aiops_bundler_balance_critical_threshold")
+	if balanceCriticalThreshold.Cmp(balanceWarningThreshold) > 0 {
+		l.fail("aiops_bundler_balance_critical_threshold", "is greater than the warning threshold")
+	}
+
+	port := l.int("aiops_bundler_port")
+	if !l.failed("aiops_bundler_port") && (port <= 0 || port > 65535) {
+		l.fail("aiops_bundler_port", "%d is not a valid port", port)
+	}
+	prometheusPort := l.int("aiops_bundler_prometheus_port")
+	if !l.failed("aiops_bundler_prometheus_port") && (prometheusPort < 0 || prometheusPort > 65535) {
+		l.fail("aiops_bundler_prometheus_port", "%d is not a valid port", prometheusPort)
+	}
+
+	supportedAiMiddlewares := l.addressSlice("aiops_bundler_supported_ai_middleware")
+	if !l.failed("aiops_bundler_supported_ai_middleware") && len(supportedAiMiddlewares) == 0 {
+		l.fail("aiops_bundler_supported_ai_middleware", "not set")
+	}
+
+	var ethClientChainID *big.Int
+	if l.isSet("aiops_bundler_eth_client_chain_id") {
+		ethClientChainID = l.uint("aiops_bundler_eth_client_chain_id")
 	}
 
 	// Return Values
-	privateKey := viper.GetString("aiops_bundler_private_key")
-	ethClientUrl := viper.GetString("aiops_bundler_eth_client_url")
-	port := viper.GetInt("aiops_bundler_port")
-	dataDirectory := viper.GetString("aiops_bundler_data_directory")
-	supportedAiMiddlewares := envArrayToAddressSlice(viper.GetString("aiops_bundler_supported_ai_middleware"))
-	beneficiary := viper.GetString("aiops_bundler_beneficiary")
-	nativeBundlerCollectorTracer := viper.GetString("aiops_bundler_native_bundler_collector_tracer")
-	nativeBundlerExecutorTracer := viper.GetString("aiops_bundler_native_bundler_executor_tracer")
-	maxVerificationGas := big.NewInt(int64(viper.GetInt("aiops_bundler_max_verification_gas")))
-	maxBatchGasLimit := big.NewInt(int64(viper.GetInt("aiops_bundler_max_batch_gas_limit")))
-	maxOpTTL := time.Second * viper.GetDuration("aiops_bundler_max_op_ttl_seconds")
-	opLookupLimit := viper.GetUint64("aiops_bundler_op_lookup_limit")
-	ethBuilderUrls := envArrayToStringSlice(viper.GetString("aiops_bundler_eth_builder_urls"))
-	blocksInTheFuture := viper.GetInt("aiops_bundler_blocks_in_the_future")
-	otelServiceName := viper.GetString("aiops_bundler_otel_service_name")
-	otelCollectorHeader := envKeyValStringToMap(viper.GetString("aiops_bundler_otel_collector_headers"))
-	otelCollectorUrl := viper.GetString("aiops_bundler_otel_collector_url")
-	otelInsecureMode := viper.GetBool("aiops_bundler_otel_insecure_mode")
-	altMempoolIPFSGateway := viper.GetString("aiops_bundler_alt_mempool_ipfs_gateway")
-	altMempoolIds := envArrayToStringSlice(viper.GetString("aiops_bundler_alt_mempool_ids"))
-	isOpStackNetwork := viper.GetBool("aiops_bundler_is_op_stack_network")
-	isArbStackNetwork := viper.GetBool("aiops_bundler_is_arb_stack_network")
-	isRIP7212Supported := viper.GetBool("aiops_bundler_is_rip7212_supported")
-	debugMode := viper.GetBool("aiops_bundler_debug_mode")
-	ginMode := viper.GetString("aiops_bundler_gin_mode")
+	ethClientUrls := l.stringSlice("aiops_bundler_eth_client_url")
+	ethClientUrl := ""
+	if len(ethClientUrls) > 0 {
+		ethClientUrl = ethClientUrls[0]
+	}
+	privateKey := ""
+	if len(privateKeys) > 0 {
+		privateKey = privateKeys[0]
+	}
+	replacementPolicy := &transaction.ReplacementPolicy{
+		Interval:          l.seconds("aiops_bundler_replacement_interval_seconds"),
+		BumpPercent:       int64(l.int("aiops_bundler_replacement_bump_percent")),
+		FeeCeilingPercent: int64(l.int("aiops_bundler_replacement_fee_ceiling_percent")),
+	}
+	if replacementPolicy.BumpPercent < transaction.DefaultBumpPercent &&
+		!l.failed("aiops_bundler_replacement_bump_percent") {
+		// A smaller bump is rejected by the node as an underpriced replacement.
+		l.fail("aiops_bundler_replacement_bump_percent", "must be at least %d", transaction.DefaultBumpPercent)
+	}
+	rpcTimeouts := &jsonrpc.Timeouts{
+		Default: l.seconds("aiops_bundler_rpc_timeout_seconds"),
+		Methods: l.durationMap("aiops_bundler_rpc_method_timeouts"),
+	}
+	pipelines := DefaultPipelines()
+	for mode, p := range pipelines {
+		pipelines[mode] = l.pipeline(mode, p)
+	}
+	chainProfile := l.chainProfile()
 	return &Values{
 		PrivateKey:                   privateKey,
+		PrivateKeys:                  privateKeys,
 		EthClientUrl:                 ethClientUrl,
+		EthClientUrls:                ethClientUrls,
+		EthClientTraceUrls:           l.stringSlice("aiops_bundler_eth_client_trace_urls"),
+		EthClientSubmitUrls:          l.stringSlice("aiops_bundler_eth_client_submit_urls"),
+		EthClientMaxBlockLag:         l.uint64("aiops_bundler_eth_client_max_block_lag"),
+		EthClientChainID:             ethClientChainID,
+		MaxBlockAge:                  l.seconds("aiops_bundler_max_block_age_seconds"),
+		SkipNodeProbe:                l.bool("aiops_bundler_skip_node_probe"),
 		Port:                         port,
-		DataDirectory:                dataDirectory,
+		DataDirectory:                l.string("aiops_bundler_data_directory"),
 		SupportedAiMiddlewares:       supportedAiMiddlewares,
 		Beneficiary:                  beneficiary,
-		NativeBundlerCollectorTracer: nativeBundlerCollectorTracer,
-		NativeBundlerExecutorTracer:  nativeBundlerExecutorTracer,
-		MaxVerificationGas:           maxVerificationGas,
-		MaxBatchGasLimit:             maxBatchGasLimit,
-		MaxOpTTL:                     maxOpTTL,
-		OpLookupLimit:                opLookupLimit,
-		ReputationConstants:          NewReputationConstantsFromEnv(),
+		NativeBundlerCollectorTracer: l.string("aiops_bundler_native_bundler_collector_tracer"),
+		NativeBundlerExecutorTracer:  l.string("aiops_bundler_native_bundler_executor_tracer"),
+		MaxVerificationGas:           l.uint("aiops_bundler_max_verification_gas"),
+		MaxBatchGasLimit:             l.uint("aiops_bundler_max_batch_gas_limit"),
+		MaxOpTTL:                     l.seconds("aiops_bundler_max_op_ttl_seconds"),
+		OpLookupLimit:                l.uint64("aiops_bundler_op_lookup_limit"),
+		ReputationConstants:          l.reputationConstants(),
+		ReplacementPolicy:            replacementPolicy,
+		SignerStrategy:               signerStrategy,
+		SignerBalanceFloor:           signerBalanceFloor,
+		SignerType:                   signerType,
+		BalanceWarningThreshold:      balanceWarningThreshold,
+		BalanceCriticalThreshold:     balanceCriticalThreshold,
+		BalanceTopUp:                 l.bool("aiops_bundler_balance_top_up"),
+		CircuitBreakerThreshold:      l.int("aiops_bundler_circuit_breaker_threshold"),
+		CircuitBreakerBaseBackoff:    l.seconds("aiops_bundler_circuit_breaker_base_backoff_seconds"),
+		CircuitBreakerMaxBackoff:     l.seconds("aiops_bundler_circuit_breaker_max_backoff_seconds"),
+		ShutdownTimeout:              l.seconds("aiops_bundler_shutdown_timeout_seconds"),
+		RPCTimeouts:                  rpcTimeouts,
+		BlockCacheTrackEvents:        l.bool("aiops_bundler_block_cache_track_events"),
+		KeystoreFiles:                keystoreFiles,
+		KeystorePassphraseFile:       keystorePassphraseFile,
+		RemoteSignerUrl:              remoteSignerUrl,
+		RemoteSignerAddresses:        remoteSignerAddresses,
+		Pipelines:                    pipelines,
 		EthBuilderUrls:               ethBuilderUrls,
-		BlocksInTheFuture:            blocksInTheFuture,
+		BlocksInTheFuture:            l.int("aiops_bundler_blocks_in_the_future"),
 		OTELServiceName:              otelServiceName,
-		OTELCollectorHeaders:         otelCollectorHeader,
+		OTELCollectorHeaders:         l.keyValMap("aiops_bundler_otel_collector_headers"),
 		OTELCollectorUrl:             otelCollectorUrl,
-		OTELInsecureMode:             otelInsecureMode,
+		OTELInsecureMode:             l.bool("aiops_bundler_otel_insecure_mode"),
+		PrometheusEnabled:            l.bool("aiops_bundler_prometheus_enabled"),
+		PrometheusPort:               prometheusPort,
 		AltMempoolIPFSGateway:        altMempoolIPFSGateway,
 		AltMempoolIds:                altMempoolIds,
-		IsOpStackNetwork:             isOpStackNetwork,
-		IsArbStackNetwork:            isArbStackNetwork,
-		IsRIP7212Supported:           isRIP7212Supported,
-		DebugMode:                    debugMode,
-		GinMode:                      ginMode,
+		ChainProfile:                 chainProfile,
+		IsRIP7212Supported:           l.bool("aiops_bundler_is_rip7212_supported"),
+		Deprecations:                 l.deprecations,
+		DebugMode:                    l.bool("aiops_bundler_debug_mode"),
+		GinMode:                      l.string("aiops_bundler_gin_mode"),
 	}
 }
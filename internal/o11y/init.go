@@ -2,16 +2,19 @@ package o11y
 
 import (
 	"context"
-	"log"
 	"math/big"
+	"net/http"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc/credentials"
 
@@ -29,30 +32,44 @@ type Opts struct {
 	// Bundler specific attributes
 	ChainID *big.Int
 	Address common.Address
+
+	// PrometheusExporter is an optional metric reader that is registered alongside the collector.
+	PrometheusExporter *prometheus.Exporter
 }
 
-func initResources(opts *Opts) *resource.Resource {
-	resources, err := resource.New(
-		context.Background(),
-		resource.WithAttributes(
-			attribute.String("service.name", opts.ServiceName),
-			attribute.String("library.language", "go"),
-			attribute.String("bundler.address", opts.Address.Hex()),
-			attribute.Int64("bundler.chain_id", opts.ChainID.Int64()),
-		),
-	)
+func initResources(opts *Opts) (*resource.Resource, error) {
+	serviceName := opts.ServiceName
+	if serviceName == "" {
+		serviceName = "aiops-bundler"
+	}
+
+	// The chain and address are not set if the process hosts more than one chain.
+	attrs := []attribute.KeyValue{
+		attribute.String("service.name", serviceName),
+		attribute.String("library.language", "go"),
+	}
+	if opts.Address != (common.Address{}) {
+		attrs = append(attrs, attribute.String("bundler.address", opts.Address.Hex()))
+	}
+	if opts.ChainID != nil {
+		attrs = append(attrs, attribute.Int64("bundler.chain_id", opts.ChainID.Int64()))
+	}
+
+	resources, err := resource.New(context.Background(), resource.WithAttributes(attrs...))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return resources
+	return resources, nil
 }
 
 func IsEnabled(serviceName string) bool {
 	return len(serviceName) > 0
 }
 
-func InitTracer(opts *Opts) func() {
+// InitTracer sets the global tracer provider to export spans to the collector. The returned function flushes
+// any buffered spans and must be called before the process exits.
+func InitTracer(opts *Opts) (func(ctx context.Context) error, error) {
 	secureOption := otlptracegrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, ""))
 	if opts.InsecureMode {
 		secureOption = otlptracegrpc.WithInsecure()
@@ -67,48 +84,71 @@ func InitTracer(opts *Opts) func() {
 		),
 	)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	otel.SetTracerProvider(
-		sdktrace.NewTracerProvider(
-			sdktrace.WithSampler(sdktrace.AlwaysSample()),
-			sdktrace.WithBatcher(exporter),
-			sdktrace.WithResource(initResources(opts)),
-		),
+	res, err := initResources(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
 	)
+	otel.SetTracerProvider(tp)
 	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
 	otel.SetTextMapPropagator(propagator)
-	return func() {
-		_ = exporter.Shutdown(context.Background())
-	}
+	return tp.Shutdown, nil
 }
 
-func InitMetrics(opts *Opts) func() {
-	secureOption := otlpmetricgrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, ""))
-	if opts.InsecureMode {
-		secureOption = otlpmetricgrpc.WithInsecure()
+// NewPrometheusExporter returns a metric reader that collects on each scrape and the handler that serves it
+// in the Prometheus text format.
+func NewPrometheusExporter() (*prometheus.Exporter, http.Handler, error) {
+	reg := promclient.NewRegistry()
+	exporter, err := prometheus.New(prometheus.WithRegisterer(reg))
+	if err != nil {
+		return nil, nil, err
 	}
+	return exporter, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), nil
+}
 
-	exporter, err := otlpmetricgrpc.New(
-		context.Background(),
-		secureOption,
-		otlpmetricgrpc.WithHeaders(opts.CollectorHeader),
-		otlpmetricgrpc.WithEndpoint(opts.CollectorUrl),
-	)
+// InitMetrics sets the global meter provider to export metrics to the collector if a service name is set and
+// to the Prometheus exporter if given. The returned function flushes any pending metrics and must be called
+// before the process exits.
+func InitMetrics(opts *Opts) (func(ctx context.Context) error, error) {
+	res, err := initResources(opts)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	mpOpts := []sdkmetric.Option{sdkmetric.WithResource(res)}
 
-	otel.SetMeterProvider(
-		sdkmetric.NewMeterProvider(
-			sdkmetric.WithResource(initResources(opts)),
-			sdkmetric.WithReader(
-				sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(30*time.Second)),
-			),
-		),
-	)
-	return func() {
-		_ = exporter.Shutdown(context.Background())
+	if IsEnabled(opts.ServiceName) {
+		secureOption := otlpmetricgrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, ""))
+		if opts.InsecureMode {
+			secureOption = otlpmetricgrpc.WithInsecure()
+		}
+
+		exporter, err := otlpmetricgrpc.New(
+			context.Background(),
+			secureOption,
+			otlpmetricgrpc.WithHeaders(opts.CollectorHeader),
+			otlpmetricgrpc.WithEndpoint(opts.CollectorUrl),
+		)
+		if err != nil {
+			return nil, err
+		}
+		mpOpts = append(
+			mpOpts,
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(30*time.Second))),
+		)
+	}
+	if opts.PrometheusExporter != nil {
+		mpOpts = append(mpOpts, sdkmetric.WithReader(opts.PrometheusExporter))
 	}
+
+	mp := sdkmetric.NewMeterProvider(mpOpts...)
+	otel.SetMeterProvider(mp)
+	return mp.Shutdown, nil
 }
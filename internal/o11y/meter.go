@@ -0,0 +1,280 @@
+package o11y
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// attrMeter is a metric.Meter that adds a fixed set of attributes to every measurement.
+type attrMeter struct {
+	metric.Meter
+	attrs metric.MeasurementOption
+}
+
+// MeterWithAttributes returns a meter that adds the given attributes to every measurement made with its
+// instruments, including observations from callbacks. This allows several instances of a component to share
+// metric names in one process and be told apart by their attributes.
+func MeterWithAttributes(meter metric.Meter, attrs ...attribute.KeyValue) metric.Meter {
+	return &attrMeter{Meter: meter, attrs: metric.WithAttributes(attrs...)}
+}
+
+func (m *attrMeter) Int64Counter(name string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	i, err := m.Meter.Int64Counter(name, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &int64Counter{Int64Counter: i, attrs: m.attrs}, nil
+}
+
+func (m *attrMeter) Int64UpDownCounter(
+	name string,
+	options ...metric.Int64UpDownCounterOption,
+) (metric.Int64UpDownCounter, error) {
+	i, err := m.Meter.Int64UpDownCounter(name, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &int64UpDownCounter{Int64UpDownCounter: i, attrs: m.attrs}, nil
+}
+
+func (m *attrMeter) Int64Histogram(
+	name string,
+	options ...metric.Int64HistogramOption,
+) (metric.Int64Histogram, error) {
+	i, err := m.Meter.Int64Histogram(name, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &int64Histogram{Int64Histogram: i, attrs: m.attrs}, nil
+}
+
+func (m *attrMeter) Float64Counter(
+	name string,
+	options ...metric.Float64CounterOption,
+) (metric.Float64Counter, error) {
+	i, err := m.Meter.Float64Counter(name, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &float64Counter{Float64Counter: i, attrs: m.attrs}, nil
+}
+
+func (m *attrMeter) Float64UpDownCounter(
+	name string,
+	options ...metric.Float64UpDownCounterOption,
+) (metric.Float64UpDownCounter, error) {
+	i, err := m.Meter.Float64UpDownCounter(name, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &float64UpDownCounter{Float64UpDownCounter: i, attrs: m.attrs}, nil
+}
+
+func (m *attrMeter) Float64Histogram(
+	name string,
+	options ...metric.Float64HistogramOption,
+) (metric.Float64Histogram, error) {
+	i, err := m.Meter.Float64Histogram(name, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &float64Histogram{Float64Histogram: i, attrs: m.attrs}, nil
+}
+
+// Observable instruments are returned as is so that they can be passed to RegisterCallback on the underlying
+// meter. Only their callbacks are wrapped.
+
+func (m *attrMeter) Int64ObservableCounter(
+	name string,
+	options ...metric.Int64ObservableCounterOption,
+) (metric.Int64ObservableCounter, error) {
+	cfg := metric.NewInt64ObservableCounterConfig(options...)
+	opts := []metric.Int64ObservableCounterOption{
+		metric.WithDescription(cfg.Description()),
+		metric.WithUnit(cfg.Unit()),
+	}
+	for _, cb := range cfg.Callbacks() {
+		opts = append(opts, metric.WithInt64Callback(m.int64Callback(cb)))
+	}
+	return m.Meter.Int64ObservableCounter(name, opts...)
+}
+
+func (m *attrMeter) Int64ObservableUpDownCounter(
+	name string,
+	options ...metric.Int64ObservableUpDownCounterOption,
+) (metric.Int64ObservableUpDownCounter, error) {
+	cfg := metric.NewInt64ObservableUpDownCounterConfig(options...)
+	opts := []metric.Int64ObservableUpDownCounterOption{
+		metric.WithDescription(cfg.Description()),
+		metric.WithUnit(cfg.Unit()),
+	}
+	for _, cb := range cfg.Callbacks() {
+		opts = append(opts, metric.WithInt64Callback(m.int64Callback(cb)))
+	}
+	return m.Meter.Int64ObservableUpDownCounter(name, opts...)
+}
+
+func (m *attrMeter) Int64ObservableGauge(
+	name string,
+	options ...metric.Int64ObservableGaugeOption,
+) (metric.Int64ObservableGauge, error) {
+	cfg := metric.NewInt64ObservableGaugeConfig(options...)
+	opts := []metric.Int64ObservableGaugeOption{
+		metric.WithDescription(cfg.Description()),
+		metric.WithUnit(cfg.Unit()),
+	}
+	for _, cb := range cfg.Callbacks() {
+		opts = append(opts, metric.WithInt64Callback(m.int64Callback(cb)))
+	}
+	return m.Meter.Int64ObservableGauge(name, opts...)
+}
+
+func (m *attrMeter) Float64ObservableCounter(
+	name string,
+	options ...metric.Float64ObservableCounterOption,
+) (metric.Float64ObservableCounter, error) {
+	cfg := metric.NewFloat64ObservableCounterConfig(options...)
+	opts := []metric.Float64ObservableCounterOption{
+		metric.WithDescription(cfg.Description()),
+		metric.WithUnit(cfg.Unit()),
+	}
+	for _, cb := range cfg.Callbacks() {
+		opts = append(opts, metric.WithFloat64Callback(m.float64Callback(cb)))
+	}
+	return m.Meter.Float64ObservableCounter(name, opts...)
+}
+
+func (m *attrMeter) Float64ObservableUpDownCounter(
+	name string,
+	options ...metric.Float64ObservableUpDownCounterOption,
+) (metric.Float64ObservableUpDownCounter, error) {
+	cfg := metric.NewFloat64ObservableUpDownCounterConfig(options...)
+	opts := []metric.Float64ObservableUpDownCounterOption{
+		metric.WithDescription(cfg.Description()),
+		metric.WithUnit(cfg.Unit()),
+	}
+	for _, cb := range cfg.Callbacks() {
+		opts = append(opts, metric.WithFloat64Callback(m.float64Callback(cb)))
+	}
+	return m.Meter.Float64ObservableUpDownCounter(name, opts...)
+}
+
+func (m *attrMeter) Float64ObservableGauge(
+	name string,
+	options ...metric.Float64ObservableGaugeOption,
+) (metric.Float64ObservableGauge, error) {
+	cfg := metric.NewFloat64ObservableGaugeConfig(options...)
+	opts := []metric.Float64ObservableGaugeOption{
+		metric.WithDescription(cfg.Description()),
+		metric.WithUnit(cfg.Unit()),
+	}
+	for _, cb := range cfg.Callbacks() {
+		opts = append(opts, metric.WithFloat64Callback(m.float64Callback(cb)))
+	}
+	return m.Meter.Float64ObservableGauge(name, opts...)
+}
+
+func (m *attrMeter) RegisterCallback(f metric.Callback, instruments ...metric.Observable) (metric.Registration, error) {
+	return m.Meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		return f(ctx, &observer{Observer: o, attrs: m.attrs})
+	}, instruments...)
+}
+
+func (m *attrMeter) int64Callback(cb metric.Int64Callback) metric.Int64Callback {
+	return func(ctx context.Context, o metric.Int64Observer) error {
+		return cb(ctx, &int64Observer{Int64Observer: o, attrs: m.attrs})
+	}
+}
+
+func (m *attrMeter) float64Callback(cb metric.Float64Callback) metric.Float64Callback {
+	return func(ctx context.Context, o metric.Float64Observer) error {
+		return cb(ctx, &float64Observer{Float64Observer: o, attrs: m.attrs})
+	}
+}
+
+type int64Counter struct {
+	metric.Int64Counter
+	attrs metric.MeasurementOption
+}
+
+func (i *int64Counter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	i.Int64Counter.Add(ctx, incr, append(options, i.attrs)...)
+}
+
+type int64UpDownCounter struct {
+	metric.Int64UpDownCounter
+	attrs metric.MeasurementOption
+}
+
+func (i *int64UpDownCounter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	i.Int64UpDownCounter.Add(ctx, incr, append(options, i.attrs)...)
+}
+
+type int64Histogram struct {
+	metric.Int64Histogram
+	attrs metric.MeasurementOption
+}
+
+func (i *int64Histogram) Record(ctx context.Context, incr int64, options ...metric.RecordOption) {
+	i.Int64Histogram.Record(ctx, incr, append(options, i.attrs)...)
+}
+
+type float64Counter struct {
+	metric.Float64Counter
+	attrs metric.MeasurementOption
+}
+
+func (i *float64Counter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	i.Float64Counter.Add(ctx, incr, append(options, i.attrs)...)
+}
+
+type float64UpDownCounter struct {
+	metric.Float64UpDownCounter
+	attrs metric.MeasurementOption
+}
+
+func (i *float64UpDownCounter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	i.Float64UpDownCounter.Add(ctx, incr, append(options, i.attrs)...)
+}
+
+type float64Histogram struct {
+	metric.Float64Histogram
+	attrs metric.MeasurementOption
+}
+
+func (i *float64Histogram) Record(ctx context.Context, incr float64, options ...metric.RecordOption) {
+	i.Float64Histogram.Record(ctx, incr, append(options, i.attrs)...)
+}
+
+type int64Observer struct {
+	metric.Int64Observer
+	attrs metric.MeasurementOption
+}
+
+func (o *int64Observer) Observe(value int64, options ...metric.ObserveOption) {
+	o.Int64Observer.Observe(value, append(options, o.attrs)...)
+}
+
+type float64Observer struct {
+	metric.Float64Observer
+	attrs metric.MeasurementOption
+}
+
+func (o *float64Observer) Observe(value float64, options ...metric.ObserveOption) {
+	o.Float64Observer.Observe(value, append(options, o.attrs)...)
+}
+
+type observer struct {
+	metric.Observer
+	attrs metric.MeasurementOption
+}
+
+func (o *observer) ObserveInt64(obsrv metric.Int64Observable, value int64, options ...metric.ObserveOption) {
+	o.Observer.ObserveInt64(obsrv, value, append(options, o.attrs)...)
+}
+
+func (o *observer) ObserveFloat64(obsrv metric.Float64Observable, value float64, options ...metric.ObserveOption) {
+	o.Observer.ObserveFloat64(obsrv, value, append(options, o.attrs)...)
+}
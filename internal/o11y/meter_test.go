@@ -0,0 +1,60 @@
+package o11y
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// TestMeterWithAttributes verifies that the attributes are added to measurements from sync instruments and
+// to observations from callbacks alongside the attributes of each measurement.
+func TestMeterWithAttributes(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	chain := attribute.Int64("chain_id", 10)
+	meter := MeterWithAttributes(provider.Meter("test"), chain)
+
+	counter, err := meter.Int64Counter("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("code", "ok")))
+	_, err = meter.Int64ObservableGauge("gauge", metric.WithInt64Callback(
+		func(ctx context.Context, o metric.Int64Observer) error {
+			o.Observe(1)
+			return nil
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.ScopeMetrics) != 1 || len(rm.ScopeMetrics[0].Metrics) != 2 {
+		t.Fatalf("got %+v, want 2 metrics", rm.ScopeMetrics)
+	}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		var attrs attribute.Set
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			attrs = data.DataPoints[0].Attributes
+			if v, ok := attrs.Value("code"); !ok || v.AsString() != "ok" {
+				t.Fatalf("%s: got attributes %v, want code=ok", m.Name, attrs.ToSlice())
+			}
+		case metricdata.Gauge[int64]:
+			attrs = data.DataPoints[0].Attributes
+		default:
+			t.Fatalf("%s: unexpected data %T", m.Name, m.Data)
+		}
+		if v, ok := attrs.Value("chain_id"); !ok || v.AsInt64() != 10 {
+			t.Fatalf("%s: got attributes %v, want chain_id=10", m.Name, attrs.ToSlice())
+		}
+	}
+}
@@ -3,20 +3,33 @@ package start
 import (
 	"time"
 
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
 	badger "github.com/dgraph-io/badger/v3"
 )
 
-func runDBGarbageCollection(db *badger.DB) {
+// runDBGarbageCollection periodically cleans up the value log in the background. The returned function stops
+// the garbage collector and waits for any run in progress to finish.
+func runDBGarbageCollection(db *badger.DB) func() {
+	done := make(chan bool)
 	go func(db *badger.DB) {
 		ticker := time.NewTicker(5 * time.Minute)
 		defer ticker.Stop()
 
-		for range ticker.C {
-		again:
-			err := db.RunValueLogGC(0.7)
-			if err == nil {
-				goto again
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			again:
+				err := utils.Recover(func() error { return db.RunValueLogGC(0.7) })
+				if err == nil {
+					goto again
+				}
 			}
 		}
 	}(db)
+
+	return func() {
+		done <- true
+	}
 }
@@ -0,0 +1,44 @@
+package start
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/probe"
+)
+
+// Doctor runs the node capability probe and writes the result of each check. An error is returned if a
+// capability required to start the bundler is missing.
+func Doctor(w io.Writer) error {
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	n := runProbes(context.Background(), conf, maxBlockAge(conf, chainProfile(conf, conf.EthClientChainID)))
+	for _, nr := range n {
+		fmt.Fprintf(w, "%s node %s\n", nr.role, nr.url)
+		for _, res := range nr.report.Results {
+			status := "ok"
+			if errors.Is(res.Err, probe.ErrSkipped) {
+				status = "skip"
+			} else if res.Err != nil {
+				status = "fail"
+			}
+
+			if res.Err != nil {
+				fmt.Fprintf(w, "  [%s] %s: %s\n", status, res.Capability, res.Err)
+			} else {
+				fmt.Fprintf(w, "  [%s] %s\n", status, res.Capability)
+			}
+		}
+	}
+
+	if err := n.stateOverridesErr(); err != nil {
+		fmt.Fprintf(w, "\nwarning: gas estimation will be disabled: %s\n", err)
+	}
+	return n.requiredErr()
+}
@@ -0,0 +1,420 @@
+package start
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/o11y"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/stake"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/transaction"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/altmempools"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/blockcache"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/chains"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/client"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/health"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/jsonrpc"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/builder"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/checks"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/entities"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/relay"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/shadow"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/balance"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/pool"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/syncguard"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// chainNode is the connection to the execution client of a single chain.
+type chainNode struct {
+	eoas    []signer.Signer
+	rpc     *rpc.Client
+	eth     *ethclient.Client
+	chain   *big.Int
+	profile *chains.Profile
+	probes  nodeReports
+}
+
+// connect creates the signers and dials the execution client for a chain. The chain ID is read from the node.
+func connect(conf *config.Values, logr logr.Logger, sd *shutdown) (*chainNode, error) {
+	eoas, err := config.NewSigners(conf)
+	if err != nil {
+		return nil, err
+	}
+	for _, eoa := range eoas {
+		if r, ok := eoa.(*signer.Remote); ok {
+			sd.addFunc("remote_signer", r.Close)
+		}
+	}
+
+	rpc, err := dialNode(conf, logr, sd)
+	if err != nil {
+		return nil, err
+	}
+	sd.addFunc("eth_client", rpc.Close)
+	eth := ethclient.NewClient(rpc)
+
+	chain, err := eth.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	profile := chainProfile(conf, chain)
+	logr.Info(
+		"chain profile",
+		"chain_id", chain.String(),
+		"profile", profile.Name,
+		"rollup", string(profile.Rollup),
+		"l1_fee_model", string(profile.L1FeeModel),
+	)
+
+	probes, err := probeNodes(context.Background(), conf, maxBlockAge(conf, profile), logr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chainNode{eoas: eoas, rpc: rpc, eth: eth, chain: chain, profile: profile, probes: probes}, nil
+}
+
+// instance is a private bundler for a single chain. Every background service it starts is added to the
+// shutdown registry passed to newInstance.
+type instance struct {
+	chain    *big.Int
+	health   *health.Checker
+	shadow   *shadow.Comparator
+	handlers []gin.HandlerFunc
+}
+
+// instanceOpts holds the values that must differ between instances hosted by one process.
+type instanceOpts struct {
+	// dataDir is the directory of the mempool and reputation store.
+	dataDir string
+
+	// meterAttrs are added to every measurement so that the metrics of each chain can be told apart.
+	meterAttrs []attribute.KeyValue
+
+	// builderUrls are the block builders that bundles are sent to in searcher mode.
+	builderUrls []string
+}
+
+// sender is the module that sends bundles and settles them in the background.
+type sender interface {
+	SendAiOperation() modules.BatchHandlerFunc
+	SetCallTimeout(timeout time.Duration)
+	OnSettled(fn transaction.SettledHandlerFunc)
+	UseDB(db *badger.DB) error
+	Pending() []*transaction.Submitted
+	Wait(ctx context.Context, hash common.Hash) error
+	Run() error
+	Shutdown(ctx context.Context) error
+}
+
+// callTimeout returns the deadline for node calls that are made in the background rather than for a client
+// request.
+func callTimeout(conf *config.Values) time.Duration {
+	if conf.RPCTimeouts == nil {
+		return 0
+	}
+	return conf.RPCTimeouts.Default
+}
+
+// newSender returns the module that sends bundles in the given mode. Searcher mode sends bundles to block
+// builders and all other modes send them to the node.
+func newSender(
+	conf *config.Values,
+	mode string,
+	n *chainNode,
+	opts *instanceOpts,
+	accounts *pool.Pool,
+	beneficiary common.Address,
+	logr logr.Logger,
+) (sender, error) {
+	if sendModule(mode) == "builder.send_aiop" {
+		if !n.profile.BuilderCompatible {
+			return nil, fmt.Errorf(
+				"error: network with chainID %d is not compatible with the Block Builder API",
+				n.chain.Uint64(),
+			)
+		}
+		fb := builder.NewBroadcaster(opts.builderUrls)
+		client := builder.New(accounts, n.eth, fb, beneficiary, conf.BlocksInTheFuture)
+		client.SetCallTimeout(callTimeout(conf))
+		return client, nil
+	}
+
+	relayer := relay.New(accounts, n.eth, n.chain, beneficiary, logr)
+	relayer.SetReplacementPolicy(conf.ReplacementPolicy)
+	relayer.SetCallTimeout(callTimeout(conf))
+	return relayer, nil
+}
+
+// newInstance initializes the mempool, Client, and Bundler for a chain in private, shadow, or searcher mode.
+func newInstance(
+	conf *config.Values,
+	mode string,
+	n *chainNode,
+	opts *instanceOpts,
+	logr logr.Logger,
+	sd *shutdown,
+) (*instance, error) {
+	shadowed := mode == "shadow"
+	meter := func(name string) metric.Meter {
+		return o11y.MeterWithAttributes(otel.GetMeterProvider().Meter(name), opts.meterAttrs...)
+	}
+	rpc, eth, chain := n.rpc, n.eth, n.chain
+	eoa := n.eoas[0]
+	beneficiary := eoa.Address()
+	if conf.Beneficiary != "" {
+		beneficiary = common.HexToAddress(conf.Beneficiary)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(opts.dataDir))
+	if err != nil {
+		return nil, err
+	}
+	sd.add("db", func(ctx context.Context) error { return db.Close() })
+	sd.addFunc("db_gc", runDBGarbageCollection(db))
+
+	accounts, err := pool.New(eth, n.eoas, conf.SignerStrategy)
+	if err != nil {
+		return nil, err
+	}
+	accounts.SetBalanceFloor(conf.SignerBalanceFloor)
+	accounts.UseLogger(logr)
+	if err := accounts.AiMeter(meter("signer_pool")); err != nil {
+		return nil, err
+	}
+
+	bc := blockcache.New(eth)
+	bc.SetInterval(n.profile.PollInterval())
+	bc.UseLogger(logr)
+	if conf.BlockCacheTrackEvents {
+		bc.TrackEvents(conf.SupportedAiMiddlewares...)
+	}
+	if err := bc.Run(); err != nil {
+		return nil, err
+	}
+	sd.addFunc("block_cache", bc.Stop)
+
+	ov := newOverhead(rpc, chain, conf.SupportedAiMiddlewares[0], n.profile)
+
+	mem, err := mempool.New(db)
+	if err != nil {
+		return nil, err
+	}
+
+	alt, err := altmempools.NewFromIPFS(chain, conf.AltMempoolIPFSGateway, conf.AltMempoolIds)
+	if err != nil {
+		return nil, err
+	}
+
+	check := checks.New(
+		db,
+		rpc,
+		ov,
+		alt,
+		conf.MaxVerificationGas,
+		conf.MaxBatchGasLimit,
+		conf.IsRIP7212Supported,
+		conf.NativeBundlerCollectorTracer,
+		conf.ReputationConstants,
+	)
+	check.UseBlockCache(bc)
+
+	send, err := newSender(conf, mode, n, opts, accounts, beneficiary, logr)
+	if err != nil {
+		return nil, err
+	}
+	if err := send.UseDB(db); err != nil {
+		return nil, err
+	}
+
+	rep := entities.New(db, eth, conf.ReputationConstants)
+	if err := rep.AiMeter(meter("reputation")); err != nil {
+		return nil, err
+	}
+
+	guard := syncguard.New(eth, maxBlockAge(conf, n.profile))
+	guard.UseLogger(logr)
+
+	// Init Client
+	c := client.New(mem, ov, chain, conf.SupportedAiMiddlewares, conf.OpLookupLimit)
+	c.SetGetAiOpReceiptFunc(client.GetAiOpReceiptWithEthClient(eth))
+	c.SetGetGasPricesFunc(client.GetGasPricesWithEthClient(eth))
+	c.SetGetGasEstimateFunc(
+		client.GetGasEstimateWithEthClient(
+			rpc,
+			ov,
+			chain,
+			conf.MaxBatchGasLimit,
+			conf.NativeBundlerExecutorTracer,
+		),
+	)
+	c.SetGetSyncErrFunc(guard.Err)
+	if err := n.probes.stateOverridesErr(); err != nil {
+		c.SetGetGasEstimateFunc(client.GetGasEstimateUnsupported(err))
+	}
+	c.SetGetAiOpByHashFunc(client.GetAiOpByHashWithEthClient(eth))
+	c.SetGetStakeFunc(stake.GetStakeWithBlockCache(bc))
+	c.UseBatchReads(rpc, bc)
+	c.UseLogger(logr)
+	if err := c.AiMeter(meter("client")); err != nil {
+		return nil, err
+	}
+
+	// Init Bundler
+	b := bundler.New(mem, chain, conf.SupportedAiMiddlewares)
+	useGasPrices(b, eth, n.profile)
+	b.SetGetStakeFunc(stake.GetStakeWithBlockCache(bc))
+	b.SetCallTimeout(callTimeout(conf))
+	b.SetCircuitBreaker(
+		conf.CircuitBreakerThreshold,
+		conf.CircuitBreakerBaseBackoff,
+		conf.CircuitBreakerMaxBackoff,
+	)
+	b.UseLogger(logr)
+	if err := b.AiMeter(meter("bundler")); err != nil {
+		return nil, err
+	}
+
+	mm, err := modules.NewMetrics(meter("modules"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Init shadow comparator
+	var cmp *shadow.Comparator
+	extra := []modules.BatchHandlerFunc{}
+	if shadowed {
+		cmp = shadow.New(eth, mem, chain, conf.SupportedAiMiddlewares)
+		cmp.SetMaxBlockRange(n.profile.MaxLogRange)
+		cmp.UseLogger(logr)
+		if err := cmp.AiMeter(meter("shadow")); err != nil {
+			return nil, err
+		}
+		b.SetDryRun(true)
+		cmp.OnIncluded(b.OnOpsIncluded)
+		extra = append(extra, modules.NamedBatchHandler("shadow.record", cmp.Record(), mm))
+	}
+
+	// Init module pipelines
+	deps := &moduleDeps{rep: rep, check: check, send: send.SendAiOperation(), metrics: mm}
+	if err := usePipeline(conf, mode, deps, c, b, extra...); err != nil {
+		return nil, err
+	}
+	c.SetGetCircuitBreakerStatusFunc(b.CircuitBreakerStatus)
+	c.SetGetAiOpStatusFunc(b.AiOpStatus)
+	b.TrackInflight(send.Pending()...)
+	if err := b.Run(); err != nil {
+		return nil, err
+	}
+	send.OnSettled(b.OnTxnSettled)
+	if err := send.Run(); err != nil {
+		return nil, err
+	}
+	sd.addDrain("sender", send.Shutdown)
+	sd.addDrain("bundler", b.Shutdown)
+
+	if shadowed {
+		if err := cmp.Run(); err != nil {
+			return nil, err
+		}
+		sd.addFunc("shadow", cmp.Stop)
+	}
+
+	// Init balance monitor. A shadow bundler never sends transactions and estimates bundles without fees so the
+	// signer does not need funds.
+	var mon *balance.Monitor
+	if !shadowed {
+		mon = balance.New(eth, accounts, conf.BalanceWarningThreshold, conf.BalanceCriticalThreshold)
+		mon.UseLogger(logr)
+		mon.SetCallTimeout(callTimeout(conf))
+		if err := mon.AiMeter(meter("balance_monitor")); err != nil {
+			return nil, err
+		}
+		if conf.BalanceTopUp {
+			mon.SetTopUp(conf.SupportedAiMiddlewares[0], beneficiary, chain)
+		}
+		mon.OnCritical(func(critical bool) {
+			if critical {
+				b.Pause("balance_critical")
+			} else {
+				b.Resume("balance_critical")
+			}
+		})
+		if err := mon.Run(); err != nil {
+			return nil, err
+		}
+		sd.addFunc("balance_monitor", mon.Stop)
+	}
+
+	// Init sync guard
+	guard.OnUnsynced(func(unsynced bool) {
+		if unsynced {
+			b.Pause("node_unsynced")
+		} else {
+			b.Resume("node_unsynced")
+		}
+	})
+	if err := guard.Run(); err != nil {
+		return nil, err
+	}
+	sd.addFunc("sync_guard", guard.Stop)
+
+	// init Debug
+	var d *client.Debug
+	if conf.DebugMode {
+		d = client.NewDebug(eoa, eth, mem, rep, b, chain, conf.SupportedAiMiddlewares[0], beneficiary)
+		d.SetWaitForSettledFunc(send.Wait)
+		b.SetMaxBatch(1)
+	}
+
+	// Init health checks
+	hc := health.New()
+	hc.AddLiveness("bundler", health.CheckBundlerHeartbeat(b, health.DefaultMaxHeartbeatAge))
+	hc.AddReadiness("node", health.CheckNodeWithEthClient(eth))
+	hc.AddReadiness("node_sync", health.CheckNodeSync(guard))
+	hc.AddReadiness("db", health.CheckDBWritable(db))
+	if mon != nil {
+		hc.AddReadiness("signer_balance", health.CheckSignerBalance(mon))
+	}
+	hc.AddReadiness("alt_mempools", health.CheckAltMempools(alt, conf.AltMempoolIds))
+
+	return &instance{
+		chain:  chain,
+		health: hc,
+		shadow: cmp,
+		handlers: []gin.HandlerFunc{
+			jsonrpc.Controller(client.NewRpcAdapter(c, d), conf.RPCTimeouts),
+			jsonrpc.WithOTELTracerAttributes(),
+		},
+	}, nil
+}
+
+// mount adds the JSON-RPC, health, and shadow report routes of the instance to a router group.
+func (i *instance) mount(r gin.IRoutes) {
+	r.GET("/health/live", i.health.LiveHandler())
+	r.GET("/health/ready", i.health.ReadyHandler())
+	if i.shadow != nil {
+		r.GET("/shadow/report", func(g *gin.Context) {
+			g.JSON(http.StatusOK, i.shadow.Report())
+		})
+	}
+	r.POST("/", i.handlers...)
+	r.POST("/rpc", i.handlers...)
+}
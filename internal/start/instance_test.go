@@ -0,0 +1,42 @@
+package start
+
+import (
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/chains"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/builder"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/relay"
+	"github.com/go-logr/logr"
+)
+
+// TestNewSenderByMode verifies that searcher mode sends bundles to block builders on compatible chains and
+// every other mode sends them to the node.
+func TestNewSenderByMode(t *testing.T) {
+	conf := &config.Values{}
+	n := &chainNode{chain: testutils.ChainID, profile: &chains.Profile{BuilderCompatible: true}}
+	opts := &instanceOpts{}
+	addr := testutils.ValidAddress1
+
+	for _, mode := range []string{"private", "shadow"} {
+		s, err := newSender(conf, mode, n, opts, nil, addr, logr.Discard())
+		if err != nil {
+			t.Fatalf("%s: got err %v, want nil", mode, err)
+		} else if _, ok := s.(*relay.Relayer); !ok {
+			t.Fatalf("%s: got %T, want *relay.Relayer", mode, s)
+		}
+	}
+
+	s, err := newSender(conf, "searcher", n, opts, nil, addr, logr.Discard())
+	if err != nil {
+		t.Fatalf("searcher: got err %v, want nil", err)
+	} else if _, ok := s.(*builder.BuilderClient); !ok {
+		t.Fatalf("searcher: got %T, want *builder.BuilderClient", s)
+	}
+
+	n.profile.BuilderCompatible = false
+	if _, err := newSender(conf, "searcher", n, opts, nil, addr, logr.Discard()); err == nil {
+		t.Fatal("searcher: got nil, want err for a chain that is not builder compatible")
+	}
+}
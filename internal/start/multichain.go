@@ -0,0 +1,407 @@
+package start
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	rpcerrors "github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/health"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/jsonrpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// chainMode is the mode of the bundler for each chain in multichain mode.
+const chainMode = "private"
+
+var (
+	// chainRetryBaseBackoff is the time to wait before retrying a chain that failed to start. It is doubled
+	// after each failed attempt up to chainRetryMaxBackoff.
+	chainRetryBaseBackoff = 5 * time.Second
+	chainRetryMaxBackoff  = 5 * time.Minute
+)
+
+// failedChain is a chain that did not start. The chain ID is only known if it is set in the config.
+type failedChain struct {
+	chain string
+	err   error
+}
+
+// chainSet holds the instance of each chain in multichain mode. Chains that failed to start are kept with
+// their error so that requests for them are rejected while the other chains keep serving.
+type chainSet struct {
+	mu        sync.RWMutex
+	names     []string
+	instances map[string]*instance
+	failed    map[string]*failedChain
+}
+
+func newChainSet() *chainSet {
+	return &chainSet{
+		names:     []string{},
+		instances: make(map[string]*instance),
+		failed:    make(map[string]*failedChain),
+	}
+}
+
+// add registers a running instance under the name of its entry in the chains list. An error is returned if
+// another entry already serves the same chain. A chain that previously failed to start keeps its place.
+func (cs *chainSet) add(name string, inst *instance) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, other := range cs.instances {
+		if other.chain.Cmp(inst.chain) == 0 {
+			return fmt.Errorf("chain %s is already served by another entry", inst.chain)
+		}
+	}
+	if _, ok := cs.failed[name]; ok {
+		delete(cs.failed, name)
+	} else {
+		cs.names = append(cs.names, name)
+	}
+	cs.instances[name] = inst
+	return nil
+}
+
+// fail registers a chain that did not start. The chain ID can be nil if it is unknown. A chain that
+// previously failed to start keeps its place and has its error replaced.
+func (cs *chainSet) fail(name string, chain *big.Int, err error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	f := &failedChain{err: err}
+	if chain != nil {
+		f.chain = chain.String()
+	}
+	if _, ok := cs.failed[name]; !ok {
+		cs.names = append(cs.names, name)
+	}
+	cs.failed[name] = f
+}
+
+// running returns the number of chains that have started.
+func (cs *chainSet) running() int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	return len(cs.instances)
+}
+
+// lookup returns the instance for a chain ID in decimal or hex. If the chain is not running, the returned
+// error is sent to the caller.
+func (cs *chainSet) lookup(param string) (*instance, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	id, ok := big.NewInt(0).SetString(param, 0)
+	if ok {
+		for _, inst := range cs.instances {
+			if inst.chain.Cmp(id) == 0 {
+				return inst, nil
+			}
+		}
+		for _, f := range cs.failed {
+			if f.chain == id.String() {
+				return nil, rpcerrors.NewRPCError(
+					rpcerrors.CHAIN_UNAVAILABLE,
+					fmt.Sprintf("chain %s is unavailable", id),
+					nil,
+				)
+			}
+		}
+	}
+	return nil, rpcerrors.NewRPCError(
+		rpcerrors.CHAIN_UNAVAILABLE,
+		fmt.Sprintf("chain %s is not supported", param),
+		nil,
+	)
+}
+
+// rpcHandler routes a JSON-RPC request to the instance for the chainId path param.
+func (cs *chainSet) rpcHandler() gin.HandlerFunc {
+	return func(g *gin.Context) {
+		inst, err := cs.lookup(g.Param("chainId"))
+		if err != nil {
+			jsonrpc.AbortWithError(g, err)
+			return
+		}
+		for _, h := range inst.handlers {
+			h(g)
+			if g.IsAborted() {
+				return
+			}
+		}
+	}
+}
+
+// healthHandler responds with a health report of the instance for the chainId path param.
+func (cs *chainSet) healthHandler(live bool) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		inst, err := cs.lookup(g.Param("chainId"))
+		if err != nil {
+			g.JSON(http.StatusServiceUnavailable, &health.Report{
+				Status:     health.StatusFail,
+				Components: map[string]health.Result{"chain": {Status: health.StatusFail, Error: err.Error()}},
+			})
+			return
+		}
+		if live {
+			inst.health.LiveHandler()(g)
+		} else {
+			inst.health.ReadyHandler()(g)
+		}
+	}
+}
+
+// report returns a component for each chain. With live set, only running chains are included and the
+// status fails if any of them fails. Otherwise chains that did not start are included and the status is ok
+// as long as one chain is ready so that the process keeps receiving traffic for the healthy chains.
+func (cs *chainSet) report(ctx context.Context, live bool) *health.Report {
+	cs.mu.RLock()
+	names := append([]string{}, cs.names...)
+	instances := make(map[string]*instance, len(cs.instances))
+	for name, inst := range cs.instances {
+		instances[name] = inst
+	}
+	failed := make(map[string]*failedChain, len(cs.failed))
+	for name, f := range cs.failed {
+		failed[name] = f
+	}
+	cs.mu.RUnlock()
+
+	r := &health.Report{Status: health.StatusFail, Components: make(map[string]health.Result)}
+	if live {
+		r.Status = health.StatusOK
+	}
+	for _, name := range names {
+		if f, ok := failed[name]; ok {
+			if !live {
+				r.Components[name] = health.Result{Status: health.StatusFail, Error: f.err.Error()}
+			}
+			continue
+		}
+
+		inst := instances[name]
+		var sub *health.Report
+		if live {
+			sub = inst.health.Live(ctx)
+		} else {
+			sub = inst.health.Ready(ctx)
+		}
+		r.Components[name] = health.Result{
+			Status:  sub.Status,
+			Details: map[string]any{"chain_id": inst.chain.String(), "components": sub.Components},
+		}
+		if live && sub.Status != health.StatusOK {
+			r.Status = health.StatusFail
+		} else if !live && sub.Status == health.StatusOK {
+			r.Status = health.StatusOK
+		}
+	}
+	return r
+}
+
+func (cs *chainSet) reportHandler(live bool) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		r := cs.report(g.Request.Context(), live)
+		code := http.StatusOK
+		if r.Status != health.StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		g.JSON(code, r)
+	}
+}
+
+// startChain connects to the node of a chain and starts its instance. The mempool of each chain is stored in
+// a separate directory named after the chain ID. A panic is returned as an error so that it does not stop
+// the other chains.
+func startChain(conf *config.Values, logr logr.Logger, sd *shutdown) (inst *instance, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	n, err := connect(conf, logr, sd)
+	if err != nil {
+		return nil, err
+	}
+	if conf.EthClientChainID != nil && conf.EthClientChainID.Cmp(n.chain) != 0 {
+		return nil, fmt.Errorf("node chain ID %s does not match eth_client_chain_id %s", n.chain, conf.EthClientChainID)
+	}
+
+	opts := &instanceOpts{
+		dataDir:    filepath.Join(conf.DataDirectory, n.chain.String()),
+		meterAttrs: []attribute.KeyValue{attribute.Int64("chain_id", n.chain.Int64())},
+	}
+	return newInstance(conf, chainMode, n, opts, logr.WithValues("chain_id", n.chain.String()), sd)
+}
+
+// chainRetry starts a chain that failed to start in the background. The wait between attempts starts at
+// chainRetryBaseBackoff and is doubled after each failure up to chainRetryMaxBackoff.
+type chainRetry struct {
+	name   string
+	conf   *config.Values
+	logger logr.Logger
+	cs     *chainSet
+	start  func(conf *config.Values, logr logr.Logger, sd *shutdown) (*instance, error)
+	sd     *shutdown
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newChainRetry(name string, conf *config.Values, logr logr.Logger, cs *chainSet) *chainRetry {
+	return &chainRetry{
+		name:   name,
+		conf:   conf,
+		logger: logr,
+		cs:     cs,
+		start:  startChain,
+		cancel: func() {},
+		done:   make(chan struct{}),
+	}
+}
+
+// run retries the chain until it has started or stop is called.
+func (r *chainRetry) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go func() {
+		defer close(r.done)
+
+		backoff := chainRetryBaseBackoff
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			csd := newShutdown(r.logger, r.conf.ShutdownTimeout)
+			inst, err := r.start(r.conf, r.logger, csd)
+			if err == nil {
+				err = r.cs.add(r.name, inst)
+			}
+			if err == nil {
+				r.logger.Info("chain started", "chain_id", inst.chain.String())
+				r.sd = csd
+				return
+			}
+
+			csd.run()
+			r.cs.fail(r.name, r.conf.EthClientChainID, err)
+			backoff *= 2
+			if backoff > chainRetryMaxBackoff {
+				backoff = chainRetryMaxBackoff
+			}
+			r.logger.Error(err, "chain failed to start", "retry_in", backoff.String())
+		}
+	}()
+}
+
+// stop cancels any further attempts and waits for the one in progress. If the chain has started, it is shut
+// down within the deadline of ctx.
+func (r *chainRetry) stop(ctx context.Context) error {
+	r.cancel()
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if r.sd != nil {
+		r.sd.runContext(ctx)
+	}
+	return nil
+}
+
+// MultiChainMode runs a private bundler for each entry in the chains list of the config file. Each chain has
+// its own signers, mempool, module pipeline, and background services. JSON-RPC requests are routed by chain
+// ID at /rpc/{chainId}. A chain that fails to start is logged and retried in the background while the other
+// chains keep serving.
+func MultiChainMode() error {
+	chains, err := config.LoadChains()
+	if err != nil {
+		return err
+	}
+
+	// Values that are shared by all chains can only be set at the top level so they are the same in each
+	// entry.
+	conf := chains[0]
+	logr := logger.NewZeroLogr().
+		WithName("aiops_bundler").
+		WithValues("bundler_mode", "multichain")
+
+	var errs error
+	for i, c := range chains {
+		errs = errors.Join(errs, prefixErr(fmt.Sprintf("chains[%d]", i), validatePipeline(c, chainMode)))
+	}
+	if errs != nil {
+		return errs
+	}
+
+	sd := newShutdown(logr, conf.ShutdownTimeout)
+	defer sd.run()
+
+	metricsHandler, err := initO11y(conf, nil, common.Address{}, sd, logr)
+	if err != nil {
+		return err
+	}
+
+	cs := newChainSet()
+	retries := []*chainRetry{}
+	for i, c := range chains {
+		name := fmt.Sprintf("chains[%d]", i)
+		clogr := logr.WithValues("chain", name)
+		logDeprecations(c, clogr)
+		csd := newShutdown(clogr, c.ShutdownTimeout)
+
+		inst, err := startChain(c, clogr, csd)
+		if err == nil {
+			err = cs.add(name, inst)
+		}
+		if err != nil {
+			clogr.Error(err, "chain failed to start", "retry_in", chainRetryBaseBackoff.String())
+			csd.run()
+			cs.fail(name, c.EthClientChainID, err)
+			retries = append(retries, newChainRetry(name, c, clogr, cs))
+			continue
+		}
+
+		clogr.Info("chain started", "chain_id", inst.chain.String())
+		sd.add(name, func(ctx context.Context) error {
+			csd.runContext(ctx)
+			return nil
+		})
+	}
+	if cs.running() == 0 {
+		return errors.New("no chain started")
+	}
+	for _, r := range retries {
+		r.run()
+		sd.add(r.name, r.stop)
+	}
+
+	// Init HTTP server
+	r, err := newRouter(conf, logr, metricsHandler)
+	if err != nil {
+		return err
+	}
+	r.GET("/health/live", cs.reportHandler(true))
+	r.GET("/health/ready", cs.reportHandler(false))
+	r.GET("/chains/:chainId/health/live", cs.healthHandler(true))
+	r.GET("/chains/:chainId/health/ready", cs.healthHandler(false))
+	r.POST("/rpc/:chainId", cs.rpcHandler())
+
+	return serve(r, conf.Port, sd, logr)
+}
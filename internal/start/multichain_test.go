@@ -0,0 +1,183 @@
+package start
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/health"
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+)
+
+func testInstance(chain int64, ready bool) *instance {
+	hc := health.New()
+	hc.AddReadiness("node", func(ctx context.Context) (any, error) {
+		if !ready {
+			return nil, errors.New("node down")
+		}
+		return nil, nil
+	})
+	return &instance{
+		chain:  big.NewInt(chain),
+		health: hc,
+		handlers: []gin.HandlerFunc{func(g *gin.Context) {
+			g.String(http.StatusOK, big.NewInt(chain).String())
+		}},
+	}
+}
+
+func testChainSet() *chainSet {
+	cs := newChainSet()
+	cs.add("chains[0]", testInstance(1, true))
+	cs.add("chains[1]", testInstance(10, false))
+	cs.fail("chains[2]", big.NewInt(42161), errors.New("dial failed"))
+	return cs
+}
+
+func postRPC(t *testing.T, cs *chainSet, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/rpc/:chainId", cs.rpcHandler())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestChainSetRoutesByChainID verifies that requests are sent to the instance of the chain in the path in
+// decimal or hex.
+func TestChainSetRoutesByChainID(t *testing.T) {
+	cs := testChainSet()
+	for path, want := range map[string]string{"/rpc/1": "1", "/rpc/10": "10", "/rpc/0xa": "10"} {
+		if got := postRPC(t, cs, path).Body.String(); got != want {
+			t.Fatalf("%s: got %s, want %s", path, got, want)
+		}
+	}
+}
+
+// TestChainSetRejectsUnavailableChains verifies that a chain that failed to start or is not configured
+// returns a JSON-RPC error.
+func TestChainSetRejectsUnavailableChains(t *testing.T) {
+	cs := testChainSet()
+	for path, want := range map[string]string{
+		"/rpc/42161": "chain 42161 is unavailable",
+		"/rpc/5":     "chain 5 is not supported",
+		"/rpc/abc":   "chain abc is not supported",
+	} {
+		var resp struct {
+			Error struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(postRPC(t, cs, path).Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error.Message != want {
+			t.Fatalf("%s: got message %q, want %q", path, resp.Error.Message, want)
+		}
+	}
+}
+
+// TestChainSetReadyReport verifies that the process is ready while any chain is ready and that every chain
+// is included in the report.
+func TestChainSetReadyReport(t *testing.T) {
+	cs := testChainSet()
+	r := cs.report(context.Background(), false)
+	if r.Status != health.StatusOK {
+		t.Fatalf("got status %s, want %s", r.Status, health.StatusOK)
+	}
+	for name, want := range map[string]string{
+		"chains[0]": health.StatusOK,
+		"chains[1]": health.StatusFail,
+		"chains[2]": health.StatusFail,
+	} {
+		if got := r.Components[name].Status; got != want {
+			t.Fatalf("%s: got status %s, want %s", name, got, want)
+		}
+	}
+
+	none := newChainSet()
+	none.add("chains[0]", testInstance(10, false))
+	if r := none.report(context.Background(), false); r.Status != health.StatusFail {
+		t.Fatalf("got status %s, want %s", r.Status, health.StatusFail)
+	}
+}
+
+// TestChainSetRejectsDuplicateChain verifies that a chain can only be served by one entry.
+func TestChainSetRejectsDuplicateChain(t *testing.T) {
+	cs := testChainSet()
+	if err := cs.add("chains[3]", testInstance(10, true)); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
+
+// TestChainRetryStartsFailedChain verifies that a chain that failed to start is retried until it starts and
+// then replaces the failed entry.
+func TestChainRetryStartsFailedChain(t *testing.T) {
+	base := chainRetryBaseBackoff
+	chainRetryBaseBackoff = time.Millisecond
+	t.Cleanup(func() { chainRetryBaseBackoff = base })
+
+	cs := testChainSet()
+	attempts := 0
+	r := newChainRetry("chains[2]", &config.Values{ShutdownTimeout: time.Second}, logr.Discard(), cs)
+	r.start = func(conf *config.Values, logr logr.Logger, sd *shutdown) (*instance, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("dial failed")
+		}
+		return testInstance(42161, true), nil
+	}
+	r.run()
+	select {
+	case <-r.done:
+	case <-time.After(time.Second):
+		t.Fatal("chain not started")
+	}
+	if err := r.stop(context.Background()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("got %d attempts, want 3", attempts)
+	}
+	if got := postRPC(t, cs, "/rpc/42161").Body.String(); got != "42161" {
+		t.Fatalf("got %s, want 42161", got)
+	}
+	if r := cs.report(context.Background(), false); len(r.Components) != 3 {
+		t.Fatalf("got %d components, want 3", len(r.Components))
+	}
+}
+
+// TestChainRetryStop verifies that stop ends the retries of a chain that does not start.
+func TestChainRetryStop(t *testing.T) {
+	base := chainRetryBaseBackoff
+	chainRetryBaseBackoff = time.Millisecond
+	t.Cleanup(func() { chainRetryBaseBackoff = base })
+
+	cs := testChainSet()
+	r := newChainRetry("chains[2]", &config.Values{ShutdownTimeout: time.Second}, logr.Discard(), cs)
+	r.start = func(conf *config.Values, logr logr.Logger, sd *shutdown) (*instance, error) {
+		return nil, errors.New("dial failed")
+	}
+	r.run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.stop(ctx); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	if _, err := cs.lookup("42161"); err == nil {
+		t.Fatal("got nil, want err for a chain that did not start")
+	}
+}
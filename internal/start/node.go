@@ -0,0 +1,40 @@
+package start
+
+import (
+	"context"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/nodepool"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/go-logr/logr"
+)
+
+// dialNode connects to the execution client. A single endpoint is dialed directly so that any scheme supported
+// by go-ethereum can be used. Otherwise calls are sent through a health checked pool of HTTP endpoints.
+func dialNode(conf *config.Values, logr logr.Logger, sd *shutdown) (*rpc.Client, error) {
+	if len(conf.EthClientUrls) == 1 && len(conf.EthClientTraceUrls) == 0 && len(conf.EthClientSubmitUrls) == 0 {
+		return rpc.Dial(conf.EthClientUrl)
+	}
+
+	eps := []nodepool.Endpoint{}
+	for _, url := range conf.EthClientUrls {
+		eps = append(eps, nodepool.Endpoint{URL: url, Role: nodepool.RoleGeneral})
+	}
+	for _, url := range conf.EthClientTraceUrls {
+		eps = append(eps, nodepool.Endpoint{URL: url, Role: nodepool.RoleTrace})
+	}
+	for _, url := range conf.EthClientSubmitUrls {
+		eps = append(eps, nodepool.Endpoint{URL: url, Role: nodepool.RoleSubmit})
+	}
+
+	p, err := nodepool.New(eps)
+	if err != nil {
+		return nil, err
+	}
+	p.UseLogger(logr)
+	p.SetMaxLag(conf.EthClientMaxBlockLag)
+	p.Run()
+	sd.addFunc("node_pool", p.Stop)
+
+	return p.Dial(context.Background())
+}
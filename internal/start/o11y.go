@@ -0,0 +1,93 @@
+package start
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/o11y"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+)
+
+// initO11y sets the global tracer and meter providers. Tracing is only enabled with a collector. Metrics are
+// exported to the collector, the Prometheus handler, or both. The returned handler is nil unless Prometheus
+// is enabled and should be mounted on the main router.
+func initO11y(
+	conf *config.Values,
+	chain *big.Int,
+	address common.Address,
+	sd *shutdown,
+	logr logr.Logger,
+) (http.Handler, error) {
+	opts := &o11y.Opts{
+		ServiceName:     conf.OTELServiceName,
+		CollectorHeader: conf.OTELCollectorHeaders,
+		CollectorUrl:    conf.OTELCollectorUrl,
+		InsecureMode:    conf.OTELInsecureMode,
+
+		ChainID: chain,
+		Address: address,
+	}
+
+	if o11y.IsEnabled(conf.OTELServiceName) {
+		tracerCleanup, err := o11y.InitTracer(opts)
+		if err != nil {
+			return nil, err
+		}
+		sd.add("tracer", tracerCleanup)
+	}
+
+	var promHandler http.Handler
+	if conf.PrometheusEnabled {
+		exporter, handler, err := o11y.NewPrometheusExporter()
+		if err != nil {
+			return nil, err
+		}
+		opts.PrometheusExporter = exporter
+		promHandler = handler
+	}
+
+	if o11y.IsEnabled(conf.OTELServiceName) || conf.PrometheusEnabled {
+		metricsCleanup, err := o11y.InitMetrics(opts)
+		if err != nil {
+			return nil, err
+		}
+		sd.add("metrics", metricsCleanup)
+	}
+
+	if promHandler != nil && conf.PrometheusPort != 0 {
+		serveAdmin(promHandler, conf.PrometheusPort, sd, logr)
+		return nil, nil
+	}
+	return promHandler, nil
+}
+
+// serveAdmin starts a separate HTTP server for the metrics endpoint so that it is not exposed on the public
+// RPC port.
+func serveAdmin(handler http.Handler, port int, sd *shutdown, logr logr.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	sd.add("admin_server", srv.Shutdown)
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logr.Error(err, "admin server error", "port", port)
+		}
+	}()
+}
+
+// mountMetrics adds the Prometheus handler to the router if it is not served on a separate port.
+func mountMetrics(r *gin.Engine, handler http.Handler) {
+	if handler == nil {
+		return
+	}
+	r.GET("/metrics", gin.WrapH(handler))
+}
@@ -0,0 +1,183 @@
+package start
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/client"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/batch"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/checks"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/entities"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/expire"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/gasprice"
+)
+
+// moduleDeps holds the instances used to create each module. It is filled in once they are initialized so
+// that a registry can validate a pipeline before connecting to the node.
+type moduleDeps struct {
+	rep     *entities.Reputation
+	check   *checks.Standalone
+	send    modules.BatchHandlerFunc
+	metrics *modules.Metrics
+}
+
+type expireConfig struct {
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+type gasLimitConfig struct {
+	MaxBatchGasLimit uint64 `mapstructure:"max_batch_gas_limit"`
+}
+
+// pipelineMode returns the mode that defines the pipeline for the given start mode.
+func pipelineMode(mode string) string {
+	if mode == "shadow" {
+		return "private"
+	}
+	return mode
+}
+
+// sendModule returns the name of the module that sends bundles in the given mode.
+func sendModule(mode string) string {
+	if pipelineMode(mode) == "searcher" {
+		return "builder.send_aiop"
+	}
+	return "relay.send_aiop"
+}
+
+func newRegistry(conf *config.Values, mode string, d *moduleDeps) *modules.Registry {
+	reg := modules.NewRegistry()
+	reg.UseMetrics(d.metrics)
+
+	// Client modules
+	reg.AddAiOpHandler("reputation.check_status", func() modules.AiOpHandlerFunc { return d.rep.CheckStatus() })
+	reg.AddAiOpHandler("reputation.validate_op_limit", func() modules.AiOpHandlerFunc {
+		return d.rep.ValidateOpLimit()
+	})
+	reg.AddAiOpHandler("reputation.check_aggregator", func() modules.AiOpHandlerFunc {
+		return d.rep.CheckAggregator()
+	})
+	reg.AddAiOpHandler("reputation.inc_ops_seen", func() modules.AiOpHandlerFunc { return d.rep.IncOpsSeen() })
+	reg.AddAiOpHandler("checks.validate_op_values", func() modules.AiOpHandlerFunc {
+		return d.check.ValidateOpValues()
+	})
+	reg.AddAiOpHandler("checks.simulate_op", func() modules.AiOpHandlerFunc { return d.check.SimulateOp() })
+
+	// Bundler modules
+	modules.RegisterBatchHandler(
+		reg,
+		"expire.drop_expired",
+		expireConfig{TTL: conf.MaxOpTTL},
+		func(cfg expireConfig) (modules.BatchHandlerFunc, error) {
+			return expire.New(cfg.TTL).DropExpired(), nil
+		},
+	)
+	reg.AddBatchHandler("gasprice.sort_by_gas_price", gasprice.SortByGasPrice)
+	reg.AddBatchHandler("gasprice.filter_underpriced", gasprice.FilterUnderpriced)
+	reg.AddBatchHandler("batch.sort_by_nonce", batch.SortByNonce)
+	modules.RegisterBatchHandler(
+		reg,
+		"batch.maintain_gas_limit",
+		gasLimitConfig{MaxBatchGasLimit: conf.MaxBatchGasLimit.Uint64()},
+		func(cfg gasLimitConfig) (modules.BatchHandlerFunc, error) {
+			if cfg.MaxBatchGasLimit == 0 {
+				return nil, fmt.Errorf("max_batch_gas_limit must be greater than 0")
+			}
+			return batch.MaintainGasLimit(big.NewInt(0).SetUint64(cfg.MaxBatchGasLimit)), nil
+		},
+	)
+	reg.AddBatchHandler("checks.code_hashes", func() modules.BatchHandlerFunc { return d.check.CodeHashes() })
+	reg.AddBatchHandler("checks.paymaster_deposit", func() modules.BatchHandlerFunc {
+		return d.check.PaymasterDeposit()
+	})
+	reg.AddBatchHandler("checks.group_by_aggregator", func() modules.BatchHandlerFunc {
+		return d.check.GroupByAggregator()
+	})
+	reg.AddBatchHandler("checks.simulate_batch", func() modules.BatchHandlerFunc { return d.check.SimulateBatch() })
+	reg.AddBatchHandler("checks.clean", func() modules.BatchHandlerFunc { return d.check.Clean() })
+	reg.AddBatchHandler("reputation.inc_ops_included", func() modules.BatchHandlerFunc {
+		return d.rep.IncOpsIncluded()
+	})
+	reg.AddBatchHandler(sendModule(mode), func() modules.BatchHandlerFunc { return d.send })
+	reg.RequireBatchHandler(sendModule(mode))
+
+	return reg
+}
+
+// prefixErr adds a prefix to each joined error so that they are reported per line.
+func prefixErr(prefix string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs error
+		for _, e := range joined.Unwrap() {
+			errs = errors.Join(errs, prefixErr(prefix, e))
+		}
+		return errs
+	}
+	return fmt.Errorf("%s: %w", prefix, err)
+}
+
+// pipelineErr prefixes each error from a registry with the mode.
+func pipelineErr(mode string, err error) error {
+	return prefixErr(mode+" pipeline", err)
+}
+
+// validatePipeline checks the configured pipeline for a mode without initializing any module.
+func validatePipeline(conf *config.Values, mode string) error {
+	p, ok := conf.Pipelines[pipelineMode(mode)]
+	if !ok {
+		return fmt.Errorf("no module pipeline for %s mode", mode)
+	}
+
+	reg := newRegistry(conf, mode, &moduleDeps{})
+	return pipelineErr(mode, errors.Join(
+		reg.ValidateAiOpHandlers("client", p.Client),
+		reg.ValidateBatchHandlers(p.Batch),
+		reg.ValidateAiOpHandlers("revalidation", p.Revalidation),
+		reg.ValidateSettledHandlers(p.Settled),
+	))
+}
+
+// usePipeline builds the configured pipeline for a mode and sets the modules on the Client and Bundler. Any
+// extra batch modules are appended to the end of the batch pipeline.
+func usePipeline(
+	conf *config.Values,
+	mode string,
+	d *moduleDeps,
+	c *client.Client,
+	b *bundler.Bundler,
+	extra ...modules.BatchHandlerFunc,
+) error {
+	p := conf.Pipelines[pipelineMode(mode)]
+	reg := newRegistry(conf, mode, d)
+
+	clientModules, err := reg.AiOpHandlers("client", p.Client)
+	if err != nil {
+		return pipelineErr(mode, err)
+	}
+	batchModules, err := reg.BatchHandlers(p.Batch)
+	if err != nil {
+		return pipelineErr(mode, err)
+	}
+	revalidationModules, err := reg.AiOpHandlers("revalidation", p.Revalidation)
+	if err != nil {
+		return pipelineErr(mode, err)
+	}
+	settledModules, err := reg.SettledHandlers(p.Settled)
+	if err != nil {
+		return pipelineErr(mode, err)
+	}
+
+	c.UseModules(clientModules...)
+	b.UseModules(append(batchModules, extra...)...)
+	b.UseRevalidationModules(revalidationModules...)
+	b.UseSettledModules(settledModules...)
+	return nil
+}
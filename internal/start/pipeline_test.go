@@ -0,0 +1,35 @@
+package start
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+)
+
+// TestDefaultPipelinesAreValid verifies that the default pipeline for each mode only uses registered modules.
+func TestDefaultPipelinesAreValid(t *testing.T) {
+	conf := &config.Values{
+		MaxBatchGasLimit: big.NewInt(18000000),
+		MaxOpTTL:         180 * time.Second,
+		Pipelines:        config.DefaultPipelines(),
+	}
+	for _, mode := range []string{"private", "searcher", "shadow"} {
+		if err := validatePipeline(conf, mode); err != nil {
+			t.Fatalf("%s: got err %v, want nil", mode, err)
+		}
+	}
+}
+
+// TestPipelineRequiresSendModule verifies that a pipeline using another mode's send module is rejected.
+func TestPipelineRequiresSendModule(t *testing.T) {
+	conf := &config.Values{
+		MaxBatchGasLimit: big.NewInt(18000000),
+		Pipelines:        config.DefaultPipelines(),
+	}
+	conf.Pipelines["searcher"].Batch = conf.Pipelines["private"].Batch
+	if err := validatePipeline(conf, "searcher"); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
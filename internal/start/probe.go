@@ -0,0 +1,125 @@
+package start
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/nodepool"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/probe"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/go-logr/logr"
+)
+
+type nodeReport struct {
+	url    string
+	role   nodepool.Role
+	report *probe.Report
+}
+
+type nodeReports []nodeReport
+
+// runProbes checks the capabilities of every configured node endpoint except submission endpoints, which are
+// often private relays that do not serve reads. Each endpoint is given the default RPC timeout to respond.
+func runProbes(ctx context.Context, conf *config.Values, maxAge time.Duration) nodeReports {
+	opts := probe.Opts{
+		ChainID:       conf.EthClientChainID,
+		AiMiddlewares: conf.SupportedAiMiddlewares,
+		MaxBlockAge:   maxAge,
+		JSTracer:      conf.NativeBundlerCollectorTracer == "" || conf.NativeBundlerExecutorTracer == "",
+		Tracer:        conf.NativeBundlerCollectorTracer,
+	}
+
+	out := nodeReports{}
+	run := func(url string, role nodepool.Role, trace bool) {
+		o := opts
+		o.Trace = trace
+		nr := nodeReport{url: nodepool.RedactURL(url), role: role}
+
+		ctx := ctx
+		if conf.RPCTimeouts != nil && conf.RPCTimeouts.Default > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, conf.RPCTimeouts.Default)
+			defer cancel()
+		}
+		rpc, err := rpc.DialContext(ctx, url)
+		if err != nil {
+			nr.report = &probe.Report{Results: []probe.Result{{Capability: probe.ChainID, Err: err}}}
+		} else {
+			nr.report = probe.Run(ctx, rpc, o)
+			rpc.Close()
+		}
+		out = append(out, nr)
+	}
+	for _, url := range conf.EthClientUrls {
+		run(url, nodepool.RoleGeneral, len(conf.EthClientTraceUrls) == 0)
+	}
+	for _, url := range conf.EthClientTraceUrls {
+		run(url, nodepool.RoleTrace, true)
+	}
+	return out
+}
+
+// requiredErr returns an error listing every failed capability that the bundler cannot run without. Skipped
+// checks are left out since the capability they depend on is already listed. A node that is out of sync is not
+// an error since the sync guard pauses the bundler until it catches up.
+func (n nodeReports) requiredErr() error {
+	msgs := []string{}
+	for _, nr := range n {
+		for _, res := range nr.report.Results {
+			if res.Err == nil || res.Capability == probe.StateOverrides || res.Capability == probe.Synced || errors.Is(res.Err, probe.ErrSkipped) {
+				continue
+			}
+			msgs = append(msgs, fmt.Sprintf("%s node %s: %s: %s", nr.role, nr.url, res.Capability, res.Err))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("node capability probe failed:\n  %s", strings.Join(msgs, "\n  "))
+}
+
+// stateOverridesErr returns an error if any general node does not support state overrides in eth_call. Gas
+// estimation depends on this and is disabled instead of refusing to start.
+func (n nodeReports) stateOverridesErr() error {
+	for _, nr := range n {
+		if nr.role != nodepool.RoleGeneral {
+			continue
+		}
+		if err := nr.report.Err(probe.StateOverrides); err != nil {
+			return fmt.Errorf("%s node %s does not support eth_call state overrides: %w", nr.role, nr.url, err)
+		}
+	}
+	return nil
+}
+
+// probeNodes runs the capability probe on startup. An error is returned if a required capability is missing.
+// Missing optional capabilities are logged and the dependent modules should be disabled by the caller.
+func probeNodes(
+	ctx context.Context,
+	conf *config.Values,
+	maxAge time.Duration,
+	logr logr.Logger,
+) (nodeReports, error) {
+	if conf.SkipNodeProbe {
+		return nodeReports{}, nil
+	}
+
+	l := logr.WithName("node_probe")
+	n := runProbes(ctx, conf, maxAge)
+	if err := n.requiredErr(); err != nil {
+		return nil, err
+	}
+	if err := n.stateOverridesErr(); err != nil {
+		l.Error(err, "gas estimation disabled")
+	}
+	for _, nr := range n {
+		if err := nr.report.Err(probe.Synced); err != nil {
+			l.Error(err, "node out of sync", "role", nr.role, "url", nr.url)
+		}
+	}
+	return n, nil
+}
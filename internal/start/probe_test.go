@@ -0,0 +1,35 @@
+package start
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/jsonrpc"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestRunProbesTimeout verifies that a node that does not respond fails the probe once the RPC timeout is
+// reached.
+func TestRunProbesTimeout(t *testing.T) {
+	n := testutils.NewNodeMock(testutils.MethodMocks{})
+	n.SetHang(true)
+	t.Cleanup(n.Close)
+
+	conf := &config.Values{
+		EthClientUrls:          []string{n.URL},
+		EthClientChainID:       testutils.ChainID,
+		SupportedAiMiddlewares: []common.Address{testutils.ValidAddress1},
+		RPCTimeouts:            &jsonrpc.Timeouts{Default: 10 * time.Millisecond},
+	}
+	start := time.Now()
+	nodes := runProbes(context.Background(), conf, time.Minute)
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("got probe duration %s, want under 1s", d)
+	}
+	if err := nodes.requiredErr(); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
@@ -0,0 +1,54 @@
+package start
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/chains"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/gas"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/gasprice"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// chainProfile returns the built-in profile of the chain with the overrides from the config.
+func chainProfile(conf *config.Values, chain *big.Int) *chains.Profile {
+	return chains.Lookup(chain).Apply(conf.ChainProfile)
+}
+
+// maxBlockAge returns the configured max age of the latest block or the default from the chain profile if
+// not set.
+func maxBlockAge(conf *config.Values, profile *chains.Profile) time.Duration {
+	if conf.MaxBlockAge > 0 {
+		return conf.MaxBlockAge
+	}
+	return profile.MaxBlockAge
+}
+
+// newOverhead returns the gas overhead with preVerificationGas calculated by the L1 fee model of the chain.
+func newOverhead(rpc *rpc.Client, chain *big.Int, ep common.Address, profile *chains.Profile) *gas.Overhead {
+	ov := gas.NewDefaultOverhead()
+	switch profile.L1FeeModel {
+	case chains.ArbL1Fee:
+		ov.SetCalcPreVerificationGasFunc(gas.CalcArbitrumPVGWithEthClient(rpc, ep))
+	case chains.OpL1Fee:
+		ov.SetCalcPreVerificationGasFunc(gas.CalcOptimismPVGWithEthClient(rpc, chain, ep))
+	}
+	ov.SetPreVerificationGasBufferFactor(profile.PVGBufferFactor)
+	return ov
+}
+
+// useGasPrices sets the gas price functions and interval of the Bundler from the chain profile. Legacy
+// transactions are sent if the profile has LegacyFees set, since the Bundler only uses a gas tip when a
+// basefee is returned.
+func useGasPrices(b *bundler.Bundler, eth *ethclient.Client, profile *chains.Profile) {
+	if !profile.LegacyFees {
+		b.SetGetBaseFeeFunc(gasprice.GetBaseFeeWithEthClient(eth))
+		b.SetGetGasTipFunc(gasprice.GetGasTipWithMin(gasprice.GetGasTipWithEthClient(eth), profile.MinPriorityFee))
+	}
+	b.SetGetLegacyGasPriceFunc(gasprice.GetLegacyGasPriceWithEthClient(eth))
+	b.SetInterval(profile.PollInterval())
+}
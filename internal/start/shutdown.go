@@ -0,0 +1,134 @@
+package start
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+)
+
+// stepTimeoutAfterDeadline is the time given to each step that is reached after the shutdown deadline has passed.
+// This allows the DB and connections to still be closed and telemetry to be flushed if an earlier step is slow.
+var stepTimeoutAfterDeadline = 2 * time.Second
+
+type shutdownStep struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// shutdown runs cleanup steps in the reverse order they were added, similar to defer. All steps should complete
+// within the timeout. Once it has passed, each remaining step is still run with stepTimeoutAfterDeadline.
+type shutdown struct {
+	logger  logr.Logger
+	timeout time.Duration
+	steps   []shutdownStep
+}
+
+func newShutdown(logger logr.Logger, timeout time.Duration) *shutdown {
+	return &shutdown{
+		logger:  logger.WithName("shutdown"),
+		timeout: timeout,
+		steps:   []shutdownStep{},
+	}
+}
+
+// add registers a cleanup step.
+func (s *shutdown) add(name string, fn func(ctx context.Context) error) {
+	s.steps = append(s.steps, shutdownStep{name, fn})
+}
+
+// addFunc registers a cleanup step that cannot fail.
+func (s *shutdown) addFunc(name string, fn func()) {
+	s.add(name, func(ctx context.Context) error {
+		fn()
+		return nil
+	})
+}
+
+// addDrain registers a cleanup step that waits for in-flight work to finish. It is given half of the time
+// remaining so that the steps after it can still run if the work does not finish in time.
+func (s *shutdown) addDrain(name string, fn func(ctx context.Context) error) {
+	s.add(name, func(ctx context.Context) error {
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Until(deadline)/2)
+			defer cancel()
+		}
+		return fn(ctx)
+	})
+}
+
+// run executes all registered steps.
+func (s *shutdown) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	s.runContext(ctx)
+}
+
+// runContext executes all registered steps within the deadline of ctx. It is used to run the steps of a
+// single chain as one step of the process. A step that is still running at the deadline is abandoned and the
+// remaining steps are run with a short deadline of their own.
+func (s *shutdown) runContext(ctx context.Context) {
+	start := time.Now()
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			stepCtx, cancel := context.WithTimeout(context.Background(), stepTimeoutAfterDeadline)
+			s.runStep(stepCtx, s.steps[i])
+			cancel()
+		} else {
+			s.runStep(ctx, s.steps[i])
+		}
+	}
+	s.logger.Info("shutdown complete", "duration", time.Since(start))
+}
+
+// runStep executes a single step and returns once it is done or ctx is done.
+func (s *shutdown) runStep(ctx context.Context, step shutdownStep) {
+	done := make(chan error, 1)
+	go func() {
+		done <- step.fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			s.logger.Error(err, "shutdown step error", "step", step.name)
+		}
+	case <-ctx.Done():
+		s.logger.Error(ctx.Err(), "shutdown deadline exceeded", "step", step.name)
+	}
+}
+
+// serve starts the HTTP server and blocks until it fails or a SIGINT or SIGTERM is received. On a signal, the
+// server is registered to stop accepting new requests and drain in-flight requests as the first shutdown
+// step.
+func serve(r *gin.Engine, port int, sd *shutdown, logger logr.Logger) error {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: r,
+	}
+	sd.add("http_server", srv.Shutdown)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received")
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
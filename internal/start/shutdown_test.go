@@ -0,0 +1,66 @@
+package start
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// TestShutdownRunsInReverseOrder verifies that steps are run in the reverse order they were added.
+func TestShutdownRunsInReverseOrder(t *testing.T) {
+	sd := newShutdown(logr.Discard(), time.Second)
+	order := []string{}
+	for _, name := range []string{"db", "bundler", "http_server"} {
+		name := name
+		sd.addFunc(name, func() { order = append(order, name) })
+	}
+	sd.run()
+
+	if want := []string{"http_server", "bundler", "db"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("got %v, want %v", order, want)
+	}
+}
+
+// TestShutdownDeadline verifies that the remaining steps are still run once the deadline is exceeded and that
+// each is given its own short deadline.
+func TestShutdownDeadline(t *testing.T) {
+	sd := newShutdown(logr.Discard(), 10*time.Millisecond)
+	called := false
+	sd.addFunc("db", func() { called = true })
+	var deadline time.Time
+	sd.add("telemetry", func(ctx context.Context) error {
+		deadline, _ = ctx.Deadline()
+		return nil
+	})
+	sd.add("bundler", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	sd.run()
+
+	if !called {
+		t.Fatal("got db step skipped, want called")
+	} else if time.Until(deadline) <= 0 {
+		t.Fatal("got telemetry step with an expired deadline, want a fresh one")
+	}
+}
+
+// TestShutdownDrainLeavesTimeForLaterSteps verifies that a drain step that does not finish still leaves time
+// for the steps after it.
+func TestShutdownDrainLeavesTimeForLaterSteps(t *testing.T) {
+	sd := newShutdown(logr.Discard(), 100*time.Millisecond)
+	called := false
+	sd.addFunc("db", func() { called = true })
+	sd.addDrain("relayer", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	sd.run()
+
+	if !called {
+		t.Fatal("got db step skipped, want called")
+	}
+}
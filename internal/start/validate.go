@@ -0,0 +1,86 @@
+package start
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
+	"github.com/go-logr/logr"
+	"github.com/spf13/viper"
+)
+
+// validateModes are the modes checked by ValidateConfig if no mode is set.
+var validateModes = []string{"private", "searcher", "shadow"}
+
+// ValidateConfig loads the config and checks the module pipeline that each mode resolves without connecting
+// to a node. If a mode is set, only that mode is checked. An error is returned with every invalid value.
+func ValidateConfig(w io.Writer) error {
+	mode := viper.GetString("mode")
+	if mode == "multichain" {
+		return validateChains(w)
+	}
+	modes := validateModes
+	if mode != "" {
+		modes = []string{}
+		for _, m := range validateModes {
+			if m == mode {
+				modes = append(modes, m)
+			}
+		}
+		if len(modes) == 0 {
+			return fmt.Errorf("%q mode not supported", mode)
+		}
+	}
+
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, mode := range modes {
+		errs = errors.Join(errs, validatePipeline(conf, mode))
+	}
+	if errs != nil {
+		return errs
+	}
+
+	for _, d := range conf.Deprecations {
+		fmt.Fprintf(w, "warning: %s\n", d)
+	}
+	fmt.Fprintln(w, "config ok")
+	return nil
+}
+
+// validateChains loads the config of each chain in multichain mode and checks the module pipeline of chainMode
+// for each entry.
+func validateChains(w io.Writer) error {
+	chains, err := config.LoadChains()
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for i, conf := range chains {
+		errs = errors.Join(errs, prefixErr(fmt.Sprintf("chains[%d]", i), validatePipeline(conf, chainMode)))
+	}
+	if errs != nil {
+		return errs
+	}
+
+	for i, conf := range chains {
+		for _, d := range conf.Deprecations {
+			fmt.Fprintf(w, "warning: chains[%d]: %s\n", i, d)
+		}
+	}
+	fmt.Fprintf(w, "config ok: %d chains\n", len(chains))
+	return nil
+}
+
+// logDeprecations logs each deprecated value that is set in the config.
+func logDeprecations(conf *config.Values, l logr.Logger) {
+	for _, d := range conf.Deprecations {
+		l.Info("deprecated config value: " + d)
+	}
+}
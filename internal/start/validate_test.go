@@ -0,0 +1,68 @@
+package start
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testPrivateKey = "0000000000000000000000000000000000000000000000000000000000000001"
+
+func writeConfig(t *testing.T, mode string, content string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("config_file", path)
+	viper.Set("mode", mode)
+}
+
+// TestValidateConfigShadowMode verifies that shadow mode is checked with the pipeline that it resolves to.
+func TestValidateConfigShadowMode(t *testing.T) {
+	writeConfig(t, "shadow", `
+eth_client_url: http://a
+private_key: "`+testPrivateKey+`"
+private_batch_modules: [builder.send_aiop]
+`)
+
+	err := ValidateConfig(io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "shadow pipeline") {
+		t.Fatalf("got err %v, want error for the shadow pipeline", err)
+	}
+}
+
+// TestValidateConfigChains verifies that the pipeline of each chain entry is checked in multichain mode.
+func TestValidateConfigChains(t *testing.T) {
+	writeConfig(t, "multichain", `
+private_key: "`+testPrivateKey+`"
+chains:
+  - eth_client_url: http://a
+  - eth_client_url: http://b
+    private_batch_modules: [not.a_module, relay.send_aiop]
+`)
+
+	err := ValidateConfig(io.Discard)
+	if err == nil {
+		t.Fatal("got nil, want err")
+	} else if msg := err.Error(); !strings.Contains(msg, "chains[1]") || strings.Contains(msg, "chains[0]") {
+		t.Fatalf("got err %v, want error for chains[1] only", err)
+	}
+}
+
+// TestValidateConfigUnknownMode verifies that a mode that cannot be started is rejected.
+func TestValidateConfigUnknownMode(t *testing.T) {
+	writeConfig(t, "archive", `
+eth_client_url: http://a
+private_key: "`+testPrivateKey+`"
+`)
+
+	if err := ValidateConfig(io.Discard); err == nil {
+		t.Fatal("got nil, want err")
+	}
+}
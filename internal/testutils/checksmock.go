@@ -1,6 +1,7 @@
 package testutils
 
 import (
+	"context"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -14,8 +15,8 @@ func MockGetCodeZero(addr common.Address) ([]byte, error) {
 	return []byte{}, nil
 }
 
-func GetMockBaseFeeFunc(val *big.Int) func() (*big.Int, error) {
-	return func() (*big.Int, error) {
+func GetMockBaseFeeFunc(val *big.Int) func(ctx context.Context) (*big.Int, error) {
+	return func(ctx context.Context) (*big.Int, error) {
 		return val, nil
 	}
 }
@@ -0,0 +1,210 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// MethodFunc computes the result of a mocked method from its params. It can return a *MockError to respond
+// with a JSON-RPC error.
+type MethodFunc func(params []json.RawMessage) (any, error)
+
+// MockError is a JSON-RPC error returned by a MethodFunc.
+type MockError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *MockError) Error() string {
+	return e.Message
+}
+
+// NodeMock is a stand-in for a node that counts the calls to each method, including calls in a batch. Methods
+// are answered from a set of MethodMocks, where a value can also be a MethodFunc. The eth_blockNumber method
+// returns a head that can be moved by the test unless it is mocked. The node can also be set to fail with an
+// HTTP status or to hang until the request is cancelled.
+type NodeMock struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	mocks    MethodMocks
+	head     uint64
+	status   int
+	hang     bool
+	requests int
+	calls    map[string]int
+	done     chan struct{}
+	closed   sync.Once
+}
+
+// NewNodeMock starts a NodeMock with a head at block 1.
+func NewNodeMock(mocks MethodMocks) *NodeMock {
+	n := &NodeMock{
+		mocks:  MethodMocks{},
+		head:   1,
+		status: http.StatusOK,
+		calls:  make(map[string]int),
+		done:   make(chan struct{}),
+	}
+	for method, mock := range mocks {
+		n.mocks[method] = mock
+	}
+	n.Server = httptest.NewServer(http.HandlerFunc(n.serve))
+	return n
+}
+
+type nodeReq struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func (n *NodeMock) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	reqs := []nodeReq{}
+	if batch {
+		err = json.Unmarshal(body, &reqs)
+	} else {
+		reqs = append(reqs, nodeReq{})
+		err = json.Unmarshal(body, &reqs[0])
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	n.requests++
+	for _, req := range reqs {
+		n.calls[req.Method]++
+	}
+	status, hang := n.status, n.hang
+	n.mu.Unlock()
+	if hang {
+		select {
+		case <-r.Context().Done():
+		case <-n.done:
+		}
+		return
+	}
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	res := []map[string]any{}
+	for _, req := range reqs {
+		item, ok := n.respond(req)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("method not in mocks: %s", req.Method)))
+			return
+		}
+		res = append(res, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		_ = json.NewEncoder(w).Encode(res)
+	} else {
+		_ = json.NewEncoder(w).Encode(res[0])
+	}
+}
+
+func (n *NodeMock) respond(req nodeReq) (map[string]any, bool) {
+	n.mu.Lock()
+	mock, ok := n.mocks[req.Method]
+	if !ok && req.Method == "eth_blockNumber" {
+		mock, ok = hexutil.EncodeUint64(n.head), true
+	}
+	n.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+	fn, isFunc := mock.(MethodFunc)
+	if !isFunc {
+		res["result"] = mock
+		return res, true
+	}
+
+	result, err := fn(req.Params)
+	if mErr, isMockErr := err.(*MockError); isMockErr {
+		res["error"] = mErr
+	} else if err != nil {
+		res["error"] = &MockError{Code: -32000, Message: err.Error()}
+	} else {
+		res["result"] = result
+	}
+	return res, true
+}
+
+// SetMock sets the result for a method. The result can also be a MethodFunc.
+func (n *NodeMock) SetMock(method string, result any) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.mocks[method] = result
+}
+
+// SetHead sets the block number returned by eth_blockNumber.
+func (n *NodeMock) SetHead(bn uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.head = bn
+}
+
+// SetStatus sets the HTTP status for every request. Any status other than 200 fails the request.
+func (n *NodeMock) SetStatus(status int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.status = status
+}
+
+// SetHang sets whether requests should block until they are cancelled or the NodeMock is closed.
+func (n *NodeMock) SetHang(hang bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.hang = hang
+}
+
+// Requests returns the number of HTTP requests received. A batch of calls counts as one request.
+func (n *NodeMock) Requests() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.requests
+}
+
+// Calls returns the number of calls received for a method, including calls in a batch.
+func (n *NodeMock) Calls(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.calls[method]
+}
+
+// Close releases any hanging requests and shuts down the server.
+func (n *NodeMock) Close() {
+	n.closed.Do(func() {
+		close(n.done)
+		n.Server.Close()
+	})
+}
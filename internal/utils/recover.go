@@ -0,0 +1,14 @@
+package utils
+
+import "fmt"
+
+// Recover calls fn and returns a panic as an error. Background loops use it so that a panic in one iteration
+// is logged instead of crashing the process along with every other chain it serves.
+func Recover(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,21 @@
+package utils_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
+)
+
+// TestRecoverReturnsPanicAsError verifies that a panic is returned as an error and other errors are passed
+// through.
+func TestRecoverReturnsPanicAsError(t *testing.T) {
+	if err := utils.Recover(func() error { panic("boom") }); err == nil || err.Error() != "panic: boom" {
+		t.Fatalf("got %v, want panic: boom", err)
+	}
+
+	want := errors.New("failed")
+	if err := utils.Recover(func() error { return want }); err != want {
+		t.Fatalf("got %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout returns a copy of parent that is cancelled after timeout. A timeout of 0 or less has no deadline
+// and the copy is only cancelled by the returned function or by parent.
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
@@ -0,0 +1,67 @@
+// Package aggregator provides helpers for interacting with signature aggregator contracts that are used by
+// AiOperations in place of individual signature validation.
+package aggregator
+
+import (
+	"context"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/methods"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ValidateAiOpSignature calls validateAiOpSignature on the aggregator for a single AiOperation. A revert
+// means the signature is invalid. Otherwise it returns the value that should replace the AiOperation's
+// signature when it is included in a bundle.
+func ValidateAiOpSignature(
+	ctx context.Context,
+	eth *ethclient.Client,
+	aggregator common.Address,
+	op *aiop.AiOperation,
+) ([]byte, error) {
+	args, err := methods.ValidateAiOpSignatureMethod.Inputs.Pack(aimiddleware.AiOperation(*op))
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := eth.CallContract(ctx, ethereum.CallMsg{
+		To:   &aggregator,
+		Data: append(methods.ValidateAiOpSignatureMethod.ID, args...),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return methods.DecodeValidateAiOpSignatureOutput(out)
+}
+
+// AggregateSignatures calls aggregateSignatures on the aggregator and returns a single signature for the
+// given batch of AiOperations.
+func AggregateSignatures(
+	ctx context.Context,
+	eth *ethclient.Client,
+	aggregator common.Address,
+	ops []*aiop.AiOperation,
+) ([]byte, error) {
+	abiOps := []aimiddleware.AiOperation{}
+	for _, op := range ops {
+		abiOps = append(abiOps, aimiddleware.AiOperation(*op))
+	}
+	args, err := methods.AggregateSignaturesMethod.Inputs.Pack(abiOps)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := eth.CallContract(ctx, ethereum.CallMsg{
+		To:   &aggregator,
+		Data: append(methods.AggregateSignaturesMethod.ID, args...),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return methods.DecodeAggregateSignaturesOutput(out)
+}
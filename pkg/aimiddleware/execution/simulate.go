@@ -30,7 +30,7 @@ type SimulateInput struct {
 	Data   []byte
 }
 
-func SimulateHandleOp(in *SimulateInput) (*reverts.ExecutionResultRevert, error) {
+func SimulateHandleOp(ctx context.Context, in *SimulateInput) (*reverts.ExecutionResultRevert, error) {
 	ep, err := aimiddleware.NewAimiddleware(in.AiMiddleware, ethclient.NewClient(in.Rpc))
 	if err != nil {
 		return nil, err
@@ -51,7 +51,7 @@ func SimulateHandleOp(in *SimulateInput) (*reverts.ExecutionResultRevert, error)
 		To:   in.AiMiddleware,
 		Data: tx.Data(),
 	}
-	err = in.Rpc.CallContext(context.Background(), nil, "eth_call", &req, "latest", in.Sos)
+	err = in.Rpc.CallContext(ctx, nil, "eth_call", &req, "latest", in.Sos)
 
 	sim, simErr := reverts.NewExecutionResult(err)
 	if simErr != nil {
@@ -71,7 +71,7 @@ func parseAiOperationEvent(
 	return ev, nil
 }
 
-func TraceSimulateHandleOp(in *TraceInput) (*TraceOutput, error) {
+func TraceSimulateHandleOp(ctx context.Context, in *TraceInput) (*TraceOutput, error) {
 	ep, err := aimiddleware.NewAimiddleware(in.AiMiddleware, ethclient.NewClient(in.Rpc))
 	if err != nil {
 		return nil, err
@@ -107,7 +107,7 @@ func TraceSimulateHandleOp(in *TraceInput) (*TraceOutput, error) {
 		Tracer:         t,
 		StateOverrides: state.WithMaxBalanceOverride(common.HexToAddress("0x"), in.Sos),
 	}
-	if err := in.Rpc.CallContext(context.Background(), &res, "debug_traceCall", &req, "latest", &opts); err != nil {
+	if err := in.Rpc.CallContext(ctx, &res, "debug_traceCall", &req, "latest", &opts); err != nil {
 		return nil, err
 	}
 	outErr, err := errors.ParseHexToRpcDataError(res.Output)
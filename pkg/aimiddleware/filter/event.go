@@ -11,6 +11,7 @@ import (
 )
 
 func filterAiOperationEvent(
+	ctx context.Context,
 	eth *ethclient.Client,
 	aiOpHash string,
 	aiMiddleware common.Address,
@@ -20,7 +21,7 @@ func filterAiOperationEvent(
 	if err != nil {
 		return nil, err
 	}
-	bn, err := eth.BlockNumber(context.Background())
+	bn, err := eth.BlockNumber(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +33,7 @@ func filterAiOperationEvent(
 	}
 
 	return ep.FilterAiOperationEvent(
-		&bind.FilterOpts{Start: startBlk.Uint64()},
+		&bind.FilterOpts{Start: startBlk.Uint64(), Context: ctx},
 		[][32]byte{common.HexToHash(aiOpHash)},
 		[]common.Address{},
 		[]common.Address{},
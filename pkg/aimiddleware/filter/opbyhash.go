@@ -26,6 +26,7 @@ type HashLookupResult struct {
 // GetAiOperationByHash filters the AiMiddleware contract for AiOperationEvents and returns the
 // corresponding AiOp from a given aiOpHash.
 func GetAiOperationByHash(
+	ctx context.Context,
 	eth *ethclient.Client,
 	aiOpHash string,
 	aiMiddleware common.Address,
@@ -37,17 +38,17 @@ func GetAiOperationByHash(
 		return nil, errors.New("Missing/invalid aiOpHash")
 	}
 
-	it, err := filterAiOperationEvent(eth, aiOpHash, aiMiddleware, blkRange)
+	it, err := filterAiOperationEvent(ctx, eth, aiOpHash, aiMiddleware, blkRange)
 	if err != nil {
 		return nil, err
 	}
 
 	if it.Next() {
-		receipt, err := eth.TransactionReceipt(context.Background(), it.Event.Raw.TxHash)
+		receipt, err := eth.TransactionReceipt(ctx, it.Event.Raw.TxHash)
 		if err != nil {
 			return nil, err
 		}
-		tx, isPending, err := eth.TransactionByHash(context.Background(), it.Event.Raw.TxHash)
+		tx, isPending, err := eth.TransactionByHash(ctx, it.Event.Raw.TxHash)
 		if err != nil {
 			return nil, err
 		} else if isPending {
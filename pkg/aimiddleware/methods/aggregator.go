@@ -0,0 +1,70 @@
+package methods
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	ValidateAiOpSignatureMethod = abi.NewMethod(
+		"validateAiOpSignature",
+		"validateAiOpSignature",
+		abi.Function,
+		"view",
+		false,
+		false,
+		abi.Arguments{
+			{Name: "aiOp", Type: aiop.AiOpType},
+		},
+		abi.Arguments{
+			{Name: "sigForAiOp", Type: bytes},
+		},
+	)
+	ValidateAiOpSignatureSelector = hexutil.Encode(ValidateAiOpSignatureMethod.ID)
+
+	AggregateSignaturesMethod = abi.NewMethod(
+		"aggregateSignatures",
+		"aggregateSignatures",
+		abi.Function,
+		"view",
+		false,
+		false,
+		abi.Arguments{
+			{Name: "aiOps", Type: aiop.AiOpArr},
+		},
+		abi.Arguments{
+			{Name: "aggregatedSignature", Type: bytes},
+		},
+	)
+	AggregateSignaturesSelector = hexutil.Encode(AggregateSignaturesMethod.ID)
+)
+
+func decodeBytesOutput(name string, method abi.Method, out []byte) ([]byte, error) {
+	args, err := method.Outputs.Unpack(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s: invalid args length: expected 1, got %d", name, len(args))
+	}
+
+	sig, ok := args[0].([]byte)
+	if !ok {
+		return nil, errors.New(name + ": cannot assert type: output is not of type []byte")
+	}
+	return sig, nil
+}
+
+// DecodeValidateAiOpSignatureOutput returns the sigForAiOp value from a call to validateAiOpSignature.
+func DecodeValidateAiOpSignatureOutput(out []byte) ([]byte, error) {
+	return decodeBytesOutput("validateAiOpSignature", ValidateAiOpSignatureMethod, out)
+}
+
+// DecodeAggregateSignaturesOutput returns the aggregatedSignature value from a call to aggregateSignatures.
+func DecodeAggregateSignaturesOutput(out []byte) ([]byte, error) {
+	return decodeBytesOutput("aggregateSignatures", AggregateSignaturesMethod, out)
+}
@@ -25,11 +25,20 @@ type StakeInfo struct {
 	UnstakeDelaySec *big.Int `json:"unstakeDelaySec"`
 }
 
+type AggregatorStakeInfo struct {
+	Aggregator common.Address `json:"aggregator"`
+	StakeInfo  *StakeInfo     `json:"stakeInfo"`
+}
+
 type ValidationResultRevert struct {
 	ReturnInfo    *ReturnInfo
 	SenderInfo    *StakeInfo
 	FactoryInfo   *StakeInfo
 	PaymasterInfo *StakeInfo
+
+	// AggregatorInfo is only set if the simulation reverted with ValidationResultWithAggregation. Otherwise it
+	// is nil.
+	AggregatorInfo *AggregatorStakeInfo
 }
 
 var (
@@ -45,6 +54,10 @@ var (
 		{Name: "stake", Type: "uint256"},
 		{Name: "unstakeDelaySec", Type: "uint256"},
 	}
+	aggregatorStakeInfoType = []abi.ArgumentMarshaling{
+		{Name: "aggregator", Type: "address"},
+		{Name: "stakeInfo", Type: "tuple", Components: stakeInfoType},
+	}
 )
 
 func validationResult() abi.Error {
@@ -61,6 +74,35 @@ func validationResult() abi.Error {
 	})
 }
 
+func validationResultWithAggregation() abi.Error {
+	returnInfo, _ := abi.NewType("tuple", "ReturnInfo", returnInfoType)
+	senderInfo, _ := abi.NewType("tuple", "SenderInfo", stakeInfoType)
+	factoryInfo, _ := abi.NewType("tuple", "FactoryInfo", stakeInfoType)
+	paymasterInfo, _ := abi.NewType("tuple", "PaymasterInfo", stakeInfoType)
+	aggregatorInfo, _ := abi.NewType("tuple", "AggregatorInfo", aggregatorStakeInfoType)
+
+	return abi.NewError("ValidationResultWithAggregation", abi.Arguments{
+		{Name: "returnInfo", Type: returnInfo},
+		{Name: "senderInfo", Type: senderInfo},
+		{Name: "factoryInfo", Type: factoryInfo},
+		{Name: "paymasterInfo", Type: paymasterInfo},
+		{Name: "aggregatorInfo", Type: aggregatorInfo},
+	})
+}
+
+func unmarshalArg(arg any, out any) error {
+	b, err := json.Marshal(arg)
+	if err != nil {
+		return fmt.Errorf("validationResult: %s", err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("validationResult: %s", err)
+	}
+	return nil
+}
+
+// NewValidationResult decodes the revert data from simulateValidation. It supports both the
+// ValidationResult and ValidationResultWithAggregation errors.
 func NewValidationResult(err error) (*ValidationResultRevert, error) {
 	rpcErr, ok := err.(rpc.DataError)
 	if !ok {
@@ -72,60 +114,59 @@ func NewValidationResult(err error) (*ValidationResultRevert, error) {
 		return nil, errors.New("validationResult: cannot assert type: data is not of type string")
 	}
 
+	raw := common.Hex2Bytes(data[2:])
 	sim := validationResult()
-	revert, err := sim.Unpack(common.Hex2Bytes(data[2:]))
+	revert, err := sim.Unpack(raw)
 	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+		simAgg := validationResultWithAggregation()
+		agg, aggErr := simAgg.Unpack(raw)
+		if aggErr != nil {
+			return nil, fmt.Errorf("validationResult: %s", err)
+		}
+		revert = agg
 	}
 
 	args, ok := revert.([]any)
 	if !ok {
 		return nil, errors.New("validationResult: cannot assert type: args is not of type []any")
 	}
-	if len(args) != 4 {
-		return nil, fmt.Errorf("validationResult: invalid args length: expected 4, got %d", len(args))
+	if len(args) != 4 && len(args) != 5 {
+		return nil, fmt.Errorf("validationResult: invalid args length: expected 4 or 5, got %d", len(args))
 	}
 
 	returnInfo := &ReturnInfo{}
-	ri, err := json.Marshal(args[0])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
-	}
-	if err := json.Unmarshal(ri, returnInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[0], returnInfo); err != nil {
+		return nil, err
 	}
 
 	senderInfo := &StakeInfo{}
-	si, err := json.Marshal(args[1])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
-	}
-	if err := json.Unmarshal(si, senderInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[1], senderInfo); err != nil {
+		return nil, err
 	}
 
 	factoryInfo := &StakeInfo{}
-	fi, err := json.Marshal(args[2])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
-	}
-	if err := json.Unmarshal(fi, factoryInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[2], factoryInfo); err != nil {
+		return nil, err
 	}
 
 	paymasterInfo := &StakeInfo{}
-	pmi, err := json.Marshal(args[3])
-	if err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+	if err := unmarshalArg(args[3], paymasterInfo); err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(pmi, paymasterInfo); err != nil {
-		return nil, fmt.Errorf("validationResult: %s", err)
+
+	var aggregatorInfo *AggregatorStakeInfo
+	if len(args) == 5 {
+		aggregatorInfo = &AggregatorStakeInfo{}
+		if err := unmarshalArg(args[4], aggregatorInfo); err != nil {
+			return nil, err
+		}
 	}
 
 	return &ValidationResultRevert{
-		ReturnInfo:    returnInfo,
-		SenderInfo:    senderInfo,
-		FactoryInfo:   factoryInfo,
-		PaymasterInfo: paymasterInfo,
+		ReturnInfo:     returnInfo,
+		SenderInfo:     senderInfo,
+		FactoryInfo:    factoryInfo,
+		PaymasterInfo:  paymasterInfo,
+		AggregatorInfo: aggregatorInfo,
 	}, nil
 }
@@ -1,6 +1,7 @@
 package simulation
 
 import (
+	"context"
 	stdError "errors"
 	"fmt"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/reverts"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -16,6 +18,7 @@ import (
 // SimulateValidation makes a static call to Aimiddleware.simulateValidation(aiop) and returns the
 // results without any state changes.
 func SimulateValidation(
+	ctx context.Context,
 	rpc *rpc.Client,
 	aiMiddleware common.Address,
 	op *aiop.AiOperation,
@@ -27,7 +30,7 @@ func SimulateValidation(
 
 	var res []interface{}
 	rawCaller := &aimiddleware.AimiddlewareRaw{Contract: ep}
-	err = rawCaller.Call(nil, &res, "simulateValidation", aimiddleware.AiOperation(*op))
+	err = rawCaller.Call(&bind.CallOpts{Context: ctx}, &res, "simulateValidation", aimiddleware.AiOperation(*op))
 	if err == nil {
 		return nil, stdError.New("unexpected result from simulateValidation")
 	}
@@ -40,7 +40,7 @@ type TraceOutput struct {
 
 // TraceSimulateValidation makes a debug_traceCall to Aimiddleware.simulateValidation(aiop) and returns
 // information related to the validation phase of a AiOperation.
-func TraceSimulateValidation(in *TraceInput) (*TraceOutput, error) {
+func TraceSimulateValidation(ctx context.Context, in *TraceInput) (*TraceOutput, error) {
 	ep, err := aimiddleware.NewAimiddleware(in.AiMiddleware, ethclient.NewClient(in.Rpc))
 	if err != nil {
 		return nil, err
@@ -71,7 +71,7 @@ func TraceSimulateValidation(in *TraceInput) (*TraceOutput, error) {
 		Tracer:         t,
 		StateOverrides: state.WithMaxBalanceOverride(common.HexToAddress("0x"), nil),
 	}
-	if err := in.Rpc.CallContext(context.Background(), &res, "debug_traceCall", &req, "latest", &opts); err != nil {
+	if err := in.Rpc.CallContext(ctx, &res, "debug_traceCall", &req, "latest", &opts); err != nil {
 		return nil, err
 	}
 
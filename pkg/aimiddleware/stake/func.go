@@ -1,34 +1,51 @@
 package stake
 
 import (
+	"context"
+
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/blockcache"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/reader"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // GetStakeFunc provides a general interface for retrieving the AiMiddleware stake for a given address.
-type GetStakeFunc = func(aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error)
+type GetStakeFunc = func(
+	ctx context.Context,
+	aiMiddleware, entity common.Address,
+) (*aimiddleware.IDepositManagerDepositInfo, error)
 
 func GetStakeFuncNoop() GetStakeFunc {
-	return func(aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
+	return func(
+		ctx context.Context,
+		aiMiddleware, entity common.Address,
+	) (*aimiddleware.IDepositManagerDepositInfo, error) {
 		return &aimiddleware.IDepositManagerDepositInfo{}, nil
 	}
 }
 
 // GetStakeWithEthClient returns a GetStakeFunc that relies on an eth client to get stake info from the
-// AiMiddleware.
+// AiMiddleware. If the context carries a Reader, the stake info is read from its cache instead.
 func GetStakeWithEthClient(eth *ethclient.Client) GetStakeFunc {
-	return func(aiMiddleware, addr common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
+	return func(
+		ctx context.Context,
+		aiMiddleware, addr common.Address,
+	) (*aimiddleware.IDepositManagerDepositInfo, error) {
 		if addr == common.HexToAddress("0x") {
 			return nil, nil
 		}
+		if r, ok := reader.FromContext(ctx); ok {
+			return r.DepositInfo(ctx, aiMiddleware, addr)
+		}
 
 		ep, err := aimiddleware.NewAimiddleware(aiMiddleware, eth)
 		if err != nil {
 			return nil, err
 		}
 
-		dep, err := ep.GetDepositInfo(nil, addr)
+		dep, err := ep.GetDepositInfo(&bind.CallOpts{Context: ctx}, addr)
 		if err != nil {
 			return nil, err
 		}
@@ -36,3 +53,21 @@ func GetStakeWithEthClient(eth *ethclient.Client) GetStakeFunc {
 		return &dep, nil
 	}
 }
+
+// GetStakeWithBlockCache returns a GetStakeFunc that reads stake info through a block-scoped cache. If the
+// context carries a Reader, the stake info is read from its cache first.
+func GetStakeWithBlockCache(cache *blockcache.Cache) GetStakeFunc {
+	return func(
+		ctx context.Context,
+		aiMiddleware, addr common.Address,
+	) (*aimiddleware.IDepositManagerDepositInfo, error) {
+		if addr == common.HexToAddress("0x") {
+			return nil, nil
+		}
+		if r, ok := reader.FromContext(ctx); ok {
+			return r.DepositInfo(ctx, aiMiddleware, addr)
+		}
+
+		return cache.DepositInfo(ctx, aiMiddleware, addr)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/aggregator"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/reverts"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
@@ -22,7 +23,7 @@ import (
 // contract.
 type Opts struct {
 	// Options for the network
-	EOA     *signer.EOA
+	EOA     signer.Signer
 	Eth     *ethclient.Client
 	ChainID *big.Int
 
@@ -31,13 +32,26 @@ type Opts struct {
 	Batch        []*aiop.AiOperation
 	Beneficiary  common.Address
 
-	// Options for the EOA transaction
+	// Aggregators maps an AiOperation hash to its signature aggregator. If any op in the batch has an
+	// aggregator, the transaction will call handleAggregatedOps instead of handleOps.
+	Aggregators map[common.Hash]common.Address
+
+	// AggregatorSignatures maps an AiOperation hash to the signature returned by validateAiOpSignature on its
+	// aggregator. It replaces the AiOperation's signature in handleAggregatedOps.
+	AggregatorSignatures map[common.Hash][]byte
+
+	// Options for the EOA transaction. If Nonce is nil, the pending nonce of the EOA is used.
+	Nonce       *big.Int
 	BaseFee     *big.Int
 	Tip         *big.Int
 	GasPrice    *big.Int
 	GasLimit    uint64
 	NoSend      bool
 	WaitTimeout time.Duration
+
+	// NoFeeEstimate leaves the fee fields out of the call in EstimateHandleOpsGas. This allows a batch to be
+	// estimated from an EOA that cannot pay for the transaction, such as in dry-run mode.
+	NoFeeEstimate bool
 }
 
 func toAbiType(batch []*aiop.AiOperation) []aimiddleware.AiOperation {
@@ -49,28 +63,147 @@ func toAbiType(batch []*aiop.AiOperation) []aimiddleware.AiOperation {
 	return ops
 }
 
+// toAbiSigForAiOpType is the same as toAbiType but replaces the signature of each AiOperation with the value
+// returned by its aggregator, if there is one.
+func toAbiSigForAiOpType(opts *Opts, batch []*aiop.AiOperation) []aimiddleware.AiOperation {
+	ops := toAbiType(batch)
+	for i, op := range batch {
+		if sig, ok := opts.AggregatorSignatures[op.GetAiOpHash(opts.AiMiddleware, opts.ChainID)]; ok {
+			ops[i].Signature = sig
+		}
+	}
+
+	return ops
+}
+
+// groupByAggregator splits a batch into groups of AiOperations that share the same aggregator. Groups are
+// returned in order of first appearance so that a batch that is already contiguous by aggregator keeps the
+// same op indexes once flattened. Ops without an aggregator are grouped under the zero address.
+func groupByAggregator(
+	ep common.Address,
+	chainID *big.Int,
+	batch []*aiop.AiOperation,
+	aggregators map[common.Hash]common.Address,
+) ([]common.Address, map[common.Address][]*aiop.AiOperation) {
+	order := []common.Address{}
+	groups := make(map[common.Address][]*aiop.AiOperation)
+	for _, op := range batch {
+		agg := aggregators[op.GetAiOpHash(ep, chainID)]
+		if _, ok := groups[agg]; !ok {
+			order = append(order, agg)
+		}
+		groups[agg] = append(groups[agg], op)
+	}
+
+	return order, groups
+}
+
+// hasAggregator returns true if any AiOperation in the batch is using a signature aggregator.
+func hasAggregator(opts *Opts) bool {
+	for _, op := range opts.Batch {
+		if agg, ok := opts.Aggregators[op.GetAiOpHash(opts.AiMiddleware, opts.ChainID)]; ok &&
+			agg != (common.Address{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// toBatchIndex maps an op index from a handleAggregatedOps call back to the index of the op in the batch. The
+// call groups ops by aggregator, so the two differ whenever ops sharing an aggregator are not contiguous in
+// the batch. Indexes from a handleOps call or out of range are returned as is.
+func toBatchIndex(opts *Opts, index int) int {
+	if !hasAggregator(opts) {
+		return index
+	}
+
+	pos := make(map[*aiop.AiOperation]int, len(opts.Batch))
+	for i, op := range opts.Batch {
+		pos[op] = i
+	}
+	order, groups := groupByAggregator(opts.AiMiddleware, opts.ChainID, opts.Batch, opts.Aggregators)
+	offset := index
+	for _, agg := range order {
+		if offset < len(groups[agg]) {
+			return pos[groups[agg][offset]]
+		}
+		offset -= len(groups[agg])
+	}
+	return index
+}
+
+// toAbiAggregatedType returns the batch as a list of ops per aggregator with the aggregated signature for
+// each group.
+func toAbiAggregatedType(ctx context.Context, opts *Opts) ([]aimiddleware.IAiMiddlewareAiOpsPerAggregator, error) {
+	order, groups := groupByAggregator(opts.AiMiddleware, opts.ChainID, opts.Batch, opts.Aggregators)
+	opsPerAgg := []aimiddleware.IAiMiddlewareAiOpsPerAggregator{}
+	for _, agg := range order {
+		sig := []byte{}
+		ops := toAbiType(groups[agg])
+		if agg != (common.Address{}) {
+			s, err := aggregator.AggregateSignatures(ctx, opts.Eth, agg, groups[agg])
+			if err != nil {
+				return nil, err
+			}
+			sig = s
+			ops = toAbiSigForAiOpType(opts, groups[agg])
+		}
+
+		opsPerAgg = append(opsPerAgg, aimiddleware.IAiMiddlewareAiOpsPerAggregator{
+			AiOps:      ops,
+			Aggregator: agg,
+			Signature:  sig,
+		})
+	}
+
+	return opsPerAgg, nil
+}
+
+// newHandleOpsTx creates a transaction that calls either handleOps or handleAggregatedOps depending on
+// whether any AiOperation in the batch is using a signature aggregator.
+func newHandleOpsTx(
+	ep *aimiddleware.Aimiddleware,
+	auth *bind.TransactOpts,
+	opts *Opts,
+) (*types.Transaction, error) {
+	if !hasAggregator(opts) {
+		return ep.HandleOps(auth, toAbiType(opts.Batch), opts.Beneficiary)
+	}
+
+	opsPerAgg, err := toAbiAggregatedType(auth.Context, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ep.HandleAggregatedOps(auth, opsPerAgg, opts.Beneficiary)
+}
+
 // EstimateHandleOpsGas returns a gas estimate required to call handleOps() with a given batch. A failed call
-// will return the cause of the revert.
-func EstimateHandleOpsGas(opts *Opts) (gas uint64, revert *reverts.FailedOpRevert, err error) {
+// will return the cause of the revert with the op index relative to opts.Batch.
+func EstimateHandleOpsGas(ctx context.Context, opts *Opts) (gas uint64, revert *reverts.FailedOpRevert, err error) {
 	ep, err := aimiddleware.NewAimiddleware(opts.AiMiddleware, opts.Eth)
 	if err != nil {
 		return 0, nil, err
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(opts.EOA.PrivateKey, opts.ChainID)
-	if err != nil {
-		return 0, nil, err
+	// The transaction is only used to derive call data and is never broadcasted. Skip signing so that
+	// estimates do not require a round trip to a remote signer.
+	auth := &bind.TransactOpts{
+		From: opts.EOA.Address(),
+		Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return tx, nil
+		},
+		Context: ctx,
 	}
 	auth.GasLimit = math.MaxUint64
 	auth.NoSend = true
 
-	tx, err := ep.HandleOps(auth, toAbiType(opts.Batch), opts.Beneficiary)
+	tx, err := newHandleOpsTx(ep, auth, opts)
 	if err != nil {
 		return 0, nil, err
 	}
 
-	est, err := opts.Eth.EstimateGas(context.Background(), ethereum.CallMsg{
-		From:       opts.EOA.Address,
+	msg := ethereum.CallMsg{
+		From:       opts.EOA.Address(),
 		To:         tx.To(),
 		Gas:        tx.Gas(),
 		GasPrice:   tx.GasPrice(),
@@ -79,12 +212,18 @@ func EstimateHandleOpsGas(opts *Opts) (gas uint64, revert *reverts.FailedOpRever
 		Value:      tx.Value(),
 		Data:       tx.Data(),
 		AccessList: tx.AccessList(),
-	})
+	}
+	if opts.NoFeeEstimate {
+		// Without a gas price the node does not check that the EOA can pay for the gas limit.
+		msg.GasPrice, msg.GasFeeCap, msg.GasTipCap = nil, nil, nil
+	}
+	est, err := opts.Eth.EstimateGas(ctx, msg)
 	if err != nil {
 		revert, err := reverts.NewFailedOp(err)
 		if err != nil {
 			return 0, nil, err
 		}
+		revert.OpIndex = toBatchIndex(opts, revert.OpIndex)
 		return 0, revert, nil
 	}
 
@@ -92,24 +231,26 @@ func EstimateHandleOpsGas(opts *Opts) (gas uint64, revert *reverts.FailedOpRever
 }
 
 // HandleOps submits a transaction to send a batch of AiOperations to the AiMiddleware.
-func HandleOps(opts *Opts) (txn *types.Transaction, err error) {
+func HandleOps(ctx context.Context, opts *Opts) (txn *types.Transaction, err error) {
 	ep, err := aimiddleware.NewAimiddleware(opts.AiMiddleware, opts.Eth)
 	if err != nil {
 		return nil, err
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(opts.EOA.PrivateKey, opts.ChainID)
-	if err != nil {
-		return nil, err
-	}
+	auth := signer.NewTransactor(opts.EOA, opts.ChainID)
+	auth.Context = ctx
 	auth.GasLimit = opts.GasLimit
 	auth.NoSend = opts.NoSend
 
-	nonce, err := opts.Eth.NonceAt(context.Background(), opts.EOA.Address, nil)
-	if err != nil {
-		return nil, err
+	if opts.Nonce != nil {
+		auth.Nonce = opts.Nonce
+	} else {
+		nonce, err := opts.Eth.PendingNonceAt(ctx, opts.EOA.Address())
+		if err != nil {
+			return nil, err
+		}
+		auth.Nonce = big.NewInt(0).SetUint64(nonce)
 	}
-	auth.Nonce = big.NewInt(int64(nonce))
 
 	if opts.BaseFee != nil && opts.Tip != nil {
 		auth.GasTipCap = SuggestMeanGasTipCap(opts.Tip, opts.Batch)
@@ -120,12 +261,12 @@ func HandleOps(opts *Opts) (txn *types.Transaction, err error) {
 		return nil, errors.New("transaction: either the dynamic or legacy gas fees must be set")
 	}
 
-	txn, err = ep.HandleOps(auth, toAbiType(opts.Batch), opts.Beneficiary)
+	txn, err = newHandleOpsTx(ep, auth, opts)
 	if err != nil {
 		return nil, err
 	} else if opts.WaitTimeout == 0 || opts.NoSend {
-		// Don't wait for transaction to be included. All aiOps in the current batch will be dropped
-		// regardless of the transaction status.
+		// Don't wait for transaction to be included. The caller is responsible for tracking the transaction
+		// status (e.g. with a Tracker).
 		return txn, nil
 	}
 
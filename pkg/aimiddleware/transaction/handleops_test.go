@@ -0,0 +1,167 @@
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TestGroupByAggregator verifies that ops are grouped by aggregator in order of first appearance and that ops
+// without an aggregator are grouped under the zero address.
+func TestGroupByAggregator(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Nonce = big.NewInt(1)
+	op3 := testutils.MockValidInitAiOp()
+	op3.Nonce = big.NewInt(2)
+	batch := []*aiop.AiOperation{op1, op2, op3}
+
+	aggs := map[common.Hash]common.Address{
+		op1.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress2,
+		op3.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress2,
+	}
+	order, groups := groupByAggregator(testutils.ValidAddress1, testutils.ChainID, batch, aggs)
+
+	if len(order) != 2 {
+		t.Fatalf("got %d groups, want 2", len(order))
+	} else if order[0] != testutils.ValidAddress2 || order[1] != (common.Address{}) {
+		t.Fatalf("got order %v, want [%s, %s]", order, testutils.ValidAddress2, common.Address{})
+	}
+
+	if len(groups[testutils.ValidAddress2]) != 2 ||
+		groups[testutils.ValidAddress2][0] != op1 ||
+		groups[testutils.ValidAddress2][1] != op3 {
+		t.Fatalf("unexpected ops in aggregator group")
+	}
+	if len(groups[common.Address{}]) != 1 || groups[common.Address{}][0] != op2 {
+		t.Fatalf("unexpected ops in zero address group")
+	}
+}
+
+// TestToBatchIndex verifies that an op index from handleAggregatedOps is mapped back to the op's index in a
+// batch that is not contiguous by aggregator.
+func TestToBatchIndex(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Nonce = big.NewInt(1)
+	op3 := testutils.MockValidInitAiOp()
+	op3.Nonce = big.NewInt(2)
+	opts := &Opts{
+		AiMiddleware: testutils.ValidAddress1,
+		ChainID:      testutils.ChainID,
+		Batch:        []*aiop.AiOperation{op1, op2, op3},
+		Aggregators: map[common.Hash]common.Address{
+			op1.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress2,
+			op3.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID): testutils.ValidAddress2,
+		},
+	}
+
+	// handleAggregatedOps is called with [op1, op3] then [op2].
+	for index, want := range []int{0, 2, 1, 3} {
+		if got := toBatchIndex(opts, index); got != want {
+			t.Fatalf("index %d: got %d, want %d", index, got, want)
+		}
+	}
+
+	opts.Aggregators = nil
+	if got := toBatchIndex(opts, 1); got != 1 {
+		t.Fatalf("without aggregators: got %d, want 1", got)
+	}
+}
+
+// TestToAbiSigForAiOpType verifies that only ops with a signature from their aggregator have it replaced.
+func TestToAbiSigForAiOpType(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Nonce = big.NewInt(1)
+	sig := []byte{0x01, 0x02}
+	opts := &Opts{
+		AiMiddleware: testutils.ValidAddress1,
+		ChainID:      testutils.ChainID,
+		Batch:        []*aiop.AiOperation{op1, op2},
+		AggregatorSignatures: map[common.Hash][]byte{
+			op1.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID): sig,
+		},
+	}
+
+	ops := toAbiSigForAiOpType(opts, opts.Batch)
+	if string(ops[0].Signature) != string(sig) {
+		t.Fatalf("got signature %x, want %x", ops[0].Signature, sig)
+	}
+	if string(ops[1].Signature) != string(op2.Signature) {
+		t.Fatalf("got signature %x, want %x", ops[1].Signature, op2.Signature)
+	}
+	if string(op1.Signature) == string(sig) {
+		t.Fatalf("original op signature was modified")
+	}
+}
+
+// TestEstimateHandleOpsGasNoFeeEstimate verifies that the gas estimate is called without fee fields when
+// NoFeeEstimate is set.
+func TestEstimateHandleOpsGasNoFeeEstimate(t *testing.T) {
+	var mu sync.Mutex
+	calls := []map[string]any{}
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_getTransactionCount":  "0x1",
+		"eth_getBlockByNumber":     testutils.NewBlockMock(),
+		"eth_maxPriorityFeePerGas": "0x1",
+		"eth_gasPrice":             "0x1",
+		"eth_estimateGas": testutils.MethodFunc(func(params []json.RawMessage) (any, error) {
+			call := map[string]any{}
+			if err := json.Unmarshal(params[0], &call); err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			calls = append(calls, call)
+			mu.Unlock()
+			return "0x1", nil
+		}),
+	})
+	defer n.Close()
+	r, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, noFee := range []bool{false, true} {
+		_, _, err := EstimateHandleOpsGas(context.Background(), &Opts{
+			EOA:           testutils.DummyEOA,
+			Eth:           ethclient.NewClient(r),
+			ChainID:       testutils.ChainID,
+			AiMiddleware:  testutils.ValidAddress1,
+			Batch:         []*aiop.AiOperation{testutils.MockValidInitAiOp()},
+			Beneficiary:   testutils.DummyEOA.Address(),
+			NoFeeEstimate: noFee,
+		})
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("got %d estimates, want 2", len(calls))
+	}
+	fees := func(call map[string]any) []string {
+		keys := []string{}
+		for _, key := range []string{"gasPrice", "maxFeePerGas", "maxPriorityFeePerGas"} {
+			if _, ok := call[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	if len(fees(calls[0])) == 0 {
+		t.Fatal("got no fee fields, want fee fields in the estimate")
+	}
+	if keys := fees(calls[1]); len(keys) != 0 {
+		t.Fatalf("got %v with NoFeeEstimate, want no fee fields", keys)
+	}
+}
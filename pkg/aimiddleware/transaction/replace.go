@@ -0,0 +1,148 @@
+package transaction
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// DefaultBumpPercent is the minimum price bump required by geth's txpool to replace a pending
+	// transaction with the same nonce.
+	DefaultBumpPercent int64 = 10
+
+	// DefaultFeeCeilingPercent caps replacement fees at the mean maxFeePerGas of the batch.
+	DefaultFeeCeilingPercent int64 = 100
+
+	cancelGasLimit uint64 = 21000
+)
+
+// ReplacementPolicy defines when and how a stuck transaction should be replaced with a higher fee.
+type ReplacementPolicy struct {
+	// Interval is the time to wait for inclusion after each send before bumping fees.
+	Interval time.Duration
+
+	// BumpPercent is the percentage increase applied to both the fee cap and tip on each replacement.
+	BumpPercent int64
+
+	// FeeCeilingPercent is the max fee cap as a percentage of the batch's mean maxFeePerGas. If a bump would
+	// exceed this ceiling, the transaction is cancelled instead.
+	FeeCeilingPercent int64
+}
+
+// NewDefaultReplacementPolicy returns a ReplacementPolicy that bumps fees at a given interval using default
+// values.
+func NewDefaultReplacementPolicy(interval time.Duration) *ReplacementPolicy {
+	return &ReplacementPolicy{
+		Interval:          interval,
+		BumpPercent:       DefaultBumpPercent,
+		FeeCeilingPercent: DefaultFeeCeilingPercent,
+	}
+}
+
+// BumpFee returns the value increased by a given percentage. The result is rounded up so that it always
+// satisfies the node's minimum price bump for replacements.
+func BumpFee(fee *big.Int, percent int64) *big.Int {
+	n := big.NewInt(0).Mul(fee, big.NewInt(100+percent))
+	q, r := big.NewInt(0).DivMod(n, big.NewInt(100), big.NewInt(0))
+	if r.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
+
+// FeeCeiling returns the highest fee per gas that the bundler is willing to pay for a given batch. This is
+// derived from the mean maxFeePerGas of all AiOperations in the batch.
+func FeeCeiling(batch []*aiop.AiOperation, percent int64) *big.Int {
+	if len(batch) == 0 {
+		return big.NewInt(0)
+	}
+
+	sum := big.NewInt(0)
+	for _, op := range batch {
+		sum = big.NewInt(0).Add(sum, op.MaxFeePerGas)
+	}
+	avg := big.NewInt(0).Div(sum, big.NewInt(int64(len(batch))))
+	return big.NewInt(0).Div(big.NewInt(0).Mul(avg, big.NewInt(percent)), big.NewInt(100))
+}
+
+func signAndSend(ctx context.Context, opts *Opts, tx *types.Transaction) (*types.Transaction, error) {
+	signed, err := opts.EOA.SignTx(tx, opts.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Eth.SendTransaction(ctx, signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+func bumpedTx(txn *types.Transaction, percent int64, to *types.Transaction) types.TxData {
+	if txn.Type() == types.LegacyTxType {
+		return &types.LegacyTx{
+			Nonce:    txn.Nonce(),
+			GasPrice: BumpFee(txn.GasPrice(), percent),
+			Gas:      to.Gas(),
+			To:       to.To(),
+			Value:    to.Value(),
+			Data:     to.Data(),
+		}
+	}
+
+	return &types.DynamicFeeTx{
+		ChainID:    txn.ChainId(),
+		Nonce:      txn.Nonce(),
+		GasTipCap:  BumpFee(txn.GasTipCap(), percent),
+		GasFeeCap:  BumpFee(txn.GasFeeCap(), percent),
+		Gas:        to.Gas(),
+		To:         to.To(),
+		Value:      to.Value(),
+		Data:       to.Data(),
+		AccessList: to.AccessList(),
+	}
+}
+
+// Replace resends a pending transaction with the same nonce and call data but with fees bumped by a given
+// percentage.
+func Replace(
+	ctx context.Context,
+	opts *Opts,
+	txn *types.Transaction,
+	percent int64,
+) (*types.Transaction, error) {
+	return signAndSend(ctx, opts, types.NewTx(bumpedTx(txn, percent, txn)))
+}
+
+// Cancel replaces a pending transaction with a zero value transfer to the EOA itself. The fee cap is the higher
+// of the ceiling and the fees bumped by a given percentage, which is the minimum that the node accepts as a
+// replacement.
+func Cancel(
+	ctx context.Context,
+	opts *Opts,
+	txn *types.Transaction,
+	percent int64,
+	ceiling *big.Int,
+) (*types.Transaction, error) {
+	to := opts.EOA.Address()
+	self := types.NewTx(&types.LegacyTx{
+		Gas:   cancelGasLimit,
+		To:    &to,
+		Value: big.NewInt(0),
+	})
+
+	data := bumpedTx(txn, percent, self)
+	switch tx := data.(type) {
+	case *types.LegacyTx:
+		if ceiling.Cmp(tx.GasPrice) > 0 {
+			tx.GasPrice = ceiling
+		}
+	case *types.DynamicFeeTx:
+		if ceiling.Cmp(tx.GasFeeCap) > 0 {
+			tx.GasFeeCap = ceiling
+		}
+	}
+	return signAndSend(ctx, opts, types.NewTx(data))
+}
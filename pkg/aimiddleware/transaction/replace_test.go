@@ -0,0 +1,36 @@
+package transaction
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+)
+
+// TestBumpFeeRoundsUp verifies that a bumped fee is always rounded up so that it meets the node's minimum
+// price bump.
+func TestBumpFeeRoundsUp(t *testing.T) {
+	if got := BumpFee(big.NewInt(100), 10); got.Cmp(big.NewInt(110)) != 0 {
+		t.Fatalf("got %d, want 110", got.Int64())
+	}
+	if got := BumpFee(big.NewInt(15), 10); got.Cmp(big.NewInt(17)) != 0 {
+		t.Fatalf("got %d, want 17", got.Int64())
+	}
+}
+
+// TestFeeCeiling verifies that the fee ceiling is derived from the mean maxFeePerGas of the batch.
+func TestFeeCeiling(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op1.MaxFeePerGas = big.NewInt(10)
+	op2 := testutils.MockValidInitAiOp()
+	op2.MaxFeePerGas = big.NewInt(30)
+	batch := []*aiop.AiOperation{op1, op2}
+
+	if got := FeeCeiling(batch, 100); got.Cmp(big.NewInt(20)) != 0 {
+		t.Fatalf("got %d, want 20", got.Int64())
+	}
+	if got := FeeCeiling(batch, 150); got.Cmp(big.NewInt(30)) != 0 {
+		t.Fatalf("got %d, want 30", got.Int64())
+	}
+}
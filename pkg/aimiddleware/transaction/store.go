@@ -0,0 +1,236 @@
+package transaction
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/dbutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	trackerKeyPrefix = dbutils.JoinValues("tracker")
+)
+
+// SignerLookupFunc returns the signer for an EOA address so that a restored transaction can be replaced.
+type SignerLookupFunc = func(address common.Address) (signer.Signer, error)
+
+// record is the part of a Submitted transaction that is persisted so that it can be tracked again after a
+// restart. Txns holds the latest transaction first followed by every transaction it replaced.
+type record struct {
+	Txns         []hexutil.Bytes                `json:"txns"`
+	Cancels      []common.Hash                  `json:"cancels"`
+	Cancelled    bool                           `json:"cancelled"`
+	SentAt       time.Time                      `json:"sentAt"`
+	Signer       common.Address                 `json:"signer"`
+	AiMiddleware common.Address                 `json:"aiMiddleware"`
+	Beneficiary  common.Address                 `json:"beneficiary"`
+	Batch        []json.RawMessage              `json:"batch"`
+	Aggregators  map[common.Hash]common.Address `json:"aggregators"`
+}
+
+func getTrackerKey(key common.Hash) []byte {
+	return []byte(dbutils.JoinValues(trackerKeyPrefix, key.String()))
+}
+
+func encodeSubmitted(sub *Submitted) ([]byte, error) {
+	rec := &record{
+		Cancels:      []common.Hash{},
+		Cancelled:    sub.Cancelled,
+		SentAt:       sub.SentAt,
+		AiMiddleware: sub.Opts.AiMiddleware,
+		Beneficiary:  sub.Opts.Beneficiary,
+		Batch:        []json.RawMessage{},
+		Aggregators:  sub.Opts.Aggregators,
+	}
+	if sub.Opts.EOA != nil {
+		rec.Signer = sub.Opts.EOA.Address()
+	}
+	for _, txn := range sub.all() {
+		raw, err := txn.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		rec.Txns = append(rec.Txns, raw)
+	}
+	for hash := range sub.cancels {
+		rec.Cancels = append(rec.Cancels, hash)
+	}
+	for _, op := range sub.Opts.Batch {
+		data, err := op.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		rec.Batch = append(rec.Batch, data)
+	}
+	return json.Marshal(rec)
+}
+
+func decodeSubmitted(key []byte, value []byte, lookup SignerLookupFunc) (*Submitted, error) {
+	rec := &record{}
+	if err := json.Unmarshal(value, rec); err != nil {
+		return nil, err
+	}
+
+	txns := []*types.Transaction{}
+	for _, raw := range rec.Txns {
+		txn := new(types.Transaction)
+		if err := txn.UnmarshalBinary(raw); err != nil {
+			return nil, err
+		}
+		txns = append(txns, txn)
+	}
+	batch := []*aiop.AiOperation{}
+	for _, raw := range rec.Batch {
+		data := make(map[string]any)
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, err
+		}
+		op, err := aiop.New(data)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, op)
+	}
+	eoa, err := lookup(rec.Signer)
+	if err != nil {
+		return nil, err
+	}
+
+	cancels := make(map[common.Hash]bool)
+	for _, hash := range rec.Cancels {
+		cancels[hash] = true
+	}
+	return &Submitted{
+		Txn: txns[0],
+		Opts: &Opts{
+			EOA:          eoa,
+			ChainID:      txns[0].ChainId(),
+			AiMiddleware: rec.AiMiddleware,
+			Batch:        batch,
+			Beneficiary:  rec.Beneficiary,
+			Aggregators:  rec.Aggregators,
+			Nonce:        big.NewInt(0).SetUint64(txns[0].Nonce()),
+			GasLimit:     txns[0].Gas(),
+		},
+		SentAt:     rec.SentAt,
+		LastSentAt: rec.SentAt,
+		Replaced:   txns[1:],
+		Cancelled:  rec.Cancelled,
+		key:        common.HexToHash(dbutils.SplitValues(string(key))[1]),
+		cancels:    cancels,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// save persists a pending transaction if the Tracker has a DB.
+func (t *Tracker) save(sub *Submitted) {
+	if t.db == nil || sub.Opts == nil {
+		return
+	}
+
+	t.mu.Lock()
+	value, err := encodeSubmitted(sub)
+	t.mu.Unlock()
+	if err == nil {
+		err = t.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(getTrackerKey(sub.key), value)
+		})
+	}
+	if err != nil {
+		t.logger.Error(err, "tracker save error", "txn_hash", sub.Txn.Hash().String())
+	}
+}
+
+// delete removes a settled transaction from the Tracker's DB.
+func (t *Tracker) delete(sub *Submitted) {
+	if t.db == nil {
+		return
+	}
+
+	err := t.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(getTrackerKey(sub.key))
+	})
+	if err != nil {
+		t.logger.Error(err, "tracker delete error", "txn_hash", sub.Txn.Hash().String())
+	}
+}
+
+// UseDB persists every tracked transaction in db until it is settled. This allows transactions that are still
+// pending when the process stops to be restored with Restore.
+func (t *Tracker) UseDB(db *badger.DB) {
+	t.db = db
+}
+
+// Restore adds every transaction that was still pending in the Tracker's DB when the process last stopped. The
+// signer of each transaction is found with lookup. A transaction that cannot be restored is logged and no
+// longer tracked.
+func (t *Tracker) Restore(lookup SignerLookupFunc) error {
+	if t.db == nil {
+		return nil
+	}
+
+	subs := []*Submitted{}
+	stale := [][]byte{}
+	err := t.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchSize = 10
+		it := txn.NewIterator(opts)
+		prefix := []byte(trackerKeyPrefix)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := item.KeyCopy(nil)
+			err := item.Value(func(v []byte) error {
+				sub, err := decodeSubmitted(key, v, lookup)
+				if err != nil {
+					t.logger.Error(err, "tracker restore error", "key", string(key))
+					stale = append(stale, key)
+					return nil
+				}
+
+				sub.Opts.Eth = t.eth
+				subs = append(subs, sub)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = t.db.Update(func(txn *badger.Txn) error {
+		for _, key := range stale {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, sub := range subs {
+		t.pending[sub.key] = sub
+		t.logger.Info(
+			"transaction restored",
+			"txn_hash", sub.Txn.Hash().String(),
+			"nonce", sub.Txn.Nonce(),
+		)
+	}
+	return nil
+}
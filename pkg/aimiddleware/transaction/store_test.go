@@ -0,0 +1,77 @@
+package transaction
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func lookupDummyEOA(address common.Address) (signer.Signer, error) {
+	if address != testutils.DummyEOA.Address() {
+		return nil, errors.New("unknown signer")
+	}
+	return testutils.DummyEOA, nil
+}
+
+// TestTrackerRestore verifies that a transaction tracked with a DB is restored by another Tracker with the
+// same batch and signer, and that it is removed from the DB once settled.
+func TestTrackerRestore(t *testing.T) {
+	db := testutils.DBMock()
+	t.Cleanup(func() { db.Close() })
+	op := testutils.MockValidInitAiOp()
+	op.MaxFeePerGas = big.NewInt(1000)
+	hash := op.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID)
+	to := testutils.ValidAddress1
+	txn, err := testutils.DummyEOA.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   testutils.ChainID,
+		Nonce:     1,
+		GasTipCap: big.NewInt(10),
+		GasFeeCap: big.NewInt(100),
+		Gas:       100000,
+		To:        &to,
+	}), testutils.ChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := newTrackerWithReceipt(t, nil)
+	tr.UseDB(db)
+	tr.Track(txn, &Opts{
+		EOA:          testutils.DummyEOA,
+		ChainID:      testutils.ChainID,
+		AiMiddleware: testutils.ValidAddress1,
+		Batch:        []*aiop.AiOperation{op},
+		Aggregators:  map[common.Hash]common.Address{hash: testutils.ValidAddress2},
+	})
+
+	restored := newTrackerWithReceipt(t, testutils.NewTransactionReceiptMock())
+	restored.UseDB(db)
+	if err := restored.Restore(lookupDummyEOA); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	p := restored.Pending()
+	if len(p) != 1 {
+		t.Fatalf("got %d pending, want 1", len(p))
+	} else if p[0].Txn.Hash() != txn.Hash() || p[0].Opts.EOA != testutils.DummyEOA {
+		t.Fatal("got restored transaction with a different hash or signer")
+	} else if len(p[0].Opts.Batch) != 1 || p[0].Opts.Batch[0].GetAiOpHash(to, testutils.ChainID) != hash {
+		t.Fatal("got restored batch that does not match")
+	} else if p[0].Opts.Aggregators[hash] != testutils.ValidAddress2 {
+		t.Fatal("got restored aggregators that do not match")
+	}
+
+	restored.poll()
+	again := newTrackerWithReceipt(t, nil)
+	again.UseDB(db)
+	if err := again.Restore(lookupDummyEOA); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if len(again.Pending()) != 0 {
+		t.Fatalf("got %d pending after settling, want 0", len(again.Pending()))
+	}
+}
@@ -0,0 +1,418 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+)
+
+var (
+	ErrTxnFailed    = errors.New("transaction: failed status")
+	ErrTxnTimeout   = errors.New("transaction: wait timeout reached")
+	ErrTxnCancelled = errors.New("transaction: cancelled at fee ceiling")
+
+	DefaultTrackerInterval = 1 * time.Second
+)
+
+// Submitted is a transaction that has been sent and is waiting to be included on-chain. If the transaction
+// has been replaced, Txn is the latest replacement and all prior transactions with the same nonce are kept in
+// Replaced.
+type Submitted struct {
+	Txn        *types.Transaction
+	Opts       *Opts
+	SentAt     time.Time
+	LastSentAt time.Time
+	Replaced   []*types.Transaction
+	Cancelled  bool
+
+	key     common.Hash
+	cancels map[common.Hash]bool
+	overdue bool
+	done    chan struct{}
+}
+
+func (s *Submitted) all() []*types.Transaction {
+	return append([]*types.Transaction{s.Txn}, s.Replaced...)
+}
+
+// Settled is the final outcome of a Submitted transaction. Err is nil if the transaction was included with a
+// successful status.
+type Settled struct {
+	*Submitted
+	Receipt *types.Receipt
+	Err     error
+}
+
+// SettledHandlerFunc is called by the Tracker once a transaction has been settled.
+type SettledHandlerFunc = func(s *Settled)
+
+// Tracker polls for the receipts of submitted transactions in the background. This allows callers to send
+// a transaction without blocking until it has been included.
+type Tracker struct {
+	eth         *ethclient.Client
+	db          *badger.DB
+	logger      logr.Logger
+	timeout     time.Duration
+	callTimeout time.Duration
+	interval    time.Duration
+	policy      *ReplacementPolicy
+	mu          sync.Mutex
+	pending     map[common.Hash]*Submitted
+	handlers    []SettledHandlerFunc
+	isRunning   bool
+	done        chan bool
+	stop        func()
+}
+
+// NewTracker returns a Tracker for transactions sent with the given ethClient.
+func NewTracker(eth *ethclient.Client, timeout time.Duration) *Tracker {
+	return &Tracker{
+		eth:       eth,
+		logger:    logger.NewZeroLogr().WithName("tracker"),
+		timeout:   timeout,
+		interval:  DefaultTrackerInterval,
+		pending:   make(map[common.Hash]*Submitted),
+		handlers:  []SettledHandlerFunc{},
+		isRunning: false,
+		done:      make(chan bool),
+		stop:      func() {},
+	}
+}
+
+// SetTimeout sets the total time to wait for a transaction to be included before it is settled with
+// ErrTxnTimeout. A transaction that is still in the node's pool after the timeout is tracked until its nonce is
+// used or the node drops it, since sending its batch again would revert. A value of 0 will wait indefinitely.
+func (t *Tracker) SetTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timeout = timeout
+}
+
+// SetCallTimeout sets the max time for each node call made while polling for receipts and sending
+// replacements. The default value is 0 which means calls have no deadline.
+func (t *Tracker) SetCallTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.callTimeout = timeout
+}
+
+// SetReplacementPolicy defines how transactions that are not included in a timely manner are replaced with
+// higher fees. The default value is nil which disables replacements.
+func (t *Tracker) SetReplacementPolicy(policy *ReplacementPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.policy = policy
+}
+
+// UseLogger defines the logger object used by the Tracker instance based on the go-logr/logr interface.
+func (t *Tracker) UseLogger(logger logr.Logger) {
+	t.logger = logger.WithName("tracker")
+}
+
+// OnSettled adds a function that will be called every time a transaction is settled.
+func (t *Tracker) OnSettled(fn SettledHandlerFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.handlers = append(t.handlers, fn)
+}
+
+// Track adds a sent transaction to be settled in the background.
+func (t *Tracker) Track(txn *types.Transaction, opts *Opts) {
+	now := time.Now()
+	sub := &Submitted{
+		Txn:        txn,
+		Opts:       opts,
+		SentAt:     now,
+		LastSentAt: now,
+		Replaced:   []*types.Transaction{},
+		Cancelled:  false,
+		key:        txn.Hash(),
+		cancels:    make(map[common.Hash]bool),
+		done:       make(chan struct{}),
+	}
+	t.mu.Lock()
+	t.pending[txn.Hash()] = sub
+	t.mu.Unlock()
+	t.save(sub)
+}
+
+// Pending returns all transactions that have not been settled.
+func (t *Tracker) Pending() []*Submitted {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	subs := []*Submitted{}
+	for _, sub := range t.pending {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// Wait blocks until the transaction that was tracked with the given hash is settled and every SettledHandlerFunc
+// has returned. It returns immediately if the transaction is not pending and returns an error if ctx is done
+// first.
+func (t *Tracker) Wait(ctx context.Context, hash common.Hash) error {
+	t.mu.Lock()
+	sub, ok := t.pending[hash]
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-sub.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracker) settle(s *Settled) {
+	t.mu.Lock()
+	delete(t.pending, s.key)
+	handlers := append([]SettledHandlerFunc{}, t.handlers...)
+	t.mu.Unlock()
+	t.delete(s.Submitted)
+
+	l := t.logger.
+		WithValues("txn_hash", s.Txn.Hash().String()).
+		WithValues("nonce", s.Txn.Nonce())
+	if s.Opts != nil && s.Opts.EOA != nil {
+		l = l.WithValues("signer", s.Opts.EOA.Address().Hex())
+	}
+	if s.Err != nil {
+		l.Error(s.Err, "transaction settled with error")
+	} else {
+		l.Info("transaction settled ok", "block_number", s.Receipt.BlockNumber.String())
+	}
+
+	for _, fn := range handlers {
+		fn(s)
+	}
+	close(s.done)
+}
+
+// replace bumps the fees of a pending transaction. If the bumped fee cap is above the ceiling for the batch,
+// the transaction is cancelled instead. A cancellation that is not included is bumped again by the minimum
+// replacement so that the nonce is always released, even when the tracker has no timeout.
+func (t *Tracker) replace(ctx context.Context, sub *Submitted, policy *ReplacementPolicy) {
+	if sub.Opts == nil || sub.Opts.EOA == nil {
+		return
+	}
+
+	l := t.logger.
+		WithValues("txn_hash", sub.Txn.Hash().String()).
+		WithValues("nonce", sub.Txn.Nonce())
+	ceiling := FeeCeiling(sub.Opts.Batch, policy.FeeCeilingPercent)
+	cancel := sub.Cancelled || BumpFee(sub.Txn.GasFeeCap(), policy.BumpPercent).Cmp(ceiling) > 0
+
+	var txn *types.Transaction
+	var err error
+	if cancel {
+		txn, err = Cancel(ctx, sub.Opts, sub.Txn, policy.BumpPercent, ceiling)
+	} else {
+		txn, err = Replace(ctx, sub.Opts, sub.Txn, policy.BumpPercent)
+	}
+	if err != nil {
+		l.Error(err, "tracker replacement error")
+		return
+	}
+
+	t.mu.Lock()
+	sub.Replaced = append(sub.Replaced, sub.Txn)
+	sub.Txn = txn
+	sub.LastSentAt = time.Now()
+	sub.Cancelled = cancel
+	if cancel {
+		sub.cancels[txn.Hash()] = true
+	}
+	t.mu.Unlock()
+	t.save(sub)
+
+	l.Info(
+		"transaction replaced",
+		"replacement_txn_hash", txn.Hash().String(),
+		"gas_fee_cap", txn.GasFeeCap().String(),
+		"gas_tip_cap", txn.GasTipCap().String(),
+		"cancelled", cancel,
+	)
+}
+
+// receipt returns the receipt of whichever transaction with the same nonce has been included.
+func (t *Tracker) receipt(ctx context.Context, sub *Submitted) (*types.Transaction, *types.Receipt, error) {
+	for _, txn := range sub.all() {
+		receipt, err := t.eth.TransactionReceipt(ctx, txn.Hash())
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		return txn, receipt, nil
+	}
+	return nil, nil, ethereum.NotFound
+}
+
+// poll checks the receipt of every pending transaction once and settles the ones that have been included or
+// have reached the timeout. Transactions that have not been included after the replacement interval are
+// replaced with higher fees.
+func (t *Tracker) poll() {
+	t.mu.Lock()
+	timeout := t.timeout
+	callTimeout := t.callTimeout
+	policy := t.policy
+	t.mu.Unlock()
+
+	for _, sub := range t.Pending() {
+		t.pollOne(sub, timeout, callTimeout, policy)
+	}
+}
+
+// released returns true if the nonce of a pending transaction can no longer be used by it. This is the case once
+// another transaction with the same nonce has been included or the node no longer has the transaction in its
+// pool.
+func (t *Tracker) released(ctx context.Context, sub *Submitted) (bool, error) {
+	if sub.Opts == nil || sub.Opts.EOA == nil {
+		return true, nil
+	}
+
+	latest, err := t.eth.NonceAt(ctx, sub.Opts.EOA.Address(), nil)
+	if err != nil {
+		return false, err
+	}
+	pending, err := t.eth.PendingNonceAt(ctx, sub.Opts.EOA.Address())
+	if err != nil {
+		return false, err
+	}
+	return latest > sub.Txn.Nonce() || pending <= sub.Txn.Nonce(), nil
+}
+
+// pollOne checks a single pending transaction. Node calls for the receipt and any replacement share a
+// deadline of callTimeout.
+func (t *Tracker) pollOne(
+	sub *Submitted,
+	timeout time.Duration,
+	callTimeout time.Duration,
+	policy *ReplacementPolicy,
+) {
+	ctx, cancel := utils.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	// The nonce is read before the receipt so that a transaction that is included in between is not settled
+	// as timed out.
+	expired := timeout > 0 && time.Since(sub.SentAt) >= timeout
+	released := false
+	if expired {
+		var err error
+		released, err = t.released(ctx, sub)
+		if err != nil {
+			t.logger.Error(err, "tracker poll error", "txn_hash", sub.Txn.Hash().String())
+			return
+		}
+	}
+
+	txn, receipt, err := t.receipt(ctx, sub)
+	if errors.Is(err, ethereum.NotFound) {
+		if released {
+			t.settle(&Settled{Submitted: sub, Err: ErrTxnTimeout})
+			return
+		} else if expired && !sub.overdue {
+			sub.overdue = true
+			t.logger.Info(
+				"transaction still pending after timeout",
+				"txn_hash", sub.Txn.Hash().String(),
+				"nonce", sub.Txn.Nonce(),
+			)
+		}
+		if policy != nil && policy.Interval > 0 && time.Since(sub.LastSentAt) >= policy.Interval {
+			t.replace(ctx, sub, policy)
+		}
+		return
+	} else if err != nil {
+		t.logger.Error(err, "tracker poll error", "txn_hash", sub.Txn.Hash().String())
+		return
+	}
+
+	if sub.cancels[txn.Hash()] {
+		t.settle(&Settled{Submitted: sub, Receipt: receipt, Err: ErrTxnCancelled})
+	} else if receipt.Status == types.ReceiptStatusFailed {
+		t.settle(&Settled{Submitted: sub, Receipt: receipt, Err: ErrTxnFailed})
+	} else {
+		t.settle(&Settled{Submitted: sub, Receipt: receipt})
+	}
+}
+
+// Run starts a goroutine that will continuously settle pending transactions.
+func (t *Tracker) Run() error {
+	if t.isRunning {
+		return nil
+	}
+
+	ticker := time.NewTicker(t.interval)
+	go func(t *Tracker) {
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+				err := utils.Recover(func() error {
+					t.poll()
+					return nil
+				})
+				if err != nil {
+					t.logger.Error(err, "tracker poll error")
+				}
+			}
+		}
+	}(t)
+
+	t.isRunning = true
+	t.stop = ticker.Stop
+	return nil
+}
+
+// Drain waits until every pending transaction has been settled or ctx is done. The Tracker must be running
+// for transactions to be settled. If ctx is done first, an error is returned with the number of transactions
+// that are still pending.
+func (t *Tracker) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		n := len(t.Pending())
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("transaction: %d still pending: %w", n, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop signals the Tracker to stop settling pending transactions.
+func (t *Tracker) Stop() {
+	if !t.isRunning {
+		return
+	}
+
+	t.isRunning = false
+	t.stop()
+	t.done <- true
+}
@@ -0,0 +1,362 @@
+package transaction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func newTrackerWithReceipt(t *testing.T, receipt map[string]any) *Tracker {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_getTransactionReceipt": receipt,
+	})
+	t.Cleanup(n.Close)
+	r, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewTracker(ethclient.NewClient(r), 0)
+}
+
+// TestTrackerSettlesIncludedTransaction verifies that a transaction with a successful receipt is settled
+// without an error and removed from the pending set.
+func TestTrackerSettlesIncludedTransaction(t *testing.T) {
+	tr := newTrackerWithReceipt(t, testutils.NewTransactionReceiptMock())
+	var settled *Settled
+	tr.OnSettled(func(s *Settled) { settled = s })
+	tr.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 1}), &Opts{})
+
+	tr.poll()
+	if settled == nil {
+		t.Fatal("transaction not settled")
+	} else if settled.Err != nil {
+		t.Fatalf("got err %v, want nil", settled.Err)
+	} else if len(tr.Pending()) != 0 {
+		t.Fatalf("got %d pending, want 0", len(tr.Pending()))
+	}
+}
+
+// TestTrackerSettlesFailedTransaction verifies that a transaction with a failed receipt is settled with
+// ErrTxnFailed.
+func TestTrackerSettlesFailedTransaction(t *testing.T) {
+	receipt := testutils.NewTransactionReceiptMock()
+	receipt["status"] = "0x0"
+	tr := newTrackerWithReceipt(t, receipt)
+	var settled *Settled
+	tr.OnSettled(func(s *Settled) { settled = s })
+	tr.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 1}), &Opts{})
+
+	tr.poll()
+	if settled == nil {
+		t.Fatal("transaction not settled")
+	} else if !errors.Is(settled.Err, ErrTxnFailed) {
+		t.Fatalf("got err %v, want ErrTxnFailed", settled.Err)
+	}
+}
+
+// TestTrackerWaitReturnsAfterHandlers verifies that Wait returns once the transaction is settled and its
+// handlers have run, and that it returns immediately for a transaction that is not pending.
+func TestTrackerWaitReturnsAfterHandlers(t *testing.T) {
+	tr := newTrackerWithReceipt(t, testutils.NewTransactionReceiptMock())
+	tr.interval = time.Millisecond
+	var mu sync.Mutex
+	handled := false
+	tr.OnSettled(func(s *Settled) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = true
+	})
+	txn := types.NewTx(&types.DynamicFeeTx{Nonce: 1})
+	tr.Track(txn, &Opts{})
+	if err := tr.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tr.Wait(ctx, txn.Hash()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !handled {
+		t.Fatal("got Wait returned before the handlers ran")
+	}
+	if err := tr.Wait(ctx, common.Hash{}); err != nil {
+		t.Fatalf("got err %v for a transaction that is not pending, want nil", err)
+	}
+}
+
+// TestTrackerDrainWaitsForPending verifies that Drain returns once every pending transaction is settled.
+func TestTrackerDrainWaitsForPending(t *testing.T) {
+	tr := newTrackerWithReceipt(t, testutils.NewTransactionReceiptMock())
+	tr.interval = time.Millisecond
+	tr.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 1}), &Opts{})
+	if err := tr.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tr.Drain(ctx); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+}
+
+// TestTrackerDrainDeadline verifies that Drain returns an error if transactions are still pending once ctx
+// is done.
+func TestTrackerDrainDeadline(t *testing.T) {
+	tr := newTrackerWithReceipt(t, nil)
+	tr.interval = time.Millisecond
+	tr.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 1}), &Opts{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tr.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got err %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// TestTrackerTimeoutWaitsForNonce verifies that a timed out transaction that is still in the node's pool keeps
+// being tracked and is only settled with ErrTxnTimeout once the node no longer has it.
+func TestTrackerTimeoutWaitsForNonce(t *testing.T) {
+	var mu sync.Mutex
+	pending := "0x2"
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_getTransactionReceipt": nil,
+		"eth_getTransactionCount": testutils.MethodFunc(func(params []json.RawMessage) (any, error) {
+			var block string
+			if err := json.Unmarshal(params[1], &block); err != nil {
+				return nil, err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if block == "pending" {
+				return pending, nil
+			}
+			return "0x1", nil
+		}),
+	})
+	t.Cleanup(n.Close)
+	r, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTracker(ethclient.NewClient(r), time.Nanosecond)
+	var settled *Settled
+	tr.OnSettled(func(s *Settled) { settled = s })
+	tr.Track(types.NewTx(&types.DynamicFeeTx{Nonce: 1}), &Opts{EOA: testutils.DummyEOA})
+
+	tr.poll()
+	if settled != nil {
+		t.Fatalf("got settled with %v while in the node's pool, want pending", settled.Err)
+	} else if len(tr.Pending()) != 1 {
+		t.Fatalf("got %d pending, want 1", len(tr.Pending()))
+	}
+
+	mu.Lock()
+	pending = "0x1"
+	mu.Unlock()
+	tr.poll()
+	if settled == nil {
+		t.Fatal("transaction not settled")
+	} else if !errors.Is(settled.Err, ErrTxnTimeout) {
+		t.Fatalf("got err %v, want ErrTxnTimeout", settled.Err)
+	}
+}
+
+// replacementNode is a stand-in for a node that keeps every sent transaction and only returns a receipt once
+// the test marks a transaction as mined.
+type replacementNode struct {
+	*testutils.NodeMock
+	mu    sync.Mutex
+	sent  []*types.Transaction
+	mined map[common.Hash]bool
+}
+
+func newReplacementNode(t *testing.T) (*ethclient.Client, *replacementNode) {
+	n := &replacementNode{mined: make(map[common.Hash]bool)}
+	n.NodeMock = testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_sendRawTransaction": testutils.MethodFunc(func(params []json.RawMessage) (any, error) {
+			var raw hexutil.Bytes
+			if err := json.Unmarshal(params[0], &raw); err != nil {
+				return nil, err
+			}
+			txn := new(types.Transaction)
+			if err := txn.UnmarshalBinary(raw); err != nil {
+				return nil, err
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			n.sent = append(n.sent, txn)
+			return txn.Hash().Hex(), nil
+		}),
+		"eth_getTransactionReceipt": testutils.MethodFunc(func(params []json.RawMessage) (any, error) {
+			var hash common.Hash
+			if err := json.Unmarshal(params[0], &hash); err != nil {
+				return nil, err
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if !n.mined[hash] {
+				return nil, nil
+			}
+			return testutils.NewTransactionReceiptMock(), nil
+		}),
+	})
+	t.Cleanup(n.Close)
+
+	r, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ethclient.NewClient(r), n
+}
+
+func (n *replacementNode) lastSent() *types.Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.sent) == 0 {
+		return nil
+	}
+	return n.sent[len(n.sent)-1]
+}
+
+func (n *replacementNode) mine(hash common.Hash) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.mined[hash] = true
+}
+
+// trackReplaceable tracks a transaction with a fee cap of 100 for a batch with a maxFeePerGas of opFee.
+func trackReplaceable(t *testing.T, tr *Tracker, eth *ethclient.Client, opFee int64) *types.Transaction {
+	op := testutils.MockValidInitAiOp()
+	op.MaxFeePerGas = big.NewInt(opFee)
+	to := testutils.ValidAddress1
+	txn, err := testutils.DummyEOA.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   testutils.ChainID,
+		Nonce:     1,
+		GasTipCap: big.NewInt(10),
+		GasFeeCap: big.NewInt(100),
+		Gas:       100000,
+		To:        &to,
+	}), testutils.ChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr.Track(txn, &Opts{
+		EOA:     testutils.DummyEOA,
+		Eth:     eth,
+		ChainID: testutils.ChainID,
+		Batch:   []*aiop.AiOperation{op},
+	})
+	return txn
+}
+
+// TestTrackerReplacesAfterInterval verifies that a pending transaction is only replaced with bumped fees once
+// the replacement interval has passed since it was sent.
+func TestTrackerReplacesAfterInterval(t *testing.T) {
+	eth, n := newReplacementNode(t)
+	tr := NewTracker(eth, 0)
+	policy := &ReplacementPolicy{Interval: time.Hour, BumpPercent: 10, FeeCeilingPercent: 100}
+	tr.SetReplacementPolicy(policy)
+	txn := trackReplaceable(t, tr, eth, 1000)
+
+	tr.poll()
+	if n.lastSent() != nil {
+		t.Fatal("got replacement before interval, want none")
+	}
+
+	policy.Interval = time.Nanosecond
+	tr.SetReplacementPolicy(policy)
+	tr.poll()
+	sent := n.lastSent()
+	if sent == nil {
+		t.Fatal("got no replacement, want one")
+	} else if sent.Nonce() != txn.Nonce() || sent.GasFeeCap().Int64() != 110 || sent.GasTipCap().Int64() != 11 {
+		t.Fatalf(
+			"got nonce %d, fee cap %d, tip %d, want %d, 110, 11",
+			sent.Nonce(),
+			sent.GasFeeCap().Int64(),
+			sent.GasTipCap().Int64(),
+			txn.Nonce(),
+		)
+	}
+	p := tr.Pending()
+	if len(p) != 1 || p[0].Txn.Hash() != sent.Hash() || p[0].Cancelled {
+		t.Fatal("got pending transaction not replaced")
+	} else if len(p[0].Replaced) != 1 || p[0].Replaced[0].Hash() != txn.Hash() {
+		t.Fatal("got original transaction not kept in Replaced")
+	}
+}
+
+// TestTrackerCancelsAtFeeCeiling verifies that a transaction is cancelled instead of bumped past the fee
+// ceiling, that a pending cancellation keeps being bumped by the minimum replacement and that it settles with
+// ErrTxnCancelled once any cancellation is mined.
+func TestTrackerCancelsAtFeeCeiling(t *testing.T) {
+	eth, n := newReplacementNode(t)
+	tr := NewTracker(eth, 0)
+	tr.SetReplacementPolicy(
+		&ReplacementPolicy{Interval: time.Nanosecond, BumpPercent: 10, FeeCeilingPercent: 100},
+	)
+	var settled *Settled
+	tr.OnSettled(func(s *Settled) { settled = s })
+	txn := trackReplaceable(t, tr, eth, 100)
+
+	tr.poll()
+	cancel := n.lastSent()
+	if cancel == nil {
+		t.Fatal("got no cancellation, want one")
+	} else if cancel.Nonce() != txn.Nonce() ||
+		*cancel.To() != testutils.DummyEOA.Address() ||
+		cancel.Value().Sign() != 0 {
+		t.Fatal("got cancellation that is not a zero value transfer to the EOA with the same nonce")
+	}
+	if p := tr.Pending(); len(p) != 1 || !p[0].Cancelled {
+		t.Fatal("got pending transaction not marked as cancelled")
+	}
+
+	if cancel.GasFeeCap().Int64() != 110 {
+		t.Fatalf("got fee cap %d, want 110", cancel.GasFeeCap().Int64())
+	}
+
+	tr.poll()
+	bumped := n.lastSent()
+	if bumped == nil || bumped.Hash() == cancel.Hash() {
+		t.Fatal("got cancellation not bumped again, want a replacement")
+	} else if *bumped.To() != testutils.DummyEOA.Address() || bumped.GasFeeCap().Int64() != 121 {
+		t.Fatalf("got fee cap %d, want a cancellation with the minimum bump of 121", bumped.GasFeeCap().Int64())
+	}
+
+	// The earlier cancellation is still a cancellation if it is the one that gets mined.
+	n.mine(cancel.Hash())
+	tr.poll()
+	if settled == nil {
+		t.Fatal("transaction not settled")
+	} else if !errors.Is(settled.Err, ErrTxnCancelled) {
+		t.Fatalf("got err %v, want ErrTxnCancelled", settled.Err)
+	} else if settled.Receipt == nil {
+		t.Fatal("got nil receipt, want receipt of the cancellation")
+	}
+}
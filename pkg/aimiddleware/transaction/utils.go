@@ -3,7 +3,6 @@ package transaction
 import (
 	"bytes"
 	"context"
-	"errors"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -28,7 +27,7 @@ func Wait(txn *types.Transaction, eth *ethclient.Client, timeout time.Duration)
 	} else if receipt.Status == types.ReceiptStatusFailed {
 		// Return an error here so that the current batch stays in the mempool. In the next bundler iteration,
 		// the offending aiOps will be dropped during gas estimation.
-		return nil, errors.New("transaction: failed status")
+		return nil, ErrTxnFailed
 	}
 	return txn, nil
 }
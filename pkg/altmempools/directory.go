@@ -14,6 +14,7 @@ import (
 // accept.
 type Directory struct {
 	invalidStorageAccess *xsync.MapOf[string, []string]
+	ids                  []string
 }
 
 type Config struct {
@@ -63,6 +64,7 @@ func New(chain *big.Int, altMempools []*Config) (*Directory, error) {
 		if skip {
 			continue
 		}
+		dir.ids = append(dir.ids, alt.Id)
 
 		for _, item := range alt.Data["allowlist"].([]any) {
 			config := item.(map[string]any)
@@ -99,6 +101,11 @@ func NewFromIPFS(chain *big.Int, ipfsGateway string, ids []string) (*Directory,
 	return New(chain, alts)
 }
 
+// Ids returns the id of every alternative mempool that was loaded for the chain.
+func (d *Directory) Ids() []string {
+	return append([]string{}, d.ids...)
+}
+
 // HasInvalidStorageAccessException will attempt to find all mempools ids that will accept the given invalid
 // storage access pattern and return it. If none is found, an empty array will be returned.
 func (d *Directory) HasInvalidStorageAccessException(entity string, contract string, slot string) []string {
@@ -0,0 +1,338 @@
+// Package blockcache provides a cache for AiMiddleware deposits and contract code that is scoped to the
+// latest block. Repeated reads within the same block are served from memory and the cache is invalidated
+// whenever a new head is seen.
+package blockcache
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+)
+
+var (
+	// DefaultInterval is the time between polling the node for a new head.
+	DefaultInterval = 1 * time.Second
+
+	// MaxEventRange is the max number of blocks to filter for deposit events when a new head is seen. If more
+	// blocks have passed, all deposits are invalidated instead.
+	MaxEventRange = uint64(100)
+)
+
+type depositKey struct {
+	aiMiddleware common.Address
+	entity       common.Address
+}
+
+// Cache holds deposits, code, and code hashes read at the latest block.
+type Cache struct {
+	eth      *ethclient.Client
+	logger   logr.Logger
+	interval time.Duration
+	tracked  []common.Address
+	done     chan bool
+	stop     func()
+	running  bool
+
+	mu       sync.Mutex
+	block    uint64
+	version  uint64
+	deposits map[depositKey]*aimiddleware.IDepositManagerDepositInfo
+	code     map[common.Address][]byte
+	hashes   map[common.Address]common.Hash
+}
+
+// New returns an empty Cache that reads through to the given eth client.
+func New(eth *ethclient.Client) *Cache {
+	return &Cache{
+		eth:      eth,
+		logger:   logger.NewZeroLogr().WithName("block_cache"),
+		interval: DefaultInterval,
+		done:     make(chan bool),
+		stop:     func() {},
+		deposits: make(map[depositKey]*aimiddleware.IDepositManagerDepositInfo),
+		code:     make(map[common.Address][]byte),
+		hashes:   make(map[common.Address]common.Hash),
+	}
+}
+
+// UseLogger defines the logger object used by the Cache instance based on the go-logr/logr interface.
+func (c *Cache) UseLogger(logger logr.Logger) {
+	c.logger = logger.WithName("block_cache")
+}
+
+// SetInterval defines the time between polling the node for a new head.
+func (c *Cache) SetInterval(interval time.Duration) {
+	c.interval = interval
+}
+
+// TrackEvents keeps deposits cached across blocks for the given AiMiddlewares. On each new head, only the
+// entities that emitted a Deposited, Withdrawn, StakeLocked, StakeUnlocked, StakeWithdrawn, or
+// AiOperationEvent log are invalidated.
+func (c *Cache) TrackEvents(aiMiddlewares ...common.Address) {
+	c.tracked = aiMiddlewares
+}
+
+// Version returns a number that changes every time the cache is invalidated. It should be read before
+// fetching a value from the node and passed to the setter so that the value is dropped if a new head was seen
+// in the meantime.
+func (c *Cache) Version() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.version
+}
+
+// GetDeposit returns a cached deposit and true if it exists.
+func (c *Cache) GetDeposit(
+	aiMiddleware common.Address,
+	entity common.Address,
+) (*aimiddleware.IDepositManagerDepositInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dep, ok := c.deposits[depositKey{aiMiddleware, entity}]
+	return dep, ok
+}
+
+// SetDeposit caches a deposit that was read at the given version.
+func (c *Cache) SetDeposit(
+	version uint64,
+	aiMiddleware common.Address,
+	entity common.Address,
+	dep *aimiddleware.IDepositManagerDepositInfo,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version == c.version {
+		c.deposits[depositKey{aiMiddleware, entity}] = dep
+	}
+}
+
+// GetCode returns cached bytecode and true if it exists.
+func (c *Cache) GetCode(addr common.Address) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	code, ok := c.code[addr]
+	return code, ok
+}
+
+// SetCode caches bytecode that was read at the given version.
+func (c *Cache) SetCode(version uint64, addr common.Address, code []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version == c.version {
+		c.code[addr] = code
+		c.hashes[addr] = crypto.Keccak256Hash(code)
+	}
+}
+
+// DepositInfo returns the AiMiddleware deposit for an entity, reading through to the node on a miss. Nil is
+// returned for the zero address.
+func (c *Cache) DepositInfo(
+	ctx context.Context,
+	aiMiddleware common.Address,
+	entity common.Address,
+) (*aimiddleware.IDepositManagerDepositInfo, error) {
+	if entity == (common.Address{}) {
+		return nil, nil
+	}
+	if dep, ok := c.GetDeposit(aiMiddleware, entity); ok {
+		return dep, nil
+	}
+
+	version := c.Version()
+	ep, err := aimiddleware.NewAimiddleware(aiMiddleware, c.eth)
+	if err != nil {
+		return nil, err
+	}
+	dep, err := ep.GetDepositInfo(&bind.CallOpts{Context: ctx}, entity)
+	if err != nil {
+		return nil, err
+	}
+	c.SetDeposit(version, aiMiddleware, entity, &dep)
+	return &dep, nil
+}
+
+// Code returns the bytecode at an address, reading through to the node on a miss.
+func (c *Cache) Code(ctx context.Context, addr common.Address) ([]byte, error) {
+	if code, ok := c.GetCode(addr); ok {
+		return code, nil
+	}
+
+	version := c.Version()
+	code, err := c.eth.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.SetCode(version, addr, code)
+	return code, nil
+}
+
+// CodeHash returns the keccak256 hash of the bytecode at an address, reading through to the node on a miss.
+func (c *Cache) CodeHash(ctx context.Context, addr common.Address) (common.Hash, error) {
+	c.mu.Lock()
+	hash, ok := c.hashes[addr]
+	c.mu.Unlock()
+	if ok {
+		return hash, nil
+	}
+
+	code, err := c.Code(ctx, addr)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(code), nil
+}
+
+// Update checks the node for a new head and invalidates the cache if one is found.
+func (c *Cache) Update(ctx context.Context) error {
+	bn, err := c.eth.BlockNumber(ctx)
+	if err != nil {
+		// The head is unknown so nothing in the cache can be trusted.
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.clear()
+		return err
+	}
+
+	c.mu.Lock()
+	prev := c.block
+	if bn == prev {
+		c.mu.Unlock()
+		return nil
+	}
+	// Values read before the new head are dropped by their setter, including reads that overlap the filter.
+	c.version++
+	c.mu.Unlock()
+
+	// Find the entities with deposit changes before taking the lock again so that reads are not blocked.
+	var changed map[depositKey]bool
+	if len(c.tracked) > 0 && prev != 0 && bn > prev && bn-prev <= MaxEventRange {
+		changed, err = c.depositChanges(ctx, prev+1, bn)
+		if err != nil {
+			c.logger.Error(err, "block cache event filter error")
+			changed = nil
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.block = bn
+	if changed == nil {
+		c.clear()
+		return nil
+	}
+	c.code = make(map[common.Address][]byte)
+	c.hashes = make(map[common.Address]common.Hash)
+	for key := range c.deposits {
+		if changed[key] {
+			delete(c.deposits, key)
+		}
+	}
+	return nil
+}
+
+func (c *Cache) clear() {
+	c.version++
+	c.deposits = make(map[depositKey]*aimiddleware.IDepositManagerDepositInfo)
+	c.code = make(map[common.Address][]byte)
+	c.hashes = make(map[common.Address]common.Hash)
+}
+
+// depositChanges returns the entities that may have had a change in deposit between two blocks inclusive.
+func (c *Cache) depositChanges(ctx context.Context, from, to uint64) (map[depositKey]bool, error) {
+	parsed, err := aimiddleware.AimiddlewareMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	accountEvents := []common.Hash{
+		parsed.Events["Deposited"].ID,
+		parsed.Events["Withdrawn"].ID,
+		parsed.Events["StakeLocked"].ID,
+		parsed.Events["StakeUnlocked"].ID,
+		parsed.Events["StakeWithdrawn"].ID,
+	}
+	opEvent := parsed.Events["AiOperationEvent"].ID
+
+	logs, err := c.eth.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: c.tracked,
+		Topics:    [][]common.Hash{append(accountEvents, opEvent)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[depositKey]bool)
+	for _, log := range logs {
+		if len(log.Topics) == 0 {
+			continue
+		}
+		if log.Topics[0] == opEvent && len(log.Topics) >= 4 {
+			// The sender or paymaster deposit is charged for the op without a separate event.
+			changed[depositKey{log.Address, common.BytesToAddress(log.Topics[2].Bytes())}] = true
+			changed[depositKey{log.Address, common.BytesToAddress(log.Topics[3].Bytes())}] = true
+		} else if len(log.Topics) >= 2 {
+			changed[depositKey{log.Address, common.BytesToAddress(log.Topics[1].Bytes())}] = true
+		}
+	}
+	return changed, nil
+}
+
+// Run starts a goroutine that polls the node for new heads and invalidates the cache.
+func (c *Cache) Run() error {
+	if c.running {
+		return nil
+	}
+	if err := c.Update(context.Background()); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(c.interval)
+	go func() {
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				if err := utils.Recover(func() error { return c.Update(ctx) }); err != nil {
+					c.logger.Error(err, "block cache update error")
+				}
+			}
+		}
+	}()
+
+	c.running = true
+	c.stop = func() {
+		ticker.Stop()
+		cancel()
+	}
+	return nil
+}
+
+// Stop signals the Cache to stop polling for new heads. An update that is in progress is canceled.
+func (c *Cache) Stop() {
+	if !c.running {
+		return
+	}
+
+	c.running = false
+	c.stop()
+	c.done <- true
+}
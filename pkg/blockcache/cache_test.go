@@ -0,0 +1,151 @@
+package blockcache
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ethMock is a stand-in for a node with a head that can be moved forward by the test.
+func ethMock(t *testing.T) (*ethclient.Client, *testutils.NodeMock) {
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_getCode": hexutil.Encode(testutils.MockByteCode),
+	})
+	t.Cleanup(n.Close)
+	r, _ := rpc.Dial(n.URL)
+	return ethclient.NewClient(r), n
+}
+
+// TestCodeCachedWithinBlock verifies that repeated reads in the same block are served from memory.
+func TestCodeCachedWithinBlock(t *testing.T) {
+	eth, n := ethMock(t)
+	c := New(eth)
+	ctx := context.Background()
+	if err := c.Update(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		code, err := c.Code(ctx, testutils.ValidAddress1)
+		if err != nil {
+			t.Fatalf("got %v, want nil", err)
+		}
+		if len(code) != len(testutils.MockByteCode) {
+			t.Fatalf("got code length %d, want %d", len(code), len(testutils.MockByteCode))
+		}
+	}
+	if err := c.Update(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if _, err := c.CodeHash(ctx, testutils.ValidAddress1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if got := n.Calls("eth_getCode"); got != 1 {
+		t.Fatalf("got %d eth_getCode calls, want 1", got)
+	}
+}
+
+// TestInvalidateOnNewHead verifies that a new head clears the cache and drops values read at an older version.
+func TestInvalidateOnNewHead(t *testing.T) {
+	eth, n := ethMock(t)
+	c := New(eth)
+	ctx := context.Background()
+	if err := c.Update(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if _, err := c.Code(ctx, testutils.ValidAddress1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	stale := c.Version()
+	n.SetHead(2)
+	if err := c.Update(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if c.Version() == stale {
+		t.Fatal("got same version, want version to change on new head")
+	}
+	if _, ok := c.GetCode(testutils.ValidAddress1); ok {
+		t.Fatal("got cached code, want cache to be cleared on new head")
+	}
+
+	c.SetCode(stale, testutils.ValidAddress2, testutils.MockByteCode)
+	if _, ok := c.GetCode(testutils.ValidAddress2); ok {
+		t.Fatal("got cached code, want value from stale version to be dropped")
+	}
+
+	if _, err := c.Code(ctx, testutils.ValidAddress1); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if got := n.Calls("eth_getCode"); got != 2 {
+		t.Fatalf("got %d eth_getCode calls, want 2", got)
+	}
+}
+
+// TestStopCancelsUpdate verifies that Stop returns while an update is waiting on the node.
+func TestStopCancelsUpdate(t *testing.T) {
+	eth, n := ethMock(t)
+	c := New(eth)
+	c.SetInterval(time.Millisecond)
+	if err := c.Run(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	n.SetHang(true)
+	calls := n.Calls("eth_blockNumber")
+	for n.Calls("eth_blockNumber") == calls {
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan bool)
+	go func() {
+		c.Stop()
+		stopped <- true
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("got Stop blocked on update, want returned")
+	}
+}
+
+// TestUpdateDropsReadsOverlappingFilter verifies that a deposit read before a new head is dropped even if it
+// is set while the deposit events are being filtered.
+func TestUpdateDropsReadsOverlappingFilter(t *testing.T) {
+	var c *Cache
+	var stale uint64
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_getLogs": testutils.MethodFunc(func(params []json.RawMessage) (any, error) {
+			dep := &aimiddleware.IDepositManagerDepositInfo{}
+			c.SetDeposit(stale, testutils.ValidAddress1, testutils.ValidAddress2, dep)
+			return []any{}, nil
+		}),
+	})
+	t.Cleanup(n.Close)
+	r, _ := rpc.Dial(n.URL)
+	c = New(ethclient.NewClient(r))
+	c.TrackEvents(testutils.ValidAddress1)
+	ctx := context.Background()
+	if err := c.Update(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	stale = c.Version()
+	n.SetHead(2)
+	if err := c.Update(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if n.Calls("eth_getLogs") != 1 {
+		t.Fatalf("got %d eth_getLogs calls, want 1", n.Calls("eth_getLogs"))
+	}
+	if _, ok := c.GetDeposit(testutils.ValidAddress1, testutils.ValidAddress2); ok {
+		t.Fatal("got cached deposit, want value read before the new head to be dropped")
+	}
+}
@@ -0,0 +1,129 @@
+package bundler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BreakerState is the state of a circuit breaker for a single AiMiddleware.
+type BreakerState string
+
+const (
+	// BreakerClosed allows batches to be processed as normal.
+	BreakerClosed BreakerState = "closed"
+
+	// BreakerOpen skips processing until the backoff period has elapsed.
+	BreakerOpen BreakerState = "open"
+
+	// BreakerHalfOpen allows a single probe. A success closes the breaker and a failure opens it again with a
+	// longer backoff.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+var (
+	// DefaultBreakerThreshold is the number of consecutive failures before a circuit breaker opens.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerBaseBackoff is the backoff period after a circuit breaker opens for the first time. It is
+	// doubled each time a probe fails.
+	DefaultBreakerBaseBackoff = 5 * time.Second
+
+	// DefaultBreakerMaxBackoff is the upper limit of the backoff period.
+	DefaultBreakerMaxBackoff = 5 * time.Minute
+)
+
+// CircuitBreakerStatus is a snapshot of a circuit breaker for a single AiMiddleware.
+type CircuitBreakerStatus struct {
+	AiMiddleware        common.Address `json:"aiMiddleware"`
+	State               BreakerState   `json:"state"`
+	ConsecutiveFailures int            `json:"consecutiveFailures"`
+	RetryAt             *time.Time     `json:"retryAt,omitempty"`
+	LastError           string         `json:"lastError,omitempty"`
+}
+
+type breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	state       BreakerState
+	failures    int
+	opens       int
+	openUntil   time.Time
+	lastErr     error
+}
+
+func newBreaker(threshold int, baseBackoff, maxBackoff time.Duration) *breaker {
+	return &breaker{
+		threshold:   threshold,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+		state:       BreakerClosed,
+	}
+}
+
+// allow returns true if a batch can be processed. An open breaker transitions to half-open once the backoff
+// has elapsed.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return true
+	}
+	if b.state == BreakerOpen {
+		if now.Before(b.openUntil) {
+			return false
+		}
+		b.state = BreakerHalfOpen
+	}
+	return true
+}
+
+// record updates the breaker with the result of processing a batch and returns the new state.
+func (b *breaker) record(now time.Time, err error) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.opens = 0
+		b.lastErr = nil
+		return b.state
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.threshold > 0 && (b.state == BreakerHalfOpen || b.failures >= b.threshold) {
+		backoff := b.baseBackoff << b.opens
+		if backoff <= 0 || backoff > b.maxBackoff {
+			backoff = b.maxBackoff
+		}
+		b.state = BreakerOpen
+		b.opens++
+		b.openUntil = now.Add(backoff)
+	}
+	return b.state
+}
+
+func (b *breaker) status(ep common.Address) CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := CircuitBreakerStatus{
+		AiMiddleware:        ep,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == BreakerOpen {
+		retryAt := b.openUntil
+		s.RetryAt = &retryAt
+	}
+	if b.lastErr != nil {
+		s.LastError = b.lastErr.Error()
+	}
+	return s
+}
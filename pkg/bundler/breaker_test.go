@@ -0,0 +1,73 @@
+package bundler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var errTest = errors.New("test")
+
+// TestBreakerOpensAfterThreshold verifies that the breaker only opens after consecutive failures reach the
+// threshold and blocks processing until the backoff has elapsed.
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := newBreaker(2, time.Second, time.Minute)
+	now := time.Now()
+
+	if s := b.record(now, errTest); s != BreakerClosed {
+		t.Fatalf("got %s, want %s", s, BreakerClosed)
+	}
+	if s := b.record(now, errTest); s != BreakerOpen {
+		t.Fatalf("got %s, want %s", s, BreakerOpen)
+	}
+	if b.allow(now) {
+		t.Fatal("got allowed, want blocked")
+	}
+	if !b.allow(now.Add(time.Second)) {
+		t.Fatal("got blocked, want allowed")
+	} else if s := b.status(common.Address{}).State; s != BreakerHalfOpen {
+		t.Fatalf("got %s, want %s", s, BreakerHalfOpen)
+	}
+}
+
+// TestBreakerHalfOpenBackoff verifies that a failed probe doubles the backoff and a successful probe closes
+// the breaker.
+func TestBreakerHalfOpenBackoff(t *testing.T) {
+	b := newBreaker(1, time.Second, time.Minute)
+	now := time.Now()
+
+	b.record(now, errTest)
+	now = now.Add(time.Second)
+	b.allow(now)
+	if s := b.record(now, errTest); s != BreakerOpen {
+		t.Fatalf("got %s, want %s", s, BreakerOpen)
+	}
+	if b.allow(now.Add(time.Second)) {
+		t.Fatal("got allowed, want blocked after doubled backoff")
+	}
+
+	now = now.Add(2 * time.Second)
+	if !b.allow(now) {
+		t.Fatal("got blocked, want allowed")
+	}
+	if s := b.record(now, nil); s != BreakerClosed {
+		t.Fatalf("got %s, want %s", s, BreakerClosed)
+	} else if f := b.status(common.Address{}).ConsecutiveFailures; f != 0 {
+		t.Fatalf("got %d failures, want 0", f)
+	}
+}
+
+// TestBreakerDisabled verifies that a threshold of 0 never blocks processing.
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(0, time.Second, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		b.record(now, errTest)
+	}
+	if !b.allow(now) {
+		t.Fatal("got blocked, want allowed")
+	}
+}
@@ -3,11 +3,19 @@ package bundler
 
 import (
 	"context"
+	stdErr "errors"
 	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/stake"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/transaction"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/gasprice"
@@ -15,9 +23,13 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-logr/logr"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
+// DefaultInterval is the time between each run of the bundler.
+var DefaultInterval = 1 * time.Second
+
 // Bundler controls the end to end process of creating a batch of AiOperations from the mempool and sending
 // it to the AiMiddleware.
 type Bundler struct {
@@ -25,34 +37,62 @@ type Bundler struct {
 	chainID                *big.Int
 	supportedAiMiddlewares []common.Address
 	batchHandler           modules.BatchHandlerFunc
+	revalidateHandler      modules.AiOpHandlerFunc
+	settledHandler         modules.BatchHandlerFunc
+	inflightMu             sync.Mutex
+	inflight               map[common.Hash]bool
+	deferredMu             sync.Mutex
+	deferred               map[common.Address]map[common.Hash]*deferral
+	pauseMu                sync.Mutex
+	paused                 map[string]bool
+	breakers               map[common.Address]*breaker
 	logger                 logr.Logger
 	meter                  metric.Meter
+	metrics                *metrics
 	isRunning              bool
+	heartbeat              atomic.Int64
 	done                   chan bool
 	stop                   func()
 	maxBatch               int
+	interval               time.Duration
+	callTimeout            time.Duration
+	dryRun                 bool
 	gbf                    gasprice.GetBaseFeeFunc
 	ggt                    gasprice.GetGasTipFunc
 	ggp                    gasprice.GetLegacyGasPriceFunc
+	gs                     stake.GetStakeFunc
 }
 
 // New initializes a new EIP-4337 bundler which can be extended with modules for validating batches and
 // excluding AiOperations that should not be sent to the AiMiddleware and/or dropped from the mempool.
 func New(mempool *mempool.Mempool, chainID *big.Int, supportedAiMiddlewares []common.Address) *Bundler {
+	breakers := make(map[common.Address]*breaker)
+	for _, ep := range supportedAiMiddlewares {
+		breakers[ep] = newBreaker(DefaultBreakerThreshold, DefaultBreakerBaseBackoff, DefaultBreakerMaxBackoff)
+	}
+
 	return &Bundler{
 		mempool:                mempool,
 		chainID:                chainID,
 		supportedAiMiddlewares: supportedAiMiddlewares,
 		batchHandler:           noop.BatchHandler,
+		revalidateHandler:      noop.AiOpHandler,
+		settledHandler:         noop.BatchHandler,
+		inflight:               make(map[common.Hash]bool),
+		deferred:               make(map[common.Address]map[common.Hash]*deferral),
+		paused:                 make(map[string]bool),
+		breakers:               breakers,
 		logger:                 logger.NewZeroLogr().WithName("bundler"),
 		meter:                  otel.GetMeterProvider().Meter("bundler"),
 		isRunning:              false,
 		done:                   make(chan bool),
 		stop:                   func() {},
 		maxBatch:               0,
+		interval:               DefaultInterval,
 		gbf:                    gasprice.NoopGetBaseFeeFunc(),
 		ggt:                    gasprice.NoopGetGasTipFunc(),
 		ggp:                    gasprice.NoopGetLegacyGasPriceFunc(),
+		gs:                     stake.GetStakeFuncNoop(),
 	}
 }
 
@@ -61,6 +101,42 @@ func (i *Bundler) SetMaxBatch(max int) {
 	i.maxBatch = max
 }
 
+// SetInterval defines the time between each run of the bundler. The default value is 1 second.
+func (i *Bundler) SetInterval(interval time.Duration) {
+	i.interval = interval
+}
+
+// SetCallTimeout defines the max time to re-validate each AiOperation from a bundle that was not included.
+// The default value is 0 which means re-validation has no deadline.
+func (i *Bundler) SetCallTimeout(timeout time.Duration) {
+	i.callTimeout = timeout
+}
+
+// SetDryRun defines whether each run should build and estimate a bundle without sending it. AiOperations in
+// a dry-run batch stay in the mempool until they are removed externally, for example once another bundler
+// includes them. Dropped AiOperations are still removed. The default value is false.
+func (i *Bundler) SetDryRun(dryRun bool) {
+	i.dryRun = dryRun
+}
+
+// SetCircuitBreaker defines when processing batches for an AiMiddleware should be paused due to repeated
+// failures. After threshold consecutive failures, the AiMiddleware is skipped for a backoff period that
+// doubles after each failed probe up to maxBackoff. A threshold of 0 disables the circuit breaker.
+func (i *Bundler) SetCircuitBreaker(threshold int, baseBackoff time.Duration, maxBackoff time.Duration) {
+	for _, ep := range i.supportedAiMiddlewares {
+		i.breakers[ep] = newBreaker(threshold, baseBackoff, maxBackoff)
+	}
+}
+
+// CircuitBreakerStatus returns the current circuit breaker state for each supported AiMiddleware.
+func (i *Bundler) CircuitBreakerStatus() []CircuitBreakerStatus {
+	statuses := []CircuitBreakerStatus{}
+	for _, ep := range i.supportedAiMiddlewares {
+		statuses = append(statuses, i.breakers[ep].status(ep))
+	}
+	return statuses
+}
+
 // SetGetBaseFeeFunc defines the function used to retrieve an estimate for basefee during each bundler run.
 func (i *Bundler) SetGetBaseFeeFunc(gbf gasprice.GetBaseFeeFunc) {
 	i.gbf = gbf
@@ -77,6 +153,12 @@ func (i *Bundler) SetGetLegacyGasPriceFunc(ggp gasprice.GetLegacyGasPriceFunc) {
 	i.ggp = ggp
 }
 
+// SetGetStakeFunc defines the function used to retrieve the AiMiddleware stake for a given address. This is
+// used to create a context for re-validating AiOperations from a failed transaction.
+func (i *Bundler) SetGetStakeFunc(gs stake.GetStakeFunc) {
+	i.gs = gs
+}
+
 // UseLogger defines the logger object used by the Bundler instance based on the go-logr/logr interface.
 func (i *Bundler) UseLogger(logger logr.Logger) {
 	i.logger = logger.WithName("bundler")
@@ -86,7 +168,13 @@ func (i *Bundler) UseLogger(logger logr.Logger) {
 // run.
 func (i *Bundler) AiMeter(meter metric.Meter) error {
 	i.meter = meter
-	_, err := i.meter.Int64ObservableGauge(
+	m, err := newMetrics(meter)
+	if err != nil {
+		return err
+	}
+	i.metrics = m
+
+	_, err = i.meter.Int64ObservableGauge(
 		"bundler_mempool_size",
 		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
 			size := 0
@@ -101,6 +189,47 @@ func (i *Bundler) AiMeter(meter metric.Meter) error {
 			return nil
 		}),
 	)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.meter.Int64ObservableGauge(
+		"bundler_circuit_breaker_state",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			for _, s := range i.CircuitBreakerStatus() {
+				for _, state := range []BreakerState{BreakerClosed, BreakerHalfOpen, BreakerOpen} {
+					v := int64(0)
+					if s.State == state {
+						v = 1
+					}
+					io.Observe(
+						v,
+						metric.WithAttributes(
+							attribute.String("aimiddleware", s.AiMiddleware.String()),
+							attribute.String("state", string(state)),
+						),
+					)
+				}
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.meter.Int64ObservableGauge(
+		"bundler_circuit_breaker_consecutive_failures",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			for _, s := range i.CircuitBreakerStatus() {
+				io.Observe(
+					int64(s.ConsecutiveFailures),
+					metric.WithAttributes(attribute.String("aimiddleware", s.AiMiddleware.String())),
+				)
+			}
+			return nil
+		}),
+	)
 	return err
 }
 
@@ -109,8 +238,115 @@ func (i *Bundler) UseModules(handlers ...modules.BatchHandlerFunc) {
 	i.batchHandler = modules.ComposeBatchHandlerFunc(handlers...)
 }
 
-// Process will create a batch from the mempool and send it through to the AiMiddleware.
-func (i *Bundler) Process(ep common.Address) (*modules.BatchHandlerCtx, error) {
+// UseRevalidationModules defines the AiOpHandlers to re-validate AiOperations from a transaction that failed
+// or was not included. AiOperations that do not pass are dropped from the mempool. Modules that check pending
+// ops in the mempool should not be used since the AiOperation itself will still be pending.
+func (i *Bundler) UseRevalidationModules(handlers ...modules.AiOpHandlerFunc) {
+	i.revalidateHandler = modules.ComposeAiOpHandlerFunc(handlers...)
+}
+
+// UseSettledModules defines the BatchHandlers to run once AiOperations have left the mempool. The batch
+// holds the AiOperations that were included and PendingRemoval holds the ones that were dropped. If a batch is
+// pending inclusion, its AiOperations are only passed to these modules once the transaction is settled.
+func (i *Bundler) UseSettledModules(handlers ...modules.BatchHandlerFunc) {
+	i.settledHandler = modules.ComposeBatchHandlerFunc(handlers...)
+}
+
+func (i *Bundler) setInflight(ep common.Address, value bool, ops ...*aiop.AiOperation) {
+	i.inflightMu.Lock()
+	defer i.inflightMu.Unlock()
+
+	for _, op := range ops {
+		if value {
+			i.inflight[op.GetAiOpHash(ep, i.chainID)] = true
+		} else {
+			delete(i.inflight, op.GetAiOpHash(ep, i.chainID))
+		}
+	}
+}
+
+// TrackInflight keeps the AiOperations of transactions that were sent before the Bundler started, such as ones
+// restored after a restart, out of new batches until the transactions are settled with OnTxnSettled.
+func (i *Bundler) TrackInflight(subs ...*transaction.Submitted) {
+	for _, sub := range subs {
+		if sub.Opts != nil {
+			i.setInflight(sub.Opts.AiMiddleware, true, sub.Opts.Batch...)
+		}
+	}
+}
+
+// filterInflight returns the AiOperations that are not part of a pending transaction.
+func (i *Bundler) filterInflight(ep common.Address, batch []*aiop.AiOperation) []*aiop.AiOperation {
+	i.inflightMu.Lock()
+	defer i.inflightMu.Unlock()
+
+	filtered := []*aiop.AiOperation{}
+	for _, op := range batch {
+		if !i.inflight[op.GetAiOpHash(ep, i.chainID)] {
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// Pause stops the Bundler from processing batches in the background until Resume is called with the same
+// reason. Multiple components can pause the Bundler independently and it will only resume once all reasons
+// are cleared. Calling Process directly is not affected.
+func (i *Bundler) Pause(reason string) {
+	i.pauseMu.Lock()
+	defer i.pauseMu.Unlock()
+
+	if !i.paused[reason] {
+		i.paused[reason] = true
+		i.logger.Info("bundler paused", "reason", reason)
+	}
+}
+
+// Resume clears a reason for pausing the Bundler.
+func (i *Bundler) Resume(reason string) {
+	i.pauseMu.Lock()
+	defer i.pauseMu.Unlock()
+
+	if i.paused[reason] {
+		delete(i.paused, reason)
+		i.logger.Info("bundler resumed", "reason", reason, "remaining_pause_reasons", len(i.paused))
+	}
+}
+
+// IsPaused returns true if the Bundler has been paused for any reason.
+func (i *Bundler) IsPaused() bool {
+	i.pauseMu.Lock()
+	defer i.pauseMu.Unlock()
+
+	return len(i.paused) > 0
+}
+
+// PauseReasons returns every reason the Bundler is currently paused for in sorted order.
+func (i *Bundler) PauseReasons() []string {
+	i.pauseMu.Lock()
+	defer i.pauseMu.Unlock()
+
+	reasons := []string{}
+	for reason := range i.paused {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// Heartbeat returns the last time the background loop ticked, including ticks skipped while paused. The zero
+// time is returned if the loop has not ticked yet.
+func (i *Bundler) Heartbeat() time.Time {
+	ns := i.heartbeat.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Process will create a batch from the mempool and send it through to the AiMiddleware. The given context is
+// passed to all modules and cancels any pending calls to the node when done.
+func (i *Bundler) Process(ctx context.Context, ep common.Address) (*modules.BatchHandlerCtx, error) {
 	// Init logger
 	start := time.Now()
 	l := i.logger.
@@ -118,78 +354,267 @@ func (i *Bundler) Process(ep common.Address) (*modules.BatchHandlerCtx, error) {
 		WithValues("aimiddleware", ep.String()).
 		WithValues("chain_id", i.chainID.String())
 
+	bCtx, err := i.build(ctx, ep, false)
+	if err != nil {
+		l.Error(err, "bundler run error")
+		return nil, err
+	} else if bCtx == nil {
+		return nil, nil
+	}
+
+	// Remove aiOps that remain in the context from mempool. If the batch is pending inclusion, the aiOps
+	// will stay in the mempool until the transaction is settled. In dry-run mode, the batch is never sent and
+	// also stays in the mempool.
+	rmOps := []*aiop.AiOperation{}
+	if bCtx.PendingInclusion {
+		i.setInflight(ep, true, bCtx.Batch...)
+	} else if !bCtx.DryRun {
+		rmOps = append(rmOps, bCtx.Batch...)
+	}
+	dh := []string{}
+	dr := []string{}
+	for _, item := range bCtx.PendingRemoval {
+		rmOps = append(rmOps, item.Op)
+		dh = append(dh, item.Op.GetAiOpHash(ep, i.chainID).String())
+		dr = append(dr, item.Reason)
+		i.metrics.recordDropped(ep, item.Reason)
+	}
+	if err := i.mempool.RemoveOps(ep, rmOps...); err != nil {
+		l.Error(err, "bundler run error")
+		return nil, err
+	}
+	included := []*aiop.AiOperation{}
+	if !bCtx.PendingInclusion && !bCtx.DryRun {
+		included = bCtx.Batch
+	}
+	if err := i.settle(ctx, ep, included, bCtx.PendingRemoval, bCtx.Aggregators); err != nil {
+		l.Error(err, "bundler settle error")
+	}
+	if _, ok := bCtx.Data["txn_hash"]; ok {
+		i.metrics.recordBundleSent(ep, len(bCtx.Batch))
+	}
+
+	// Deferred aiOps stay in the mempool and are tracked until the next run.
+	fh := []string{}
+	fr := []string{}
+	for _, item := range bCtx.Deferred {
+		fh = append(fh, item.Op.GetAiOpHash(ep, i.chainID).String())
+		fr = append(fr, item.Reason)
+		i.metrics.recordDeferred(ep, item.Reason)
+	}
+	i.setDeferred(ep, bCtx.Deferred)
+
+	// Update logs for the current run.
+	bat := []string{}
+	for _, op := range bCtx.Batch {
+		bat = append(bat, op.GetAiOpHash(ep, i.chainID).String())
+	}
+	l = l.WithValues("batch_aiop_hashes", bat)
+	l = l.WithValues("dropped_aiop_hashes", dh)
+	l = l.WithValues("dropped_aiop_reasons", dr)
+	l = l.WithValues("deferred_aiop_hashes", fh)
+	l = l.WithValues("deferred_aiop_reasons", fr)
+
+	for k, v := range bCtx.Data {
+		l = l.WithValues(k, v)
+	}
+	l = l.WithValues("duration", time.Since(start))
+	l.Info("bundler run ok")
+	return bCtx, nil
+}
+
+// Preview runs the batch modules in dry-run mode and returns the resulting context without sending a bundle
+// or changing the mempool. Modules with side effects must check BatchHandlerCtx.DryRun and modules that keep
+// a record of dry runs must also check BatchHandlerCtx.Preview. A nil context is returned if there are no
+// pending AiOperations.
+func (i *Bundler) Preview(ctx context.Context, ep common.Address) (*modules.BatchHandlerCtx, error) {
+	return i.build(ctx, ep, true)
+}
+
+// build creates a batch from the mempool and runs it through the batch modules. A preview is always a dry run.
+func (i *Bundler) build(ctx context.Context, ep common.Address, preview bool) (*modules.BatchHandlerCtx, error) {
 	// Get all pending aiOps from the mempool. This will be in FIFO order. Downstream modules should sort it
 	// based on more specific strategies.
 	batch, err := i.mempool.Dump(ep)
 	if err != nil {
-		l.Error(err, "bundler run error")
 		return nil, err
 	}
+	batch = i.filterInflight(ep, batch)
 	if len(batch) == 0 {
 		return nil, nil
 	}
-	batch = adjustBatchSize(i.maxBatch, batch)
+	batch, overflow := adjustBatchSize(i.maxBatch, batch)
 
 	// Get current block basefee
-	bf, err := i.gbf()
+	bf, err := i.gbf(ctx)
 	if err != nil {
-		l.Error(err, "bundler run error")
 		return nil, err
 	}
 
 	// Get suggested gas tip
 	var gt *big.Int
 	if bf != nil {
-		gt, err = i.ggt()
+		gt, err = i.ggt(ctx)
 		if err != nil {
-			l.Error(err, "bundler run error")
 			return nil, err
 		}
 	}
 
 	// Get suggested gas price (for networks that don't support EIP-1559)
-	gp, err := i.ggp()
+	gp, err := i.ggp(ctx)
 	if err != nil {
-		l.Error(err, "bundler run error")
 		return nil, err
 	}
 
 	// Create context and execute modules.
-	ctx := modules.NewBatchHandlerContext(batch, ep, i.chainID, bf, gt, gp)
-	if err := i.batchHandler(ctx); err != nil {
-		l.Error(err, "bundler run error")
+	bCtx := modules.NewBatchHandlerContext(ctx, batch, ep, i.chainID, bf, gt, gp)
+	bCtx.DryRun = preview || i.dryRun
+	bCtx.Preview = preview
+	for _, op := range overflow {
+		bCtx.Deferred = append(bCtx.Deferred, &modules.DeferredItem{Op: op, Reason: DeferReasonMaxBatch})
+	}
+	if err := i.batchHandler(bCtx); err != nil {
 		return nil, err
 	}
+	return bCtx, nil
+}
+
+// settle runs the settled modules for AiOperations that have left the mempool.
+func (i *Bundler) settle(
+	ctx context.Context,
+	ep common.Address,
+	included []*aiop.AiOperation,
+	dropped []*modules.PendingRemovalItem,
+	aggregators map[common.Hash]common.Address,
+) error {
+	if len(included) == 0 && len(dropped) == 0 {
+		return nil
+	}
+
+	sCtx := modules.NewBatchHandlerContext(ctx, included, ep, i.chainID, nil, nil, nil)
+	sCtx.PendingRemoval = append(sCtx.PendingRemoval, dropped...)
+	for hash, agg := range aggregators {
+		sCtx.Aggregators[hash] = agg
+	}
+	return i.settledHandler(sCtx)
+}
+
+// OnOpsIncluded is called when AiOperations have been included on-chain by another bundler and removed from
+// the mempool. They are passed to the settled modules in the same way as ops from the Bundler's own bundles.
+func (i *Bundler) OnOpsIncluded(
+	ctx context.Context,
+	ep common.Address,
+	ops []*aiop.AiOperation,
+	aggregators map[common.Hash]common.Address,
+) error {
+	return i.settle(ctx, ep, ops, nil, aggregators)
+}
+
+// OnTxnSettled is called when a transaction sent by the Bundler's modules has been settled. AiOperations in
+// an included transaction are removed from the mempool and passed to the settled modules. Otherwise,
+// AiOperations from a failed, timed out, or cancelled transaction are re-validated and returned to the next
+// batch unless they are rejected. An error from the node during re-validation does not drop an AiOperation.
+func (i *Bundler) OnTxnSettled(s *transaction.Settled) {
+	if s.Opts == nil {
+		return
+	}
+	ctx := context.Background()
+	ep := s.Opts.AiMiddleware
+	l := i.logger.
+		WithName("settled").
+		WithValues("aimiddleware", ep.String()).
+		WithValues("chain_id", i.chainID.String()).
+		WithValues("txn_hash", s.Txn.Hash().String())
+
+	i.setInflight(ep, false, s.Opts.Batch...)
+	if s.Err == nil {
+		if s.Receipt != nil {
+			i.metrics.recordGasUsed(ep, s.Receipt.GasUsed)
+		}
+		if err := i.mempool.RemoveOps(ep, s.Opts.Batch...); err != nil {
+			l.Error(err, "bundler settle error")
+			return
+		}
+		if err := i.settle(ctx, ep, s.Opts.Batch, nil, s.Opts.Aggregators); err != nil {
+			l.Error(err, "bundler settle error")
+		}
+		return
+	}
 
-	// Remove aiOps that remain in the context from mempool.
-	rmOps := append([]*aiop.AiOperation{}, ctx.Batch...)
+	requeued := []string{}
+	dropped := []*aiop.AiOperation{}
+	removals := []*modules.PendingRemovalItem{}
 	dh := []string{}
 	dr := []string{}
-	for _, item := range ctx.PendingRemoval {
-		rmOps = append(rmOps, item.Op)
-		dh = append(dh, item.Op.GetAiOpHash(ep, i.chainID).String())
-		dr = append(dr, item.Reason)
+	for _, op := range s.Opts.Batch {
+		hash := op.GetAiOpHash(ep, i.chainID).String()
+		if err := i.revalidate(ctx, ep, op); err != nil && !isRejected(err) {
+			l.Error(err, "bundler revalidation error", "aiop_hash", hash)
+			requeued = append(requeued, hash)
+		} else if err != nil {
+			dropped = append(dropped, op)
+			removals = append(removals, &modules.PendingRemovalItem{Op: op, Reason: err.Error()})
+			dh = append(dh, hash)
+			dr = append(dr, err.Error())
+			i.metrics.recordDropped(ep, "revalidation failed")
+		} else {
+			requeued = append(requeued, hash)
+		}
 	}
-	if err := i.mempool.RemoveOps(ep, rmOps...); err != nil {
-		l.Error(err, "bundler run error")
-		return nil, err
+	if err := i.mempool.RemoveOps(ep, dropped...); err != nil {
+		l.Error(err, "bundler requeue error")
+		return
+	}
+	if err := i.settle(ctx, ep, nil, removals, nil); err != nil {
+		l.Error(err, "bundler settle error")
 	}
 
-	// Update logs for the current run.
-	bat := []string{}
-	for _, op := range ctx.Batch {
-		bat = append(bat, op.GetAiOpHash(ep, i.chainID).String())
+	l.Info(
+		"bundler requeue ok",
+		"txn_error", s.Err.Error(),
+		"requeued_aiop_hashes", requeued,
+		"dropped_aiop_hashes", dh,
+		"dropped_aiop_reasons", dr,
+	)
+}
+
+func (i *Bundler) revalidate(ctx context.Context, ep common.Address, op *aiop.AiOperation) error {
+	ctx, cancel := utils.WithTimeout(ctx, i.callTimeout)
+	defer cancel()
+
+	hctx, err := modules.NewAiOpHandlerContext(ctx, op, ep, i.chainID, i.mempool, i.gs)
+	if err != nil {
+		return err
 	}
-	l = l.WithValues("batch_aiop_hashes", bat)
-	l = l.WithValues("dropped_aiop_hashes", dh)
-	l = l.WithValues("dropped_aiop_reasons", dr)
+	return i.revalidateHandler(hctx)
+}
 
-	for k, v := range ctx.Data {
-		l = l.WithValues(k, v)
+// isRejected returns true if err is a validation rejection of the AiOperation. Any other error, such as a
+// failed node call or a timeout, says nothing about the AiOperation itself.
+func isRejected(err error) bool {
+	var rpcErr *errors.RPCError
+	return stdErr.As(err, &rpcErr)
+}
+
+// processWithBreaker calls Process for an AiMiddleware unless its circuit breaker is open.
+func (i *Bundler) processWithBreaker(ctx context.Context, ep common.Address) {
+	b := i.breakers[ep]
+	if !b.allow(time.Now()) {
+		return
+	}
+
+	prev := b.status(ep).State
+	_, err := i.Process(ctx, ep)
+	state := b.record(time.Now(), err)
+	if state != prev {
+		i.logger.Info(
+			"bundler circuit breaker changed",
+			"aimiddleware", ep.String(),
+			"chain_id", i.chainID.String(),
+			"from", string(prev),
+			"to", string(state),
+		)
 	}
-	l = l.WithValues("duration", time.Since(start))
-	l.Info("bundler run ok")
-	return ctx, nil
 }
 
 // Run starts a goroutine that will continuously process batches from the mempool.
@@ -198,18 +623,25 @@ func (i *Bundler) Run() error {
 		return nil
 	}
 
-	ticker := time.NewTicker(1 * time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(i.interval)
 	go func(i *Bundler) {
 		for {
 			select {
 			case <-i.done:
 				return
 			case <-ticker.C:
+				i.heartbeat.Store(time.Now().UnixNano())
+				if i.IsPaused() {
+					continue
+				}
 				for _, ep := range i.supportedAiMiddlewares {
-					_, err := i.Process(ep)
+					err := utils.Recover(func() error {
+						i.processWithBreaker(ctx, ep)
+						return nil
+					})
 					if err != nil {
-						// Already logged.
-						continue
+						i.logger.Error(err, "bundler run error", "aimiddleware", ep.String())
 					}
 				}
 			}
@@ -217,17 +649,40 @@ func (i *Bundler) Run() error {
 	}(i)
 
 	i.isRunning = true
-	i.stop = ticker.Stop
+	i.stop = func() {
+		ticker.Stop()
+		cancel()
+	}
 	return nil
 }
 
-// Stop signals the bundler to stop continuously processing batches from the mempool.
+// Stop signals the bundler to stop continuously processing batches from the mempool. A batch that is being
+// processed is allowed to finish first.
 func (i *Bundler) Stop() {
 	if !i.isRunning {
 		return
 	}
 
 	i.isRunning = false
-	i.stop()
 	i.done <- true
+	i.stop()
+}
+
+// Shutdown is the same as Stop but only waits for the batch that is being processed until ctx is done. After
+// that, the batch is cancelled and an error is returned.
+func (i *Bundler) Shutdown(ctx context.Context) error {
+	if !i.isRunning {
+		return nil
+	}
+
+	i.isRunning = false
+	select {
+	case i.done <- true:
+		i.stop()
+		return nil
+	case <-ctx.Done():
+		i.stop()
+		i.done <- true
+		return ctx.Err()
+	}
 }
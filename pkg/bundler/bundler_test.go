@@ -0,0 +1,396 @@
+package bundler
+
+import (
+	"context"
+	stdErr "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/transaction"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func newPendingInclusionBundler(t *testing.T, op *aiop.AiOperation) (*Bundler, *mempool.Mempool) {
+	db := testutils.DBMock()
+	t.Cleanup(func() { db.Close() })
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.AddOp(testutils.ValidAddress1, op); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(mem, testutils.ChainID, []common.Address{testutils.ValidAddress1})
+	b.UseModules(func(ctx *modules.BatchHandlerCtx) error {
+		ctx.PendingInclusion = true
+		return nil
+	})
+	return b, mem
+}
+
+func settled(op *aiop.AiOperation, err error) *transaction.Settled {
+	return &transaction.Settled{
+		Submitted: &transaction.Submitted{
+			Txn:  types.NewTx(&types.DynamicFeeTx{}),
+			Opts: &transaction.Opts{AiMiddleware: testutils.ValidAddress1, Batch: []*aiop.AiOperation{op}},
+		},
+		Err: err,
+	}
+}
+
+// TestProcessKeepsOpsPendingInclusion verifies that ops in a batch pending inclusion stay in the mempool but
+// are not included in the next batch.
+func TestProcessKeepsOpsPendingInclusion(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, mem := newPendingInclusionBundler(t, op)
+
+	if ctx, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if len(ctx.Batch) != 1 {
+		t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+	}
+	if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(ops))
+	}
+	if ctx, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if ctx != nil {
+		t.Fatalf("got batch length %d, want 0", len(ctx.Batch))
+	}
+}
+
+// TestTrackInflightExcludesRestoredOps verifies that ops from a transaction sent before the Bundler started are
+// not added to a batch until the transaction is settled.
+func TestTrackInflightExcludesRestoredOps(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, _ := newPendingInclusionBundler(t, op)
+	s := settled(op, transaction.ErrTxnTimeout)
+	b.TrackInflight(s.Submitted)
+
+	if ctx, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if ctx != nil {
+		t.Fatalf("got batch length %d, want 0", len(ctx.Batch))
+	}
+
+	b.OnTxnSettled(s)
+	if ctx, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if ctx == nil || len(ctx.Batch) != 1 {
+		t.Fatal("settled op not in next batch")
+	}
+}
+
+// TestOnTxnSettledRemovesIncludedOps verifies that ops are removed from the mempool once the transaction is
+// included.
+func TestOnTxnSettledRemovesIncludedOps(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, mem := newPendingInclusionBundler(t, op)
+	_, _ = b.Process(context.Background(), testutils.ValidAddress1)
+
+	b.OnTxnSettled(settled(op, nil))
+	if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 0 {
+		t.Fatalf("got mempool length %d, want 0", len(ops))
+	}
+}
+
+// TestSettledModulesRunOnInclusion verifies that ops in a batch pending inclusion are only passed to the
+// settled modules once the transaction is included.
+func TestSettledModulesRunOnInclusion(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, _ := newPendingInclusionBundler(t, op)
+	calls := []*modules.BatchHandlerCtx{}
+	b.UseSettledModules(func(ctx *modules.BatchHandlerCtx) error {
+		calls = append(calls, ctx)
+		return nil
+	})
+
+	_, _ = b.Process(context.Background(), testutils.ValidAddress1)
+	if len(calls) != 0 {
+		t.Fatalf("got %d settled calls before inclusion, want 0", len(calls))
+	}
+
+	b.OnTxnSettled(settled(op, nil))
+	if len(calls) != 1 {
+		t.Fatalf("got %d settled calls, want 1", len(calls))
+	} else if len(calls[0].Batch) != 1 || len(calls[0].PendingRemoval) != 0 {
+		t.Fatalf(
+			"got %d included and %d dropped, want 1 and 0",
+			len(calls[0].Batch),
+			len(calls[0].PendingRemoval),
+		)
+	}
+}
+
+// TestSettledModulesSkipFailedBundles verifies that ops from a failed transaction are not passed to the
+// settled modules as included, while ops that fail re-validation are passed as dropped.
+func TestSettledModulesSkipFailedBundles(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, _ := newPendingInclusionBundler(t, op)
+	calls := []*modules.BatchHandlerCtx{}
+	b.UseSettledModules(func(ctx *modules.BatchHandlerCtx) error {
+		calls = append(calls, ctx)
+		return nil
+	})
+	_, _ = b.Process(context.Background(), testutils.ValidAddress1)
+
+	b.OnTxnSettled(settled(op, transaction.ErrTxnFailed))
+	if len(calls) != 0 {
+		t.Fatalf("got %d settled calls for requeued ops, want 0", len(calls))
+	}
+
+	_, _ = b.Process(context.Background(), testutils.ValidAddress1)
+	b.UseRevalidationModules(func(ctx *modules.AiOpHandlerCtx) error {
+		return errors.NewRPCError(errors.REJECTED_BY_EP_OR_ACCOUNT, "invalid", nil)
+	})
+	b.OnTxnSettled(settled(op, transaction.ErrTxnFailed))
+	if len(calls) != 1 {
+		t.Fatalf("got %d settled calls, want 1", len(calls))
+	} else if len(calls[0].Batch) != 0 || len(calls[0].PendingRemoval) != 1 {
+		t.Fatalf(
+			"got %d included and %d dropped, want 0 and 1",
+			len(calls[0].Batch),
+			len(calls[0].PendingRemoval),
+		)
+	}
+}
+
+// TestOnOpsIncludedRunsSettledModules verifies that ops included by another bundler are passed to the settled
+// modules even if the Bundler is in dry-run mode.
+func TestOnOpsIncludedRunsSettledModules(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, _ := newPendingInclusionBundler(t, op)
+	b.SetDryRun(true)
+	calls := []*modules.BatchHandlerCtx{}
+	b.UseSettledModules(func(ctx *modules.BatchHandlerCtx) error {
+		calls = append(calls, ctx)
+		return nil
+	})
+
+	hash := op.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID)
+	aggs := map[common.Hash]common.Address{hash: testutils.ValidAddress2}
+	err := b.OnOpsIncluded(context.Background(), testutils.ValidAddress1, []*aiop.AiOperation{op}, aggs)
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("got %d settled calls, want 1", len(calls))
+	} else if calls[0].DryRun || len(calls[0].Batch) != 1 {
+		t.Fatalf("got dry run %t and %d included, want false and 1", calls[0].DryRun, len(calls[0].Batch))
+	} else if calls[0].GetAggregator(op) != testutils.ValidAddress2 {
+		t.Fatalf("got aggregator %s, want %s", calls[0].GetAggregator(op), testutils.ValidAddress2)
+	}
+}
+
+// TestOnTxnSettledRequeuesTimedOutOps verifies that ops from a transaction that was not included are
+// returned to the next batch.
+func TestOnTxnSettledRequeuesTimedOutOps(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, _ := newPendingInclusionBundler(t, op)
+	_, _ = b.Process(context.Background(), testutils.ValidAddress1)
+
+	b.OnTxnSettled(settled(op, transaction.ErrTxnTimeout))
+	if ctx, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if ctx == nil || len(ctx.Batch) != 1 {
+		t.Fatal("requeued op not in next batch")
+	}
+}
+
+// TestOnTxnSettledRequeuesOnNodeError verifies that an op is returned to the next batch if re-validation fails
+// with an error from the node instead of a rejection.
+func TestOnTxnSettledRequeuesOnNodeError(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, mem := newPendingInclusionBundler(t, op)
+	b.UseRevalidationModules(func(ctx *modules.AiOpHandlerCtx) error {
+		return stdErr.New("connection refused")
+	})
+	_, _ = b.Process(context.Background(), testutils.ValidAddress1)
+
+	b.OnTxnSettled(settled(op, transaction.ErrTxnFailed))
+	if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(ops))
+	}
+	if ctx, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if ctx == nil || len(ctx.Batch) != 1 {
+		t.Fatal("requeued op not in next batch")
+	}
+}
+
+// TestRunRecoversFromPanic verifies that a panic in a module is logged and the Bundler keeps processing
+// batches.
+func TestRunRecoversFromPanic(t *testing.T) {
+	b, _ := newPendingInclusionBundler(t, testutils.MockValidInitAiOp())
+	b.SetInterval(time.Millisecond)
+	calls := make(chan bool, 2)
+	b.UseModules(func(ctx *modules.BatchHandlerCtx) error {
+		select {
+		case calls <- true:
+		default:
+		}
+		panic("boom")
+	})
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			t.Fatalf("got %d runs, want 2", i)
+		}
+	}
+}
+
+// runBlocked starts the Bundler with a module that blocks until release is closed or the batch is cancelled.
+// It returns once the module is running and the returned channel receives the batch's context error.
+func runBlocked(t *testing.T, b *Bundler, release chan bool) chan error {
+	var once sync.Once
+	started := make(chan bool)
+	result := make(chan error, 1)
+	b.SetInterval(time.Millisecond)
+	b.UseModules(func(ctx *modules.BatchHandlerCtx) error {
+		once.Do(func() { close(started) })
+		select {
+		case <-release:
+		case <-ctx.Context().Done():
+		}
+		select {
+		case result <- ctx.Context().Err():
+		default:
+		}
+		return nil
+	})
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("batch not started")
+	}
+	return result
+}
+
+// TestStopFinishesCurrentBatch verifies that Stop waits for the batch being processed instead of cancelling
+// it.
+func TestStopFinishesCurrentBatch(t *testing.T) {
+	b, _ := newPendingInclusionBundler(t, testutils.MockValidInitAiOp())
+	release := make(chan bool)
+	result := runBlocked(t, b, release)
+
+	stopped := make(chan bool)
+	go func() {
+		b.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("got stopped before the batch finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("got batch err %v, want nil", err)
+	}
+	<-stopped
+}
+
+// TestShutdownCancelsBatchAtDeadline verifies that Shutdown cancels the batch being processed once ctx is
+// done.
+func TestShutdownCancelsBatchAtDeadline(t *testing.T) {
+	b, _ := newPendingInclusionBundler(t, testutils.MockValidInitAiOp())
+	result := runBlocked(t, b, make(chan bool))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Shutdown(ctx); !stdErr.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got err %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-result; !stdErr.Is(err, context.Canceled) {
+		t.Fatalf("got batch err %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestPauseRequiresAllReasonsCleared verifies that the Bundler stays paused until every reason is resumed.
+func TestPauseRequiresAllReasonsCleared(t *testing.T) {
+	b, _ := newPendingInclusionBundler(t, testutils.MockValidInitAiOp())
+
+	b.Pause("a")
+	b.Pause("b")
+	b.Resume("a")
+	if !b.IsPaused() {
+		t.Fatal("got unpaused, want paused")
+	}
+
+	b.Resume("b")
+	if b.IsPaused() {
+		t.Fatal("got paused, want unpaused")
+	}
+}
+
+// TestPreviewDoesNotChangeMempool verifies that a preview runs modules in dry-run and preview mode and keeps
+// every op in the mempool, including ops the modules marked for removal.
+func TestPreviewDoesNotChangeMempool(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	b, mem := newPendingInclusionBundler(t, op)
+	b.UseModules(func(ctx *modules.BatchHandlerCtx) error {
+		if !ctx.DryRun || !ctx.Preview {
+			t.Fatalf("got DryRun %t and Preview %t, want true and true", ctx.DryRun, ctx.Preview)
+		}
+		ctx.MarkOpIndexForRemoval(0, "test")
+		return nil
+	})
+
+	ctx, err := b.Preview(context.Background(), testutils.ValidAddress1)
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if len(ctx.PendingRemoval) != 1 {
+		t.Fatalf("got pending removal length %d, want 1", len(ctx.PendingRemoval))
+	}
+	if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(ops))
+	}
+}
+
+// TestProcessDryRunKeepsBatch verifies that a dry-run bundler keeps the batch in the mempool for the next run
+// but still removes dropped ops.
+func TestProcessDryRunKeepsBatch(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Sender = testutils.ValidAddress2
+	b, mem := newPendingInclusionBundler(t, op1)
+	if err := mem.AddOp(testutils.ValidAddress1, op2); err != nil {
+		t.Fatal(err)
+	}
+	b.SetDryRun(true)
+	b.UseModules(func(ctx *modules.BatchHandlerCtx) error {
+		ctx.MarkOpIndexForRemoval(1, "test")
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if ctx, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		} else if len(ctx.Batch) != 1 {
+			t.Fatalf("got batch length %d, want 1", len(ctx.Batch))
+		}
+		if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 1 {
+			t.Fatalf("got mempool length %d, want 1", len(ops))
+		}
+		b.UseModules(func(ctx *modules.BatchHandlerCtx) error { return nil })
+	}
+}
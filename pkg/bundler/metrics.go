@@ -0,0 +1,138 @@
+package bundler
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// maxReasonLength is the max length of a drop reason before it is reported as "other". Reasons are used as a
+// metric attribute and must have a low cardinality.
+const maxReasonLength = 64
+
+var aaErrorCode = regexp.MustCompile(`^AA\d\d`)
+
+// metrics holds the instruments for each bundler run. A nil value records nothing.
+type metrics struct {
+	bundlesSent metric.Int64Counter
+	bundleSize  metric.Int64Histogram
+	gasUsed     metric.Int64Histogram
+	dropped     metric.Int64Counter
+	deferred    metric.Int64Counter
+}
+
+func newMetrics(meter metric.Meter) (*metrics, error) {
+	bundlesSent, err := meter.Int64Counter(
+		"bundler_bundles_sent",
+		metric.WithDescription("The number of bundle transactions sent to the node."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bundleSize, err := meter.Int64Histogram(
+		"bundler_bundle_size",
+		metric.WithDescription("The number of AiOperations in each sent bundle."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	gasUsed, err := meter.Int64Histogram(
+		"bundler_bundle_gas_used",
+		metric.WithDescription("The gas used by each included handleOps transaction."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped, err := meter.Int64Counter(
+		"bundler_aiops_dropped",
+		metric.WithDescription("The number of AiOperations dropped from the mempool by reason."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deferred, err := meter.Int64Counter(
+		"bundler_aiops_deferred",
+		metric.WithDescription("The number of AiOperations skipped for a batch but kept in the mempool by reason."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metrics{
+		bundlesSent: bundlesSent,
+		bundleSize:  bundleSize,
+		gasUsed:     gasUsed,
+		dropped:     dropped,
+		deferred:    deferred,
+	}, nil
+}
+
+func (m *metrics) recordBundleSent(ep common.Address, size int) {
+	if m == nil {
+		return
+	}
+	attrs := metric.WithAttributes(attribute.String("aimiddleware", ep.String()))
+	m.bundlesSent.Add(context.Background(), 1, attrs)
+	m.bundleSize.Record(context.Background(), int64(size), attrs)
+}
+
+func (m *metrics) recordGasUsed(ep common.Address, gasUsed uint64) {
+	if m == nil {
+		return
+	}
+	m.gasUsed.Record(
+		context.Background(),
+		int64(gasUsed),
+		metric.WithAttributes(attribute.String("aimiddleware", ep.String())),
+	)
+}
+
+func (m *metrics) recordDropped(ep common.Address, reason string) {
+	if m == nil {
+		return
+	}
+	m.dropped.Add(
+		context.Background(),
+		1,
+		metric.WithAttributes(
+			attribute.String("aimiddleware", ep.String()),
+			attribute.String("reason", normalizeReason(reason)),
+		),
+	)
+}
+
+func (m *metrics) recordDeferred(ep common.Address, reason string) {
+	if m == nil {
+		return
+	}
+	m.deferred.Add(
+		context.Background(),
+		1,
+		metric.WithAttributes(
+			attribute.String("aimiddleware", ep.String()),
+			attribute.String("reason", normalizeReason(reason)),
+		),
+	)
+}
+
+// normalizeReason reduces a drop reason to a value with a low cardinality. AiMiddleware errors are reported
+// by their AAxx code and reasons that include dynamic values such as addresses or revert data are reported as
+// "other".
+func normalizeReason(reason string) string {
+	reason = strings.TrimSpace(reason)
+	if code := aaErrorCode.FindString(reason); code != "" {
+		return code
+	}
+	if reason == "" || strings.Contains(reason, "0x") || len(reason) > maxReasonLength {
+		return "other"
+	}
+	return reason
+}
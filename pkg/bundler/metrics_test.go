@@ -0,0 +1,28 @@
+package bundler
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestNormalizeReason verifies that drop reasons are reduced to a low cardinality value before they are used
+// as a metric attribute.
+func TestNormalizeReason(t *testing.T) {
+	cases := []struct {
+		reason string
+		want   string
+	}{
+		{reason: "op expired", want: "op expired"},
+		{reason: "AA25 invalid account nonce", want: "AA25"},
+		{reason: "AA33 reverted: 0x1234", want: "AA33"},
+		{reason: "paymaster 0xabc has insufficient deposit", want: "other"},
+		{reason: strings.Repeat("a", maxReasonLength+1), want: "other"},
+		{reason: "", want: "other"},
+	}
+
+	for _, c := range cases {
+		if got := normalizeReason(c.reason); got != c.want {
+			t.Fatalf("%q: got %q, want %q", c.reason, got, c.want)
+		}
+	}
+}
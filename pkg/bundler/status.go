@@ -0,0 +1,91 @@
+package bundler
+
+import (
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DeferReasonMaxBatch is recorded for AiOperations that did not fit in the max batch size.
+const DeferReasonMaxBatch = "max batch size reached"
+
+const (
+	AiOpNotFound = "not_found"
+	AiOpPending  = "pending"
+	AiOpInflight = "inflight"
+	AiOpDeferred = "deferred"
+)
+
+// AiOpStatus is the state of an AiOperation in the mempool from the Bundler's point of view.
+type AiOpStatus struct {
+	Status         string     `json:"status"`
+	Reason         string     `json:"reason,omitempty"`
+	DeferredCount  int        `json:"deferredCount,omitempty"`
+	LastDeferredAt *time.Time `json:"lastDeferredAt,omitempty"`
+}
+
+type deferral struct {
+	reason string
+	count  int
+	at     time.Time
+}
+
+// setDeferred replaces the deferrals for an AiMiddleware with the ones from the latest run. An op deferred
+// in consecutive runs keeps a running count.
+func (i *Bundler) setDeferred(ep common.Address, items []*modules.DeferredItem) {
+	i.deferredMu.Lock()
+	defer i.deferredMu.Unlock()
+
+	prev := i.deferred[ep]
+	next := make(map[common.Hash]*deferral)
+	now := time.Now()
+	for _, item := range items {
+		hash := item.Op.GetAiOpHash(ep, i.chainID)
+		d := &deferral{reason: item.Reason, count: 1, at: now}
+		if p, ok := prev[hash]; ok {
+			d.count = p.count + 1
+		}
+		next[hash] = d
+	}
+	i.deferred[ep] = next
+}
+
+// AiOpStatus returns whether an AiOperation is pending, part of a transaction waiting to be included, or was
+// deferred from the latest batch along with the reason.
+func (i *Bundler) AiOpStatus(ep common.Address, hash common.Hash) (*AiOpStatus, error) {
+	ops, err := i.mempool.Dump(ep)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, op := range ops {
+		if op.GetAiOpHash(ep, i.chainID) == hash {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return &AiOpStatus{Status: AiOpNotFound}, nil
+	}
+
+	i.inflightMu.Lock()
+	inflight := i.inflight[hash]
+	i.inflightMu.Unlock()
+	if inflight {
+		return &AiOpStatus{Status: AiOpInflight}, nil
+	}
+
+	i.deferredMu.Lock()
+	defer i.deferredMu.Unlock()
+	if d, ok := i.deferred[ep][hash]; ok {
+		at := d.at
+		return &AiOpStatus{
+			Status:         AiOpDeferred,
+			Reason:         d.reason,
+			DeferredCount:  d.count,
+			LastDeferredAt: &at,
+		}, nil
+	}
+	return &AiOpStatus{Status: AiOpPending}, nil
+}
@@ -0,0 +1,85 @@
+package bundler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestAiOpStatusDeferred verifies that an op deferred by a module stays in the mempool and reports the
+// reason and the number of consecutive runs it was deferred for.
+func TestAiOpStatusDeferred(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := testutils.MockValidInitAiOp()
+	if err := mem.AddOp(testutils.ValidAddress1, op); err != nil {
+		t.Fatal(err)
+	}
+	hash := op.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID)
+
+	b := New(mem, testutils.ChainID, []common.Address{testutils.ValidAddress1})
+	if s, err := b.AiOpStatus(testutils.ValidAddress1, hash); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if s.Status != AiOpPending {
+		t.Fatalf("got %s, want %s", s.Status, AiOpPending)
+	}
+
+	b.UseModules(func(ctx *modules.BatchHandlerCtx) error {
+		ctx.DeferOpIndex(0, "test")
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := b.Process(context.Background(), testutils.ValidAddress1); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		}
+	}
+
+	if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(ops))
+	}
+	if s, err := b.AiOpStatus(testutils.ValidAddress1, hash); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if s.Status != AiOpDeferred {
+		t.Fatalf("got %s, want %s", s.Status, AiOpDeferred)
+	} else if s.Reason != "test" {
+		t.Fatalf("got reason %s, want test", s.Reason)
+	} else if s.DeferredCount != 2 {
+		t.Fatalf("got count %d, want 2", s.DeferredCount)
+	}
+}
+
+// TestAiOpStatusMaxBatch verifies that ops over the max batch size are deferred instead of silently skipped.
+func TestAiOpStatusMaxBatch(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Sender = testutils.ValidAddress2
+	for _, op := range []*aiop.AiOperation{op1, op2} {
+		if err := mem.AddOp(testutils.ValidAddress1, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := New(mem, testutils.ChainID, []common.Address{testutils.ValidAddress1})
+	b.SetMaxBatch(1)
+	ctx, err := b.Process(context.Background(), testutils.ValidAddress1)
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if len(ctx.Deferred) != 1 || ctx.Deferred[0].Reason != DeferReasonMaxBatch {
+		t.Fatal("overflow op not deferred")
+	}
+}
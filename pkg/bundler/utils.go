@@ -2,9 +2,10 @@ package bundler
 
 import "github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 
-func adjustBatchSize(max int, batch []*aiop.AiOperation) []*aiop.AiOperation {
+// adjustBatchSize splits the batch at the max size. The second value holds the ops that did not fit.
+func adjustBatchSize(max int, batch []*aiop.AiOperation) ([]*aiop.AiOperation, []*aiop.AiOperation) {
 	if len(batch) > max && max > 0 {
-		return batch[:max]
+		return batch[:max], batch[max:]
 	}
-	return batch
+	return batch, nil
 }
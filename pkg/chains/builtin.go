@@ -0,0 +1,102 @@
+package chains
+
+import (
+	"math/big"
+	"time"
+)
+
+var (
+	EthereumChainID        = big.NewInt(1)
+	GoerliChainID          = big.NewInt(5)
+	SepoliaChainID         = big.NewInt(11155111)
+	ArbitrumOneChainID     = big.NewInt(42161)
+	ArbitrumGoerliChainID  = big.NewInt(421613)
+	ArbitrumSepoliaChainID = big.NewInt(421614)
+	OptimismChainID        = big.NewInt(10)
+	OptimismGoerliChainID  = big.NewInt(420)
+	OptimismSepoliaChainID = big.NewInt(11155420)
+	BaseChainID            = big.NewInt(8453)
+	BaseGoerliChainID      = big.NewInt(84531)
+	BaseSepoliaChainID     = big.NewInt(84532)
+	LyraChainID            = big.NewInt(957)
+	LyraSepoliaChainID     = big.NewInt(902)
+	Ancient8SepoliaChainID = big.NewInt(28122024)
+)
+
+// rollupDefaults returns a profile with the defaults shared by every chain of a rollup type.
+func rollupDefaults(rollup Rollup) Profile {
+	p := Profile{
+		Rollup:         L1,
+		L1FeeModel:     NoL1Fee,
+		MinPriorityFee: big.NewInt(0),
+		MaxLogRange:    DefaultMaxLogRange,
+	}
+	switch rollup {
+	case OpStack:
+		p.Rollup = OpStack
+		p.L1FeeModel = OpL1Fee
+		p.PVGBufferFactor = 1
+		p.BlockTime = 2 * time.Second
+		p.MaxBlockAge = 30 * time.Second
+	case ArbStack:
+		p.Rollup = ArbStack
+		p.L1FeeModel = ArbL1Fee
+		p.PVGBufferFactor = 16
+		p.BlockTime = 250 * time.Millisecond
+		p.MaxBlockAge = 120 * time.Second
+	}
+	return p
+}
+
+func ethereum(name string) *Profile {
+	p := rollupDefaults(L1)
+	p.Name = name
+	p.BlockTime = 12 * time.Second
+	p.MaxBlockAge = 60 * time.Second
+	p.BuilderCompatible = true
+	return &p
+}
+
+func opStack(name string) *Profile {
+	p := rollupDefaults(OpStack)
+	p.Name = name
+	return &p
+}
+
+func arbStack(name string) *Profile {
+	p := rollupDefaults(ArbStack)
+	p.Name = name
+	return &p
+}
+
+// builtins are the profiles of known chains keyed by chain ID.
+var builtins = map[uint64]*Profile{
+	EthereumChainID.Uint64():        ethereum("ethereum"),
+	GoerliChainID.Uint64():          ethereum("goerli"),
+	SepoliaChainID.Uint64():         ethereum("sepolia"),
+	ArbitrumOneChainID.Uint64():     arbStack("arbitrum_one"),
+	ArbitrumGoerliChainID.Uint64():  arbStack("arbitrum_goerli"),
+	ArbitrumSepoliaChainID.Uint64(): arbStack("arbitrum_sepolia"),
+	OptimismChainID.Uint64():        opStack("optimism"),
+	OptimismGoerliChainID.Uint64():  opStack("optimism_goerli"),
+	OptimismSepoliaChainID.Uint64(): opStack("optimism_sepolia"),
+	BaseChainID.Uint64():            opStack("base"),
+	BaseGoerliChainID.Uint64():      opStack("base_goerli"),
+	BaseSepoliaChainID.Uint64():     opStack("base_sepolia"),
+	LyraChainID.Uint64():            opStack("lyra"),
+	LyraSepoliaChainID.Uint64():     opStack("lyra_sepolia"),
+	Ancient8SepoliaChainID.Uint64(): opStack("ancient8_sepolia"),
+}
+
+// Lookup returns a copy of the built-in profile for a chain. Unknown chains use a generic L1 profile with
+// no block time, which polls every DefaultPollInterval and only checks eth_syncing for node sync.
+func Lookup(chain *big.Int) *Profile {
+	if chain != nil && chain.IsUint64() {
+		if p, ok := builtins[chain.Uint64()]; ok {
+			return p.Apply(nil)
+		}
+	}
+	p := rollupDefaults(L1)
+	p.Name = "unknown"
+	return &p
+}
@@ -0,0 +1,164 @@
+// Package chains defines the network specific behavior of the bundler for each chain.
+package chains
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Rollup is the type of network a chain runs on.
+type Rollup string
+
+// L1FeeModel determines how the L1 data fee of a rollup is added to the preVerificationGas.
+type L1FeeModel string
+
+const (
+	L1       Rollup = "l1"
+	OpStack  Rollup = "op_stack"
+	ArbStack Rollup = "arb_stack"
+
+	// NoL1Fee is used by chains that only charge for L2 execution.
+	NoL1Fee L1FeeModel = "none"
+
+	// OpL1Fee reads the L1 data fee from the OP Stack GasPriceOracle.
+	OpL1Fee L1FeeModel = "op_stack"
+
+	// ArbL1Fee reads the L1 data fee from the Arbitrum NodeInterface.
+	ArbL1Fee L1FeeModel = "arbitrum"
+)
+
+var (
+	// DefaultPollInterval is the max time between each run of the bundler and block cache.
+	DefaultPollInterval = 1 * time.Second
+
+	// DefaultMaxLogRange is the max number of blocks in a single eth_getLogs query.
+	DefaultMaxLogRange uint64 = 1000
+)
+
+// Profile holds the behavior of the bundler that differs between chains.
+type Profile struct {
+	// Name identifies the profile in logs.
+	Name string
+
+	// Rollup and L1FeeModel determine how preVerificationGas is calculated. PVGBufferFactor is the
+	// percentage added to the preVerificationGas during estimation to account for a variable L1 fee.
+	Rollup          Rollup
+	L1FeeModel      L1FeeModel
+	PVGBufferFactor int64
+
+	// LegacyFees sends legacy transactions even if the chain reports a basefee. MinPriorityFee is the lowest
+	// tip used for EIP-1559 transactions, which some chains require to include a transaction.
+	LegacyFees     bool
+	MinPriorityFee *big.Int
+
+	// BlockTime is the expected time between blocks. MaxBlockAge is the max age of the latest block before a
+	// node is considered out of sync. A MaxBlockAge of 0 only checks eth_syncing.
+	BlockTime   time.Duration
+	MaxBlockAge time.Duration
+
+	// MinPollInterval is the shortest time between each run of the bundler and block cache on chains with fast
+	// blocks. A value of 0 uses DefaultPollInterval so that faster polling is opt-in.
+	MinPollInterval time.Duration
+
+	// MaxLogRange is the max number of blocks the node allows in a single eth_getLogs query.
+	MaxLogRange uint64
+
+	// BuilderCompatible is true if the chain supports the Block Builder API used in searcher mode.
+	BuilderCompatible bool
+}
+
+// PollInterval returns the time between each run of the bundler and block cache. Chains with blocks faster
+// than DefaultPollInterval are polled once per block, but no more often than MinPollInterval.
+func (p *Profile) PollInterval() time.Duration {
+	floor := p.MinPollInterval
+	if floor <= 0 {
+		floor = DefaultPollInterval
+	}
+	if p.BlockTime > 0 && p.BlockTime < DefaultPollInterval {
+		if p.BlockTime < floor {
+			return floor
+		}
+		return p.BlockTime
+	}
+	return DefaultPollInterval
+}
+
+// Overrides replaces any field of a Profile that is not nil.
+type Overrides struct {
+	Rollup            *Rollup
+	L1FeeModel        *L1FeeModel
+	PVGBufferFactor   *int64
+	LegacyFees        *bool
+	MinPriorityFee    *big.Int
+	BlockTime         *time.Duration
+	MaxBlockAge       *time.Duration
+	MinPollInterval   *time.Duration
+	MaxLogRange       *uint64
+	BuilderCompatible *bool
+}
+
+// Apply returns a copy of the Profile with the overrides set. If the rollup is changed, the L1 fee model and
+// PVG buffer of the new rollup are used unless they are also overridden.
+func (p *Profile) Apply(o *Overrides) *Profile {
+	out := *p
+	if p.MinPriorityFee != nil {
+		out.MinPriorityFee = new(big.Int).Set(p.MinPriorityFee)
+	}
+	if o == nil {
+		return &out
+	}
+
+	if o.Rollup != nil && *o.Rollup != p.Rollup {
+		r := rollupDefaults(*o.Rollup)
+		out.Rollup, out.L1FeeModel, out.PVGBufferFactor = r.Rollup, r.L1FeeModel, r.PVGBufferFactor
+	}
+	if o.L1FeeModel != nil {
+		out.L1FeeModel = *o.L1FeeModel
+	}
+	if o.PVGBufferFactor != nil {
+		out.PVGBufferFactor = *o.PVGBufferFactor
+	}
+	if o.LegacyFees != nil {
+		out.LegacyFees = *o.LegacyFees
+	}
+	if o.MinPriorityFee != nil {
+		out.MinPriorityFee = new(big.Int).Set(o.MinPriorityFee)
+	}
+	if o.BlockTime != nil {
+		out.BlockTime = *o.BlockTime
+	}
+	if o.MaxBlockAge != nil {
+		out.MaxBlockAge = *o.MaxBlockAge
+	}
+	if o.MinPollInterval != nil {
+		out.MinPollInterval = *o.MinPollInterval
+	}
+	if o.MaxLogRange != nil {
+		out.MaxLogRange = *o.MaxLogRange
+	}
+	if o.BuilderCompatible != nil {
+		out.BuilderCompatible = *o.BuilderCompatible
+	}
+	return &out
+}
+
+// ParseRollup returns an error if the value is not a supported Rollup.
+func ParseRollup(s string) (Rollup, error) {
+	switch r := Rollup(s); r {
+	case L1, OpStack, ArbStack:
+		return r, nil
+	default:
+		return "", fmt.Errorf("%q is not a valid rollup, must be %s, %s, or %s", s, L1, OpStack, ArbStack)
+	}
+}
+
+// ParseL1FeeModel returns an error if the value is not a supported L1FeeModel.
+func ParseL1FeeModel(s string) (L1FeeModel, error) {
+	switch m := L1FeeModel(s); m {
+	case NoL1Fee, OpL1Fee, ArbL1Fee:
+		return m, nil
+	default:
+		return "", fmt.Errorf("%q is not a valid l1 fee model, must be %s, %s, or %s", s, NoL1Fee, OpL1Fee, ArbL1Fee)
+	}
+}
@@ -0,0 +1,79 @@
+package chains
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+// TestLookupBuiltins verifies the profile of each rollup type and that unknown chains use the L1 defaults.
+func TestLookupBuiltins(t *testing.T) {
+	arb := Lookup(ArbitrumOneChainID)
+	if arb.Rollup != ArbStack || arb.L1FeeModel != ArbL1Fee || arb.PVGBufferFactor != 16 {
+		t.Fatalf("got arbitrum profile %+v", arb)
+	}
+	op := Lookup(BaseChainID)
+	if op.Rollup != OpStack || op.L1FeeModel != OpL1Fee || op.PVGBufferFactor != 1 {
+		t.Fatalf("got base profile %+v", op)
+	}
+	if eth := Lookup(EthereumChainID); !eth.BuilderCompatible || eth.MaxBlockAge != 60*time.Second {
+		t.Fatalf("got ethereum profile %+v", eth)
+	}
+
+	unknown := Lookup(big.NewInt(999999))
+	if unknown.Rollup != L1 || unknown.BuilderCompatible || unknown.MaxBlockAge != 0 {
+		t.Fatalf("got unknown profile %+v", unknown)
+	}
+	if Lookup(nil).Name != "unknown" {
+		t.Fatal("nil chain must use the unknown profile")
+	}
+}
+
+// TestApplyOverrides verifies that only set fields are replaced and that changing the rollup uses its fee
+// model unless that is also overridden.
+func TestApplyOverrides(t *testing.T) {
+	rollup := OpStack
+	buffer := int64(5)
+	p := Lookup(big.NewInt(999999)).Apply(&Overrides{
+		Rollup:          &rollup,
+		PVGBufferFactor: &buffer,
+		MinPriorityFee:  big.NewInt(100),
+	})
+	if p.Rollup != OpStack || p.L1FeeModel != OpL1Fee || p.PVGBufferFactor != 5 {
+		t.Fatalf("got profile %+v", p)
+	}
+	if p.MinPriorityFee.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("got min priority fee %s, want 100", p.MinPriorityFee)
+	}
+	if p.Name != "unknown" || p.MaxLogRange != DefaultMaxLogRange {
+		t.Fatalf("got profile %+v, want fields that are not overridden kept", p)
+	}
+
+	p.MinPriorityFee.SetInt64(1)
+	if Lookup(EthereumChainID).MinPriorityFee.Sign() != 0 {
+		t.Fatal("built-in profiles must not be modified through a copy")
+	}
+}
+
+// TestPollInterval verifies that chains with fast blocks are only polled once per block down to the
+// MinPollInterval that the operator opts in to.
+func TestPollInterval(t *testing.T) {
+	arb := Lookup(ArbitrumOneChainID)
+	if got := arb.PollInterval(); got != DefaultPollInterval {
+		t.Fatalf("got %s, want %s", got, DefaultPollInterval)
+	}
+	arb.MinPollInterval = 500 * time.Millisecond
+	if got := arb.PollInterval(); got != 500*time.Millisecond {
+		t.Fatalf("got %s, want 500ms", got)
+	}
+	arb.MinPollInterval = time.Millisecond
+	if got := arb.PollInterval(); got != 250*time.Millisecond {
+		t.Fatalf("got %s, want 250ms", got)
+	}
+	if got := Lookup(EthereumChainID).PollInterval(); got != DefaultPollInterval {
+		t.Fatalf("got %s, want %s", got, DefaultPollInterval)
+	}
+	if got := Lookup(nil).PollInterval(); got != DefaultPollInterval {
+		t.Fatalf("got %s, want %s", got, DefaultPollInterval)
+	}
+}
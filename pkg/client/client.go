@@ -2,21 +2,27 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"math/big"
+	"time"
 
 	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/filter"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/stake"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/gas"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/noop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/reader"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/state"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Client controls the end to end process of adding incoming AiOperations to the mempool. It also
@@ -28,11 +34,17 @@ type Client struct {
 	supportedAiMiddlewares []common.Address
 	aiOpHandler            modules.AiOpHandlerFunc
 	logger                 logr.Logger
+	metrics                *metrics
 	getAiOpReceipt         GetAiOpReceiptFunc
 	getGasPrices           GetGasPricesFunc
 	getGasEstimate         GetGasEstimateFunc
 	getAiOpByHash          GetAiOpByHashFunc
 	getStakeFunc           stake.GetStakeFunc
+	getBreakerStatus       GetCircuitBreakerStatusFunc
+	getAiOpStatus          GetAiOpStatusFunc
+	getSyncErr             GetSyncErrFunc
+	batchRpc               *rpc.Client
+	batchCache             reader.Cache
 	opLookupLimit          uint64
 }
 
@@ -57,6 +69,9 @@ func New(
 		getGasEstimate:         getGasEstimateNoop(),
 		getAiOpByHash:          getAiOpByHashNoop(),
 		getStakeFunc:           stake.GetStakeFuncNoop(),
+		getBreakerStatus:       getCircuitBreakerStatusNoop(),
+		getAiOpStatus:          getAiOpStatusNoop(),
+		getSyncErr:             getSyncErrNoop(),
 		opLookupLimit:          opLookupLimit,
 	}
 }
@@ -76,11 +91,31 @@ func (i *Client) UseLogger(logger logr.Logger) {
 	i.logger = logger.WithName("client")
 }
 
+// AiMeter defines an opentelemetry meter object used by the Client instance to capture metrics for incoming
+// AiOperations and gas estimates.
+func (i *Client) AiMeter(meter metric.Meter) error {
+	m, err := newMetrics(meter)
+	if err != nil {
+		return err
+	}
+	i.metrics = m
+	return nil
+}
+
 // UseModules defines the AiOpHandlers to process a aiOp after it has gone through the standard checks.
 func (i *Client) UseModules(handlers ...modules.AiOpHandlerFunc) {
 	i.aiOpHandler = modules.ComposeAiOpHandlerFunc(handlers...)
 }
 
+// UseBatchReads enables request-scoped batching and caching of node reads in *Client.SendAiOperation. The
+// deposits, code, and basefee needed by the standard checks are fetched with a single batch request and any
+// module that reads the same values again will hit the cache. An optional longer lived cache can be given to
+// share values across requests.
+func (i *Client) UseBatchReads(rpc *rpc.Client, cache reader.Cache) {
+	i.batchRpc = rpc
+	i.batchCache = cache
+}
+
 // SetGetAiOpReceiptFunc defines a general function for fetching a AiOpReceipt given a aiOpHash and
 // AiMiddleware address. This function is called in *Client.GetAiOperationReceipt.
 func (i *Client) SetGetAiOpReceiptFunc(fn GetAiOpReceiptFunc) {
@@ -113,12 +148,42 @@ func (i *Client) SetGetStakeFunc(fn stake.GetStakeFunc) {
 	i.getStakeFunc = fn
 }
 
+// SetGetCircuitBreakerStatusFunc defines a general function for fetching the circuit breaker state of the
+// bundler. This function is called in *Client.CircuitBreakerStatus.
+func (i *Client) SetGetCircuitBreakerStatusFunc(fn GetCircuitBreakerStatusFunc) {
+	i.getBreakerStatus = fn
+}
+
+// SetGetAiOpStatusFunc defines a general function for fetching the bundler state of an AiOperation. This
+// function is called in *Client.AiOperationStatus.
+func (i *Client) SetGetAiOpStatusFunc(fn GetAiOpStatusFunc) {
+	i.getAiOpStatus = fn
+}
+
+// SetGetSyncErrFunc defines a general function for checking that the node is in sync. This function is
+// called in *Client.SendAiOperation and *Client.EstimateAiOperationGas before any validation.
+func (i *Client) SetGetSyncErrFunc(fn GetSyncErrFunc) {
+	i.getSyncErr = fn
+}
+
 // SendAiOperation implements the method call for eth_sendAiOperation.
 // It returns true if aiOp was accepted otherwise returns an error.
-func (i *Client) SendAiOperation(op map[string]any, ep string) (string, error) {
+func (i *Client) SendAiOperation(ctx context.Context, op map[string]any, ep string) (string, error) {
+	hash, err := i.sendAiOperation(ctx, op, ep)
+	i.metrics.recordSend(ep, err)
+	return hash, err
+}
+
+func (i *Client) sendAiOperation(ctx context.Context, op map[string]any, ep string) (string, error) {
 	// Init logger
 	l := i.logger.WithName("eth_sendAiOperation")
 
+	// Check the node is in sync before validating against its latest block.
+	if err := i.getSyncErr(); err != nil {
+		l.Error(err, "eth_sendAiOperation error")
+		return "", err
+	}
+
 	// Check AiMiddleware and aiOp is valid.
 	epAddr, err := i.parseAiMiddlewareAddress(ep)
 	if err != nil {
@@ -137,8 +202,27 @@ func (i *Client) SendAiOperation(op map[string]any, ep string) (string, error) {
 	hash := aiOp.GetAiOpHash(epAddr, i.chainID)
 	l = l.WithValues("aiop_hash", hash)
 
+	// Prefetch independent reads for the module stack in a single batch.
+	if i.batchRpc != nil {
+		r := reader.New(i.batchRpc)
+		if i.batchCache != nil {
+			r.UseCache(i.batchCache)
+		}
+		ctx = reader.WithReader(ctx, r)
+		if err := r.Load(ctx, reader.Request{
+			AiMiddleware: epAddr,
+			Deposits:     []common.Address{aiOp.Sender, aiOp.GetFactory(), aiOp.GetPaymaster()},
+			Code:         []common.Address{aiOp.Sender, aiOp.GetPaymaster()},
+			Head:         true,
+		}); err != nil {
+			l.Error(err, "eth_sendAiOperation error")
+			return "", err
+		}
+	}
+
 	// Run through client module stack.
-	ctx, err := modules.NewAiOpHandlerContext(
+	opCtx, err := modules.NewAiOpHandlerContext(
+		ctx,
 		aiOp,
 		epAddr,
 		i.chainID,
@@ -149,13 +233,13 @@ func (i *Client) SendAiOperation(op map[string]any, ep string) (string, error) {
 		l.Error(err, "eth_sendAiOperation error")
 		return "", err
 	}
-	if err := i.aiOpHandler(ctx); err != nil {
+	if err := i.aiOpHandler(opCtx); err != nil {
 		l.Error(err, "eth_sendAiOperation error")
 		return "", err
 	}
 
 	// Add aiOp to mempool.
-	if err := i.mempool.AddOp(epAddr, ctx.AiOp); err != nil {
+	if err := i.mempool.AddOp(epAddr, opCtx.AiOp); err != nil {
 		l.Error(err, "eth_sendAiOperation error")
 		return "", err
 	}
@@ -169,6 +253,19 @@ func (i *Client) SendAiOperation(op map[string]any, ep string) (string, error) {
 // values will not be validated although there should be dummy values in place for the most reliable results
 // (e.g. a signature with the correct length).
 func (i *Client) EstimateAiOperationGas(
+	ctx context.Context,
+	op map[string]any,
+	ep string,
+	os map[string]any,
+) (*gas.GasEstimates, error) {
+	start := time.Now()
+	est, err := i.estimateAiOperationGas(ctx, op, ep, os)
+	i.metrics.recordEstimation(ep, start, err)
+	return est, err
+}
+
+func (i *Client) estimateAiOperationGas(
+	ctx context.Context,
 	op map[string]any,
 	ep string,
 	os map[string]any,
@@ -176,6 +273,12 @@ func (i *Client) EstimateAiOperationGas(
 	// Init logger
 	l := i.logger.WithName("eth_estimateAiOperationGas")
 
+	// Check the node is in sync before estimating against its latest block.
+	if err := i.getSyncErr(); err != nil {
+		l.Error(err, "eth_estimateAiOperationGas error")
+		return nil, err
+	}
+
 	// Check AiMiddleware and aiOp is valid.
 	epAddr, err := i.parseAiMiddlewareAddress(ep)
 	if err != nil {
@@ -205,7 +308,7 @@ func (i *Client) EstimateAiOperationGas(
 	// estimations upstream. The default balance override also ensures simulations won't revert on
 	// insufficient funds.
 	if aiOp.MaxFeePerGas.Cmp(common.Big0) != 1 {
-		gp, err := i.getGasPrices()
+		gp, err := i.getGasPrices(ctx)
 		if err != nil {
 			l.Error(err, "eth_estimateAiOperationGas error")
 			return nil, err
@@ -215,14 +318,14 @@ func (i *Client) EstimateAiOperationGas(
 	}
 
 	// Estimate gas limits
-	vg, cg, err := i.getGasEstimate(epAddr, aiOp, sos)
+	vg, cg, err := i.getGasEstimate(ctx, epAddr, aiOp, sos)
 	if err != nil {
 		l.Error(err, "eth_estimateAiOperationGas error")
 		return nil, err
 	}
 
 	// Calculate PreVerificationGas
-	pvg, err := i.ov.CalcPreVerificationGasWithBuffer(aiOp)
+	pvg, err := i.ov.CalcPreVerificationGasWithBuffer(ctx, aiOp)
 	if err != nil {
 		l.Error(err, "eth_estimateAiOperationGas error")
 		return nil, err
@@ -242,12 +345,13 @@ func (i *Client) EstimateAiOperationGas(
 // GetAiOperationReceipt fetches a AiOperation receipt based on a aiOpHash returned by
 // *Client.SendAiOperation.
 func (i *Client) GetAiOperationReceipt(
+	ctx context.Context,
 	hash string,
 ) (*filter.AiOperationReceipt, error) {
 	// Init logger
 	l := i.logger.WithName("eth_getAiOperationReceipt").WithValues("aiop_hash", hash)
 
-	ev, err := i.getAiOpReceipt(hash, i.supportedAiMiddlewares[0], i.opLookupLimit)
+	ev, err := i.getAiOpReceipt(ctx, hash, i.supportedAiMiddlewares[0], i.opLookupLimit)
 	if err != nil {
 		l.Error(err, "eth_getAiOperationReceipt error")
 		return nil, err
@@ -259,11 +363,11 @@ func (i *Client) GetAiOperationReceipt(
 
 // GetAiOperationByHash returns a AiOperation based on a given aiOpHash returned by
 // *Client.SendAiOperation.
-func (i *Client) GetAiOperationByHash(hash string) (*filter.HashLookupResult, error) {
+func (i *Client) GetAiOperationByHash(ctx context.Context, hash string) (*filter.HashLookupResult, error) {
 	// Init logger
 	l := i.logger.WithName("eth_getAiOperationByHash").WithValues("aiop_hash", hash)
 
-	res, err := i.getAiOpByHash(hash, i.supportedAiMiddlewares[0], i.chainID, i.opLookupLimit)
+	res, err := i.getAiOpByHash(ctx, hash, i.supportedAiMiddlewares[0], i.chainID, i.opLookupLimit)
 	if err != nil {
 		l.Error(err, "eth_getAiOperationByHash error")
 		return nil, err
@@ -289,3 +393,20 @@ func (i *Client) SupportedAiMiddlewares() ([]string, error) {
 func (i *Client) ChainID() (string, error) {
 	return hexutil.EncodeBig(i.chainID), nil
 }
+
+// CircuitBreakerStatus implements the method call for bundler_getCircuitBreakerStatus. It returns the circuit
+// breaker state for each supported AiMiddleware.
+func (i *Client) CircuitBreakerStatus() ([]bundler.CircuitBreakerStatus, error) {
+	return i.getBreakerStatus(), nil
+}
+
+// AiOperationStatus implements the method call for bundler_getAiOperationStatus. It returns whether the
+// AiOperation is pending in the mempool, part of a transaction waiting to be included, or was deferred from
+// the latest batch along with the reason.
+func (i *Client) AiOperationStatus(hash string, ep string) (*bundler.AiOpStatus, error) {
+	epAddr, err := i.parseAiMiddlewareAddress(ep)
+	if err != nil {
+		return nil, err
+	}
+	return i.getAiOpStatus(epAddr, common.HexToHash(hash))
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,7 +17,7 @@ import (
 
 // Debug exposes methods used for testing the bundler. These should not be made available in production.
 type Debug struct {
-	eoa          *signer.EOA
+	eoa          signer.Signer
 	eth          *ethclient.Client
 	mempool      *mempool.Mempool
 	rep          *entities.Reputation
@@ -24,10 +25,11 @@ type Debug struct {
 	chainID      *big.Int
 	aimiddleware common.Address
 	beneficiary  common.Address
+	waitSettled  WaitForSettledFunc
 }
 
 func NewDebug(
-	eoa *signer.EOA,
+	eoa signer.Signer,
 	eth *ethclient.Client,
 	mempool *mempool.Mempool,
 	rep *entities.Reputation,
@@ -36,7 +38,13 @@ func NewDebug(
 	aimiddleware common.Address,
 	beneficiary common.Address,
 ) *Debug {
-	return &Debug{eoa, eth, mempool, rep, bundler, chainID, aimiddleware, beneficiary}
+	return &Debug{eoa, eth, mempool, rep, bundler, chainID, aimiddleware, beneficiary, waitForSettledNoop()}
+}
+
+// SetWaitForSettledFunc defines a general function for waiting until a sent bundle is settled. This function is
+// called in *Debug.SendBundleNow so that the mempool reflects the outcome of the bundle once it returns.
+func (d *Debug) SetWaitForSettledFunc(fn WaitForSettledFunc) {
+	d.waitSettled = fn
 }
 
 // ClearState clears the bundler mempool and reputation data of paymasters/accounts/factories/aggregators.
@@ -74,22 +82,45 @@ func (d *Debug) DumpMempool(ep string) ([]map[string]any, error) {
 }
 
 // SendBundleNow forces the bundler to build and execute a bundle from the mempool as handleOps() transaction.
-func (d *Debug) SendBundleNow() (string, error) {
-	ctx, err := d.bundler.Process(d.aimiddleware)
+// A bundle that is sent is settled before this returns, so its AiOperations are no longer in the mempool if it
+// was included.
+func (d *Debug) SendBundleNow(ctx context.Context) (string, error) {
+	bCtx, err := d.bundler.Process(ctx, d.aimiddleware)
 	if err != nil {
 		return "", err
 	}
-	if ctx == nil {
+	if bCtx == nil {
 		return "", nil
 	}
 
-	hash, ok := ctx.Data["txn_hash"].(string)
+	hash, ok := bCtx.Data["txn_hash"].(string)
 	if !ok {
 		return "", errors.New("txn_hash not in ctx Data")
 	}
+	if bCtx.PendingInclusion {
+		if err := d.waitSettled(ctx, common.HexToHash(hash)); err != nil {
+			return "", err
+		}
+	}
 	return hash, nil
 }
 
+// PreviewBundle runs the bundler's batch modules in dry-run mode and returns the AiOperations that would be
+// included in the next bundle, the ones that would be deferred or dropped along with the reason, the gas
+// estimate, the fee caps, and the projected profit. No transaction is sent and the mempool is not changed.
+func (d *Debug) PreviewBundle(ctx context.Context, ep string) (*BundlePreview, error) {
+	epAddr := d.aimiddleware
+	if ep != "" {
+		epAddr = common.HexToAddress(ep)
+	}
+
+	bCtx, err := d.bundler.Preview(ctx, epAddr)
+	if err != nil {
+		return nil, err
+	}
+	return newBundlePreview(epAddr, d.chainID, bCtx), nil
+}
+
 // SetBundlingMode allows the bundler to be stopped so that an explicit call to debug_bundler_sendBundleNow is
 // required to send a bundle.
 func (d *Debug) SetBundlingMode(mode string) (string, error) {
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// metrics holds the instruments for incoming RPC requests. A nil value records nothing.
+type metrics struct {
+	received   metric.Int64Counter
+	rejected   metric.Int64Counter
+	estimation metric.Float64Histogram
+}
+
+func newMetrics(meter metric.Meter) (*metrics, error) {
+	received, err := meter.Int64Counter(
+		"client_aiops_received",
+		metric.WithDescription("The number of AiOperations received by eth_sendAiOperation."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected, err := meter.Int64Counter(
+		"client_aiops_rejected",
+		metric.WithDescription("The number of AiOperations rejected by eth_sendAiOperation by error code."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	estimation, err := meter.Float64Histogram(
+		"client_estimation_duration",
+		metric.WithDescription("The duration of each eth_estimateAiOperationGas call."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metrics{
+		received:   received,
+		rejected:   rejected,
+		estimation: estimation,
+	}, nil
+}
+
+// errorCode returns the JSON-RPC error code of err or "unknown" if it does not have one.
+func errorCode(err error) string {
+	var rpcErr interface{ Code() int }
+	if errors.As(err, &rpcErr) {
+		return strconv.Itoa(rpcErr.Code())
+	}
+	return "unknown"
+}
+
+func (m *metrics) recordSend(ep string, err error) {
+	if m == nil {
+		return
+	}
+	epAttr := attribute.String("aimiddleware", common.HexToAddress(ep).String())
+	m.received.Add(context.Background(), 1, metric.WithAttributes(epAttr))
+	if err != nil {
+		m.rejected.Add(
+			context.Background(),
+			1,
+			metric.WithAttributes(epAttr, attribute.String("code", errorCode(err))),
+		)
+	}
+}
+
+func (m *metrics) recordEstimation(ep string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.estimation.Record(
+		context.Background(),
+		time.Since(start).Seconds(),
+		metric.WithAttributes(
+			attribute.String("aimiddleware", common.HexToAddress(ep).String()),
+			attribute.Bool("error", err != nil),
+		),
+	)
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"math/big"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/transaction"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// PreviewItem is an AiOperation that was excluded from a previewed bundle.
+type PreviewItem struct {
+	AiOpHash common.Hash `json:"aiOpHash"`
+	Reason   string      `json:"reason"`
+}
+
+// BundlePreview is the result of running the batch modules in dry-run mode. ProjectedProfit is the gas
+// estimate multiplied by the difference between the mean effective gas price of the AiOperations and the
+// effective gas price of the bundle transaction. It is an approximation since the actual gas used by each
+// AiOperation is only known once the bundle is included.
+type BundlePreview struct {
+	AiMiddleware         common.Address `json:"aiMiddleware"`
+	AiOpHashes           []common.Hash  `json:"aiOpHashes"`
+	Deferred             []PreviewItem  `json:"deferred"`
+	Dropped              []PreviewItem  `json:"dropped"`
+	GasEstimate          hexutil.Uint64 `json:"gasEstimate"`
+	BaseFee              *hexutil.Big   `json:"baseFee,omitempty"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas,omitempty"`
+	GasPrice             *hexutil.Big   `json:"gasPrice,omitempty"`
+	ProjectedProfit      *hexutil.Big   `json:"projectedProfit"`
+}
+
+func newBundlePreview(ep common.Address, chainID *big.Int, bCtx *modules.BatchHandlerCtx) *BundlePreview {
+	p := &BundlePreview{
+		AiMiddleware:    ep,
+		AiOpHashes:      []common.Hash{},
+		Deferred:        []PreviewItem{},
+		Dropped:         []PreviewItem{},
+		ProjectedProfit: (*hexutil.Big)(big.NewInt(0)),
+	}
+	if bCtx == nil {
+		return p
+	}
+
+	for _, op := range bCtx.Batch {
+		p.AiOpHashes = append(p.AiOpHashes, op.GetAiOpHash(ep, chainID))
+	}
+	for _, item := range bCtx.Deferred {
+		p.Deferred = append(p.Deferred, PreviewItem{item.Op.GetAiOpHash(ep, chainID), item.Reason})
+	}
+	for _, item := range bCtx.PendingRemoval {
+		p.Dropped = append(p.Dropped, PreviewItem{item.Op.GetAiOpHash(ep, chainID), item.Reason})
+	}
+	if est, ok := bCtx.Data[modules.GasEstimateKey].(uint64); ok {
+		p.GasEstimate = hexutil.Uint64(est)
+	}
+	if len(bCtx.Batch) == 0 {
+		return p
+	}
+
+	// Use the same fee suggestions as the handleOps transaction.
+	var txnPrice *big.Int
+	if bCtx.BaseFee != nil && bCtx.Tip != nil {
+		feeCap := transaction.SuggestMeanGasFeeCap(bCtx.BaseFee, bCtx.Tip, bCtx.Batch)
+		tipCap := transaction.SuggestMeanGasTipCap(bCtx.Tip, bCtx.Batch)
+		p.BaseFee = (*hexutil.Big)(bCtx.BaseFee)
+		p.MaxFeePerGas = (*hexutil.Big)(feeCap)
+		p.MaxPriorityFeePerGas = (*hexutil.Big)(tipCap)
+
+		txnPrice = big.NewInt(0).Add(bCtx.BaseFee, tipCap)
+		if txnPrice.Cmp(feeCap) == 1 {
+			txnPrice = feeCap
+		}
+	} else if bCtx.GasPrice != nil {
+		txnPrice = transaction.SuggestMeanGasPrice(bCtx.GasPrice, bCtx.Batch)
+		p.GasPrice = (*hexutil.Big)(txnPrice)
+	} else {
+		return p
+	}
+
+	opsPrice := meanEffectiveGasPrice(bCtx.BaseFee, bCtx.Batch)
+	margin := big.NewInt(0).Sub(opsPrice, txnPrice)
+	p.ProjectedProfit = (*hexutil.Big)(
+		big.NewInt(0).Mul(margin, big.NewInt(0).SetUint64(uint64(p.GasEstimate))),
+	)
+	return p
+}
+
+// meanEffectiveGasPrice returns the mean gas price the AiOperations in the batch will pay the beneficiary. A
+// nil basefee uses maxFeePerGas for networks that don't support EIP-1559.
+func meanEffectiveGasPrice(basefee *big.Int, batch []*aiop.AiOperation) *big.Int {
+	sum := big.NewInt(0)
+	for _, op := range batch {
+		if basefee != nil {
+			sum = big.NewInt(0).Add(sum, op.GetDynamicGasPrice(basefee))
+		} else {
+			sum = big.NewInt(0).Add(sum, op.MaxFeePerGas)
+		}
+	}
+	return big.NewInt(0).Div(sum, big.NewInt(int64(len(batch))))
+}
@@ -1,9 +1,11 @@
 package client
 
 import (
+	"context"
 	"errors"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/filter"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/gas"
 )
 
@@ -25,31 +27,34 @@ func NewRpcAdapter(client *Client, debug *Debug) *RpcAdapter {
 }
 
 // Eth_sendAiOperation routes method calls to *Client.SendAiOperation.
-func (r *RpcAdapter) Eth_sendAiOperation(op aiOperation, ep string) (string, error) {
-	return r.client.SendAiOperation(op, ep)
+func (r *RpcAdapter) Eth_sendAiOperation(ctx context.Context, op aiOperation, ep string) (string, error) {
+	return r.client.SendAiOperation(ctx, op, ep)
 }
 
 // Eth_estimateAiOperationGas routes method calls to *Client.EstimateAiOperationGas.
 func (r *RpcAdapter) Eth_estimateAiOperationGas(
+	ctx context.Context,
 	op aiOperation,
 	ep string,
 	os optional_stateOverride,
 ) (*gas.GasEstimates, error) {
-	return r.client.EstimateAiOperationGas(op, ep, os)
+	return r.client.EstimateAiOperationGas(ctx, op, ep, os)
 }
 
 // Eth_getAiOperationReceipt routes method calls to *Client.GetAiOperationReceipt.
 func (r *RpcAdapter) Eth_getAiOperationReceipt(
+	ctx context.Context,
 	aiOpHash string,
 ) (*filter.AiOperationReceipt, error) {
-	return r.client.GetAiOperationReceipt(aiOpHash)
+	return r.client.GetAiOperationReceipt(ctx, aiOpHash)
 }
 
 // Eth_getAiOperationByHash routes method calls to *Client.GetAiOperationByHash.
 func (r *RpcAdapter) Eth_getAiOperationByHash(
+	ctx context.Context,
 	aiOpHash string,
 ) (*filter.HashLookupResult, error) {
-	return r.client.GetAiOperationByHash(aiOpHash)
+	return r.client.GetAiOperationByHash(ctx, aiOpHash)
 }
 
 // Eth_supportedAiMiddlewares routes method calls to *Client.SupportedAiMiddlewares.
@@ -62,6 +67,16 @@ func (r *RpcAdapter) Eth_chainId() (string, error) {
 	return r.client.ChainID()
 }
 
+// Bundler_getCircuitBreakerStatus routes method calls to *Client.CircuitBreakerStatus.
+func (r *RpcAdapter) Bundler_getCircuitBreakerStatus() ([]bundler.CircuitBreakerStatus, error) {
+	return r.client.CircuitBreakerStatus()
+}
+
+// Bundler_getAiOperationStatus routes method calls to *Client.AiOperationStatus.
+func (r *RpcAdapter) Bundler_getAiOperationStatus(hash string, ep string) (*bundler.AiOpStatus, error) {
+	return r.client.AiOperationStatus(hash, ep)
+}
+
 // Debug_bundler_clearState routes method calls to *Debug.ClearState.
 func (r *RpcAdapter) Debug_bundler_clearState() (string, error) {
 	if r.debug == nil {
@@ -81,12 +96,21 @@ func (r *RpcAdapter) Debug_bundler_dumpMempool(ep string) ([]map[string]any, err
 }
 
 // Debug_bundler_sendBundleNow routes method calls to *Debug.SendBundleNow.
-func (r *RpcAdapter) Debug_bundler_sendBundleNow() (string, error) {
+func (r *RpcAdapter) Debug_bundler_sendBundleNow(ctx context.Context) (string, error) {
 	if r.debug == nil {
 		return "", errors.New("rpc: debug mode is not enabled")
 	}
 
-	return r.debug.SendBundleNow()
+	return r.debug.SendBundleNow(ctx)
+}
+
+// Debug_bundler_previewBundle routes method calls to *Debug.PreviewBundle.
+func (r *RpcAdapter) Debug_bundler_previewBundle(ctx context.Context, ep string) (*BundlePreview, error) {
+	if r.debug == nil {
+		return nil, errors.New("rpc: debug mode is not enabled")
+	}
+
+	return r.debug.PreviewBundle(ctx, ep)
 }
 
 // Debug_bundler_setBundlingMode routes method calls to *Debug.SetBundlingMode.
@@ -1,10 +1,13 @@
 package client
 
 import (
+	"context"
+	"fmt"
 	"math/big"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/filter"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/fees"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/gas"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/state"
@@ -15,10 +18,10 @@ import (
 
 // GetAiOpReceiptFunc is a general interface for fetching a AiOperationReceipt given a aiOpHash,
 // AiMiddleware address, and block range.
-type GetAiOpReceiptFunc = func(hash string, ep common.Address, blkRange uint64) (*filter.AiOperationReceipt, error)
+type GetAiOpReceiptFunc = func(ctx context.Context, hash string, ep common.Address, blkRange uint64) (*filter.AiOperationReceipt, error)
 
 func getAiOpReceiptNoop() GetAiOpReceiptFunc {
-	return func(hash string, ep common.Address, blkRange uint64) (*filter.AiOperationReceipt, error) {
+	return func(ctx context.Context, hash string, ep common.Address, blkRange uint64) (*filter.AiOperationReceipt, error) {
 		return nil, nil
 	}
 }
@@ -26,16 +29,55 @@ func getAiOpReceiptNoop() GetAiOpReceiptFunc {
 // GetAiOpReceiptWithEthClient returns an implementation of GetAiOpReceiptFunc that relies on an eth
 // client to fetch a AiOperationReceipt.
 func GetAiOpReceiptWithEthClient(eth *ethclient.Client) GetAiOpReceiptFunc {
-	return func(hash string, ep common.Address, blkRange uint64) (*filter.AiOperationReceipt, error) {
-		return filter.GetAiOperationReceipt(eth, hash, ep, blkRange)
+	return func(ctx context.Context, hash string, ep common.Address, blkRange uint64) (*filter.AiOperationReceipt, error) {
+		return filter.GetAiOperationReceipt(ctx, eth, hash, ep, blkRange)
+	}
+}
+
+// GetCircuitBreakerStatusFunc is a general interface for fetching the circuit breaker state of the bundler
+// for each supported AiMiddleware.
+type GetCircuitBreakerStatusFunc = func() []bundler.CircuitBreakerStatus
+
+func getCircuitBreakerStatusNoop() GetCircuitBreakerStatusFunc {
+	return func() []bundler.CircuitBreakerStatus {
+		return []bundler.CircuitBreakerStatus{}
+	}
+}
+
+// GetAiOpStatusFunc is a general interface for fetching the bundler state of an AiOperation in the mempool.
+type GetAiOpStatusFunc = func(ep common.Address, hash common.Hash) (*bundler.AiOpStatus, error)
+
+func getAiOpStatusNoop() GetAiOpStatusFunc {
+	return func(ep common.Address, hash common.Hash) (*bundler.AiOpStatus, error) {
+		return &bundler.AiOpStatus{Status: bundler.AiOpNotFound}, nil
+	}
+}
+
+// WaitForSettledFunc is a general interface for waiting until a bundle transaction sent by the bundler has
+// been settled and its AiOperations have left the mempool or been returned to it.
+type WaitForSettledFunc = func(ctx context.Context, hash common.Hash) error
+
+func waitForSettledNoop() WaitForSettledFunc {
+	return func(ctx context.Context, hash common.Hash) error {
+		return nil
+	}
+}
+
+// GetSyncErrFunc is a general interface for checking that the node is in sync. It returns an error if
+// AiOperations should not be validated against the node's latest block.
+type GetSyncErrFunc = func() error
+
+func getSyncErrNoop() GetSyncErrFunc {
+	return func() error {
+		return nil
 	}
 }
 
 // GetGasPricesFunc is a general interface for fetching values for maxFeePerGas and maxPriorityFeePerGas.
-type GetGasPricesFunc = func() (*fees.GasPrices, error)
+type GetGasPricesFunc = func(ctx context.Context) (*fees.GasPrices, error)
 
 func getGasPricesNoop() GetGasPricesFunc {
-	return func() (*fees.GasPrices, error) {
+	return func(ctx context.Context) (*fees.GasPrices, error) {
 		return &fees.GasPrices{
 			MaxFeePerGas:         big.NewInt(0),
 			MaxPriorityFeePerGas: big.NewInt(0),
@@ -46,14 +88,15 @@ func getGasPricesNoop() GetGasPricesFunc {
 // GetGasPricesWithEthClient returns an implementation of GetGasPricesFunc that relies on an eth client to
 // fetch values for maxFeePerGas and maxPriorityFeePerGas.
 func GetGasPricesWithEthClient(eth *ethclient.Client) GetGasPricesFunc {
-	return func() (*fees.GasPrices, error) {
-		return fees.NewGasPrices(eth)
+	return func(ctx context.Context) (*fees.GasPrices, error) {
+		return fees.NewGasPrices(ctx, eth)
 	}
 }
 
 // GetGasEstimateFunc is a general interface for fetching an estimate for verificationGasLimit and
 // callGasLimit given a aiOp and AiMiddleware address.
 type GetGasEstimateFunc = func(
+	ctx context.Context,
 	ep common.Address,
 	op *aiop.AiOperation,
 	sos state.OverrideSet,
@@ -61,6 +104,7 @@ type GetGasEstimateFunc = func(
 
 func getGasEstimateNoop() GetGasEstimateFunc {
 	return func(
+		ctx context.Context,
 		ep common.Address,
 		op *aiop.AiOperation,
 		sos state.OverrideSet,
@@ -69,6 +113,19 @@ func getGasEstimateNoop() GetGasEstimateFunc {
 	}
 }
 
+// GetGasEstimateUnsupported returns an implementation of GetGasEstimateFunc that always fails with the given
+// reason. This is used when the node is missing a feature required for estimation.
+func GetGasEstimateUnsupported(reason error) GetGasEstimateFunc {
+	return func(
+		ctx context.Context,
+		ep common.Address,
+		op *aiop.AiOperation,
+		sos state.OverrideSet,
+	) (verificationGas uint64, callGas uint64, err error) {
+		return 0, 0, fmt.Errorf("gas estimation is disabled: %w", reason)
+	}
+}
+
 // GetGasEstimateWithEthClient returns an implementation of GetGasEstimateFunc that relies on an eth client to
 // fetch an estimate for verificationGasLimit and callGasLimit.
 func GetGasEstimateWithEthClient(
@@ -79,11 +136,12 @@ func GetGasEstimateWithEthClient(
 	tracer string,
 ) GetGasEstimateFunc {
 	return func(
+		ctx context.Context,
 		ep common.Address,
 		op *aiop.AiOperation,
 		sos state.OverrideSet,
 	) (verificationGas uint64, callGas uint64, err error) {
-		return gas.EstimateGas(&gas.EstimateInput{
+		return gas.EstimateGas(ctx, &gas.EstimateInput{
 			Rpc:          rpc,
 			AiMiddleware: ep,
 			Op:           op,
@@ -98,10 +156,10 @@ func GetGasEstimateWithEthClient(
 
 // GetAiOpByHashFunc is a general interface for fetching a AiOperation given a aiOpHash, AiMiddleware
 // address, chain ID, and block range.
-type GetAiOpByHashFunc func(hash string, ep common.Address, chain *big.Int, blkRange uint64) (*filter.HashLookupResult, error)
+type GetAiOpByHashFunc func(ctx context.Context, hash string, ep common.Address, chain *big.Int, blkRange uint64) (*filter.HashLookupResult, error)
 
 func getAiOpByHashNoop() GetAiOpByHashFunc {
-	return func(hash string, ep common.Address, chain *big.Int, blkRange uint64) (*filter.HashLookupResult, error) {
+	return func(ctx context.Context, hash string, ep common.Address, chain *big.Int, blkRange uint64) (*filter.HashLookupResult, error) {
 		return nil, nil
 	}
 }
@@ -109,7 +167,7 @@ func getAiOpByHashNoop() GetAiOpByHashFunc {
 // GetAiOpByHashWithEthClient returns an implementation of GetAiOpByHashFunc that relies on an eth client
 // to fetch a AiOperation.
 func GetAiOpByHashWithEthClient(eth *ethclient.Client) GetAiOpByHashFunc {
-	return func(hash string, ep common.Address, chain *big.Int, blkRange uint64) (*filter.HashLookupResult, error) {
-		return filter.GetAiOperationByHash(eth, hash, ep, chain, blkRange)
+	return func(ctx context.Context, hash string, ep common.Address, chain *big.Int, blkRange uint64) (*filter.HashLookupResult, error) {
+		return filter.GetAiOperationByHash(ctx, eth, hash, ep, chain, blkRange)
 	}
 }
@@ -12,6 +12,8 @@ var (
 	INVALID_FIELDS             = -32602
 
 	EXECUTION_REVERTED = -32521
+	NODE_OUT_OF_SYNC   = -32010
+	CHAIN_UNAVAILABLE  = -32011
 )
 
 // RPCError is a custom error that fits the JSON-RPC error spec.
@@ -15,19 +15,19 @@ type GasPrices struct {
 }
 
 // NewGasPrices returns an instance of GasPrices with the latest suggested fees derived from an Eth Client.
-func NewGasPrices(eth *ethclient.Client) (*GasPrices, error) {
+func NewGasPrices(ctx context.Context, eth *ethclient.Client) (*GasPrices, error) {
 	gp := GasPrices{}
-	if head, err := eth.HeaderByNumber(context.Background(), nil); err != nil {
+	if head, err := eth.HeaderByNumber(ctx, nil); err != nil {
 		return nil, err
 	} else if head.BaseFee != nil {
-		tip, err := eth.SuggestGasTipCap(context.Background())
+		tip, err := eth.SuggestGasTipCap(ctx)
 		if err != nil {
 			return nil, err
 		}
 		gp.MaxFeePerGas = big.NewInt(0).Add(tip, big.NewInt(0).Mul(head.BaseFee, common.Big2))
 		gp.MaxPriorityFeePerGas = tip
 	} else {
-		sgp, err := eth.SuggestGasPrice(context.Background())
+		sgp, err := eth.SuggestGasPrice(ctx)
 		if err != nil {
 			return nil, err
 		}
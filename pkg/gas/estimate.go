@@ -1,6 +1,7 @@
 package gas
 
 import (
+	"context"
 	"math/big"
 	"strings"
 
@@ -59,9 +60,9 @@ type EstimateInput struct {
 // retryEstimateGas will recursively call estimateGas if execution has caused VGL to be under estimated. This
 // can occur for edge cases where a paymaster's postOp > gas required during verification or if verification
 // has a dependency on CGL. Reset the estimate with a higher buffer on VGL.
-func retryEstimateGas(err error, vgl int64, in *EstimateInput) (uint64, uint64, error) {
+func retryEstimateGas(ctx context.Context, err error, vgl int64, in *EstimateInput) (uint64, uint64, error) {
 	if isValidationOOG(err) && in.attempts < maxRetries {
-		return EstimateGas(&EstimateInput{
+		return EstimateGas(ctx, &EstimateInput{
 			Rpc:          in.Rpc,
 			AiMiddleware: in.AiMiddleware,
 			Op:           in.Op,
@@ -79,7 +80,7 @@ func retryEstimateGas(err error, vgl int64, in *EstimateInput) (uint64, uint64,
 
 // EstimateGas uses the simulateHandleOp method on the AiMiddleware to derive an estimate for
 // verificationGasLimit and callGasLimit.
-func EstimateGas(in *EstimateInput) (verificationGas uint64, callGas uint64, err error) {
+func EstimateGas(ctx context.Context, in *EstimateInput) (verificationGas uint64, callGas uint64, err error) {
 	// Set the initial conditions.
 	data, err := in.Op.ToMap()
 	if err != nil {
@@ -114,7 +115,7 @@ func EstimateGas(in *EstimateInput) (verificationGas uint64, callGas uint64, err
 		if err != nil {
 			return 0, 0, err
 		}
-		_, err = execution.SimulateHandleOp(&execution.SimulateInput{
+		_, err = execution.SimulateHandleOp(ctx, &execution.SimulateInput{
 			Rpc:          in.Rpc,
 			AiMiddleware: in.AiMiddleware,
 			Op:           simOp,
@@ -156,7 +157,7 @@ func EstimateGas(in *EstimateInput) (verificationGas uint64, callGas uint64, err
 	if err != nil {
 		return 0, 0, err
 	}
-	out, err := execution.TraceSimulateHandleOp(&execution.TraceInput{
+	out, err := execution.TraceSimulateHandleOp(ctx, &execution.TraceInput{
 		Rpc:          in.Rpc,
 		AiMiddleware: in.AiMiddleware,
 		Op:           simOp,
@@ -166,7 +167,7 @@ func EstimateGas(in *EstimateInput) (verificationGas uint64, callGas uint64, err
 		Tracer:       in.Tracer,
 	})
 	if err != nil {
-		return retryEstimateGas(err, f, in)
+		return retryEstimateGas(ctx, err, f, in)
 	}
 
 	// Calculate final values for verificationGasLimit and callGasLimit.
@@ -185,7 +186,7 @@ func EstimateGas(in *EstimateInput) (verificationGas uint64, callGas uint64, err
 	if err != nil {
 		return 0, 0, err
 	}
-	_, err = execution.TraceSimulateHandleOp(&execution.TraceInput{
+	_, err = execution.TraceSimulateHandleOp(ctx, &execution.TraceInput{
 		Rpc:          in.Rpc,
 		AiMiddleware: in.AiMiddleware,
 		Op:           simOp,
@@ -210,7 +211,7 @@ func EstimateGas(in *EstimateInput) (verificationGas uint64, callGas uint64, err
 				if err != nil {
 					return 0, 0, err
 				}
-				_, err = execution.TraceSimulateHandleOp(&execution.TraceInput{
+				_, err = execution.TraceSimulateHandleOp(ctx, &execution.TraceInput{
 					Rpc:          in.Rpc,
 					AiMiddleware: in.AiMiddleware,
 					Op:           simOp,
@@ -242,7 +243,7 @@ func EstimateGas(in *EstimateInput) (verificationGas uint64, callGas uint64, err
 			}
 			return simOp.VerificationGasLimit.Uint64(), big.NewInt(f).Uint64(), nil
 		}
-		return retryEstimateGas(err, simOp.VerificationGasLimit.Int64(), in)
+		return retryEstimateGas(ctx, err, simOp.VerificationGasLimit.Int64(), in)
 	}
 	return simOp.VerificationGasLimit.Uint64(), simOp.CallGasLimit.Uint64(), nil
 }
@@ -3,6 +3,7 @@ package gas
 
 import (
 	"bytes"
+	"context"
 	"math"
 	"math/big"
 
@@ -94,7 +95,7 @@ func (ov *Overhead) CalcPerAiOpCost(op *aiop.AiOperation) float64 {
 }
 
 // CalcPreVerificationGas returns an expected gas cost for processing a AiOperation from a batch.
-func (ov *Overhead) CalcPreVerificationGas(op *aiop.AiOperation) (*big.Int, error) {
+func (ov *Overhead) CalcPreVerificationGas(ctx context.Context, op *aiop.AiOperation) (*big.Int, error) {
 	// Sanitize fields to reduce as much variability due to length and zero bytes
 	data, err := op.ToMap()
 	if err != nil {
@@ -118,7 +119,7 @@ func (ov *Overhead) CalcPreVerificationGas(op *aiop.AiOperation) (*big.Int, erro
 	static := big.NewInt(int64(math.Round(pvg)))
 
 	// Use value from CalcPreVerificationGasFunc if set, otherwise return the static value.
-	g, err := ov.calcPVGFunc(tmp, static)
+	g, err := ov.calcPVGFunc(ctx, tmp, static)
 	if err != nil {
 		return nil, err
 	}
@@ -129,8 +130,8 @@ func (ov *Overhead) CalcPreVerificationGas(op *aiop.AiOperation) (*big.Int, erro
 }
 
 // CalcPreVerificationGasWithBuffer returns CalcPreVerificationGas increased by the set PVG buffer factor.
-func (ov *Overhead) CalcPreVerificationGasWithBuffer(op *aiop.AiOperation) (*big.Int, error) {
-	pvg, err := ov.CalcPreVerificationGas(op)
+func (ov *Overhead) CalcPreVerificationGasWithBuffer(ctx context.Context, op *aiop.AiOperation) (*big.Int, error) {
+	pvg, err := ov.CalcPreVerificationGas(ctx, op)
 	if err != nil {
 		return nil, err
 	}
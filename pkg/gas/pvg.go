@@ -21,11 +21,16 @@ import (
 )
 
 // CalcPreVerificationGasFunc defines an interface for a function to calculate PVG given a aiOp and a static
-// value. The static input is the value derived from the default overheads.
-type CalcPreVerificationGasFunc = func(op *aiop.AiOperation, static *big.Int) (*big.Int, error)
+// value. The static input is the value derived from the default overheads. Any calls to the node are made with
+// the given ctx.
+type CalcPreVerificationGasFunc = func(
+	ctx context.Context,
+	op *aiop.AiOperation,
+	static *big.Int,
+) (*big.Int, error)
 
 func calcPVGFuncNoop() CalcPreVerificationGasFunc {
-	return func(op *aiop.AiOperation, static *big.Int) (*big.Int, error) {
+	return func(ctx context.Context, op *aiop.AiOperation, static *big.Int) (*big.Int, error) {
 		return nil, nil
 	}
 }
@@ -39,7 +44,7 @@ func CalcArbitrumPVGWithEthClient(
 ) CalcPreVerificationGasFunc {
 	pk, _ := crypto.GenerateKey()
 	dummy, _ := signer.New(hexutil.Encode(crypto.FromECDSA(pk))[2:])
-	return func(op *aiop.AiOperation, static *big.Int) (*big.Int, error) {
+	return func(ctx context.Context, op *aiop.AiOperation, static *big.Int) (*big.Int, error) {
 		// Sanitize paymasterAndData.
 		// TODO: Figure out why variability in this field is causing Arbitrum's precompile to return different
 		// values.
@@ -56,7 +61,7 @@ func CalcArbitrumPVGWithEthClient(
 		// Pack handleOps method inputs
 		ho, err := methods.HandleOpsMethod.Inputs.Pack(
 			[]aimiddleware.AiOperation{aimiddleware.AiOperation(*tmp)},
-			dummy.Address,
+			dummy.Address(),
 		)
 		if err != nil {
 			return nil, err
@@ -83,7 +88,7 @@ func CalcArbitrumPVGWithEthClient(
 			"data": hexutil.Encode(append(nodeinterface.GasEstimateL1ComponentMethod.ID, ge...)),
 		}
 		var out any
-		if err := rpc.Call(&out, "eth_call", &req, "latest"); err != nil {
+		if err := rpc.CallContext(ctx, &out, "eth_call", &req, "latest"); err != nil {
 			return nil, err
 		}
 
@@ -105,24 +110,24 @@ func CalcOptimismPVGWithEthClient(
 ) CalcPreVerificationGasFunc {
 	pk, _ := crypto.GenerateKey()
 	dummy, _ := signer.New(hexutil.Encode(crypto.FromECDSA(pk))[2:])
-	return func(op *aiop.AiOperation, static *big.Int) (*big.Int, error) {
+	return func(ctx context.Context, op *aiop.AiOperation, static *big.Int) (*big.Int, error) {
 		// Create Raw HandleOps Transaction
 		eth := ethclient.NewClient(rpc)
-		head, err := eth.HeaderByNumber(context.Background(), nil)
+		head, err := eth.HeaderByNumber(ctx, nil)
 		if err != nil {
 			return nil, err
 		}
-		tip, err := eth.SuggestGasTipCap(context.Background())
+		tip, err := eth.SuggestGasTipCap(ctx)
 		if err != nil {
 			return nil, err
 		}
-		tx, err := transaction.HandleOps(&transaction.Opts{
+		tx, err := transaction.HandleOps(ctx, &transaction.Opts{
 			EOA:          dummy,
 			Eth:          eth,
 			ChainID:      chainID,
 			AiMiddleware: aiMiddleware,
 			Batch:        []*aiop.AiOperation{op},
-			Beneficiary:  dummy.Address,
+			Beneficiary:  dummy.Address(),
 			BaseFee:      head.BaseFee,
 			Tip:          tip,
 			GasLimit:     math.MaxUint64,
@@ -149,7 +154,7 @@ func CalcOptimismPVGWithEthClient(
 			"data": hexutil.Encode(append(gaspriceoracle.GetL1FeeMethod.ID, ge...)),
 		}
 		var out any
-		if err := rpc.Call(&out, "eth_call", &req, "latest"); err != nil {
+		if err := rpc.CallContext(ctx, &out, "eth_call", &req, "latest"); err != nil {
 			return nil, err
 		}
 
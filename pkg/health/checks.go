@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/dbutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/altmempools"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/bundler"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/balance"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/syncguard"
+	badger "github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	// DefaultMaxHeartbeatAge is the max time since the last bundler loop tick before it is considered dead.
+	DefaultMaxHeartbeatAge = 30 * time.Second
+
+	dbKey = []byte(dbutils.JoinValues("health", "probe"))
+)
+
+// CheckNodeWithEthClient returns a CheckFunc that fails if the node cannot return the latest block number.
+func CheckNodeWithEthClient(eth *ethclient.Client) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		bn, err := eth.BlockNumber(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"block": bn}, nil
+	}
+}
+
+// CheckNodeSync returns a CheckFunc that fails if the node was out of sync on the last check by the Guard.
+func CheckNodeSync(guard *syncguard.Guard) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		s := guard.Status()
+		if !s.Synced {
+			return s, fmt.Errorf("node out of sync: %s", s.Reason)
+		}
+		return s, nil
+	}
+}
+
+// CheckDBWritable returns a CheckFunc that fails if a short lived key cannot be written to the DB.
+func CheckDBWritable(db *badger.DB) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		err := db.Update(func(txn *badger.Txn) error {
+			val := []byte(strconv.FormatInt(time.Now().Unix(), 10))
+			return txn.SetEntry(badger.NewEntry(dbKey, val).WithTTL(time.Minute))
+		})
+		return nil, err
+	}
+}
+
+// CheckBundlerHeartbeat returns a CheckFunc that fails if the bundler loop has not ticked within the max age.
+// A paused bundler still ticks so this only fails if the loop has stopped or is stuck.
+func CheckBundlerHeartbeat(b *bundler.Bundler, maxAge time.Duration) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		hb := b.Heartbeat()
+		details := map[string]any{"pauseReasons": b.PauseReasons()}
+		if hb.IsZero() {
+			return details, fmt.Errorf("bundler loop has not started")
+		}
+
+		details["lastHeartbeat"] = hb
+		if age := time.Since(hb); age > maxAge {
+			return details, fmt.Errorf("bundler loop heartbeat is %s old", age.Round(time.Second))
+		}
+		return details, nil
+	}
+}
+
+// CheckSignerBalance returns a CheckFunc that fails if every signer is below the critical balance threshold.
+func CheckSignerBalance(mon *balance.Monitor) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		levels := map[string]string{}
+		for addr, level := range mon.Levels() {
+			levels[addr.Hex()] = level.String()
+		}
+		if mon.IsCritical() {
+			return levels, fmt.Errorf("all signers are below the critical balance")
+		}
+		return levels, nil
+	}
+}
+
+// CheckAltMempools returns a CheckFunc that fails if a configured alternative mempool was not loaded for the
+// chain.
+func CheckAltMempools(dir *altmempools.Directory, ids []string) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		loaded := dir.Ids()
+		details := map[string]any{"configured": ids, "loaded": loaded}
+
+		seen := make(map[string]bool)
+		for _, id := range loaded {
+			seen[id] = true
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				return details, fmt.Errorf("alt mempool %s not loaded for chain", id)
+			}
+		}
+		return details, nil
+	}
+}
@@ -0,0 +1,142 @@
+// Package health aggregates the state of bundler components into liveness and readiness reports that can be
+// served to an orchestrator.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// DefaultTimeout is the max time for all component checks in a single report.
+	DefaultTimeout = 5 * time.Second
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc returns an error if a component is unhealthy. The returned details are included in the report
+// regardless of the result.
+type CheckFunc = func(ctx context.Context) (details any, err error)
+
+type component struct {
+	name string
+	live bool
+	fn   CheckFunc
+}
+
+// Result is the state of a single component.
+type Result struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Report is the state of every component included in a liveness or readiness check.
+type Report struct {
+	Status     string            `json:"status"`
+	Components map[string]Result `json:"components"`
+}
+
+// Checker holds the components of a bundler instance. Liveness components indicate the process should be
+// restarted if they fail. Readiness components indicate traffic should be routed away from the instance.
+type Checker struct {
+	components []component
+	timeout    time.Duration
+}
+
+// New returns a Checker with no components.
+func New() *Checker {
+	return &Checker{
+		components: []component{},
+		timeout:    DefaultTimeout,
+	}
+}
+
+// SetTimeout defines the max time for all component checks in a single report.
+func (c *Checker) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// AddLiveness adds a component that is included in both liveness and readiness reports.
+func (c *Checker) AddLiveness(name string, fn CheckFunc) {
+	c.components = append(c.components, component{name: name, live: true, fn: fn})
+}
+
+// AddReadiness adds a component that is only included in readiness reports.
+func (c *Checker) AddReadiness(name string, fn CheckFunc) {
+	c.components = append(c.components, component{name: name, live: false, fn: fn})
+}
+
+// Live returns a report of all liveness components.
+func (c *Checker) Live(ctx context.Context) *Report {
+	return c.run(ctx, true)
+}
+
+// Ready returns a report of all components.
+func (c *Checker) Ready(ctx context.Context) *Report {
+	return c.run(ctx, false)
+}
+
+func (c *Checker) run(ctx context.Context, liveOnly bool) *Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	r := &Report{Status: StatusOK, Components: make(map[string]Result)}
+	for _, comp := range c.components {
+		if liveOnly && !comp.live {
+			continue
+		}
+
+		wg.Add(1)
+		go func(comp component) {
+			defer wg.Done()
+			details, err := comp.fn(ctx)
+			res := Result{Status: StatusOK, Details: details}
+			if err != nil {
+				res.Status = StatusFail
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			r.Components[comp.name] = res
+			if err != nil {
+				r.Status = StatusFail
+			}
+		}(comp)
+	}
+	wg.Wait()
+	return r
+}
+
+func handler(fn func(ctx context.Context) *Report) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		r := fn(g.Request.Context())
+		code := http.StatusOK
+		if r.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		g.JSON(code, r)
+	}
+}
+
+// LiveHandler responds with a liveness report. The status code is 200 if all components are healthy and 503
+// otherwise.
+func (c *Checker) LiveHandler() gin.HandlerFunc {
+	return handler(c.Live)
+}
+
+// ReadyHandler responds with a readiness report. The status code is 200 if all components are healthy and 503
+// otherwise.
+func (c *Checker) ReadyHandler() gin.HandlerFunc {
+	return handler(c.Ready)
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/gin-gonic/gin"
+)
+
+func ok(ctx context.Context) (any, error) {
+	return nil, nil
+}
+
+func fail(ctx context.Context) (any, error) {
+	return "details", errors.New("down")
+}
+
+// TestLiveOnlyIncludesLivenessComponents verifies that a failing readiness component does not fail liveness.
+func TestLiveOnlyIncludesLivenessComponents(t *testing.T) {
+	c := New()
+	c.AddLiveness("loop", ok)
+	c.AddReadiness("node", fail)
+
+	live := c.Live(context.Background())
+	if live.Status != StatusOK || len(live.Components) != 1 {
+		t.Fatalf("got %+v, want ok with 1 component", live)
+	}
+
+	ready := c.Ready(context.Background())
+	if ready.Status != StatusFail || len(ready.Components) != 2 {
+		t.Fatalf("got %+v, want fail with 2 components", ready)
+	}
+	if res := ready.Components["node"]; res.Error != "down" || res.Details != "details" {
+		t.Fatalf("got %+v, want error and details", res)
+	}
+}
+
+// TestReadyHandlerStatusCode verifies that a failing component responds with 503.
+func TestReadyHandlerStatusCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := New()
+	c.AddReadiness("node", fail)
+	r := gin.New()
+	r.GET("/health/ready", c.ReadyHandler())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+// TestCheckDBWritable verifies that a write to an open DB succeeds.
+func TestCheckDBWritable(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+
+	if _, err := CheckDBWritable(db)(context.Background()); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
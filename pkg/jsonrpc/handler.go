@@ -2,12 +2,14 @@
 package jsonrpc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
 	"github.com/gin-gonic/gin"
@@ -17,10 +19,28 @@ import (
 
 var (
 	optionalTypePrefix = "optional_"
+	contextType        = reflect.TypeOf((*context.Context)(nil)).Elem()
 )
 
-func formatConversionErrMsg(i int, call *reflect.Value) string {
-	s, _ := strings.CutPrefix(call.Type().In(i).Name(), optionalTypePrefix)
+// Timeouts defines the deadline for handling each JSON-RPC method. The Default value applies to any method
+// that is not set in Methods. A value of 0 means the method can run for as long as the request is open.
+type Timeouts struct {
+	Default time.Duration
+	Methods map[string]time.Duration
+}
+
+func (t *Timeouts) get(method string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	if d, ok := t.Methods[method]; ok {
+		return d
+	}
+	return t.Default
+}
+
+func formatConversionErrMsg(i int, in reflect.Type) string {
+	s, _ := strings.CutPrefix(in.Name(), optionalTypePrefix)
 	return fmt.Sprintf("Param [%d] can't be converted to %s", i, s)
 }
 
@@ -37,6 +57,16 @@ func jsonrpcError(c *gin.Context, code int, message string, data any, id any) {
 	c.Abort()
 }
 
+// AbortWithError responds with a JSON-RPC error object and aborts the request. It is used to reject a
+// request before it reaches a Controller so the id is always null.
+func AbortWithError(c *gin.Context, err error) {
+	if rpcErr, ok := err.(*errors.RPCError); ok {
+		jsonrpcError(c, rpcErr.Code(), rpcErr.Error(), rpcErr.Data(), nil)
+	} else {
+		jsonrpcError(c, -32603, "Internal error", err.Error(), nil)
+	}
+}
+
 // parseRequestId checks if the JSON-RPC request contains an id field that is either NULL, Number, or String.
 func parseRequestId(data map[string]any) (any, bool) {
 	id, ok := data["id"]
@@ -49,13 +79,20 @@ func parseRequestId(data map[string]any) (any, bool) {
 	return nil, false
 }
 
+// hasContextInput checks if the API method has defined a context.Context as its first input. If so, the
+// context of the request is passed in and the input is not counted as a param.
+func hasContextInput(call *reflect.Value) bool {
+	return call.Type().NumIn() > 0 && call.Type().In(0) == contextType
+}
+
 // hasOptionalInput checks if the API method has defined an optional final input:
 //  1. The input must start with the "optional_" prefix in its name.
 //  2. The input must be of kind Map.
 func hasOptionalInput(numIn int, call *reflect.Value) bool {
+	last := call.Type().NumIn() - 1
 	return numIn > 0 &&
-		strings.HasPrefix(call.Type().In(numIn-1).Name(), optionalTypePrefix) &&
-		call.Type().In(numIn-1).Kind() == reflect.Map
+		strings.HasPrefix(call.Type().In(last).Name(), optionalTypePrefix) &&
+		call.Type().In(last).Kind() == reflect.Map
 }
 
 // hasValidParamLength checks if the number of parameters in the request is correct:
@@ -72,7 +109,12 @@ func isOptionalParamUndefined(numParams, numIn int, hasOptional bool) bool {
 
 // handleRequest includes the core logic for parsing individual JSON-RPC requests and returning its id,
 // result, and success flag.
-func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any, result any, success bool) {
+func handleRequest(
+	api interface{},
+	timeouts *Timeouts,
+	c *gin.Context,
+	data map[string]any,
+) (id any, result any, success bool) {
 	id, ok := parseRequestId(data)
 	if !ok {
 		jsonrpcError(c, -32600, "Invalid Request", "No or invalid 'id' in request", nil)
@@ -102,7 +144,11 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 		return id, nil, false
 	}
 
-	numIn := call.Type().NumIn()
+	offset := 0
+	if hasContextInput(&call) {
+		offset = 1
+	}
+	numIn := call.Type().NumIn() - offset
 	numParams := len(params)
 	hasOptional := hasOptionalInput(numIn, &call)
 	if !hasValidParamLength(numParams, numIn, hasOptional) {
@@ -114,9 +160,20 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 		numParams++
 	}
 
-	args := make([]reflect.Value, numParams)
+	ctx := c.Request.Context()
+	if d := timeouts.get(method); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	args := make([]reflect.Value, numParams+offset)
+	if offset > 0 {
+		args[0] = reflect.ValueOf(ctx)
+	}
 	for i, arg := range params {
-		switch call.Type().In(i).Kind() {
+		in := call.Type().In(i + offset)
+		switch in.Kind() {
 		case reflect.Float32:
 			val, ok := arg.(float32)
 			if !ok {
@@ -124,12 +181,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Float64:
 			val, ok := arg.(float64)
@@ -138,12 +195,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Int:
 			val, ok := arg.(int)
@@ -160,12 +217,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Int8:
 			val, ok := arg.(int8)
@@ -181,12 +238,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Int16:
 			val, ok := arg.(int16)
@@ -202,12 +259,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Int32:
 			val, ok := arg.(int32)
@@ -223,12 +280,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Int64:
 			val, ok := arg.(int64)
@@ -244,15 +301,15 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Interface:
-			args[i] = reflect.ValueOf(arg)
+			args[i+offset] = reflect.ValueOf(arg)
 
 		case reflect.Map:
 			val, ok := arg.(map[string]any)
@@ -261,12 +318,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Slice:
 			val, ok := arg.([]interface{})
@@ -275,12 +332,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.String:
 			val, ok := arg.(string)
@@ -289,12 +346,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Uint:
 			val, ok := arg.(uint)
@@ -310,12 +367,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Uint8:
 			val, ok := arg.(uint8)
@@ -331,12 +388,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Uint16:
 			val, ok := arg.(uint16)
@@ -352,12 +409,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Uint32:
 			val, ok := arg.(uint32)
@@ -373,12 +430,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		case reflect.Uint64:
 			val, ok := arg.(uint64)
@@ -394,12 +451,12 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 					c,
 					-32602,
 					"Invalid params",
-					formatConversionErrMsg(i, &call),
+					formatConversionErrMsg(i, in),
 					&id,
 				)
 				return id, nil, false
 			}
-			args[i] = reflect.ValueOf(val)
+			args[i+offset] = reflect.ValueOf(val)
 
 		default:
 			if !ok {
@@ -414,7 +471,9 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 	if err, ok := value[len(value)-1].Interface().(error); ok && err != nil {
 		rpcErr, ok := err.(*errors.RPCError)
 
-		if ok {
+		if ctx.Err() == context.DeadlineExceeded {
+			jsonrpcError(c, -32603, "Internal error", "Request timed out", &id)
+		} else if ok {
 			jsonrpcError(c, rpcErr.Code(), rpcErr.Error(), rpcErr.Data(), &id)
 		} else {
 			jsonrpcError(c, -32601, err.Error(), err.Error(), &id)
@@ -434,9 +493,13 @@ func handleRequest(api interface{}, c *gin.Context, data map[string]any) (id any
 //
 // If request is valid it will also set the data on the Gin context with the key "json-rpc-request".
 //
+// If the first input of a struct method is a context.Context, it will be set to the context of the HTTP
+// request. This context is canceled if the caller disconnects or the deadline for the method in timeouts is
+// exceeded. A nil timeouts means no deadline is set.
+//
 // NOTE: For batched requests in the current version, "json-rpc-request" on the Gin context contains only the
 // last request in the array.
-func Controller(api interface{}) gin.HandlerFunc {
+func Controller(api interface{}, timeouts *Timeouts) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method != "POST" {
 			jsonrpcError(c, -32700, "Parse error", "POST method excepted", nil)
@@ -466,7 +529,7 @@ func Controller(api interface{}) gin.HandlerFunc {
 
 			var result []gin.H
 			for _, data := range batch {
-				id, res, success := handleRequest(api, c, data)
+				id, res, success := handleRequest(api, timeouts, c, data)
 				if !success {
 					return
 				}
@@ -478,7 +541,7 @@ func Controller(api interface{}) gin.HandlerFunc {
 				})
 			}
 			c.JSON(http.StatusOK, result)
-		} else if id, res, success := handleRequest(api, c, data); success {
+		} else if id, res, success := handleRequest(api, timeouts, c, data); success {
 			c.JSON(http.StatusOK, gin.H{
 				"jsonrpc": "2.0",
 				"id":      id,
@@ -3,22 +3,24 @@ package batch
 import (
 	"math/big"
 
-	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/gas"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 )
 
+// DeferReasonGasLimit is recorded for aiOps that are deferred by MaintainGasLimit.
+const DeferReasonGasLimit = "batch gas limit reached"
+
 // MaintainGasLimit returns a BatchHandlerFunc that ensures the max gas used from the entire batch does not
-// exceed the allowed threshold.
+// exceed the allowed threshold. AiOps that do not fit are deferred to the next batch.
 func MaintainGasLimit(maxBatchGasLimit *big.Int) modules.BatchHandlerFunc {
 	// See comment in pkg/modules/checks/gas.go
 	staticOv := gas.NewDefaultOverhead()
 
 	return func(ctx *modules.BatchHandlerCtx) error {
-		bat := []*aiop.AiOperation{}
+		over := map[int]string{}
 		sum := big.NewInt(0)
-		for _, op := range ctx.Batch {
-			static, err := staticOv.CalcPreVerificationGas(op)
+		for i, op := range ctx.Batch {
+			static, err := staticOv.CalcPreVerificationGas(ctx.Context(), op)
 			if err != nil {
 				return err
 			}
@@ -27,12 +29,14 @@ func MaintainGasLimit(maxBatchGasLimit *big.Int) modules.BatchHandlerFunc {
 
 			sum = big.NewInt(0).Add(sum, mga)
 			if sum.Cmp(maxBatchGasLimit) >= 0 {
+				for j := i; j < len(ctx.Batch); j++ {
+					over[j] = DeferReasonGasLimit
+				}
 				break
 			}
-			bat = append(bat, op)
 		}
-		ctx.Batch = bat
 
+		ctx.DeferOps(over)
 		return nil
 	}
 }
@@ -0,0 +1,120 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/metachris/flashbotsrpc"
+)
+
+// DefaultBroadcastTimeout is the max duration to wait for a response from each block builder.
+var DefaultBroadcastTimeout = 30 * time.Second
+
+type rpcRequest struct {
+	ID      int    `json:"id"`
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	ID      int                    `json:"id"`
+	JSONRPC string                 `json:"jsonrpc"`
+	Result  json.RawMessage        `json:"result"`
+	Error   *flashbotsrpc.RpcError `json:"error"`
+}
+
+// Broadcaster sends bundles to a list of block builders. Requests are authenticated with the
+// X-Flashbots-Signature header which is signed by a Signer so that the bundler's private key does not need to
+// be held in memory.
+type Broadcaster struct {
+	urls   []string
+	client *http.Client
+}
+
+// NewBroadcaster returns a Broadcaster for the given block builder URLs.
+func NewBroadcaster(urls []string) *Broadcaster {
+	return &Broadcaster{
+		urls:   urls,
+		client: &http.Client{Timeout: DefaultBroadcastTimeout},
+	}
+}
+
+// BroadcastBundle calls eth_sendBundle on all block builders concurrently and returns a response for each
+// one.
+func (b *Broadcaster) BroadcastBundle(
+	s signer.Signer,
+	param flashbotsrpc.FlashbotsSendBundleRequest,
+) []flashbotsrpc.BuilderBroadcastResponse {
+	body, err := json.Marshal(rpcRequest{ID: 1, JSONRPC: "2.0", Method: "eth_sendBundle", Params: []any{param}})
+	if err != nil {
+		return []flashbotsrpc.BuilderBroadcastResponse{{Err: err}}
+	}
+	sig, err := s.SignText([]byte(crypto.Keccak256Hash(body).Hex()))
+	if err != nil {
+		return []flashbotsrpc.BuilderBroadcastResponse{{Err: err}}
+	}
+	header := s.Address().Hex() + ":" + hexutil.Encode(sig)
+
+	var wg sync.WaitGroup
+	responses := make([]flashbotsrpc.BuilderBroadcastResponse, len(b.urls))
+	for i, url := range b.urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			responses[i] = b.send(url, body, header)
+		}(i, url)
+	}
+	wg.Wait()
+
+	return responses
+}
+
+func (b *Broadcaster) send(url string, body []byte, signature string) flashbotsrpc.BuilderBroadcastResponse {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return flashbotsrpc.BuilderBroadcastResponse{Err: err}
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-Flashbots-Signature", signature)
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return flashbotsrpc.BuilderBroadcastResponse{Err: err}
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return flashbotsrpc.BuilderBroadcastResponse{Err: err}
+	}
+
+	// On error, some builders respond with {"error": "..."} instead of a JSON-RPC response.
+	relayErr := &flashbotsrpc.RelayErrorResponse{}
+	if err := json.Unmarshal(data, relayErr); err == nil && relayErr.Error != "" {
+		return flashbotsrpc.BuilderBroadcastResponse{
+			Err: fmt.Errorf("%w: %s", flashbotsrpc.ErrRelayErrorResponse, relayErr.Error),
+		}
+	}
+
+	rpcRes := &rpcResponse{}
+	if err := json.Unmarshal(data, rpcRes); err != nil {
+		return flashbotsrpc.BuilderBroadcastResponse{Err: err}
+	} else if rpcRes.Error != nil {
+		return flashbotsrpc.BuilderBroadcastResponse{
+			Err: fmt.Errorf("%w: %s", flashbotsrpc.ErrRelayErrorResponse, rpcRes.Error.Message),
+		}
+	}
+
+	bundle := flashbotsrpc.FlashbotsSendBundleResponse{}
+	err = json.Unmarshal(rpcRes.Result, &bundle)
+	return flashbotsrpc.BuilderBroadcastResponse{BundleResponse: bundle, Err: err}
+}
@@ -9,9 +9,12 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/transaction"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/pool"
+	badger "github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -21,73 +24,188 @@ import (
 // BuilderClient provides a connection to a block builder API to enable AiOperations to be sent through the
 // mev-boost process.
 type BuilderClient struct {
-	eoa               *signer.EOA
+	accounts          *pool.Pool
 	eth               *ethclient.Client
-	rpc               *flashbotsrpc.BuilderBroadcastRPC
+	rpc               *Broadcaster
 	beneficiary       common.Address
 	blocksInTheFuture int
-	waitTimeout       time.Duration
+	tracker           *transaction.Tracker
+	callTimeout       time.Duration
 }
 
 // New returns an instance of a BuilderClient with modules to send AiOperation bundles via the mev-boost
 // process.
 func New(
-	eoa *signer.EOA,
+	accounts *pool.Pool,
 	eth *ethclient.Client,
-	fb *flashbotsrpc.BuilderBroadcastRPC,
+	fb *Broadcaster,
 	beneficiary common.Address,
 	blocksInTheFuture int,
 ) *BuilderClient {
-	return &BuilderClient{
-		eoa:               eoa,
+	b := &BuilderClient{
+		accounts:          accounts,
 		eth:               eth,
 		rpc:               fb,
 		beneficiary:       beneficiary,
 		blocksInTheFuture: blocksInTheFuture,
-		waitTimeout:       DefaultWaitTimeout,
+		tracker:           transaction.NewTracker(eth, DefaultWaitTimeout),
 	}
+	b.tracker.OnSettled(b.settleNonce)
+	return b
 }
 
-// SetWaitTimeout sets the total time to wait for a transaction to be included. When a timeout is reached, the
-// BatchHandler will throw an error if the transaction has not been included or has been included but with a
-// failed status.
+func (b *BuilderClient) settleNonce(s *transaction.Settled) {
+	if s.Opts == nil || s.Opts.EOA == nil {
+		return
+	}
+	acc, err := b.accounts.Account(s.Opts.EOA.Address())
+	if err != nil {
+		return
+	}
+
+	if s.Receipt != nil {
+		acc.Nonces.Confirm(s.Txn.Nonce())
+	} else if errors.Is(s.Err, transaction.ErrTxnTimeout) {
+		// A timed out transaction may still be in the node's pool and reusing its nonce would collide with it.
+		ctx, cancel := utils.WithTimeout(context.Background(), b.callTimeout)
+		defer cancel()
+		if err := acc.Nonces.Resync(ctx, s.Txn.Nonce()); err != nil {
+			acc.Nonces.Release(s.Txn.Nonce())
+		}
+	} else {
+		acc.Nonces.Release(s.Txn.Nonce())
+	}
+}
+
+// SetWaitTimeout sets the total time to wait for a transaction to be included. Transactions are tracked in the
+// background and on a timeout the transaction's nonce and batch are reused in a later bundle. A transaction that
+// the node still has in its pool is tracked past the timeout until its nonce is used.
 //
-// The default value is 72 seconds. Setting the value to 0 will skip waiting for a transaction to be included.
+// The default value is 72 seconds. Setting the value to 0 will track transactions without a deadline.
 func (b *BuilderClient) SetWaitTimeout(timeout time.Duration) {
-	b.waitTimeout = timeout
+	b.tracker.SetTimeout(timeout)
+}
+
+// SetCallTimeout sets the max time for each node call made while tracking sent transactions in the
+// background. The default value is 0 which means calls have no deadline.
+func (b *BuilderClient) SetCallTimeout(timeout time.Duration) {
+	b.callTimeout = timeout
+	b.tracker.SetCallTimeout(timeout)
+}
+
+// UseDB persists sent transactions in db until they are settled. Transactions that were still pending when the
+// process last stopped are tracked again and their nonces are reserved so that they are not reused.
+func (b *BuilderClient) UseDB(db *badger.DB) error {
+	b.tracker.UseDB(db)
+	err := b.tracker.Restore(func(address common.Address) (signer.Signer, error) {
+		acc, err := b.accounts.Account(address)
+		if err != nil {
+			return nil, err
+		}
+		return acc.EOA, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range b.tracker.Pending() {
+		if acc, err := b.accounts.Account(sub.Opts.EOA.Address()); err == nil {
+			acc.Nonces.Reserve(sub.Txn.Nonce())
+		}
+	}
+	return nil
+}
+
+// Pending returns all sent transactions that have not been settled.
+func (b *BuilderClient) Pending() []*transaction.Submitted {
+	return b.tracker.Pending()
+}
+
+// OnSettled adds a function that will be called every time a sent transaction is settled.
+func (b *BuilderClient) OnSettled(fn transaction.SettledHandlerFunc) {
+	b.tracker.OnSettled(fn)
+}
+
+// Run starts tracking sent transactions in the background. This must be called for nonces to be settled and
+// allow each account to send its next bundle.
+func (b *BuilderClient) Run() error {
+	return b.tracker.Run()
+}
+
+// Stop signals the BuilderClient to stop tracking sent transactions.
+func (b *BuilderClient) Stop() {
+	b.tracker.Stop()
+}
+
+// Wait blocks until the sent transaction with the given hash is settled and every function added with
+// OnSettled has returned, or ctx is done.
+func (b *BuilderClient) Wait(ctx context.Context, hash common.Hash) error {
+	return b.tracker.Wait(ctx, hash)
+}
+
+// Shutdown waits for sent transactions to be settled before it stops tracking them. If ctx is done first, an
+// error is returned and the remaining transactions are only tracked again if they were persisted with UseDB.
+func (b *BuilderClient) Shutdown(ctx context.Context) error {
+	defer b.tracker.Stop()
+	return b.tracker.Drain(ctx)
 }
 
 // SendAiOperation returns a BatchHandler that is used by the Bundler to send batches to a block builder
 // that supports eth_sendBundle.
+//
+// Bundles never enter the node's public pool, so a bundle that reuses the pending nonce from the node would
+// depend on an earlier bundle that may never be included. Each account therefore has at most one tracked
+// bundle and the batch is deferred while the selected account is waiting for its bundle to settle.
 func (b *BuilderClient) SendAiOperation() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
+		acc, err := b.accounts.Next(ctx.Context())
+		if err != nil {
+			return err
+		}
+		ctx.Data["signer"] = acc.EOA.Address().Hex()
+		if !ctx.DryRun && acc.Nonces.Pending() > 0 {
+			reasons := make(map[int]string)
+			for i := range ctx.Batch {
+				reasons[i] = DeferReasonPendingBundle
+			}
+			ctx.DeferOps(reasons)
+			return nil
+		}
+
 		opts := transaction.Opts{
-			EOA:          b.eoa,
-			Eth:          b.eth,
-			ChainID:      ctx.ChainID,
-			AiMiddleware: ctx.AiMiddleware,
-			Batch:        ctx.Batch,
-			Beneficiary:  b.beneficiary,
-			BaseFee:      ctx.BaseFee,
-			Tip:          ctx.Tip,
-			GasPrice:     ctx.GasPrice,
-			GasLimit:     0,
-			NoSend:       true,
-			WaitTimeout:  b.waitTimeout,
+			EOA:                  acc.EOA,
+			Eth:                  b.eth,
+			ChainID:              ctx.ChainID,
+			AiMiddleware:         ctx.AiMiddleware,
+			Batch:                ctx.Batch,
+			Beneficiary:          b.beneficiary,
+			Aggregators:          ctx.Aggregators,
+			AggregatorSignatures: ctx.AggregatorSigs,
+			BaseFee:              ctx.BaseFee,
+			Tip:                  ctx.Tip,
+			GasPrice:             ctx.GasPrice,
+			GasLimit:             0,
+			NoFeeEstimate:        ctx.DryRun,
+			NoSend:               true,
 		}
 		// Estimate gas for handleOps() and drop all aiOps that cause unexpected reverts.
 		for len(ctx.Batch) > 0 {
-			est, revert, err := transaction.EstimateHandleOpsGas(&opts)
+			est, revert, err := transaction.EstimateHandleOpsGas(ctx.Context(), &opts)
 
 			if err != nil {
 				return err
 			} else if revert != nil {
 				ctx.MarkOpIndexForRemoval(revert.OpIndex, revert.Reason)
+				opts.Batch = ctx.Batch
 			} else {
 				opts.GasLimit = est
 				break
 			}
 		}
+		if ctx.DryRun {
+			ctx.Data[modules.GasEstimateKey] = opts.GasLimit
+			return nil
+		}
 
 		// No need to continue if the batch size is 0. Otherwise we would just be sending empty batches.
 		if len(ctx.Batch) == 0 {
@@ -95,7 +213,7 @@ func (b *BuilderClient) SendAiOperation() modules.BatchHandlerFunc {
 		}
 
 		// Calculate the max base fee up to a future block number.
-		bn, err := b.eth.BlockNumber(context.Background())
+		bn, err := b.eth.BlockNumber(ctx.Context())
 		if err != nil {
 			return err
 		}
@@ -108,11 +226,17 @@ func (b *BuilderClient) SendAiOperation() modules.BatchHandlerFunc {
 		}
 		opts.BaseFee = mbf
 
-		// Create no send transaction to the AiMiddleware
-		txn, err := transaction.HandleOps(&opts)
+		// Create no send transaction to the AiMiddleware with the next available nonce.
+		n, err := acc.Nonces.Next(ctx.Context())
 		if err != nil {
 			return err
 		}
+		opts.Nonce = big.NewInt(0).SetUint64(n)
+		txn, err := transaction.HandleOps(ctx.Context(), &opts)
+		if err != nil {
+			acc.Nonces.Release(n)
+			return err
+		}
 
 		// Broadcast bundle to a list of ethereum block builders for all blocks up to a future block.
 		shouldFail := true
@@ -124,7 +248,7 @@ func (b *BuilderClient) SendAiOperation() modules.BatchHandlerFunc {
 				BlockNumber: hexutil.EncodeBig(fbn),
 			}
 
-			results := b.rpc.BroadcastBundle(b.eoa.PrivateKey, sendBundleArgs)
+			results := b.rpc.BroadcastBundle(acc.EOA, sendBundleArgs)
 			for _, result := range results {
 				if result.Err != nil {
 					errs = errors.Join(errs, result.Err)
@@ -136,14 +260,15 @@ func (b *BuilderClient) SendAiOperation() modules.BatchHandlerFunc {
 
 		// If there are no successful broadcast, return an error.
 		if shouldFail {
+			acc.Nonces.Release(n)
 			return fmt.Errorf("%w: \n\n%w", ErrFlashbotsBroadcastBundle, errs)
 		}
 
-		// Wait for transaction to be included on-chain.
-		if _, err := transaction.Wait(txn, opts.Eth, opts.WaitTimeout); err != nil {
-			return err
-		}
+		// Track the transaction in the background until it is included on-chain.
+		b.tracker.Track(txn, &opts)
+		ctx.PendingInclusion = true
 		ctx.Data["txn_hash"] = txn.Hash().String()
+		ctx.Data["txn_nonce"] = n
 
 		return nil
 	}
@@ -1,17 +1,24 @@
 package builder
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"math/big"
+	"sync"
 	"testing"
 
 	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/pool"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
-	"github.com/metachris/flashbotsrpc"
 )
 
 func TestSendAiOperationWithAllUpstreamErrors(t *testing.T) {
@@ -19,6 +26,7 @@ func TestSendAiOperationWithAllUpstreamErrors(t *testing.T) {
 		"eth_blockNumber":           "0x1",
 		"eth_gasPrice":              "0x1",
 		"eth_getTransactionCount":   "0x1",
+		"eth_getBalance":            "0x1",
 		"eth_estimateGas":           "0x1",
 		"eth_getBlockByNumber":      testutils.NewBlockMock(),
 		"eth_getTransactionReceipt": testutils.NewTransactionReceiptMock(),
@@ -28,11 +36,13 @@ func TestSendAiOperationWithAllUpstreamErrors(t *testing.T) {
 
 	bb1 := testutils.BadBuilderRpcMock()
 	bb2 := testutils.BadBuilderRpcMock()
-	fb := flashbotsrpc.NewBuilderBroadcastRPC([]string{bb1.URL, bb2.URL})
-	fn := New(testutils.DummyEOA, eth, fb, testutils.DummyEOA.Address, 1).SendAiOperation()
+	fb := NewBroadcaster([]string{bb1.URL, bb2.URL})
+	accounts, _ := pool.New(eth, []signer.Signer{testutils.DummyEOA}, pool.RoundRobin)
+	fn := New(accounts, eth, fb, testutils.DummyEOA.Address(), 1).SendAiOperation()
 
 	if err := fn(
 		modules.NewBatchHandlerContext(
+			context.Background(),
 			[]*aiop.AiOperation{testutils.MockValidInitAiOp()},
 			common.HexToAddress("0x"),
 			testutils.ChainID,
@@ -50,6 +60,7 @@ func TestSendAiOperationWithPartialUpstreamErrors(t *testing.T) {
 		"eth_blockNumber":           "0x1",
 		"eth_gasPrice":              "0x1",
 		"eth_getTransactionCount":   "0x1",
+		"eth_getBalance":            "0x1",
 		"eth_estimateGas":           "0x1",
 		"eth_getBlockByNumber":      testutils.NewBlockMock(),
 		"eth_getTransactionReceipt": testutils.NewTransactionReceiptMock(),
@@ -63,11 +74,13 @@ func TestSendAiOperationWithPartialUpstreamErrors(t *testing.T) {
 		},
 	})
 	bb2 := testutils.BadBuilderRpcMock()
-	fb := flashbotsrpc.NewBuilderBroadcastRPC([]string{bb1.URL, bb2.URL})
-	fn := New(testutils.DummyEOA, eth, fb, testutils.DummyEOA.Address, 1).SendAiOperation()
+	fb := NewBroadcaster([]string{bb1.URL, bb2.URL})
+	accounts, _ := pool.New(eth, []signer.Signer{testutils.DummyEOA}, pool.RoundRobin)
+	fn := New(accounts, eth, fb, testutils.DummyEOA.Address(), 1).SendAiOperation()
 
 	if err := fn(
 		modules.NewBatchHandlerContext(
+			context.Background(),
 			[]*aiop.AiOperation{testutils.MockValidInitAiOp()},
 			common.HexToAddress("0x"),
 			testutils.ChainID,
@@ -85,6 +98,7 @@ func TestSendAiOperationWithNoUpstreamErrors(t *testing.T) {
 		"eth_blockNumber":           "0x1",
 		"eth_gasPrice":              "0x1",
 		"eth_getTransactionCount":   "0x1",
+		"eth_getBalance":            "0x1",
 		"eth_estimateGas":           "0x1",
 		"eth_getBlockByNumber":      testutils.NewBlockMock(),
 		"eth_getTransactionReceipt": testutils.NewTransactionReceiptMock(),
@@ -102,11 +116,13 @@ func TestSendAiOperationWithNoUpstreamErrors(t *testing.T) {
 			"bundleHash": testutils.MockHash,
 		},
 	})
-	fb := flashbotsrpc.NewBuilderBroadcastRPC([]string{bb1.URL, bb2.URL})
-	fn := New(testutils.DummyEOA, eth, fb, testutils.DummyEOA.Address, 1).SendAiOperation()
+	fb := NewBroadcaster([]string{bb1.URL, bb2.URL})
+	accounts, _ := pool.New(eth, []signer.Signer{testutils.DummyEOA}, pool.RoundRobin)
+	fn := New(accounts, eth, fb, testutils.DummyEOA.Address(), 1).SendAiOperation()
 
 	if err := fn(
 		modules.NewBatchHandlerContext(
+			context.Background(),
 			[]*aiop.AiOperation{testutils.MockValidInitAiOp()},
 			common.HexToAddress("0x"),
 			testutils.ChainID,
@@ -118,3 +134,182 @@ func TestSendAiOperationWithNoUpstreamErrors(t *testing.T) {
 		t.Fatalf("got %v, want nil", err)
 	}
 }
+
+// failedOpData returns the revert data of a FailedOp error from the AiMiddleware.
+func failedOpData(t *testing.T, opIndex int64, reason string) string {
+	uint256, _ := abi.NewType("uint256", "", nil)
+	str, _ := abi.NewType("string", "", nil)
+	args, err := abi.Arguments{{Type: uint256}, {Type: str}}.Pack(big.NewInt(opIndex), reason)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hexutil.Encode(append(crypto.Keccak256([]byte("FailedOp(uint256,string)"))[:4], args...))
+}
+
+// TestSendAiOperationEstimatesRemainingBatch verifies that once an op is dropped for a FailedOp revert, the
+// next estimate only includes the ops that are left in the batch.
+func TestSendAiOperationEstimatesRemainingBatch(t *testing.T) {
+	revert := failedOpData(t, 0, "AA23 reverted")
+	var mu sync.Mutex
+	estimates := []string{}
+	estimateGas := func(params []json.RawMessage) (any, error) {
+		var call struct {
+			Input hexutil.Bytes `json:"input"`
+			Data  hexutil.Bytes `json:"data"`
+		}
+		if err := json.Unmarshal(params[0], &call); err != nil {
+			return nil, err
+		}
+		data := call.Input
+		if data == nil {
+			data = call.Data
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		estimates = append(estimates, hexutil.Encode(data))
+		if len(estimates) == 1 {
+			return nil, &testutils.MockError{Code: 3, Message: "execution reverted", Data: revert}
+		}
+		return "0x1", nil
+	}
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_gasPrice":              "0x1",
+		"eth_getTransactionCount":   "0x1",
+		"eth_getBalance":            "0x1",
+		"eth_getBlockByNumber":      testutils.NewBlockMock(),
+		"eth_getTransactionReceipt": testutils.NewTransactionReceiptMock(),
+		"eth_estimateGas":           testutils.MethodFunc(estimateGas),
+	})
+	defer n.Close()
+	r, _ := rpc.Dial(n.URL)
+	eth := ethclient.NewClient(r)
+
+	bb := testutils.RpcMock(testutils.MethodMocks{
+		"eth_sendBundle": map[string]string{
+			"bundleHash": testutils.MockHash,
+		},
+	})
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Nonce = big.NewInt(1)
+	accounts, _ := pool.New(eth, []signer.Signer{testutils.DummyEOA}, pool.RoundRobin)
+	fn := New(accounts, eth, NewBroadcaster([]string{bb.URL}), testutils.DummyEOA.Address(), 1).SendAiOperation()
+	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{op1, op2},
+		common.HexToAddress("0x"),
+		testutils.ChainID,
+		big.NewInt(1),
+		big.NewInt(1),
+		big.NewInt(1),
+	)
+
+	if err := fn(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(ctx.Batch) != 1 || ctx.Batch[0] != op2 {
+		t.Fatalf("got batch of %d ops, want [op2]", len(ctx.Batch))
+	}
+	if len(estimates) != 2 {
+		t.Fatalf("got %d estimates, want 2", len(estimates))
+	} else if len(estimates[1]) >= len(estimates[0]) {
+		t.Fatalf("second estimate was not for the remaining batch")
+	}
+}
+
+// TestSendAiOperationTracksWithoutWaitTimeout verifies that a wait timeout of 0 still tracks the sent
+// transaction so that the batch is kept in the mempool until it is included.
+func TestSendAiOperationTracksWithoutWaitTimeout(t *testing.T) {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_blockNumber":         "0x1",
+		"eth_gasPrice":            "0x1",
+		"eth_getTransactionCount": "0x1",
+		"eth_getBalance":          "0x1",
+		"eth_estimateGas":         "0x1",
+		"eth_getBlockByNumber":    testutils.NewBlockMock(),
+	})
+	r, _ := rpc.Dial(n.URL)
+	eth := ethclient.NewClient(r)
+
+	bb := testutils.RpcMock(testutils.MethodMocks{
+		"eth_sendBundle": map[string]string{
+			"bundleHash": testutils.MockHash,
+		},
+	})
+	accounts, _ := pool.New(eth, []signer.Signer{testutils.DummyEOA}, pool.RoundRobin)
+	b := New(accounts, eth, NewBroadcaster([]string{bb.URL}), testutils.DummyEOA.Address(), 1)
+	b.SetWaitTimeout(0)
+	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{testutils.MockValidInitAiOp()},
+		common.HexToAddress("0x"),
+		testutils.ChainID,
+		big.NewInt(1),
+		big.NewInt(1),
+		big.NewInt(1),
+	)
+
+	if err := b.SendAiOperation()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if !ctx.PendingInclusion {
+		t.Fatal("got PendingInclusion false, want true")
+	}
+	if p := b.tracker.Pending(); len(p) != 1 {
+		t.Fatalf("got %d pending transactions, want 1", len(p))
+	}
+}
+
+// TestSendAiOperationDefersWhileBundlePending verifies that a second batch for an account with a tracked bundle
+// is deferred instead of being sent with the next nonce.
+func TestSendAiOperationDefersWhileBundlePending(t *testing.T) {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_blockNumber":         "0x1",
+		"eth_gasPrice":            "0x1",
+		"eth_getTransactionCount": "0x1",
+		"eth_getBalance":          "0x1",
+		"eth_estimateGas":         "0x1",
+		"eth_getBlockByNumber":    testutils.NewBlockMock(),
+	})
+	r, _ := rpc.Dial(n.URL)
+	eth := ethclient.NewClient(r)
+
+	bb := testutils.RpcMock(testutils.MethodMocks{
+		"eth_sendBundle": map[string]string{
+			"bundleHash": testutils.MockHash,
+		},
+	})
+	accounts, _ := pool.New(eth, []signer.Signer{testutils.DummyEOA}, pool.RoundRobin)
+	b := New(accounts, eth, NewBroadcaster([]string{bb.URL}), testutils.DummyEOA.Address(), 1)
+	newCtx := func(op *aiop.AiOperation) *modules.BatchHandlerCtx {
+		return modules.NewBatchHandlerContext(
+			context.Background(),
+			[]*aiop.AiOperation{op},
+			common.HexToAddress("0x"),
+			testutils.ChainID,
+			big.NewInt(1),
+			big.NewInt(1),
+			big.NewInt(1),
+		)
+	}
+
+	if err := b.SendAiOperation()(newCtx(testutils.MockValidInitAiOp())); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	op := testutils.MockValidInitAiOp()
+	op.Nonce = big.NewInt(1)
+	ctx := newCtx(op)
+	if err := b.SendAiOperation()(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if ctx.PendingInclusion || len(ctx.Batch) != 0 {
+		t.Fatalf("got batch of %d ops, want it deferred", len(ctx.Batch))
+	}
+	if len(ctx.Deferred) != 1 || ctx.Deferred[0].Reason != DeferReasonPendingBundle {
+		t.Fatalf("got %d deferred ops, want 1 with reason %q", len(ctx.Deferred), DeferReasonPendingBundle)
+	}
+	if p := b.tracker.Pending(); len(p) != 1 {
+		t.Fatalf("got %d pending transactions, want 1", len(p))
+	}
+}
@@ -3,19 +3,13 @@ package builder
 import (
 	"errors"
 	"time"
-
-	"github.com/AO-Metaplayer/aiops-bundler/internal/config"
-	mapset "github.com/deckarep/golang-set/v2"
 )
 
-var (
-	// CompatibleChainIDs is a set of chainIDs that support the Block Builder API.
-	CompatibleChainIDs = mapset.NewSet(
-		config.EthereumChainID.Uint64(),
-		config.GoerliChainID.Uint64(),
-		config.SepoliaChainID.Uint64(),
-	)
+// DeferReasonPendingBundle is recorded for aiOps that are deferred while the selected account has a bundle
+// that is not yet settled.
+const DeferReasonPendingBundle = "signer has a pending bundle"
 
+var (
 	DefaultWaitTimeout = 72 * time.Second
 
 	ErrFlashbotsBroadcastBundle = errors.New("flashbots broadcast bundle error")
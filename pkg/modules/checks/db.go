@@ -2,6 +2,7 @@ package checks
 
 import (
 	"encoding/json"
+	"errors"
 
 	"github.com/AO-Metaplayer/aiops-bundler/internal/dbutils"
 	"github.com/dgraph-io/badger/v3"
@@ -11,6 +12,7 @@ import (
 var (
 	keyPrefix        = dbutils.JoinValues("checks")
 	codeHashesPrefix = dbutils.JoinValues(keyPrefix, "codeHashes")
+	aggregatorPrefix = dbutils.JoinValues(keyPrefix, "aggregators")
 )
 
 func getCodeHashesKey(aiOpHash common.Hash) []byte {
@@ -54,3 +56,51 @@ func removeSavedCodeHashes(db *badger.DB, aiOpHashes ...common.Hash) error {
 		return nil
 	})
 }
+
+func getAggregatorKey(aiOpHash common.Hash) []byte {
+	return []byte(dbutils.JoinValues(aggregatorPrefix, aiOpHash.String()))
+}
+
+// saveAggregator stores the aggregator of an AiOperation followed by the signature that should replace the
+// AiOperation's signature once it is bundled.
+func saveAggregator(db *badger.DB, aiOpHash common.Hash, aggregator common.Address, sigForAiOp []byte) error {
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Set(getAggregatorKey(aiOpHash), append(aggregator.Bytes(), sigForAiOp...))
+	})
+}
+
+func getSavedAggregator(db *badger.DB, aiOpHash common.Hash) (common.Address, []byte, error) {
+	var agg common.Address
+	var sig []byte
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(getAggregatorKey(aiOpHash))
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			if len(val) < common.AddressLength {
+				return errors.New("checks: invalid saved aggregator")
+			}
+			agg = common.BytesToAddress(val[:common.AddressLength])
+			sig = append([]byte{}, val[common.AddressLength:]...)
+			return nil
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return common.Address{}, nil, nil
+	}
+
+	return agg, sig, err
+}
+
+func removeSavedAggregators(db *badger.DB, aiOpHashes ...common.Hash) error {
+	return db.Update(func(txn *badger.Txn) error {
+		for _, aiOpHash := range aiOpHashes {
+			if err := txn.Delete(getAggregatorKey(aiOpHash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
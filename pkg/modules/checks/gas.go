@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 
@@ -9,12 +10,12 @@ import (
 )
 
 // ValidateGasAvailable checks that the max available gas is less than the batch gas limit.
-func ValidateGasAvailable(op *aiop.AiOperation, maxBatchGasLimit *big.Int) error {
+func ValidateGasAvailable(ctx context.Context, op *aiop.AiOperation, maxBatchGasLimit *big.Int) error {
 	// This calculation ensures that we are only checking the gas used for execution. In rollups, the PVG also
 	// includes the L1 callData cost. If the L1 gas component spikes, it can cause the PVG value of legit ops
 	// to be greater than the maxBatchGasLimit. For non-rollups, the results would be the same as just calling
 	// op.GetMaxGasAvailable().
-	static, err := gas.NewDefaultOverhead().CalcPreVerificationGas(op)
+	static, err := gas.NewDefaultOverhead().CalcPreVerificationGas(ctx, op)
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"math/big"
 	"testing"
 
@@ -13,7 +14,7 @@ import (
 func TestOpMAGLessThanMax(t *testing.T) {
 	op := testutils.MockValidInitAiOp()
 	max := big.NewInt(0).Add(op.GetMaxGasAvailable(), common.Big1)
-	err := ValidateGasAvailable(op, max)
+	err := ValidateGasAvailable(context.Background(), op, max)
 
 	if err != nil {
 		t.Fatalf("got %v, want nil", err)
@@ -24,7 +25,7 @@ func TestOpMAGLessThanMax(t *testing.T) {
 // Expect nil.
 func TestOpMAGEqualToMax(t *testing.T) {
 	op := testutils.MockValidInitAiOp()
-	err := ValidateGasAvailable(op, op.GetMaxGasAvailable())
+	err := ValidateGasAvailable(context.Background(), op, op.GetMaxGasAvailable())
 
 	if err != nil {
 		t.Fatalf("got %v, want nil", err)
@@ -36,7 +37,7 @@ func TestOpMAGEqualToMax(t *testing.T) {
 func TestOpMAGMoreThanMax(t *testing.T) {
 	op := testutils.MockValidInitAiOp()
 	max := big.NewInt(0).Sub(op.GetMaxGasAvailable(), common.Big1)
-	err := ValidateGasAvailable(op, max)
+	err := ValidateGasAvailable(context.Background(), op, max)
 
 	if err == nil {
 		t.Fatalf("got nil, want err")
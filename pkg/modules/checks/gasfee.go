@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
@@ -10,8 +11,8 @@ import (
 // ValidateFeePerGas checks the maxFeePerGas is sufficiently high to be included with the current
 // block.basefee. Alternatively, if basefee is not supported, then check that maxPriorityFeePerGas is equal to
 // maxFeePerGas as a fallback.
-func ValidateFeePerGas(op *aiop.AiOperation, gbf gasprice.GetBaseFeeFunc) error {
-	bf, err := gbf()
+func ValidateFeePerGas(ctx context.Context, op *aiop.AiOperation, gbf gasprice.GetBaseFeeFunc) error {
+	bf, err := gbf(ctx)
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"testing"
 
 	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
@@ -13,7 +14,7 @@ func TestMFLessThanBF(t *testing.T) {
 	gbf := testutils.GetMockBaseFeeFunc(common.Big2)
 	op.MaxFeePerGas = common.Big1
 	op.MaxPriorityFeePerGas = common.Big0
-	err := ValidateFeePerGas(op, gbf)
+	err := ValidateFeePerGas(context.Background(), op, gbf)
 
 	if err == nil {
 		t.Fatal("got nil, want err")
@@ -26,7 +27,7 @@ func TestMFEqualBF(t *testing.T) {
 	gbf := testutils.GetMockBaseFeeFunc(common.Big1)
 	op.MaxFeePerGas = common.Big1
 	op.MaxPriorityFeePerGas = common.Big0
-	err := ValidateFeePerGas(op, gbf)
+	err := ValidateFeePerGas(context.Background(), op, gbf)
 
 	if err != nil {
 		t.Fatalf("got %v, want nil", err)
@@ -39,7 +40,7 @@ func TestMFMoreThanBF(t *testing.T) {
 	gbf := testutils.GetMockBaseFeeFunc(common.Big1)
 	op.MaxFeePerGas = common.Big2
 	op.MaxPriorityFeePerGas = common.Big0
-	err := ValidateFeePerGas(op, gbf)
+	err := ValidateFeePerGas(context.Background(), op, gbf)
 
 	if err != nil {
 		t.Fatalf("got %v, want nil", err)
@@ -52,7 +53,7 @@ func TestMPFMoreThanMF(t *testing.T) {
 	gbf := testutils.GetMockBaseFeeFunc(common.Big1)
 	op.MaxFeePerGas = common.Big2
 	op.MaxPriorityFeePerGas = common.Big3
-	err := ValidateFeePerGas(op, gbf)
+	err := ValidateFeePerGas(context.Background(), op, gbf)
 
 	if err == nil {
 		t.Fatal("got nil, want err")
@@ -65,7 +66,7 @@ func TestMPFEqualMF(t *testing.T) {
 	gbf := testutils.GetMockBaseFeeFunc(common.Big1)
 	op.MaxFeePerGas = common.Big2
 	op.MaxPriorityFeePerGas = common.Big2
-	err := ValidateFeePerGas(op, gbf)
+	err := ValidateFeePerGas(context.Background(), op, gbf)
 
 	if err != nil {
 		t.Fatalf("got %v, want nil", err)
@@ -78,7 +79,7 @@ func TestMPFLessThanMF(t *testing.T) {
 	gbf := testutils.GetMockBaseFeeFunc(common.Big1)
 	op.MaxFeePerGas = common.Big2
 	op.MaxPriorityFeePerGas = common.Big1
-	err := ValidateFeePerGas(op, gbf)
+	err := ValidateFeePerGas(context.Background(), op, gbf)
 
 	if err != nil {
 		t.Fatalf("got %v, want nil", err)
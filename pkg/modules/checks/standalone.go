@@ -3,19 +3,27 @@
 package checks
 
 import (
+	"context"
+	stdErr "errors"
 	"math/big"
+	"sort"
 	"time"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/aggregator"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/reverts"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/simulation"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/altmempools"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/blockcache"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/gas"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/entities"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/gasprice"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/reader"
 	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -36,6 +44,7 @@ type Standalone struct {
 	isRIP7212Supported bool
 	tracer             string
 	repConst           *entities.ReputationConstants
+	cache              *blockcache.Cache
 }
 
 // New returns a Standalone instance with methods that can be used in Client and Bundler modules to perform
@@ -63,24 +72,32 @@ func New(
 		isRIP7212Supported,
 		tracer,
 		repConst,
+		nil,
 	}
 }
 
+// UseBlockCache defines a block-scoped cache for reading deposits and code. This avoids repeated calls to the
+// node for the same values within a block, for example when checking code hashes on every bundler run.
+func (s *Standalone) UseBlockCache(cache *blockcache.Cache) {
+	s.cache = cache
+}
+
 // ValidateOpValues returns a AiOpHandler that runs through some first line sanity checks for new AiOps
 // received by the Client. This should be one of the first modules executed by the Client.
 func (s *Standalone) ValidateOpValues() modules.AiOpHandlerFunc {
 	return func(ctx *modules.AiOpHandlerCtx) error {
-		gc := getCodeWithEthClient(s.eth)
+		gc := getCodeWithEthClient(ctx.Context(), s.eth, s.cache)
+		gbf := gasprice.GetBaseFeeWithEthClient(s.eth)
 
 		g := new(errgroup.Group)
 		g.Go(func() error { return ValidateSender(ctx.AiOp, gc) })
 		g.Go(func() error { return ValidateInitCode(ctx.AiOp) })
-		g.Go(func() error { return ValidateVerificationGas(ctx.AiOp, s.ov, s.maxVerificationGas) })
+		g.Go(func() error { return ValidateVerificationGas(ctx.Context(), ctx.AiOp, s.ov, s.maxVerificationGas) })
 		g.Go(func() error { return ValidatePaymasterAndData(ctx.AiOp, ctx.GetPaymasterDepositInfo(), gc) })
 		g.Go(func() error { return ValidateCallGasLimit(ctx.AiOp, s.ov) })
-		g.Go(func() error { return ValidateFeePerGas(ctx.AiOp, gasprice.GetBaseFeeWithEthClient(s.eth)) })
+		g.Go(func() error { return ValidateFeePerGas(ctx.Context(), ctx.AiOp, gbf) })
 		g.Go(func() error { return ValidatePendingOps(ctx.AiOp, ctx.GetPendingSenderOps()) })
-		g.Go(func() error { return ValidateGasAvailable(ctx.AiOp, s.maxBatchGasLimit) })
+		g.Go(func() error { return ValidateGasAvailable(ctx.Context(), ctx.AiOp, s.maxBatchGasLimit) })
 
 		if err := g.Wait(); err != nil {
 			return errors.NewRPCError(errors.INVALID_FIELDS, err.Error(), err.Error())
@@ -89,17 +106,33 @@ func (s *Standalone) ValidateOpValues() modules.AiOpHandlerFunc {
 	}
 }
 
-// SimulateOp returns a AiOpHandler that runs through simulation of new AiOps with the AiMiddleware.
+// SimulateOp returns a AiOpHandler that runs through simulation of new AiOps with the AiMiddleware. If the
+// AiOp uses a signature aggregator, the signature is also validated with the aggregator contract and the
+// returned signature is saved to replace the AiOp's signature once it is bundled.
 func (s *Standalone) SimulateOp() modules.AiOpHandlerFunc {
 	return func(ctx *modules.AiOpHandlerCtx) error {
-		gc := getCodeWithEthClient(s.eth)
+		gc := getCodeWithEthClient(ctx.Context(), s.eth, s.cache)
 		g := new(errgroup.Group)
+		var aggInfo *reverts.AggregatorStakeInfo
+		var sigForAiOp []byte
 		g.Go(func() error {
-			sim, err := simulation.SimulateValidation(s.rpc, ctx.AiMiddleware, ctx.AiOp)
+			sim, err := simulation.SimulateValidation(ctx.Context(), s.rpc, ctx.AiMiddleware, ctx.AiOp)
 
 			if err != nil {
 				return errors.NewRPCError(errors.REJECTED_BY_EP_OR_ACCOUNT, err.Error(), err.Error())
 			}
+			if sim.AggregatorInfo != nil {
+				sig, err := aggregator.ValidateAiOpSignature(ctx.Context(), s.eth, sim.AggregatorInfo.Aggregator, ctx.AiOp)
+				if err != nil {
+					return errors.NewRPCError(
+						errors.INVALID_SIGNATURE,
+						"Invalid AiOp signature for aggregator",
+						err.Error(),
+					)
+				}
+				aggInfo = sim.AggregatorInfo
+				sigForAiOp = sig
+			}
 			if sim.ReturnInfo.SigFailed {
 				return errors.NewRPCError(
 					errors.INVALID_SIGNATURE,
@@ -118,7 +151,7 @@ func (s *Standalone) SimulateOp() modules.AiOpHandlerFunc {
 			return nil
 		})
 		g.Go(func() error {
-			out, err := simulation.TraceSimulateValidation(&simulation.TraceInput{
+			out, err := simulation.TraceSimulateValidation(ctx.Context(), &simulation.TraceInput{
 				Rpc:                s.rpc,
 				AiMiddleware:       ctx.AiMiddleware,
 				AltMempools:        s.alt,
@@ -136,6 +169,12 @@ func (s *Standalone) SimulateOp() modules.AiOpHandlerFunc {
 				return errors.NewRPCError(errors.BANNED_OPCODE, err.Error(), err.Error())
 			}
 
+			// Fetch the code for all touched contracts in a single batch if the request has a Reader.
+			if r, ok := reader.FromContext(ctx.Context()); ok {
+				if err := r.Load(ctx.Context(), reader.Request{Code: out.TouchedContracts}); err != nil {
+					return errors.NewRPCError(errors.BANNED_OPCODE, err.Error(), err.Error())
+				}
+			}
 			ch, err := getCodeHashes(out.TouchedContracts, gc)
 			if err != nil {
 				return errors.NewRPCError(errors.BANNED_OPCODE, err.Error(), err.Error())
@@ -143,7 +182,19 @@ func (s *Standalone) SimulateOp() modules.AiOpHandlerFunc {
 			return saveCodeHashes(s.db, ctx.AiOp.GetAiOpHash(ctx.AiMiddleware, ctx.ChainID), ch)
 		})
 
-		return g.Wait()
+		if err := g.Wait(); err != nil {
+			return err
+		}
+		if aggInfo != nil {
+			ctx.AggregatorInfo = aggInfo
+			return saveAggregator(
+				s.db,
+				ctx.AiOp.GetAiOpHash(ctx.AiMiddleware, ctx.ChainID),
+				aggInfo.Aggregator,
+				sigForAiOp,
+			)
+		}
+		return nil
 	}
 }
 
@@ -151,13 +202,17 @@ func (s *Standalone) SimulateOp() modules.AiOpHandlerFunc {
 // the first simulation.
 func (s *Standalone) CodeHashes() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
-		gc := getCodeWithEthClient(s.eth)
+		gc := getCodeWithEthClient(ctx.Context(), s.eth, s.cache)
 
 		end := len(ctx.Batch) - 1
 		for i := end; i >= 0; i-- {
 			op := ctx.Batch[i]
 			chs, err := getSavedCodeHashes(s.db, op.GetAiOpHash(ctx.AiMiddleware, ctx.ChainID))
-			if err != nil {
+			if stdErr.Is(err, badger.ErrKeyNotFound) {
+				// This can happen for ops that were returned to the mempool without being re-validated.
+				ctx.MarkOpIndexForRemoval(i, "code hashes not found")
+				continue
+			} else if err != nil {
 				return err
 			}
 
@@ -173,6 +228,23 @@ func (s *Standalone) CodeHashes() modules.BatchHandlerFunc {
 	}
 }
 
+func (s *Standalone) getDepositInfo(
+	ctx context.Context,
+	ep *aimiddleware.Aimiddleware,
+	aiMiddleware common.Address,
+	entity common.Address,
+) (*aimiddleware.IDepositManagerDepositInfo, error) {
+	if s.cache != nil {
+		return s.cache.DepositInfo(ctx, aiMiddleware, entity)
+	}
+
+	dep, err := ep.GetDepositInfo(&bind.CallOpts{Context: ctx}, entity)
+	if err != nil {
+		return nil, err
+	}
+	return &dep, nil
+}
+
 // PaymasterDeposit returns a BatchHandler that tracks each paymaster in the batch and ensures it has enough
 // deposit to pay for all the AiOps that use it.
 func (s *Standalone) PaymasterDeposit() modules.BatchHandlerFunc {
@@ -190,7 +262,7 @@ func (s *Standalone) PaymasterDeposit() modules.BatchHandlerFunc {
 			}
 
 			if _, ok := deps[pm]; !ok {
-				dep, err := ep.GetDepositInfo(nil, pm)
+				dep, err := s.getDepositInfo(ctx.Context(), ep, ctx.AiMiddleware, pm)
 				if err != nil {
 					return err
 				}
@@ -208,6 +280,36 @@ func (s *Standalone) PaymasterDeposit() modules.BatchHandlerFunc {
 	}
 }
 
+// GroupByAggregator returns a BatchHandler that sets the signature aggregator and the signature returned by
+// the aggregator for each op in the batch. The batch is ordered so that ops sharing an aggregator are
+// contiguous and stay in the same order as the groups in handleAggregatedOps. FailedOp indexes are mapped back
+// to the batch by the transaction package, so this ordering is not required for correctness.
+func (s *Standalone) GroupByAggregator() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		order := map[common.Address]int{}
+		for _, op := range ctx.Batch {
+			hash := op.GetAiOpHash(ctx.AiMiddleware, ctx.ChainID)
+			agg, sig, err := getSavedAggregator(s.db, hash)
+			if err != nil {
+				return err
+			}
+
+			if agg != (common.Address{}) {
+				ctx.Aggregators[hash] = agg
+				ctx.AggregatorSigs[hash] = sig
+			}
+			if _, ok := order[agg]; !ok {
+				order[agg] = len(order)
+			}
+		}
+
+		sort.SliceStable(ctx.Batch, func(i, j int) bool {
+			return order[ctx.GetAggregator(ctx.Batch[i])] < order[ctx.GetAggregator(ctx.Batch[j])]
+		})
+		return nil
+	}
+}
+
 // TODO: Implement
 func (s *Standalone) SimulateBatch() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
@@ -215,11 +317,19 @@ func (s *Standalone) SimulateBatch() modules.BatchHandlerFunc {
 	}
 }
 
-// Clean returns a BatchHandler that clears the DB of data that is no longer required. This should be one of
-// the last modules executed by the Bundler.
+// Clean returns a BatchHandler that clears the DB of data that is no longer required once AiOps have left
+// the mempool. This should be used in the settled stage. If used in the batch stage, AiOps in a batch that is
+// pending inclusion are skipped since they may still be returned to the mempool.
 func (s *Standalone) Clean() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
-		all := append([]*aiop.AiOperation{}, ctx.Batch...)
+		if ctx.DryRun {
+			return nil
+		}
+
+		all := []*aiop.AiOperation{}
+		if !ctx.PendingInclusion {
+			all = append(all, ctx.Batch...)
+		}
 		for _, item := range ctx.PendingRemoval {
 			all = append(all, item.Op)
 		}
@@ -228,6 +338,9 @@ func (s *Standalone) Clean() modules.BatchHandlerFunc {
 			hashes = append(hashes, op.GetAiOpHash(ctx.AiMiddleware, ctx.ChainID))
 		}
 
-		return removeSavedCodeHashes(s.db, hashes...)
+		if err := removeSavedCodeHashes(s.db, hashes...); err != nil {
+			return err
+		}
+		return removeSavedAggregators(s.db, hashes...)
 	}
 }
@@ -3,6 +3,8 @@ package checks
 import (
 	"context"
 
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/blockcache"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/reader"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
@@ -10,9 +12,16 @@ import (
 // GetCodeFunc provides a general interface for retrieving the bytecode for a given address.
 type GetCodeFunc = func(addr common.Address) ([]byte, error)
 
-// getCodeWithEthClient returns a GetCodeFunc that uses an eth client to call eth_getCode.
-func getCodeWithEthClient(eth *ethclient.Client) GetCodeFunc {
+// getCodeWithEthClient returns a GetCodeFunc that uses an eth client to call eth_getCode. If the context
+// carries a Reader, the code is read from its cache instead. Otherwise the block cache is used if set.
+func getCodeWithEthClient(ctx context.Context, eth *ethclient.Client, cache *blockcache.Cache) GetCodeFunc {
 	return func(addr common.Address) ([]byte, error) {
-		return eth.CodeAt(context.Background(), addr, nil)
+		if r, ok := reader.FromContext(ctx); ok {
+			return r.Code(ctx, addr)
+		}
+		if cache != nil {
+			return cache.Code(ctx, addr)
+		}
+		return eth.CodeAt(ctx, addr, nil)
 	}
 }
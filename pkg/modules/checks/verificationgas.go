@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 
@@ -11,7 +12,12 @@ import (
 // ValidateVerificationGas checks that the verificationGasLimit is sufficiently low (<= MAX_VERIFICATION_GAS)
 // and the preVerificationGas is sufficiently high (enough to pay for the calldata gas cost of serializing
 // the AiOperation plus PRE_VERIFICATION_OVERHEAD_GAS).
-func ValidateVerificationGas(op *aiop.AiOperation, ov *gas.Overhead, maxVerificationGas *big.Int) error {
+func ValidateVerificationGas(
+	ctx context.Context,
+	op *aiop.AiOperation,
+	ov *gas.Overhead,
+	maxVerificationGas *big.Int,
+) error {
 	if op.VerificationGasLimit.Cmp(maxVerificationGas) > 0 {
 		return fmt.Errorf(
 			"verificationGasLimit: exceeds maxVerificationGas of %s",
@@ -19,7 +25,7 @@ func ValidateVerificationGas(op *aiop.AiOperation, ov *gas.Overhead, maxVerifica
 		)
 	}
 
-	pvg, err := ov.CalcPreVerificationGas(op)
+	pvg, err := ov.CalcPreVerificationGas(ctx, op)
 	if err != nil {
 		return err
 	}
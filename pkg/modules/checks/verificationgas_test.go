@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"math/big"
 	"testing"
 
@@ -16,7 +17,7 @@ func TestOpVGlessThanMaxVG(t *testing.T) {
 	ov := gas.NewDefaultOverhead()
 	mvg := big.NewInt(0).Add(op.VerificationGasLimit, common.Big1)
 
-	if err := ValidateVerificationGas(op, ov, mvg); err != nil {
+	if err := ValidateVerificationGas(context.Background(), op, ov, mvg); err != nil {
 		t.Fatalf("got %v, want nil", err)
 	}
 }
@@ -28,7 +29,7 @@ func TestOpVGEqualMaxVG(t *testing.T) {
 	ov := gas.NewDefaultOverhead()
 	mvg := big.NewInt(0).Add(op.VerificationGasLimit, common.Big0)
 
-	if err := ValidateVerificationGas(op, ov, mvg); err != nil {
+	if err := ValidateVerificationGas(context.Background(), op, ov, mvg); err != nil {
 		t.Fatalf("got %v, want nil", err)
 	}
 }
@@ -40,7 +41,7 @@ func TestOpVGMoreThanMaxVG(t *testing.T) {
 	ov := gas.NewDefaultOverhead()
 	mvg := big.NewInt(0).Sub(op.VerificationGasLimit, common.Big1)
 
-	if err := ValidateVerificationGas(op, ov, mvg); err == nil {
+	if err := ValidateVerificationGas(context.Background(), op, ov, mvg); err == nil {
 		t.Fatal("got nil, want err")
 	}
 }
@@ -50,10 +51,10 @@ func TestOpVGMoreThanMaxVG(t *testing.T) {
 func TestOpPVGMoreThanOH(t *testing.T) {
 	op := testutils.MockValidInitAiOp()
 	ov := gas.NewDefaultOverhead()
-	pvg, _ := ov.CalcPreVerificationGas(op)
+	pvg, _ := ov.CalcPreVerificationGas(context.Background(), op)
 	op.PreVerificationGas = big.NewInt(0).Add(pvg, common.Big1)
 
-	if err := ValidateVerificationGas(op, ov, op.VerificationGasLimit); err != nil {
+	if err := ValidateVerificationGas(context.Background(), op, ov, op.VerificationGasLimit); err != nil {
 		t.Fatalf("got %v, want nil", err)
 	}
 }
@@ -63,10 +64,10 @@ func TestOpPVGMoreThanOH(t *testing.T) {
 func TestOpPVGEqualOH(t *testing.T) {
 	op := testutils.MockValidInitAiOp()
 	ov := gas.NewDefaultOverhead()
-	pvg, _ := ov.CalcPreVerificationGas(op)
+	pvg, _ := ov.CalcPreVerificationGas(context.Background(), op)
 	op.PreVerificationGas = big.NewInt(0).Add(pvg, common.Big0)
 
-	if err := ValidateVerificationGas(op, ov, op.VerificationGasLimit); err != nil {
+	if err := ValidateVerificationGas(context.Background(), op, ov, op.VerificationGasLimit); err != nil {
 		t.Fatalf("got %v, want nil", err)
 	}
 }
@@ -76,10 +77,10 @@ func TestOpPVGEqualOH(t *testing.T) {
 func TestOpPVGLessThanOH(t *testing.T) {
 	op := testutils.MockValidInitAiOp()
 	ov := gas.NewDefaultOverhead()
-	pvg, _ := ov.CalcPreVerificationGas(op)
+	pvg, _ := ov.CalcPreVerificationGas(context.Background(), op)
 	op.PreVerificationGas = big.NewInt(0).Sub(pvg, common.Big1)
 
-	if err := ValidateVerificationGas(op, ov, op.VerificationGasLimit); err == nil {
+	if err := ValidateVerificationGas(context.Background(), op, ov, op.VerificationGasLimit); err == nil {
 		t.Fatal("got nil, want err")
 	}
 }
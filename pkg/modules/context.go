@@ -1,36 +1,71 @@
 package modules
 
 import (
+	"context"
 	"math/big"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/reverts"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/stake"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// GasEstimateKey is the Data key for the handleOps gas estimate of the batch.
+const GasEstimateKey = "gas_estimate"
+
 type PendingRemovalItem struct {
 	Op     *aiop.AiOperation
 	Reason string
 }
 
+// DeferredItem is an op that was excluded from the current batch but is kept in the mempool for the next
+// run.
+type DeferredItem struct {
+	Op     *aiop.AiOperation
+	Reason string
+}
+
 // BatchHandlerCtx is the object passed to BatchHandler functions during the Bundler's Run process. It
 // also contains a Data field for adding arbitrary key-value pairs to the context. These values will be
 // logged by the Bundler at the end of each run.
+//
+// PendingInclusion should be set by modules that send the batch and settle the transaction asynchronously.
+// If set, the Bundler will keep the batch in the mempool until the transaction is settled.
+//
+// Modules that exclude ops from the batch should use DeferOp, DeferOps or MarkOpIndexForRemoval instead of
+// rebuilding Batch directly so that the reason is recorded.
+//
+// DryRun is set when the batch is only being previewed. Modules must not send transactions or write state
+// in a dry run. Instead, modules that send the batch should set the handleOps gas estimate in Data under
+// GasEstimateKey.
+//
+// Preview is set when the batch is built for a caller to inspect, such as debug_bundler_previewBundle. A
+// preview is always a dry run and modules must not keep any record of it either.
 type BatchHandlerCtx struct {
-	Batch          []*aiop.AiOperation
-	PendingRemoval []*PendingRemovalItem
-	AiMiddleware   common.Address
-	ChainID        *big.Int
-	BaseFee        *big.Int
-	Tip            *big.Int
-	GasPrice       *big.Int
-	Data           map[string]any
+	Batch            []*aiop.AiOperation
+	PendingRemoval   []*PendingRemovalItem
+	Deferred         []*DeferredItem
+	PendingInclusion bool
+	DryRun           bool
+	Preview          bool
+	AiMiddleware     common.Address
+	ChainID          *big.Int
+	BaseFee          *big.Int
+	Tip              *big.Int
+	GasPrice         *big.Int
+	Aggregators      map[common.Hash]common.Address
+	AggregatorSigs   map[common.Hash][]byte
+	Data             map[string]any
+
+	ctx context.Context
 }
 
-// NewBatchHandlerContext creates a new BatchHandlerCtx using a copy of the given batch.
+// NewBatchHandlerContext creates a new BatchHandlerCtx using a copy of the given batch. The given context
+// is carried through to every module and should be used for any calls to the node.
 func NewBatchHandlerContext(
+	ctx context.Context,
 	batch []*aiop.AiOperation,
 	aiMiddleware common.Address,
 	chainID *big.Int,
@@ -42,20 +77,95 @@ func NewBatchHandlerContext(
 	copy = append(copy, batch...)
 
 	return &BatchHandlerCtx{
-		Batch:          copy,
-		PendingRemoval: []*PendingRemovalItem{},
-		AiMiddleware:   aiMiddleware,
-		ChainID:        chainID,
-		BaseFee:        baseFee,
-		Tip:            tip,
-		GasPrice:       gasPrice,
-		Data:           make(map[string]any),
+		Batch:            copy,
+		PendingRemoval:   []*PendingRemovalItem{},
+		Deferred:         []*DeferredItem{},
+		PendingInclusion: false,
+		AiMiddleware:     aiMiddleware,
+		ChainID:          chainID,
+		BaseFee:          baseFee,
+		Tip:              tip,
+		GasPrice:         gasPrice,
+		Aggregators:      make(map[common.Hash]common.Address),
+		AggregatorSigs:   make(map[common.Hash][]byte),
+		Data:             make(map[string]any),
+		ctx:              ctx,
+	}
+}
+
+// Context returns the context of the current bundler run. It is canceled when the bundler is stopped.
+func (c *BatchHandlerCtx) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
 	}
+	return c.ctx
 }
 
 // MarkOpIndexForRemoval will remove the op by index from the batch and add it to the pending removal array.
 // This should be used for ops that are not to be included on-chain and dropped from the mempool.
 func (c *BatchHandlerCtx) MarkOpIndexForRemoval(index int, reason string) {
+	op := c.removeOpIndex(index)
+	if op == nil {
+		return
+	}
+
+	c.PendingRemoval = append(c.PendingRemoval, &PendingRemovalItem{
+		Op:     op,
+		Reason: reason,
+	})
+}
+
+// DeferOpIndex will remove the op by index from the batch and add it to the deferred array. This should be
+// used for ops that are still valid but cannot be included in the current batch. Deferred ops stay in the
+// mempool and are considered again in the next run.
+func (c *BatchHandlerCtx) DeferOpIndex(index int, reason string) {
+	op := c.removeOpIndex(index)
+	if op == nil {
+		return
+	}
+
+	c.Deferred = append(c.Deferred, &DeferredItem{
+		Op:     op,
+		Reason: reason,
+	})
+}
+
+// DeferOp is the same as DeferOpIndex but finds the op in the batch by reference. It is a no-op if the op
+// is not in the batch.
+func (c *BatchHandlerCtx) DeferOp(op *aiop.AiOperation, reason string) {
+	for i, curr := range c.Batch {
+		if curr == op {
+			c.DeferOpIndex(i, reason)
+			return
+		}
+	}
+}
+
+// DeferOps is the same as DeferOpIndex but defers many ops in a single pass. The given map is keyed by the
+// index of each op to defer and holds the reason to record for it. Indexes that are not in the batch are
+// ignored.
+func (c *BatchHandlerCtx) DeferOps(reasons map[int]string) {
+	if len(reasons) == 0 {
+		return
+	}
+
+	batch := []*aiop.AiOperation{}
+	for i, op := range c.Batch {
+		reason, ok := reasons[i]
+		if !ok {
+			batch = append(batch, op)
+			continue
+		}
+
+		c.Deferred = append(c.Deferred, &DeferredItem{
+			Op:     op,
+			Reason: reason,
+		})
+	}
+	c.Batch = batch
+}
+
+func (c *BatchHandlerCtx) removeOpIndex(index int) *aiop.AiOperation {
 	batch := []*aiop.AiOperation{}
 	var op *aiop.AiOperation
 	for i, curr := range c.Batch {
@@ -66,14 +176,17 @@ func (c *BatchHandlerCtx) MarkOpIndexForRemoval(index int, reason string) {
 		}
 	}
 	if op == nil {
-		return
+		return nil
 	}
 
 	c.Batch = batch
-	c.PendingRemoval = append(c.PendingRemoval, &PendingRemovalItem{
-		Op:     op,
-		Reason: reason,
-	})
+	return op
+}
+
+// GetAggregator returns the signature aggregator for an op in the batch. The zero address is returned if the
+// op is not using an aggregator.
+func (c *BatchHandlerCtx) GetAggregator(op *aiop.AiOperation) common.Address {
+	return c.Aggregators[op.GetAiOpHash(c.AiMiddleware, c.ChainID)]
 }
 
 // AiOpHandlerCtx is the object passed to AiOpHandler functions during the Client's SendAiOperation
@@ -82,16 +195,20 @@ type AiOpHandlerCtx struct {
 	AiOp                *aiop.AiOperation
 	AiMiddleware        common.Address
 	ChainID             *big.Int
+	AggregatorInfo      *reverts.AggregatorStakeInfo
 	pendingSenderOps    []*aiop.AiOperation
 	pendingFactoryOps   []*aiop.AiOperation
 	pendingPaymasterOps []*aiop.AiOperation
 	senderDeposit       *aimiddleware.IDepositManagerDepositInfo
 	factoryDeposit      *aimiddleware.IDepositManagerDepositInfo
 	paymasterDeposit    *aimiddleware.IDepositManagerDepositInfo
+	ctx                 context.Context
 }
 
-// NewAiOpHandlerContext creates a new AiOpHandlerCtx using a given op.
+// NewAiOpHandlerContext creates a new AiOpHandlerCtx using a given op. The given context is usually derived
+// from the incoming request and should be used for any calls to the node.
 func NewAiOpHandlerContext(
+	ctx context.Context,
 	op *aiop.AiOperation,
 	aiMiddleware common.Address,
 	chainID *big.Int,
@@ -113,15 +230,15 @@ func NewAiOpHandlerContext(
 	}
 
 	// Fetch the current aimiddleware deposits by entity
-	sd, err := gs(aiMiddleware, op.Sender)
+	sd, err := gs(ctx, aiMiddleware, op.Sender)
 	if err != nil {
 		return nil, err
 	}
-	fd, err := gs(aiMiddleware, op.GetFactory())
+	fd, err := gs(ctx, aiMiddleware, op.GetFactory())
 	if err != nil {
 		return nil, err
 	}
-	pd, err := gs(aiMiddleware, op.GetPaymaster())
+	pd, err := gs(ctx, aiMiddleware, op.GetPaymaster())
 	if err != nil {
 		return nil, err
 	}
@@ -136,9 +253,19 @@ func NewAiOpHandlerContext(
 		senderDeposit:       sd,
 		factoryDeposit:      fd,
 		paymasterDeposit:    pd,
+		ctx:                 ctx,
 	}, nil
 }
 
+// Context returns the context of the request that submitted the AiOperation. It is canceled if the caller
+// disconnects or the request deadline is exceeded.
+func (c *AiOpHandlerCtx) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 // GetSenderDepositInfo returns the current AiMiddleware deposit for the sender.
 func (c *AiOpHandlerCtx) GetSenderDepositInfo() *aimiddleware.IDepositManagerDepositInfo {
 	return c.senderDeposit
@@ -168,3 +295,12 @@ func (c *AiOpHandlerCtx) GetPendingFactoryOps() []*aiop.AiOperation {
 func (c *AiOpHandlerCtx) GetPendingPaymasterOps() []*aiop.AiOperation {
 	return c.pendingPaymasterOps
 }
+
+// GetAggregator returns the address of the signature aggregator returned during simulation. The zero address
+// is returned if the op is not using an aggregator.
+func (c *AiOpHandlerCtx) GetAggregator() common.Address {
+	if c.AggregatorInfo == nil {
+		return common.Address{}
+	}
+	return c.AggregatorInfo.Aggregator
+}
@@ -1,6 +1,7 @@
 package modules
 
 import (
+	"context"
 	"math/big"
 	"testing"
 
@@ -21,6 +22,7 @@ func TestNoPendingOps(t *testing.T) {
 	op.PaymasterAndData = []byte{}
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
@@ -58,6 +60,7 @@ func TestGetPendingSenderOps(t *testing.T) {
 	_ = mem.AddOp(testutils.ValidAddress5, penOp3)
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
@@ -105,6 +108,7 @@ func TestGetPendingFactoryOps(t *testing.T) {
 	_ = mem.AddOp(testutils.ValidAddress5, penOp3)
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
@@ -152,6 +156,7 @@ func TestGetPendingPaymasterOps(t *testing.T) {
 	_ = mem.AddOp(testutils.ValidAddress5, penOp3)
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
@@ -184,11 +189,12 @@ func TestNilDepositInfo(t *testing.T) {
 	op.PaymasterAndData = []byte{}
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
 		mem,
-		func(aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
+		func(ctx context.Context, aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
 			if entity == op.Sender {
 				return testutils.NonStakedZeroDepositInfo, nil
 			}
@@ -213,11 +219,12 @@ func TestGetSenderDepositInfo(t *testing.T) {
 	op.PaymasterAndData = []byte{}
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
 		mem,
-		func(aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
+		func(ctx context.Context, aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
 			if entity == op.Sender {
 				return testutils.NonStakedZeroDepositInfo, nil
 			}
@@ -240,11 +247,12 @@ func TestGetFactoryDepositInfo(t *testing.T) {
 	op.PaymasterAndData = []byte{}
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
 		mem,
-		func(aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
+		func(ctx context.Context, aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
 			if entity == testutils.ValidAddress1 {
 				return testutils.NonStakedZeroDepositInfo, nil
 			}
@@ -267,11 +275,12 @@ func TestGetPaymasterDepositInfo(t *testing.T) {
 	op.PaymasterAndData = testutils.ValidAddress1.Bytes()
 
 	ctx, err := NewAiOpHandlerContext(
+		context.Background(),
 		op,
 		testutils.ValidAddress5,
 		testutils.ChainID,
 		mem,
-		func(aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
+		func(ctx context.Context, aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, error) {
 			if entity == testutils.ValidAddress1 {
 				return testutils.NonStakedZeroDepositInfo, nil
 			}
@@ -284,3 +293,64 @@ func TestGetPaymasterDepositInfo(t *testing.T) {
 		t.Fatalf("want %p, got %p", testutils.NonStakedZeroDepositInfo, dep)
 	}
 }
+
+func TestDeferOpKeepsOrder(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Sender = testutils.ValidAddress2
+	op3 := testutils.MockValidInitAiOp()
+	op3.Sender = testutils.ValidAddress3
+	ctx := NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{op1, op2, op3},
+		testutils.ValidAddress5,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+
+	ctx.DeferOp(op2, "test")
+	ctx.DeferOp(op2, "test")
+	if len(ctx.Batch) != 2 {
+		t.Fatalf("batch: want 2, got %d", len(ctx.Batch))
+	} else if ctx.Batch[0] != op1 || ctx.Batch[1] != op3 {
+		t.Fatal("batch: incorrect order")
+	} else if len(ctx.Deferred) != 1 {
+		t.Fatalf("deferred: want 1, got %d", len(ctx.Deferred))
+	} else if ctx.Deferred[0].Op != op2 || ctx.Deferred[0].Reason != "test" {
+		t.Fatal("deferred: incorrect item")
+	} else if len(ctx.PendingRemoval) != 0 {
+		t.Fatalf("pending removal: want 0, got %d", len(ctx.PendingRemoval))
+	}
+}
+
+func TestDeferOpsKeepsOrder(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Sender = testutils.ValidAddress2
+	op3 := testutils.MockValidInitAiOp()
+	op3.Sender = testutils.ValidAddress3
+	ctx := NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{op1, op2, op3},
+		testutils.ValidAddress5,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+
+	ctx.DeferOps(map[int]string{0: "first", 2: "third", 3: "missing"})
+	if len(ctx.Batch) != 1 {
+		t.Fatalf("batch: want 1, got %d", len(ctx.Batch))
+	} else if ctx.Batch[0] != op2 {
+		t.Fatal("batch: incorrect op")
+	} else if len(ctx.Deferred) != 2 {
+		t.Fatalf("deferred: want 2, got %d", len(ctx.Deferred))
+	} else if ctx.Deferred[0].Op != op1 || ctx.Deferred[0].Reason != "first" {
+		t.Fatal("deferred: incorrect first item")
+	} else if ctx.Deferred[1].Op != op3 || ctx.Deferred[1].Reason != "third" {
+		t.Fatal("deferred: incorrect second item")
+	}
+}
@@ -3,14 +3,18 @@
 package entities
 
 import (
+	"context"
 	stdErr "errors"
 	"fmt"
+	"math/big"
 
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Reputation provides Client and Bundler modules to track the reputation of every entity seen in a
@@ -26,6 +30,27 @@ func New(db *badger.DB, eth *ethclient.Client, repConst *ReputationConstants) *R
 	return &Reputation{db, eth, repConst}
 }
 
+// AiMeter defines an opentelemetry meter object used by the Reputation instance to capture the number of
+// entities in each status.
+func (r *Reputation) AiMeter(meter metric.Meter) error {
+	_, err := meter.Int64ObservableGauge(
+		"reputation_entities",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			return r.db.View(func(txn *badger.Txn) error {
+				counts, err := countByStatus(txn, r.repConst)
+				if err != nil {
+					return err
+				}
+				for s, n := range counts {
+					io.Observe(int64(n), metric.WithAttributes(attribute.String("status", s.String())))
+				}
+				return nil
+			})
+		}),
+	)
+	return err
+}
+
 // CheckStatus returns a AiOpHandler that is used by the Client to determine if the aiOp is allowed based
 // on the entities status.
 //  1. ok: entity is allowed
@@ -150,6 +175,49 @@ func (r *Reputation) ValidateOpLimit() modules.AiOpHandlerFunc {
 	}
 }
 
+// CheckAggregator returns a AiOpHandler that is used by the Client to determine if the aiOp's signature
+// aggregator is allowed based on its status and stake. This module must run after simulation since the
+// aggregator is only known once the AiMiddleware returns its stake info.
+func (r *Reputation) CheckAggregator() modules.AiOpHandlerFunc {
+	return func(ctx *modules.AiOpHandlerCtx) error {
+		if ctx.AggregatorInfo == nil {
+			return nil
+		}
+
+		agg := ctx.AggregatorInfo.Aggregator
+		si := ctx.AggregatorInfo.StakeInfo
+		if si == nil ||
+			si.Stake.Cmp(r.repConst.MinStakeValue) < 0 ||
+			si.UnstakeDelaySec.Cmp(big.NewInt(int64(r.repConst.MinUnstakeDelay))) < 0 {
+			return errors.NewRPCError(
+				errors.INVALID_AGGREGATOR,
+				fmt.Sprintf("unstaked aggregator: %s", agg.Hex()),
+				nil,
+			)
+		}
+
+		return r.db.Update(func(txn *badger.Txn) error {
+			if status, err := getStatus(txn, agg, r.repConst); err != nil {
+				return err
+			} else if status == banned {
+				return errors.NewRPCError(
+					errors.INVALID_AGGREGATOR,
+					fmt.Sprintf("banned aggregator: %s", agg.Hex()),
+					nil,
+				)
+			} else if status == throttled {
+				return errors.NewRPCError(
+					errors.INVALID_AGGREGATOR,
+					fmt.Sprintf("throttled aggregator: %s", agg.Hex()),
+					nil,
+				)
+			}
+
+			return nil
+		})
+	}
+}
+
 // IncOpsSeen returns a AiOpHandler that is used by the Client to increment the opsSeen counter for all
 // included entities.
 func (r *Reputation) IncOpsSeen() modules.AiOpHandlerFunc {
@@ -168,15 +236,25 @@ func (r *Reputation) IncOpsSeen() modules.AiOpHandlerFunc {
 				err = stdErr.Join(err, incrementOpsSeenByEntity(txn, paymaster))
 			}
 
+			aggregator := ctx.GetAggregator()
+			if aggregator != common.HexToAddress("0x") {
+				err = stdErr.Join(err, incrementOpsSeenByEntity(txn, aggregator))
+			}
+
 			return err
 		})
 	}
 }
 
 // IncOpsIncluded returns a BatchHandler used by the Bundler to increment opsIncluded counters for all
-// relevant entities in the batch. This module should be used last once batches have been sent.
+// relevant entities in the batch. This module should be used in the settled stage so that only AiOperations
+// included on-chain are counted. If used in the batch stage, a batch that is pending inclusion is skipped.
 func (r *Reputation) IncOpsIncluded() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
+		if ctx.DryRun || ctx.PendingInclusion {
+			return nil
+		}
+
 		return r.db.Update(func(txn *badger.Txn) error {
 			c := make(addressCounter)
 			for _, op := range ctx.Batch {
@@ -202,6 +280,15 @@ func (r *Reputation) IncOpsIncluded() modules.BatchHandlerFunc {
 
 					c[paymaster]++
 				}
+
+				aggregator := ctx.GetAggregator(op)
+				if aggregator != common.HexToAddress("0x") {
+					if _, ok := c[aggregator]; !ok {
+						c[aggregator] = 0
+					}
+
+					c[aggregator]++
+				}
 			}
 
 			return incrementOpsIncludedByEntity(txn, c)
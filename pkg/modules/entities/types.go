@@ -1,6 +1,8 @@
 package entities
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -14,7 +16,7 @@ type ReputationOverride struct {
 // coming into the mempool.
 type ReputationConstants struct {
 	MinUnstakeDelay                int
-	MinStakeValue                  int64
+	MinStakeValue                  *big.Int
 	SameSenderMempoolCount         int
 	SameUnstakedEntityMempoolCount int
 	ThrottledEntityMempoolCount    int
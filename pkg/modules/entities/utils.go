@@ -20,6 +20,17 @@ const (
 	banned
 )
 
+func (s status) String() string {
+	switch s {
+	case throttled:
+		return "throttled"
+	case banned:
+		return "banned"
+	default:
+		return "ok"
+	}
+}
+
 var (
 	emaHours       = 24
 	opsCountPrefix = dbutils.JoinValues("entity", "opsCount")
@@ -35,7 +46,8 @@ func getOpsCountValue(opsSeen int, opsIncluded int) []byte {
 	)
 }
 
-func applyExpWeights(txn *badger.Txn, key []byte, value []byte) (opsSeen int, opsIncluded int, err error) {
+// parseOpsCountValue returns the counts stored in value with hourly decay applied since it was last updated.
+func parseOpsCountValue(value []byte) (opsSeen int, opsIncluded int, err error) {
 	counts := dbutils.SplitValues(string(value))
 	opsSeen, err = strconv.Atoi(counts[0])
 	if err != nil {
@@ -59,6 +71,14 @@ func applyExpWeights(txn *badger.Txn, key []byte, value []byte) (opsSeen int, op
 		opsSeen -= opsSeen / emaHours
 		opsIncluded -= opsIncluded / emaHours
 	}
+	return opsSeen, opsIncluded, nil
+}
+
+func applyExpWeights(txn *badger.Txn, key []byte, value []byte) (opsSeen int, opsIncluded int, err error) {
+	opsSeen, opsIncluded, err = parseOpsCountValue(value)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	e := badger.NewEntry(key, getOpsCountValue(opsSeen, opsIncluded))
 	err = txn.SetEntry(e)
@@ -124,18 +144,45 @@ func getStatus(txn *badger.Txn, entity common.Address, repConst *ReputationConst
 	if err != nil {
 		return ok, err
 	}
+	return statusFromCounts(opsSeen, opsIncluded, repConst), nil
+}
+
+func statusFromCounts(opsSeen int, opsIncluded int, repConst *ReputationConstants) status {
 	if opsSeen == 0 {
-		return ok, nil
+		return ok
 	}
 
 	minExpectedIncluded := opsSeen / repConst.MinInclusionRateDenominator
 	if minExpectedIncluded <= opsIncluded+repConst.ThrottlingSlack {
-		return ok, nil
+		return ok
 	} else if minExpectedIncluded <= opsIncluded+repConst.BanSlack {
-		return throttled, nil
+		return throttled
 	} else {
-		return banned, nil
+		return banned
+	}
+}
+
+// countByStatus returns the number of tracked entities in each status without updating their stored counts.
+func countByStatus(txn *badger.Txn, repConst *ReputationConstants) (map[status]int, error) {
+	counts := map[status]int{ok: 0, throttled: 0, banned: 0}
+	opts := badger.DefaultIteratorOptions
+	prefix := []byte(opsCountPrefix)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		var opsSeen, opsIncluded int
+		err := it.Item().Value(func(val []byte) error {
+			var err error
+			opsSeen, opsIncluded, err = parseOpsCountValue(val)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		counts[statusFromCounts(opsSeen, opsIncluded, repConst)]++
 	}
+	return counts, nil
 }
 
 func overrideEntity(txn *badger.Txn, entry *ReputationOverride) error {
@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestCountByStatus verifies that every tracked entity is counted once under its current status.
+func TestCountByStatus(t *testing.T) {
+	db := testutils.DBMock()
+	defer db.Close()
+	repConst := &ReputationConstants{MinInclusionRateDenominator: 10, ThrottlingSlack: 10, BanSlack: 50}
+
+	entries := []*ReputationOverride{
+		{Address: common.HexToAddress("0x01"), OpsSeen: 10, OpsIncluded: 1},
+		{Address: common.HexToAddress("0x02"), OpsSeen: 200, OpsIncluded: 0},
+		{Address: common.HexToAddress("0x03"), OpsSeen: 1000, OpsIncluded: 0},
+	}
+	if err := db.Update(func(txn *badger.Txn) error {
+		for _, e := range entries {
+			if err := overrideEntity(txn, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	var counts map[status]int
+	if err := db.View(func(txn *badger.Txn) error {
+		var err error
+		counts, err = countByStatus(txn, repConst)
+		return err
+	}); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	for s, want := range map[status]int{ok: 1, throttled: 1, banned: 1} {
+		if counts[s] != want {
+			t.Fatalf("%s: got %d, want %d", s, counts[s], want)
+		}
+	}
+}
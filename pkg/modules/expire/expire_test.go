@@ -1,6 +1,7 @@
 package expire
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -23,6 +24,7 @@ func TestDropExpired(t *testing.T) {
 	}
 
 	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
 		[]*aiop.AiOperation{op1, op2},
 		testutils.ValidAddress1,
 		testutils.ChainID,
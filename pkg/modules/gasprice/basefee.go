@@ -4,24 +4,30 @@ import (
 	"context"
 	"math/big"
 
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/reader"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // GetBaseFeeFunc provides a general interface for retrieving the closest estimate for basefee to allow for
 // timely execution of a transaction.
-type GetBaseFeeFunc = func() (*big.Int, error)
+type GetBaseFeeFunc = func(ctx context.Context) (*big.Int, error)
 
 // NoopGetBaseFeeFunc returns nil basefee and nil error.
 func NoopGetBaseFeeFunc() GetBaseFeeFunc {
-	return func() (*big.Int, error) {
+	return func(ctx context.Context) (*big.Int, error) {
 		return nil, nil
 	}
 }
 
-// GetBaseFeeWithEthClient returns a GetBaseFeeFunc using an eth client.
+// GetBaseFeeWithEthClient returns a GetBaseFeeFunc using an eth client. If the context carries a Reader, the
+// basefee is read from its cache instead.
 func GetBaseFeeWithEthClient(eth *ethclient.Client) GetBaseFeeFunc {
-	return func() (*big.Int, error) {
-		head, err := eth.HeaderByNumber(context.Background(), nil)
+	return func(ctx context.Context) (*big.Int, error) {
+		if r, ok := reader.FromContext(ctx); ok {
+			return r.BaseFee(ctx)
+		}
+
+		head, err := eth.HeaderByNumber(ctx, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -3,30 +3,34 @@ package gasprice
 import (
 	"math/big"
 
-	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 	"github.com/ethereum/go-ethereum/common"
 )
 
-// FilterUnderpriced returns a BatchHandlerFunc that will filter out all the aiOps that are below either the
-// dynamic or legacy GasPrice set in the context.
+// DeferReasonUnderpriced is recorded for aiOps that are deferred by FilterUnderpriced.
+const DeferReasonUnderpriced = "underpriced"
+
+// FilterUnderpriced returns a BatchHandlerFunc that will defer all the aiOps that are below either the
+// dynamic or legacy GasPrice set in the context. Deferred aiOps stay in the mempool in case gas prices drop.
 func FilterUnderpriced() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
-		b := []*aiop.AiOperation{}
-		for _, op := range ctx.Batch {
+		underpriced := map[int]string{}
+		for i, op := range ctx.Batch {
 			if ctx.BaseFee != nil && ctx.BaseFee.Cmp(common.Big0) != 0 && ctx.Tip != nil {
 				gp := big.NewInt(0).Add(ctx.BaseFee, ctx.Tip)
-				if op.GetDynamicGasPrice(ctx.BaseFee).Cmp(gp) >= 0 {
-					b = append(b, op)
+				if op.GetDynamicGasPrice(ctx.BaseFee).Cmp(gp) < 0 {
+					underpriced[i] = DeferReasonUnderpriced
 				}
 			} else if ctx.GasPrice != nil {
-				if op.MaxFeePerGas.Cmp(ctx.GasPrice) >= 0 {
-					b = append(b, op)
+				if op.MaxFeePerGas.Cmp(ctx.GasPrice) < 0 {
+					underpriced[i] = DeferReasonUnderpriced
 				}
+			} else {
+				underpriced[i] = DeferReasonUnderpriced
 			}
 		}
 
-		ctx.Batch = b
+		ctx.DeferOps(underpriced)
 		return nil
 	}
 }
@@ -1,6 +1,7 @@
 package gasprice_test
 
 import (
+	"context"
 	"math/big"
 	"testing"
 
@@ -31,6 +32,7 @@ func TestFilterUnderpricedDynamic(t *testing.T) {
 	op3.MaxPriorityFeePerGas = big.NewInt(1)
 
 	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
 		[]*aiop.AiOperation{op1, op2, op3},
 		testutils.ValidAddress1,
 		testutils.ChainID,
@@ -46,6 +48,12 @@ func TestFilterUnderpricedDynamic(t *testing.T) {
 		t.Fatal("incorrect order: first op out of place")
 	} else if !testutils.IsOpsEqual(ctx.Batch[1], op3) {
 		t.Fatal("incorrect order: second op out of place")
+	} else if len(ctx.Deferred) != 1 {
+		t.Fatalf("got deferred length %d, want 1", len(ctx.Deferred))
+	} else if !testutils.IsOpsEqual(ctx.Deferred[0].Op, op1) {
+		t.Fatal("incorrect deferred op")
+	} else if ctx.Deferred[0].Reason != gasprice.DeferReasonUnderpriced {
+		t.Fatalf("got reason %s, want %s", ctx.Deferred[0].Reason, gasprice.DeferReasonUnderpriced)
 	}
 }
 
@@ -67,6 +75,7 @@ func TestFilterUnderpricedGasPrice(t *testing.T) {
 	op3.MaxPriorityFeePerGas = big.NewInt(6)
 
 	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
 		[]*aiop.AiOperation{op1, op2, op3},
 		testutils.ValidAddress1,
 		testutils.ChainID,
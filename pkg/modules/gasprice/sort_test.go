@@ -1,6 +1,7 @@
 package gasprice_test
 
 import (
+	"context"
 	"math/big"
 	"testing"
 
@@ -31,6 +32,7 @@ func TestSortByGasPriceBaseDynamic(t *testing.T) {
 	op3.MaxPriorityFeePerGas = big.NewInt(1)
 
 	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
 		[]*aiop.AiOperation{op1, op2, op3},
 		testutils.ValidAddress1,
 		testutils.ChainID,
@@ -69,6 +71,7 @@ func TestSortByGasPriceLegacy(t *testing.T) {
 	op3.MaxPriorityFeePerGas = big.NewInt(6)
 
 	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
 		[]*aiop.AiOperation{op1, op2, op3},
 		testutils.ValidAddress1,
 		testutils.ChainID,
@@ -9,22 +9,34 @@ import (
 
 // GetGasTipFunc provides a general interface for retrieving the closest estimate for gas tip to allow for
 // timely execution of a transaction.
-type GetGasTipFunc = func() (*big.Int, error)
+type GetGasTipFunc = func(ctx context.Context) (*big.Int, error)
 
 // NoopGetGasTipFunc returns nil gas tip and nil error.
 func NoopGetGasTipFunc() GetGasTipFunc {
-	return func() (*big.Int, error) {
+	return func(ctx context.Context) (*big.Int, error) {
 		return nil, nil
 	}
 }
 
 // GetGasTipWithEthClient returns a GetGasTipFunc using an eth client.
 func GetGasTipWithEthClient(eth *ethclient.Client) GetGasTipFunc {
-	return func() (*big.Int, error) {
-		gt, err := eth.SuggestGasTipCap(context.Background())
+	return func(ctx context.Context) (*big.Int, error) {
+		gt, err := eth.SuggestGasTipCap(ctx)
 		if err != nil {
 			return nil, err
 		}
 		return gt, nil
 	}
 }
+
+// GetGasTipWithMin returns a GetGasTipFunc that never suggests a tip below min. Some chains do not include
+// transactions with a tip under a fixed floor regardless of the node's suggestion.
+func GetGasTipWithMin(ggt GetGasTipFunc, min *big.Int) GetGasTipFunc {
+	return func(ctx context.Context) (*big.Int, error) {
+		gt, err := ggt(ctx)
+		if err != nil || gt == nil || min == nil || gt.Cmp(min) >= 0 {
+			return gt, err
+		}
+		return new(big.Int).Set(min), nil
+	}
+}
@@ -0,0 +1,34 @@
+package gasprice_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules/gasprice"
+)
+
+// TestGetGasTipWithMin verifies that the suggested tip is raised to the min but never lowered.
+func TestGetGasTipWithMin(t *testing.T) {
+	tip := func(v int64) gasprice.GetGasTipFunc {
+		return func(ctx context.Context) (*big.Int, error) {
+			return big.NewInt(v), nil
+		}
+	}
+	min := big.NewInt(10)
+
+	for in, want := range map[int64]int64{1: 10, 10: 10, 25: 25} {
+		got, err := gasprice.GetGasTipWithMin(tip(in), min)(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Int64() != want {
+			t.Fatalf("suggested %d: got %s, want %d", in, got, want)
+		}
+	}
+
+	got, _ := gasprice.GetGasTipWithMin(gasprice.NoopGetGasTipFunc(), min)(context.Background())
+	if got != nil {
+		t.Fatalf("got %s, want nil tip kept", got)
+	}
+}
@@ -0,0 +1,141 @@
+package modules
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+
+var (
+	moduleNameAttrKey = attribute.Key("module")
+	chainIDAttrKey    = attribute.Key("chain_id")
+)
+
+// Metrics holds the instruments used by NamedBatchHandler and NamedAiOpHandler to capture the duration of each
+// module. Each pipeline should be given Metrics created from its own meter so that the measurements carry the
+// attributes of that meter. A nil *Metrics does not record durations.
+type Metrics struct {
+	batchDuration metric.Float64Histogram
+	aiOpDuration  metric.Float64Histogram
+}
+
+// NewMetrics returns Metrics with instruments created from the given opentelemetry meter.
+func NewMetrics(meter metric.Meter) (*Metrics, error) {
+	bd, err := meter.Float64Histogram(
+		"modules_batch_handler_duration",
+		metric.WithDescription("The duration of each BatchHandler in the Bundler pipeline."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ad, err := meter.Float64Histogram(
+		"modules_aiop_handler_duration",
+		metric.WithDescription("The duration of each AiOpHandler in the Client pipeline."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Metrics{batchDuration: bd, aiOpDuration: ad}, nil
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// chainIDAttr separates the module spans of each chain when one process hosts more than one.
+func chainIDAttr(chain *big.Int) attribute.KeyValue {
+	if chain == nil {
+		return chainIDAttrKey.Int64(0)
+	}
+	return chainIDAttrKey.Int64(chain.Int64())
+}
+
+func recordDuration(h metric.Float64Histogram, name string, start time.Time, err error) {
+	h.Record(
+		context.Background(),
+		time.Since(start).Seconds(),
+		metric.WithAttributes(moduleNameAttrKey.String(name), attribute.Bool("error", err != nil)),
+	)
+}
+
+// NamedBatchHandler wraps a BatchHandler in a span and records its duration in m under the given module name.
+// The batch size before and after the module is added to the span so that traces show where ops were
+// filtered. Calls to the node that use ctx.Context() are children of the module's span.
+func NamedBatchHandler(name string, fn BatchHandlerFunc, m *Metrics) BatchHandlerFunc {
+	return func(ctx *BatchHandlerCtx) error {
+		parent := ctx.ctx
+		spanCtx, span := otel.Tracer(instrumentationName).Start(
+			ctx.Context(),
+			"batch."+name,
+			trace.WithAttributes(
+				moduleNameAttrKey.String(name),
+				chainIDAttr(ctx.ChainID),
+				attribute.String("aimiddleware", ctx.AiMiddleware.String()),
+				attribute.Int("batch.size_before", len(ctx.Batch)),
+				attribute.Int("batch.removed_before", len(ctx.PendingRemoval)),
+				attribute.Int("batch.deferred_before", len(ctx.Deferred)),
+			),
+		)
+		ctx.ctx = spanCtx
+		start := time.Now()
+
+		err := fn(ctx)
+
+		ctx.ctx = parent
+		span.SetAttributes(
+			attribute.Int("batch.size_after", len(ctx.Batch)),
+			attribute.Int("batch.removed_after", len(ctx.PendingRemoval)),
+			attribute.Int("batch.deferred_after", len(ctx.Deferred)),
+		)
+		if m != nil {
+			recordDuration(m.batchDuration, name, start, err)
+		}
+		endSpan(span, err)
+		return err
+	}
+}
+
+// NamedAiOpHandler wraps an AiOpHandler in a span and records its duration in m under the given module name.
+// Calls to the node that use ctx.Context() are children of the module's span.
+func NamedAiOpHandler(name string, fn AiOpHandlerFunc, m *Metrics) AiOpHandlerFunc {
+	return func(ctx *AiOpHandlerCtx) error {
+		parent := ctx.ctx
+		spanCtx, span := otel.Tracer(instrumentationName).Start(
+			ctx.Context(),
+			"aiop."+name,
+			trace.WithAttributes(
+				moduleNameAttrKey.String(name),
+				chainIDAttr(ctx.ChainID),
+				attribute.String("aimiddleware", ctx.AiMiddleware.String()),
+				attribute.String("sender", ctx.AiOp.Sender.String()),
+			),
+		)
+		ctx.ctx = spanCtx
+		start := time.Now()
+
+		err := fn(ctx)
+
+		ctx.ctx = parent
+		if m != nil {
+			recordDuration(m.aiOpDuration, name, start, err)
+		}
+		endSpan(span, err)
+		return err
+	}
+}
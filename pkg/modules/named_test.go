@@ -0,0 +1,151 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func withSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+func spanAttr(attrs []attribute.KeyValue, key string) attribute.Value {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// TestNamedBatchHandlerRecordsBatchSize verifies that the span for a batch module records the batch size
+// before and after the module ran.
+func TestNamedBatchHandlerRecordsBatchSize(t *testing.T) {
+	sr := withSpanRecorder(t)
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Nonce = testutils.OneETH
+	ctx := NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{op1, op2},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+
+	var inner trace.SpanContext
+	fn := NamedBatchHandler("test.drop_first", func(ctx *BatchHandlerCtx) error {
+		inner = trace.SpanContextFromContext(ctx.Context())
+		ctx.MarkOpIndexForRemoval(0, "test")
+		return nil
+	}, nil)
+	if err := fn(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "batch.test.drop_first" {
+		t.Fatalf("got %s, want batch.test.drop_first", s.Name())
+	}
+	if v := spanAttr(s.Attributes(), "batch.size_before").AsInt64(); v != 2 {
+		t.Fatalf("size before: got %d, want 2", v)
+	}
+	if v := spanAttr(s.Attributes(), "batch.size_after").AsInt64(); v != 1 {
+		t.Fatalf("size after: got %d, want 1", v)
+	}
+	if inner.SpanID() != s.SpanContext().SpanID() {
+		t.Fatal("module context is not a child of the module span")
+	}
+	if trace.SpanContextFromContext(ctx.Context()).IsValid() {
+		t.Fatal("module span was not removed from the context after the module ran")
+	}
+}
+
+// TestNamedAiOpHandlerRecordsError verifies that an error from a client module is set on its span and
+// returned to the caller.
+func TestNamedAiOpHandlerRecordsError(t *testing.T) {
+	sr := withSpanRecorder(t)
+	errTest := errors.New("test")
+	ctx := &AiOpHandlerCtx{AiOp: testutils.MockValidInitAiOp(), AiMiddleware: testutils.ValidAddress1}
+
+	fn := NamedAiOpHandler("test.fail", func(ctx *AiOpHandlerCtx) error {
+		return errTest
+	}, nil)
+	if err := fn(ctx); !errors.Is(err, errTest) {
+		t.Fatalf("got %v, want %v", err, errTest)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	} else if spans[0].Status().Code != codes.Error {
+		t.Fatalf("got %s, want %s", spans[0].Status().Code, codes.Error)
+	}
+}
+
+// TestMetricsRecordsDuration verifies that a named module given Metrics records its duration with the module
+// name.
+func TestMetricsRecordsDuration(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	m, err := NewMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	ctx := NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{testutils.MockValidInitAiOp()},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	fn := NamedBatchHandler("test.noop", func(ctx *BatchHandlerCtx) error { return nil }, m)
+	if err := fn(ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "modules_batch_handler_duration" {
+				continue
+			}
+			h, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok || len(h.DataPoints) != 1 {
+				t.Fatal("got no data point for the module")
+			}
+			if v, _ := h.DataPoints[0].Attributes.Value(moduleNameAttrKey); v.AsString() != "test.noop" {
+				t.Fatalf("got module %s, want test.noop", v.AsString())
+			}
+			return
+		}
+	}
+	t.Fatal("got no batch handler duration, want one")
+}
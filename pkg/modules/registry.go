@@ -0,0 +1,232 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ModuleSpec references a registered module by name. Config holds the values decoded into the module's typed
+// config. Keys that are not part of the config are rejected.
+type ModuleSpec struct {
+	Name   string         `mapstructure:"name"`
+	Config map[string]any `mapstructure:"config"`
+}
+
+type entry[H any] struct {
+	decode func(raw map[string]any) (any, error)
+	build  func(cfg any) (H, error)
+}
+
+// Registry holds the named Client and Bundler modules that can be used in a pipeline. Constructors are only
+// called when a pipeline is built so that a registry can validate a pipeline before the dependencies of
+// each module are initialized.
+type Registry struct {
+	aiOp     map[string]*entry[AiOpHandlerFunc]
+	batch    map[string]*entry[BatchHandlerFunc]
+	required []string
+	metrics  *Metrics
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		aiOp:     make(map[string]*entry[AiOpHandlerFunc]),
+		batch:    make(map[string]*entry[BatchHandlerFunc]),
+		required: []string{},
+	}
+}
+
+func newEntry[T any, H any](defaults T, fn func(cfg T) (H, error)) *entry[H] {
+	return &entry[H]{
+		decode: func(raw map[string]any) (any, error) {
+			cfg := defaults
+			dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+				DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+				ErrorUnused:      true,
+				WeaklyTypedInput: true,
+				Result:           &cfg,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := dec.Decode(raw); err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		},
+		build: func(cfg any) (H, error) {
+			return fn(cfg.(T))
+		},
+	}
+}
+
+// UseMetrics defines the Metrics used to record the duration of each module in the pipelines built by the
+// Registry. By default durations are not recorded.
+func (r *Registry) UseMetrics(m *Metrics) {
+	r.metrics = m
+}
+
+// RegisterAiOpHandler adds a Client module with a typed config. The defaults are used for any field not set
+// in a ModuleSpec.
+func RegisterAiOpHandler[T any](r *Registry, name string, defaults T, fn func(cfg T) (AiOpHandlerFunc, error)) {
+	r.aiOp[name] = newEntry(defaults, fn)
+}
+
+// RegisterBatchHandler adds a Bundler module with a typed config. The defaults are used for any field not
+// set in a ModuleSpec.
+func RegisterBatchHandler[T any](r *Registry, name string, defaults T, fn func(cfg T) (BatchHandlerFunc, error)) {
+	r.batch[name] = newEntry(defaults, fn)
+}
+
+// AddAiOpHandler adds a Client module that has no config.
+func (r *Registry) AddAiOpHandler(name string, fn func() AiOpHandlerFunc) {
+	RegisterAiOpHandler(r, name, struct{}{}, func(_ struct{}) (AiOpHandlerFunc, error) { return fn(), nil })
+}
+
+// AddBatchHandler adds a Bundler module that has no config.
+func (r *Registry) AddBatchHandler(name string, fn func() BatchHandlerFunc) {
+	RegisterBatchHandler(r, name, struct{}{}, func(_ struct{}) (BatchHandlerFunc, error) { return fn(), nil })
+}
+
+// RequireBatchHandler marks Bundler modules that every batch pipeline must include, such as the module that
+// sends the bundle.
+func (r *Registry) RequireBatchHandler(names ...string) {
+	r.required = append(r.required, names...)
+}
+
+// AiOpHandlerNames returns the sorted names of all registered Client modules.
+func (r *Registry) AiOpHandlerNames() []string {
+	return sortedNames(r.aiOp)
+}
+
+// BatchHandlerNames returns the sorted names of all registered Bundler modules.
+func (r *Registry) BatchHandlerNames() []string {
+	return sortedNames(r.batch)
+}
+
+func sortedNames[H any](m map[string]*entry[H]) []string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeAll decodes the config for each spec and returns every error found instead of only the first.
+func decodeAll[H any](stage string, m map[string]*entry[H], specs []ModuleSpec) ([]any, error) {
+	var errs error
+	cfgs := make([]any, len(specs))
+	seen := make(map[string]bool)
+	for i, spec := range specs {
+		e, ok := m[spec.Name]
+		if !ok {
+			errs = errors.Join(errs, fmt.Errorf("%s module %d: unknown module %q", stage, i, spec.Name))
+			continue
+		}
+		if seen[spec.Name] {
+			errs = errors.Join(errs, fmt.Errorf("%s module %d: duplicate module %q", stage, i, spec.Name))
+			continue
+		}
+		seen[spec.Name] = true
+
+		cfg, err := e.decode(spec.Config)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s module %d: %s config: %w", stage, i, spec.Name, err))
+			continue
+		}
+		cfgs[i] = cfg
+	}
+	return cfgs, errs
+}
+
+func (r *Registry) checkRequired(specs []ModuleSpec) error {
+	var errs error
+	for _, name := range r.required {
+		found := false
+		for _, spec := range specs {
+			if spec.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = errors.Join(errs, fmt.Errorf("batch pipeline: missing required module %q", name))
+		}
+	}
+	return errs
+}
+
+// ValidateAiOpHandlers checks that every spec references a registered Client module with a valid config.
+func (r *Registry) ValidateAiOpHandlers(stage string, specs []ModuleSpec) error {
+	_, err := decodeAll(stage, r.aiOp, specs)
+	return err
+}
+
+// ValidateBatchHandlers checks that every spec references a registered Bundler module with a valid config
+// and that all required modules are included.
+func (r *Registry) ValidateBatchHandlers(specs []ModuleSpec) error {
+	_, err := decodeAll("batch", r.batch, specs)
+	return errors.Join(err, r.checkRequired(specs))
+}
+
+// AiOpHandlers returns the Client modules for the given specs in order. Each module is wrapped with
+// NamedAiOpHandler. The stage is used to identify the pipeline in errors.
+func (r *Registry) AiOpHandlers(stage string, specs []ModuleSpec) ([]AiOpHandlerFunc, error) {
+	cfgs, err := decodeAll(stage, r.aiOp, specs)
+	if err != nil {
+		return nil, err
+	}
+
+	fns := []AiOpHandlerFunc{}
+	for i, spec := range specs {
+		fn, err := r.aiOp[spec.Name].build(cfgs[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s module %d: %s: %w", stage, i, spec.Name, err)
+		}
+		fns = append(fns, NamedAiOpHandler(spec.Name, fn, r.metrics))
+	}
+	return fns, nil
+}
+
+// ValidateSettledHandlers checks that every spec references a registered Bundler module with a valid config.
+// Unlike the batch stage, no module is required.
+func (r *Registry) ValidateSettledHandlers(specs []ModuleSpec) error {
+	_, err := decodeAll("settled", r.batch, specs)
+	return err
+}
+
+func (r *Registry) buildBatchHandlers(stage string, specs []ModuleSpec) ([]BatchHandlerFunc, error) {
+	cfgs, _ := decodeAll(stage, r.batch, specs)
+
+	fns := []BatchHandlerFunc{}
+	for i, spec := range specs {
+		fn, err := r.batch[spec.Name].build(cfgs[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s module %d: %s: %w", stage, i, spec.Name, err)
+		}
+		fns = append(fns, NamedBatchHandler(spec.Name, fn, r.metrics))
+	}
+	return fns, nil
+}
+
+// BatchHandlers returns the Bundler modules for the given specs in order. Each module is wrapped with
+// NamedBatchHandler.
+func (r *Registry) BatchHandlers(specs []ModuleSpec) ([]BatchHandlerFunc, error) {
+	if err := r.ValidateBatchHandlers(specs); err != nil {
+		return nil, err
+	}
+	return r.buildBatchHandlers("batch", specs)
+}
+
+// SettledHandlers returns the Bundler modules that run once AiOperations have left the mempool for the given
+// specs in order. Each module is wrapped with NamedBatchHandler.
+func (r *Registry) SettledHandlers(specs []ModuleSpec) ([]BatchHandlerFunc, error) {
+	if err := r.ValidateSettledHandlers(specs); err != nil {
+		return nil, err
+	}
+	return r.buildBatchHandlers("settled", specs)
+}
@@ -0,0 +1,103 @@
+package modules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Limit   int           `mapstructure:"limit"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func newTestRegistry(got *testConfig) *Registry {
+	reg := NewRegistry()
+	reg.AddAiOpHandler("a", func() AiOpHandlerFunc { return func(ctx *AiOpHandlerCtx) error { return nil } })
+	reg.AddBatchHandler("send", func() BatchHandlerFunc { return func(ctx *BatchHandlerCtx) error { return nil } })
+	RegisterBatchHandler(reg, "limit", testConfig{Limit: 1}, func(cfg testConfig) (BatchHandlerFunc, error) {
+		if cfg.Limit < 0 {
+			return nil, errors.New("negative limit")
+		}
+		*got = cfg
+		return func(ctx *BatchHandlerCtx) error { return nil }, nil
+	})
+	reg.RequireBatchHandler("send")
+	return reg
+}
+
+// TestRegistryDecodesTypedConfig verifies that string values are decoded into the module's config and unset
+// fields keep their defaults.
+func TestRegistryDecodesTypedConfig(t *testing.T) {
+	var got testConfig
+	reg := newTestRegistry(&got)
+
+	fns, err := reg.BatchHandlers([]ModuleSpec{
+		{Name: "limit", Config: map[string]any{"timeout": "5s"}},
+		{Name: "send"},
+	})
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if len(fns) != 2 {
+		t.Fatalf("got %d modules, want 2", len(fns))
+	}
+	if got.Limit != 1 || got.Timeout != 5*time.Second {
+		t.Fatalf("got config %+v, want limit 1 and timeout 5s", got)
+	}
+
+	if _, err := reg.BatchHandlers([]ModuleSpec{
+		{Name: "limit", Config: map[string]any{"limit": "-1"}},
+		{Name: "send"},
+	}); err == nil || !strings.Contains(err.Error(), "negative limit") {
+		t.Fatalf("got err %v, want negative limit", err)
+	}
+}
+
+// TestRegistryValidateReportsAllErrors verifies that every invalid spec in a pipeline is reported at once.
+func TestRegistryValidateReportsAllErrors(t *testing.T) {
+	var got testConfig
+	reg := newTestRegistry(&got)
+
+	err := reg.ValidateBatchHandlers([]ModuleSpec{
+		{Name: "unknown"},
+		{Name: "limit", Config: map[string]any{"typo": "1"}},
+		{Name: "limit"},
+	})
+	if err == nil {
+		t.Fatal("got nil, want err")
+	}
+	for _, want := range []string{
+		`unknown module "unknown"`,
+		"typo",
+		`duplicate module "limit"`,
+		`missing required module "send"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("got err %v, want it to contain %s", err, want)
+		}
+	}
+
+	if err := reg.ValidateAiOpHandlers("client", []ModuleSpec{{Name: "a", Config: map[string]any{"x": 1}}}); err == nil {
+		t.Fatal("got nil, want err for config on a module without config")
+	}
+}
+
+// TestRegistrySettledHandlersDoNotRequireSend verifies that the settled stage uses the registered Bundler
+// modules without requiring the send module.
+func TestRegistrySettledHandlersDoNotRequireSend(t *testing.T) {
+	var got testConfig
+	reg := newTestRegistry(&got)
+
+	fns, err := reg.SettledHandlers([]ModuleSpec{{Name: "limit"}})
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if len(fns) != 1 {
+		t.Fatalf("got %d modules, want 1", len(fns))
+	}
+
+	err = reg.ValidateSettledHandlers([]ModuleSpec{{Name: "unknown"}})
+	if err == nil || !strings.Contains(err.Error(), `settled module 0: unknown module "unknown"`) {
+		t.Fatalf("got err %v, want unknown module in settled stage", err)
+	}
+}
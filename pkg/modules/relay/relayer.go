@@ -3,12 +3,17 @@
 package relay
 
 import (
+	"context"
+	"errors"
 	"math/big"
 	"time"
 
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/transaction"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
 	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/pool"
+	badger "github.com/dgraph-io/badger/v3"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/go-logr/logr"
@@ -24,81 +29,204 @@ import (
 // propagated through the network and it is impossible to prevent collisions from multiple bundlers trying to
 // relay the same ops.
 type Relayer struct {
-	eoa         *signer.EOA
+	accounts    *pool.Pool
 	eth         *ethclient.Client
 	chainID     *big.Int
 	beneficiary common.Address
 	logger      logr.Logger
-	waitTimeout time.Duration
+	tracker     *transaction.Tracker
+	callTimeout time.Duration
 }
 
 // New initializes a new EOA relayer for sending batches to the AiMiddleware.
 func New(
-	eoa *signer.EOA,
+	accounts *pool.Pool,
 	eth *ethclient.Client,
 	chainID *big.Int,
 	beneficiary common.Address,
 	l logr.Logger,
 ) *Relayer {
-	return &Relayer{
-		eoa:         eoa,
+	r := &Relayer{
+		accounts:    accounts,
 		eth:         eth,
 		chainID:     chainID,
 		beneficiary: beneficiary,
 		logger:      l.WithName("relayer"),
-		waitTimeout: DefaultWaitTimeout,
+		tracker:     transaction.NewTracker(eth, DefaultWaitTimeout),
 	}
+	r.tracker.UseLogger(r.logger)
+	r.tracker.OnSettled(r.settleNonce)
+	return r
 }
 
-// SetWaitTimeout sets the total time to wait for a transaction to be included. When a timeout is reached, the
-// BatchHandler will throw an error if the transaction has not been included or has been included but with a
-// failed status.
+func (r *Relayer) settleNonce(s *transaction.Settled) {
+	if s.Opts == nil || s.Opts.EOA == nil {
+		return
+	}
+	acc, err := r.accounts.Account(s.Opts.EOA.Address())
+	if err != nil {
+		return
+	}
+
+	if s.Receipt != nil {
+		acc.Nonces.Confirm(s.Txn.Nonce())
+	} else if errors.Is(s.Err, transaction.ErrTxnTimeout) {
+		// A timed out transaction may still be in the node's pool and reusing its nonce would collide with it.
+		ctx, cancel := utils.WithTimeout(context.Background(), r.callTimeout)
+		defer cancel()
+		if err := acc.Nonces.Resync(ctx, s.Txn.Nonce()); err != nil {
+			r.logger.Error(err, "failed to resync nonce, releasing", "nonce", s.Txn.Nonce())
+			acc.Nonces.Release(s.Txn.Nonce())
+		}
+	} else {
+		acc.Nonces.Release(s.Txn.Nonce())
+	}
+}
+
+// SetWaitTimeout sets the total time to wait for a transaction to be included. Transactions are tracked in the
+// background and on a timeout the transaction's nonce and batch are reused in a later bundle. A transaction that
+// the node still has in its pool is tracked past the timeout until its nonce is used.
 //
-// The default value is 30 seconds. Setting the value to 0 will skip waiting for a transaction to be included.
+// The default value is 72 seconds. Setting the value to 0 will track transactions without a deadline.
 func (r *Relayer) SetWaitTimeout(timeout time.Duration) {
-	r.waitTimeout = timeout
+	r.tracker.SetTimeout(timeout)
+}
+
+// SetReplacementPolicy defines how transactions that are not included in a timely manner are replaced with
+// higher fees or cancelled if the fee ceiling is reached. By default transactions are not replaced.
+func (r *Relayer) SetReplacementPolicy(policy *transaction.ReplacementPolicy) {
+	r.tracker.SetReplacementPolicy(policy)
+}
+
+// SetCallTimeout sets the max time for each node call made while tracking sent transactions in the
+// background. The default value is 0 which means calls have no deadline.
+func (r *Relayer) SetCallTimeout(timeout time.Duration) {
+	r.callTimeout = timeout
+	r.tracker.SetCallTimeout(timeout)
+}
+
+// UseDB persists sent transactions in db until they are settled. Transactions that were still pending when the
+// process last stopped are tracked again and their nonces are reserved so that they are not reused.
+func (r *Relayer) UseDB(db *badger.DB) error {
+	r.tracker.UseDB(db)
+	err := r.tracker.Restore(func(address common.Address) (signer.Signer, error) {
+		acc, err := r.accounts.Account(address)
+		if err != nil {
+			return nil, err
+		}
+		return acc.EOA, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range r.tracker.Pending() {
+		if acc, err := r.accounts.Account(sub.Opts.EOA.Address()); err == nil {
+			acc.Nonces.Reserve(sub.Txn.Nonce())
+		}
+	}
+	return nil
+}
+
+// Pending returns all sent transactions that have not been settled.
+func (r *Relayer) Pending() []*transaction.Submitted {
+	return r.tracker.Pending()
+}
+
+// OnSettled adds a function that will be called every time a sent transaction is settled.
+func (r *Relayer) OnSettled(fn transaction.SettledHandlerFunc) {
+	r.tracker.OnSettled(fn)
+}
+
+// Run starts tracking sent transactions in the background. This must be called for nonces to be settled and
+// allow multiple bundles to be in flight at the same time.
+func (r *Relayer) Run() error {
+	return r.tracker.Run()
+}
+
+// Stop signals the Relayer to stop tracking sent transactions.
+func (r *Relayer) Stop() {
+	r.tracker.Stop()
+}
+
+// Wait blocks until the sent transaction with the given hash is settled and every function added with
+// OnSettled has returned, or ctx is done.
+func (r *Relayer) Wait(ctx context.Context, hash common.Hash) error {
+	return r.tracker.Wait(ctx, hash)
+}
+
+// Shutdown waits for sent transactions to be settled before it stops tracking them. If ctx is done first, an
+// error is returned and the remaining transactions are only tracked again if they were persisted with UseDB.
+func (r *Relayer) Shutdown(ctx context.Context) error {
+	defer r.tracker.Stop()
+	return r.tracker.Drain(ctx)
 }
 
 // SendAiOperation returns a BatchHandler that is used by the Bundler to send batches in a regular EOA
 // transaction.
 func (r *Relayer) SendAiOperation() modules.BatchHandlerFunc {
 	return func(ctx *modules.BatchHandlerCtx) error {
+		acc, err := r.accounts.Next(ctx.Context())
+		if err != nil {
+			return err
+		}
+		ctx.Data["signer"] = acc.EOA.Address().Hex()
+
 		opts := transaction.Opts{
-			EOA:          r.eoa,
-			Eth:          r.eth,
-			ChainID:      ctx.ChainID,
-			AiMiddleware: ctx.AiMiddleware,
-			Batch:        ctx.Batch,
-			Beneficiary:  r.beneficiary,
-			BaseFee:      ctx.BaseFee,
-			Tip:          ctx.Tip,
-			GasPrice:     ctx.GasPrice,
-			GasLimit:     0,
-			WaitTimeout:  r.waitTimeout,
+			EOA:                  acc.EOA,
+			Eth:                  r.eth,
+			ChainID:              ctx.ChainID,
+			AiMiddleware:         ctx.AiMiddleware,
+			Batch:                ctx.Batch,
+			Beneficiary:          r.beneficiary,
+			Aggregators:          ctx.Aggregators,
+			AggregatorSignatures: ctx.AggregatorSigs,
+			BaseFee:              ctx.BaseFee,
+			Tip:                  ctx.Tip,
+			GasPrice:             ctx.GasPrice,
+			GasLimit:             0,
+			NoFeeEstimate:        ctx.DryRun,
 		}
 		// Estimate gas for handleOps() and drop all aiOps that cause unexpected reverts.
 		for len(ctx.Batch) > 0 {
-			est, revert, err := transaction.EstimateHandleOpsGas(&opts)
+			est, revert, err := transaction.EstimateHandleOpsGas(ctx.Context(), &opts)
 
 			if err != nil {
 				return err
 			} else if revert != nil {
 				ctx.MarkOpIndexForRemoval(revert.OpIndex, revert.Reason)
+				opts.Batch = ctx.Batch
 			} else {
 				opts.GasLimit = est
 				break
 			}
 		}
+		if ctx.DryRun {
+			ctx.Data[modules.GasEstimateKey] = opts.GasLimit
+			return nil
+		}
 
-		// Call handleOps() with gas estimate. Any aiOps that cause a revert at this stage will be
-		// caught and dropped in the next iteration.
-		if len(ctx.Batch) > 0 {
-			if txn, err := transaction.HandleOps(&opts); err != nil {
-				return err
-			} else {
-				ctx.Data["txn_hash"] = txn.Hash().String()
-			}
+		// Call handleOps() with gas estimate and the next available nonce. The transaction is tracked in the
+		// background so that the Bundler is not blocked from sending the next batch. Any aiOps that cause a
+		// revert at this stage will be caught and dropped in the next iteration.
+		if len(ctx.Batch) == 0 {
+			return nil
+		}
+		n, err := acc.Nonces.Next(ctx.Context())
+		if err != nil {
+			return err
+		}
+		opts.Nonce = big.NewInt(0).SetUint64(n)
+
+		txn, err := transaction.HandleOps(ctx.Context(), &opts)
+		if err != nil {
+			acc.Nonces.Release(n)
+			return err
 		}
+		r.tracker.Track(txn, &opts)
+		ctx.PendingInclusion = true
+		ctx.Data["txn_hash"] = txn.Hash().String()
+		ctx.Data["txn_nonce"] = n
 
 		return nil
 	}
@@ -0,0 +1,480 @@
+// Package nodepool provides a JSON-RPC transport that spreads calls across several Ethereum nodes. Each node is
+// health checked in the background and calls fail over to the next best node on error. The pool is used through
+// a regular *rpc.Client so it can be dropped in wherever a single node connection was used before.
+package nodepool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/go-logr/logr"
+)
+
+// Role defines the calls an endpoint is used for.
+type Role string
+
+const (
+	// RoleGeneral endpoints serve all reads and are the fallback for submissions.
+	RoleGeneral Role = "general"
+
+	// RoleTrace endpoints serve debug_traceCall and debug_traceTransaction. If none are set, general endpoints
+	// are assumed to support the tracer.
+	RoleTrace Role = "trace"
+
+	// RoleSubmit endpoints are tried first for raw transactions. These are not checked for block height since
+	// private relays often do not serve reads.
+	RoleSubmit Role = "submit"
+)
+
+var (
+	// DefaultInterval is the time between health checks.
+	DefaultInterval = 5 * time.Second
+
+	// DefaultMaxLag is the max number of blocks an endpoint can fall behind the highest known head before it is
+	// considered unhealthy.
+	DefaultMaxLag = uint64(5)
+
+	// DefaultMaxErrors is the number of consecutive errors before an endpoint is considered unhealthy.
+	DefaultMaxErrors = 3
+
+	traceMethods = map[string]bool{
+		"debug_traceCall":        true,
+		"debug_traceTransaction": true,
+	}
+	submitMethods = map[string]bool{
+		"eth_sendRawTransaction":            true,
+		"eth_sendRawTransactionConditional": true,
+	}
+
+	ErrNoEndpoint = errors.New("nodepool: no endpoint available")
+)
+
+// Endpoint is a node URL and the role it is used for.
+type Endpoint struct {
+	URL  string
+	Role Role
+}
+
+type endpoint struct {
+	Endpoint
+	url    *url.URL
+	tracer bool
+
+	// The fields below are guarded by the Pool mutex.
+	latency time.Duration
+	block   uint64
+	errors  int
+	lastErr error
+	healthy bool
+}
+
+// Status is a snapshot of an endpoint's health. The URL is redacted to the scheme and host so that it can be
+// safely logged or displayed.
+type Status struct {
+	URL     string        `json:"url"`
+	Role    Role          `json:"role"`
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"latency"`
+	Block   uint64        `json:"block"`
+	Lag     uint64        `json:"lag"`
+	Errors  int           `json:"errors"`
+}
+
+// Pool is an http.RoundTripper that routes JSON-RPC requests to the healthiest endpoint for the call.
+type Pool struct {
+	endpoints []*endpoint
+	base      http.RoundTripper
+	logger    logr.Logger
+	interval  time.Duration
+	maxLag    uint64
+	maxErrors int
+	done      chan bool
+	running   bool
+
+	mu   sync.Mutex
+	head uint64
+}
+
+// New returns a Pool for the given endpoints. At least one general endpoint is required and all URLs must be
+// HTTP or HTTPS.
+func New(endpoints []Endpoint) (*Pool, error) {
+	p := &Pool{
+		base:      http.DefaultTransport,
+		logger:    logger.NewZeroLogr().WithName("node_pool"),
+		interval:  DefaultInterval,
+		maxLag:    DefaultMaxLag,
+		maxErrors: DefaultMaxErrors,
+		done:      make(chan bool),
+	}
+
+	hasGeneral, hasTrace := false, false
+	for _, ep := range endpoints {
+		u, err := url.Parse(ep.URL)
+		if err != nil {
+			return nil, fmt.Errorf("nodepool: invalid url: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("nodepool: unsupported scheme %s for %s endpoint", u.Scheme, ep.Role)
+		}
+
+		switch ep.Role {
+		case RoleGeneral:
+			hasGeneral = true
+		case RoleTrace:
+			hasTrace = true
+		case RoleSubmit:
+		default:
+			return nil, fmt.Errorf("nodepool: unknown role %s", ep.Role)
+		}
+		p.endpoints = append(p.endpoints, &endpoint{Endpoint: ep, url: u, healthy: true})
+	}
+	if !hasGeneral {
+		return nil, errors.New("nodepool: at least one general endpoint is required")
+	}
+
+	for _, e := range p.endpoints {
+		e.tracer = e.Role == RoleTrace || (!hasTrace && e.Role == RoleGeneral)
+	}
+	return p, nil
+}
+
+// UseLogger defines the logger object used by the Pool instance based on the go-logr/logr interface.
+func (p *Pool) UseLogger(logger logr.Logger) {
+	p.logger = logger.WithName("node_pool")
+}
+
+// SetInterval defines the time between health checks.
+func (p *Pool) SetInterval(interval time.Duration) {
+	p.interval = interval
+}
+
+// SetMaxLag defines the max number of blocks an endpoint can fall behind before it is considered unhealthy.
+func (p *Pool) SetMaxLag(lag uint64) {
+	p.maxLag = lag
+}
+
+// Dial returns an *rpc.Client that sends all calls through the Pool.
+func (p *Pool) Dial(ctx context.Context) (*rpc.Client, error) {
+	return rpc.DialOptions(ctx, p.endpoints[0].URL, rpc.WithHTTPClient(&http.Client{Transport: p}))
+}
+
+// RoundTrip implements http.RoundTripper. The request is sent to each candidate endpoint in order of health until
+// one responds without a transport error or a 429 or 5xx status.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	role := roleFor(body)
+	lastErr := fmt.Errorf("%w: %s", ErrNoEndpoint, role)
+	for _, e := range p.candidates(role) {
+		start := time.Now()
+		res, err := p.send(req, e, body)
+		if err == nil && res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+			p.observe(e, time.Since(start), nil)
+			return res, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("nodepool: %s returned status %d", redact(e.url), res.StatusCode)
+			_ = res.Body.Close()
+		}
+
+		p.observe(e, 0, err)
+		lastErr = err
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+func (p *Pool) send(req *http.Request, e *endpoint, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL = &url.URL{Scheme: e.url.Scheme, Host: e.url.Host, Path: e.url.Path, RawQuery: e.url.RawQuery}
+	out.Host = e.url.Host
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	if e.url.User != nil {
+		pass, _ := e.url.User.Password()
+		out.SetBasicAuth(e.url.User.Username(), pass)
+	}
+	return p.base.RoundTrip(out)
+}
+
+// candidates returns the endpoints that can serve a role, healthiest first.
+func (p *Pool) candidates(role Role) []*endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var primary, fallback []*endpoint
+	for _, e := range p.endpoints {
+		switch role {
+		case RoleTrace:
+			if e.tracer {
+				primary = append(primary, e)
+			}
+		case RoleSubmit:
+			if e.Role == RoleSubmit {
+				primary = append(primary, e)
+			} else if e.Role == RoleGeneral {
+				fallback = append(fallback, e)
+			}
+		default:
+			if e.Role == RoleGeneral {
+				primary = append(primary, e)
+			}
+		}
+	}
+	p.sort(primary)
+	p.sort(fallback)
+	return append(primary, fallback...)
+}
+
+// sort orders endpoints by health, then block height, then latency. The caller must hold the lock.
+func (p *Pool) sort(eps []*endpoint) {
+	sort.SliceStable(eps, func(i, j int) bool {
+		a, b := eps[i], eps[j]
+		if ah, bh := p.isHealthy(a), p.isHealthy(b); ah != bh {
+			return ah
+		}
+		if a.block != b.block {
+			return a.block > b.block
+		}
+		return a.latency < b.latency
+	})
+}
+
+// isHealthy returns true if the endpoint is under the error limit and not lagging. The caller must hold the lock.
+func (p *Pool) isHealthy(e *endpoint) bool {
+	if e.errors >= p.maxErrors {
+		return false
+	}
+	if e.Role == RoleSubmit || e.block == 0 {
+		return true
+	}
+	return p.head-e.block <= p.maxLag
+}
+
+// observe records the result of a call to an endpoint.
+func (p *Pool) observe(e *endpoint, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		e.errors++
+		e.lastErr = err
+	} else {
+		e.errors = 0
+		e.lastErr = nil
+		if e.latency == 0 {
+			e.latency = latency
+		} else {
+			e.latency = (e.latency*4 + latency) / 5
+		}
+	}
+	p.updateHealth(e)
+}
+
+// updateHealth logs a change in an endpoint's health. The caller must hold the lock.
+func (p *Pool) updateHealth(e *endpoint) {
+	healthy := p.isHealthy(e)
+	if healthy == e.healthy {
+		return
+	}
+
+	e.healthy = healthy
+	l := p.logger.WithValues(
+		"url", redact(e.url),
+		"role", string(e.Role),
+		"block", e.block,
+		"head", p.head,
+		"errors", e.errors,
+	)
+	if healthy {
+		l.Info("node endpoint recovered")
+	} else {
+		l.Error(e.lastErr, "node endpoint unhealthy")
+	}
+}
+
+// Check polls the block height of every endpoint except submission endpoints and updates their health.
+func (p *Pool) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		if e.Role == RoleSubmit {
+			continue
+		}
+
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			start := time.Now()
+			bn, err := p.blockNumber(ctx, e)
+			p.observe(e, time.Since(start), err)
+			if err == nil {
+				p.mu.Lock()
+				e.block = bn
+				if bn > p.head {
+					p.head = bn
+				}
+				p.mu.Unlock()
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.endpoints {
+		p.updateHealth(e)
+	}
+}
+
+func (p *Pool) blockNumber(ctx context.Context, e *endpoint) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := p.send(req, e, body)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("nodepool: %s returned status %d", redact(e.url), res.StatusCode)
+	}
+
+	var out struct {
+		Result hexutil.Uint64 `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return 0, err
+	}
+	if out.Error != nil {
+		return 0, fmt.Errorf("nodepool: eth_blockNumber: %s", out.Error.Message)
+	}
+	return uint64(out.Result), nil
+}
+
+// Status returns a snapshot of the health of each endpoint.
+func (p *Pool) Status() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := []Status{}
+	for _, e := range p.endpoints {
+		s := Status{
+			URL:     redact(e.url),
+			Role:    e.Role,
+			Healthy: p.isHealthy(e),
+			Latency: e.latency,
+			Block:   e.block,
+			Errors:  e.errors,
+		}
+		if e.block != 0 && p.head > e.block {
+			s.Lag = p.head - e.block
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// Run does an initial health check and starts a goroutine that repeats it on an interval.
+func (p *Pool) Run() {
+	if p.running {
+		return
+	}
+	p.Check(context.Background())
+
+	ticker := time.NewTicker(p.interval)
+	go func() {
+		for {
+			select {
+			case <-p.done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				p.Check(context.Background())
+			}
+		}
+	}()
+
+	p.running = true
+}
+
+// Stop signals the Pool to stop health checks.
+func (p *Pool) Stop() {
+	if !p.running {
+		return
+	}
+
+	p.running = false
+	p.done <- true
+}
+
+// roleFor returns the role needed to serve a single or batch JSON-RPC request.
+func roleFor(body []byte) Role {
+	var msgs []struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &msgs); err != nil {
+		var msg struct {
+			Method string `json:"method"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return RoleGeneral
+		}
+		msgs = append(msgs, msg)
+	}
+
+	role := RoleGeneral
+	for _, msg := range msgs {
+		if traceMethods[msg.Method] {
+			return RoleTrace
+		}
+		if submitMethods[msg.Method] {
+			role = RoleSubmit
+		}
+	}
+	return role
+}
+
+func redact(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// RedactURL returns only the scheme and host of a URL so that it can be logged without leaking credentials in the
+// path or query.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "invalid url"
+	}
+	return redact(u)
+}
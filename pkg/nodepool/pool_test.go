@@ -0,0 +1,115 @@
+package nodepool
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// newNodeMock is a stand-in for a node at the given block that can be set to fail with an HTTP status.
+func newNodeMock(t *testing.T, block uint64, status int) *testutils.NodeMock {
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_chainId":            "0x1",
+		"eth_call":               "0x1",
+		"debug_traceCall":        "0x1",
+		"eth_sendRawTransaction": "0x1",
+	})
+	n.SetHead(block)
+	n.SetStatus(status)
+	t.Cleanup(n.Close)
+	return n
+}
+
+func call(t *testing.T, p *Pool, method string) error {
+	c, err := p.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var out hexutil.Uint64
+	return c.CallContext(context.Background(), &out, method)
+}
+
+// TestFailover verifies that a call is retried on the next endpoint when the first one fails.
+func TestFailover(t *testing.T) {
+	bad := newNodeMock(t, 10, http.StatusBadGateway)
+	good := newNodeMock(t, 10, http.StatusOK)
+	p, err := New([]Endpoint{{URL: bad.URL, Role: RoleGeneral}, {URL: good.URL, Role: RoleGeneral}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := call(t, p, "eth_chainId"); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if bad.Calls("eth_chainId") != 1 || good.Calls("eth_chainId") != 1 {
+		t.Fatalf("got bad=%d good=%d calls, want 1 each", bad.Calls("eth_chainId"), good.Calls("eth_chainId"))
+	}
+}
+
+// TestLaggingEndpointUnhealthy verifies that an endpoint behind the head by more than the max lag is tried last.
+func TestLaggingEndpointUnhealthy(t *testing.T) {
+	behind := newNodeMock(t, 10, http.StatusOK)
+	ahead := newNodeMock(t, 20, http.StatusOK)
+	p, err := New([]Endpoint{{URL: behind.URL, Role: RoleGeneral}, {URL: ahead.URL, Role: RoleGeneral}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Check(context.Background())
+
+	for _, s := range p.Status() {
+		if want := s.Block == 20; s.Healthy != want {
+			t.Fatalf("got healthy=%t for block %d, want %t", s.Healthy, s.Block, want)
+		}
+	}
+	if err := call(t, p, "eth_chainId"); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if behind.Calls("eth_chainId") != 0 || ahead.Calls("eth_chainId") != 1 {
+		t.Fatalf("got behind=%d ahead=%d calls, want 0 and 1", behind.Calls("eth_chainId"), ahead.Calls("eth_chainId"))
+	}
+}
+
+// TestRouteByRole verifies that traces only go to tracing endpoints and submissions prefer submission endpoints.
+func TestRouteByRole(t *testing.T) {
+	general := newNodeMock(t, 10, http.StatusOK)
+	trace := newNodeMock(t, 10, http.StatusOK)
+	submit := newNodeMock(t, 10, http.StatusOK)
+	p, err := New([]Endpoint{
+		{URL: general.URL, Role: RoleGeneral},
+		{URL: trace.URL, Role: RoleTrace},
+		{URL: submit.URL, Role: RoleSubmit},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"eth_call", "debug_traceCall", "eth_sendRawTransaction"} {
+		if err := call(t, p, method); err != nil {
+			t.Fatalf("%s: got %v, want nil", method, err)
+		}
+	}
+	if general.Calls("eth_call") != 1 || trace.Calls("eth_call") != 0 || submit.Calls("eth_call") != 0 {
+		t.Fatal("eth_call not routed to general endpoint")
+	}
+	if trace.Calls("debug_traceCall") != 1 || general.Calls("debug_traceCall") != 0 {
+		t.Fatal("debug_traceCall not routed to trace endpoint")
+	}
+	if submit.Calls("eth_sendRawTransaction") != 1 || general.Calls("eth_sendRawTransaction") != 0 {
+		t.Fatal("eth_sendRawTransaction not routed to submit endpoint")
+	}
+}
+
+// TestNewRequiresGeneral verifies that a pool cannot be created without a general endpoint.
+func TestNewRequiresGeneral(t *testing.T) {
+	if _, err := New([]Endpoint{{URL: "http://localhost:8545", Role: RoleTrace}}); err == nil {
+		t.Fatal("got nil, want err")
+	}
+	if _, err := New([]Endpoint{{URL: "ws://localhost:8546", Role: RoleGeneral}}); err == nil {
+		t.Fatal("got nil, want err for unsupported scheme")
+	}
+}
@@ -0,0 +1,143 @@
+// Package nonce provides a manager for tracking the nonce of a bundler EOA across multiple in-flight
+// transactions.
+package nonce
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Manager tracks the nonces of an EOA that have been reserved for transactions that are not yet mined. This
+// allows the bundler to have more than one transaction in flight at a time.
+//
+// No state is persisted. On restart, the next nonce is recovered from the pending nonce reported by the
+// node. Any nonce that was reserved but never made it to the node (e.g. a send error or a dropped
+// transaction) is considered a gap and will be reused before a new nonce is allocated.
+type Manager struct {
+	mu       sync.Mutex
+	eth      *ethclient.Client
+	address  common.Address
+	next     uint64
+	inflight map[uint64]bool
+}
+
+// New returns a nonce Manager for the given address.
+func New(eth *ethclient.Client, address common.Address) *Manager {
+	return &Manager{
+		eth:      eth,
+		address:  address,
+		next:     0,
+		inflight: make(map[uint64]bool),
+	}
+}
+
+// Address returns the EOA address that the Manager is tracking nonces for.
+func (m *Manager) Address() common.Address {
+	return m.address
+}
+
+// Next reserves and returns the next usable nonce. The lowest gap is always returned first. Every nonce
+// returned by Next must eventually be passed to either Confirm or Release.
+func (m *Manager) Next(ctx context.Context) (uint64, error) {
+	// Node calls are made before taking the lock so that a slow node does not block Confirm and Release.
+	latest, err := m.eth.NonceAt(ctx, m.address, nil)
+	if err != nil {
+		return 0, err
+	}
+	pending, err := m.eth.PendingNonceAt(ctx, m.address)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Nonces below the latest mined nonce can no longer be in flight.
+	for n := range m.inflight {
+		if n < latest {
+			delete(m.inflight, n)
+		}
+	}
+
+	// The node is ahead of the manager. This happens on restart or if the EOA was used externally.
+	if pending > m.next {
+		m.next = pending
+	}
+
+	// Any nonce the node does not know about and is not reserved must be filled first.
+	for n := pending; n < m.next; n++ {
+		if !m.inflight[n] {
+			m.inflight[n] = true
+			return n, nil
+		}
+	}
+
+	n := m.next
+	m.inflight[n] = true
+	m.next++
+	return n, nil
+}
+
+// Reserve marks a nonce as used by a transaction that was sent before the Manager was created, such as one
+// restored after a restart. The nonce must then be passed to Confirm, Release or Resync like one from Next.
+func (m *Manager) Reserve(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inflight[nonce] = true
+	if nonce >= m.next {
+		m.next = nonce + 1
+	}
+}
+
+// Confirm marks a reserved nonce as used by a mined transaction.
+func (m *Manager) Confirm(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.inflight, nonce)
+}
+
+// Release returns a reserved nonce that was not used by a mined transaction so that it can be reused by
+// the next call to Next.
+func (m *Manager) Release(nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.inflight, nonce)
+	if nonce == m.next-1 {
+		m.next = nonce
+	}
+}
+
+// Resync settles a reserved nonce whose transaction was not mined before a timeout. The transaction may still
+// be in the node's pool, so the pending nonce is read from the node and the nonce is only reused if the node
+// does not know about it.
+func (m *Manager) Resync(ctx context.Context, nonce uint64) error {
+	pending, err := m.eth.PendingNonceAt(ctx, m.address)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.inflight, nonce)
+	if pending > m.next {
+		m.next = pending
+	} else if pending <= nonce && nonce == m.next-1 {
+		m.next = nonce
+	}
+	return nil
+}
+
+// Pending returns the number of reserved nonces that have not been confirmed or released.
+func (m *Manager) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.inflight)
+}
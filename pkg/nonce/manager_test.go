@@ -0,0 +1,142 @@
+package nonce
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_getTransactionCount": "0x1",
+	})
+	t.Cleanup(n.Close)
+	r, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(ethclient.NewClient(r), testutils.DummyEOA.Address())
+}
+
+// TestNextRecoversFromPendingNonce verifies that the first nonce is read from the node and each subsequent
+// call reserves a new nonce.
+func TestNextRecoversFromPendingNonce(t *testing.T) {
+	m := newTestManager(t)
+
+	for _, want := range []uint64{1, 2, 3} {
+		if got, err := m.Next(context.Background()); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		} else if got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	}
+	if m.Pending() != 3 {
+		t.Fatalf("got %d pending, want 3", m.Pending())
+	}
+}
+
+// TestNextFillsGaps verifies that a released nonce below the next nonce is reused before a new nonce is
+// allocated.
+func TestNextFillsGaps(t *testing.T) {
+	m := newTestManager(t)
+
+	first, _ := m.Next(context.Background())
+	second, _ := m.Next(context.Background())
+	m.Release(first)
+
+	if got, err := m.Next(context.Background()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if got != first {
+		t.Fatalf("got %d, want %d", got, first)
+	}
+	if got, _ := m.Next(context.Background()); got != second+1 {
+		t.Fatalf("got %d, want %d", got, second+1)
+	}
+}
+
+// TestReleaseLastNonce verifies that releasing the most recently reserved nonce rewinds the next nonce.
+func TestReleaseLastNonce(t *testing.T) {
+	m := newTestManager(t)
+
+	_, _ = m.Next(context.Background())
+	last, _ := m.Next(context.Background())
+	m.Release(last)
+
+	if got, _ := m.Next(context.Background()); got != last {
+		t.Fatalf("got %d, want %d", got, last)
+	}
+}
+
+// TestNextCancelled verifies that a cancelled context returns an error without reserving a nonce.
+func TestNextCancelled(t *testing.T) {
+	m := newTestManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := m.Next(ctx); err == nil {
+		t.Fatal("got nil, want err")
+	}
+	if m.Pending() != 0 {
+		t.Fatalf("got %d pending, want 0", m.Pending())
+	}
+}
+
+// TestResync verifies that a timed out nonce is kept in use while the node still has its transaction and is
+// reused once the node no longer knows about it.
+func TestResync(t *testing.T) {
+	var pending atomic.Uint64
+	pending.Store(1)
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_getTransactionCount": testutils.MethodFunc(func(params []json.RawMessage) (any, error) {
+			if string(params[1]) == `"pending"` {
+				return hexutil.Uint64(pending.Load()), nil
+			}
+			return "0x1", nil
+		}),
+	})
+	defer n.Close()
+	r, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(ethclient.NewClient(r), testutils.DummyEOA.Address())
+
+	first, _ := m.Next(context.Background())
+	pending.Store(first + 1)
+	if err := m.Resync(context.Background(), first); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	if got, _ := m.Next(context.Background()); got != first+1 {
+		t.Fatalf("got %d, want %d while the node has the transaction", got, first+1)
+	}
+
+	pending.Store(first + 1)
+	if err := m.Resync(context.Background(), first+1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	if got, _ := m.Next(context.Background()); got != first+1 {
+		t.Fatalf("got %d, want %d once the node dropped the transaction", got, first+1)
+	}
+}
+
+// TestReserve verifies that a reserved nonce is not returned by Next even if the node does not know about it.
+func TestReserve(t *testing.T) {
+	m := newTestManager(t)
+	m.Reserve(1)
+
+	if got, err := m.Next(context.Background()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if got != 2 {
+		t.Fatalf("got %d, want 2", got)
+	}
+	if m.Pending() != 2 {
+		t.Fatalf("got %d pending, want 2", m.Pending())
+	}
+}
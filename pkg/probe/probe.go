@@ -0,0 +1,267 @@
+// Package probe checks that a node supports the features required by the bundler. Misconfigured nodes
+// otherwise fail in confusing ways at the point a AiOperation is first validated.
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware/utils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/state"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/tracer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Capability is a node feature that is checked by the probe.
+type Capability string
+
+const (
+	ChainID                 Capability = "chain_id"
+	Synced                  Capability = "synced"
+	AiMiddlewareCode        Capability = "aimiddleware_code"
+	StateOverrides          Capability = "state_overrides"
+	TraceCall               Capability = "debug_trace_call"
+	JSTracer                Capability = "js_tracer"
+	SimulateValidationTrace Capability = "simulate_validation_trace"
+)
+
+var (
+	// ErrSkipped is returned for a capability that was not checked because one it depends on failed.
+	ErrSkipped = errors.New("skipped")
+
+	// probeAddr is an address with no code that is overridden with probeCode during checks.
+	probeAddr = common.HexToAddress("0x0000000000000000000000000000000000004337")
+
+	// probeCode returns 42 as a uint256: PUSH1 0x2a PUSH1 0 MSTORE PUSH1 0x20 PUSH1 0 RETURN.
+	probeCode = hexutil.Bytes(common.FromHex("0x602a60005260206000f3"))
+
+	probeJSTracer = "{step: function(log, db) {}, fault: function(log, db) {}, result: function(ctx, db) { return 42; }}"
+)
+
+// Opts defines the expected node state and the features to check.
+type Opts struct {
+	// ChainID is the expected chain. If nil, any chain is accepted.
+	ChainID *big.Int
+
+	// AiMiddlewares must have code deployed. The first one is used for the simulateValidation trace.
+	AiMiddlewares []common.Address
+
+	// MaxBlockAge is the max time since the latest block before the node is considered out of sync. A value of 0
+	// only checks eth_syncing.
+	MaxBlockAge time.Duration
+
+	// Trace enables checks for debug_traceCall and the simulateValidation trace.
+	Trace bool
+
+	// JSTracer enables the check for JS tracer support. This is only needed if a native tracer is not set.
+	JSTracer bool
+
+	// Tracer is the custom tracer for the simulateValidation trace. If empty, the loaded JS tracer is used.
+	Tracer string
+}
+
+// Result is the outcome of checking a single capability. A nil Err means the capability is supported.
+type Result struct {
+	Capability Capability
+	Err        error
+}
+
+// Report is the set of results from a probe in the order they were checked.
+type Report struct {
+	Results []Result
+}
+
+// Err returns the error for a capability or nil if it is supported or was not checked.
+func (r *Report) Err(c Capability) error {
+	for _, res := range r.Results {
+		if res.Capability == c {
+			return res.Err
+		}
+	}
+	return nil
+}
+
+// Checked returns true if a capability was part of the probe.
+func (r *Report) Checked(c Capability) bool {
+	for _, res := range r.Results {
+		if res.Capability == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(c Capability, err error) error {
+	r.Results = append(r.Results, Result{Capability: c, Err: err})
+	return err
+}
+
+// Run checks each capability against the node and returns a report. Individual failures are recorded in the
+// report and do not stop the remaining checks unless they depend on it.
+func Run(ctx context.Context, rpc *rpc.Client, opts Opts) *Report {
+	eth := ethclient.NewClient(rpc)
+	r := &Report{}
+
+	_ = r.add(ChainID, checkChainID(ctx, eth, opts.ChainID))
+	_ = r.add(Synced, checkSynced(ctx, eth, opts.MaxBlockAge))
+	_ = r.add(AiMiddlewareCode, checkAiMiddlewareCode(ctx, eth, opts.AiMiddlewares))
+	_ = r.add(StateOverrides, checkStateOverrides(ctx, rpc))
+	if !opts.Trace {
+		return r
+	}
+
+	if err := r.add(TraceCall, checkTraceCall(ctx, rpc)); err != nil {
+		skip := fmt.Errorf("%w: %s unsupported", ErrSkipped, TraceCall)
+		if opts.JSTracer {
+			_ = r.add(JSTracer, skip)
+		}
+		_ = r.add(SimulateValidationTrace, skip)
+		return r
+	}
+	if opts.JSTracer {
+		_ = r.add(JSTracer, checkJSTracer(ctx, rpc))
+	}
+	if r.Err(AiMiddlewareCode) != nil || len(opts.AiMiddlewares) == 0 {
+		_ = r.add(SimulateValidationTrace, fmt.Errorf("%w: %s unavailable", ErrSkipped, AiMiddlewareCode))
+		return r
+	}
+	_ = r.add(SimulateValidationTrace, checkSimulateValidationTrace(ctx, rpc, opts.AiMiddlewares[0], opts.Tracer))
+	return r
+}
+
+func checkChainID(ctx context.Context, eth *ethclient.Client, want *big.Int) error {
+	got, err := eth.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	if want != nil && got.Cmp(want) != 0 {
+		return fmt.Errorf("node is on chain %s, want %s", got, want)
+	}
+	return nil
+}
+
+func checkSynced(ctx context.Context, eth *ethclient.Client, maxAge time.Duration) error {
+	sp, err := eth.SyncProgress(ctx)
+	if err != nil {
+		return err
+	}
+	if sp != nil {
+		return fmt.Errorf("node is syncing: block %d of %d", sp.CurrentBlock, sp.HighestBlock)
+	}
+	if maxAge == 0 {
+		return nil
+	}
+
+	head, err := eth.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	age := time.Since(time.Unix(int64(head.Time), 0))
+	if age > maxAge {
+		return fmt.Errorf("latest block %s is %s old, max is %s", head.Number, age.Round(time.Second), maxAge)
+	}
+	return nil
+}
+
+func checkAiMiddlewareCode(ctx context.Context, eth *ethclient.Client, eps []common.Address) error {
+	for _, ep := range eps {
+		code, err := eth.CodeAt(ctx, ep, nil)
+		if err != nil {
+			return err
+		}
+		if len(code) == 0 {
+			return fmt.Errorf("no code at AiMiddleware %s", ep)
+		}
+	}
+	return nil
+}
+
+func probeOverrides() state.OverrideSet {
+	return state.OverrideSet{probeAddr: state.OverrideAccount{Code: &probeCode}}
+}
+
+func checkStateOverrides(ctx context.Context, rpc *rpc.Client) error {
+	var out hexutil.Bytes
+	req := utils.EthCallReq{From: common.Address{}, To: probeAddr}
+	if err := rpc.CallContext(ctx, &out, "eth_call", &req, "latest", probeOverrides()); err != nil {
+		return err
+	}
+	if new(big.Int).SetBytes(out).Cmp(big.NewInt(42)) != 0 {
+		return fmt.Errorf("eth_call ignored state overrides: got %s", out)
+	}
+	return nil
+}
+
+func checkTraceCall(ctx context.Context, rpc *rpc.Client) error {
+	var out json.RawMessage
+	req := utils.EthCallReq{From: common.Address{}, To: probeAddr}
+	opts := map[string]any{"stateOverrides": probeOverrides()}
+	return rpc.CallContext(ctx, &out, "debug_traceCall", &req, "latest", opts)
+}
+
+func checkJSTracer(ctx context.Context, rpc *rpc.Client) error {
+	var out json.RawMessage
+	req := utils.EthCallReq{From: common.Address{}, To: probeAddr}
+	opts := utils.TraceCallOpts{Tracer: probeJSTracer, StateOverrides: probeOverrides()}
+	if err := rpc.CallContext(ctx, &out, "debug_traceCall", &req, "latest", &opts); err != nil {
+		return err
+	}
+	if string(out) != "42" {
+		return fmt.Errorf("unexpected JS tracer result: %s", out)
+	}
+	return nil
+}
+
+func checkSimulateValidationTrace(ctx context.Context, rpc *rpc.Client, ep common.Address, t string) error {
+	parsed, err := aimiddleware.AimiddlewareMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	op := aimiddleware.AiOperation{
+		Sender:               probeAddr,
+		Nonce:                big.NewInt(0),
+		InitCode:             []byte{},
+		CallData:             []byte{},
+		CallGasLimit:         big.NewInt(0),
+		VerificationGasLimit: big.NewInt(100000),
+		PreVerificationGas:   big.NewInt(0),
+		MaxFeePerGas:         big.NewInt(0),
+		MaxPriorityFeePerGas: big.NewInt(0),
+		PaymasterAndData:     []byte{},
+		Signature:            []byte{},
+	}
+	data, err := parsed.Pack("simulateValidation", op)
+	if err != nil {
+		return err
+	}
+	if t == "" {
+		t = tracer.Loaded.BundlerCollectorTracer
+	}
+
+	var res tracer.BundlerCollectorReturn
+	req := utils.TraceCallReq{
+		From:         common.Address{},
+		To:           ep,
+		Data:         data,
+		MaxFeePerGas: hexutil.Big(*big.NewInt(0)),
+	}
+	opts := utils.TraceCallOpts{
+		Tracer:         t,
+		StateOverrides: state.WithMaxBalanceOverride(common.Address{}, nil),
+	}
+	if err := rpc.CallContext(ctx, &res, "debug_traceCall", &req, "latest", &opts); err != nil {
+		return err
+	}
+	if len(res.CallsFromAiMiddleware) == 0 && len(res.Calls) == 0 {
+		return errors.New("simulateValidation trace returned no calls")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func dial(t *testing.T, mocks testutils.MethodMocks) *rpc.Client {
+	s := testutils.RpcMock(mocks)
+	t.Cleanup(s.Close)
+
+	c, err := rpc.Dial(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// TestRunWithoutTraceCall verifies that trace dependent checks are skipped when debug_traceCall is unsupported.
+func TestRunWithoutTraceCall(t *testing.T) {
+	c := dial(t, testutils.MethodMocks{
+		"eth_chainId": "0x1",
+		"eth_syncing": false,
+		"eth_getCode": hexutil.Encode(testutils.MockByteCode),
+		"eth_call":    hexutil.Encode(common.LeftPadBytes([]byte{42}, 32)),
+	})
+
+	r := Run(context.Background(), c, Opts{
+		ChainID:       big.NewInt(1),
+		AiMiddlewares: []common.Address{testutils.ValidAddress1},
+		Trace:         true,
+		JSTracer:      true,
+	})
+	for _, capability := range []Capability{ChainID, Synced, AiMiddlewareCode, StateOverrides} {
+		if err := r.Err(capability); err != nil {
+			t.Fatalf("%s: got %v, want nil", capability, err)
+		}
+	}
+	if err := r.Err(TraceCall); err == nil || errors.Is(err, ErrSkipped) {
+		t.Fatalf("%s: got %v, want node error", TraceCall, err)
+	}
+	for _, capability := range []Capability{JSTracer, SimulateValidationTrace} {
+		if err := r.Err(capability); !errors.Is(err, ErrSkipped) {
+			t.Fatalf("%s: got %v, want %v", capability, err, ErrSkipped)
+		}
+	}
+}
+
+// TestRunWrongChainAndIgnoredOverrides verifies that a node on the wrong chain, without AiMiddleware code, and
+// ignoring state overrides fails each check. Trace checks are not run unless enabled.
+func TestRunWrongChainAndIgnoredOverrides(t *testing.T) {
+	c := dial(t, testutils.MethodMocks{
+		"eth_chainId": "0x2",
+		"eth_syncing": false,
+		"eth_getCode": "0x",
+		"eth_call":    "0x",
+	})
+
+	r := Run(context.Background(), c, Opts{
+		ChainID:       big.NewInt(1),
+		AiMiddlewares: []common.Address{testutils.ValidAddress1},
+	})
+	for _, capability := range []Capability{ChainID, AiMiddlewareCode, StateOverrides} {
+		if err := r.Err(capability); err == nil {
+			t.Fatalf("%s: got nil, want err", capability)
+		}
+	}
+	if r.Checked(TraceCall) || r.Checked(SimulateValidationTrace) {
+		t.Fatal("got trace checks, want none")
+	}
+}
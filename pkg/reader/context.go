@@ -0,0 +1,16 @@
+package reader
+
+import "context"
+
+type ctxKey struct{}
+
+// WithReader returns a copy of the parent context that carries the given Reader.
+func WithReader(parent context.Context, r *Reader) context.Context {
+	return context.WithValue(parent, ctxKey{}, r)
+}
+
+// FromContext returns the Reader carried by the context, if any.
+func FromContext(ctx context.Context) (*Reader, bool) {
+	r, ok := ctx.Value(ctxKey{}).(*Reader)
+	return r, ok && r != nil
+}
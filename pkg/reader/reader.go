@@ -0,0 +1,238 @@
+// Package reader provides request-scoped batching and caching for reads from an Ethereum node. Independent
+// reads that would otherwise be sent one at a time are collapsed into a single JSON-RPC batch request.
+package reader
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type depositKey struct {
+	aiMiddleware common.Address
+	entity       common.Address
+}
+
+// Request is a set of reads to load with a single call to the node. The zero address is skipped for both
+// deposits and code.
+type Request struct {
+	AiMiddleware common.Address
+	Deposits     []common.Address
+	Code         []common.Address
+	Head         bool
+}
+
+// Cache is a longer lived store of deposits and code, such as a block-scoped cache. A Reader checks it before
+// making a call to the node and adds any values it fetches.
+type Cache interface {
+	Version() uint64
+	GetDeposit(aiMiddleware, entity common.Address) (*aimiddleware.IDepositManagerDepositInfo, bool)
+	SetDeposit(version uint64, aiMiddleware, entity common.Address, dep *aimiddleware.IDepositManagerDepositInfo)
+	GetCode(addr common.Address) ([]byte, bool)
+	SetCode(version uint64, addr common.Address, code []byte)
+}
+
+// Reader batches reads from the node and caches the results for its lifetime. A Reader is safe for concurrent
+// use but does not invalidate its cache, so it should be scoped to a single request.
+type Reader struct {
+	rpc   *rpc.Client
+	cache Cache
+
+	mu       sync.Mutex
+	deposits map[depositKey]*aimiddleware.IDepositManagerDepositInfo
+	code     map[common.Address][]byte
+	head     *types.Header
+}
+
+// New returns a Reader with an empty cache.
+func New(rpc *rpc.Client) *Reader {
+	return &Reader{
+		rpc:      rpc,
+		deposits: make(map[depositKey]*aimiddleware.IDepositManagerDepositInfo),
+		code:     make(map[common.Address][]byte),
+	}
+}
+
+// UseCache defines a longer lived cache that is checked before making a call to the node.
+func (r *Reader) UseCache(cache Cache) {
+	r.cache = cache
+}
+
+// Load fetches every read in the request that is not already cached with a single batch call.
+func (r *Reader) Load(ctx context.Context, req Request) error {
+	parsed, err := aimiddleware.AimiddlewareMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+
+	var version uint64
+	if r.cache != nil {
+		version = r.cache.Version()
+	}
+
+	r.mu.Lock()
+	var elems []rpc.BatchElem
+	var depKeys []depositKey
+	var depOut []*hexutil.Bytes
+	for _, addr := range dedupe(req.Deposits) {
+		key := depositKey{req.AiMiddleware, addr}
+		if _, ok := r.deposits[key]; ok {
+			continue
+		}
+		if r.cache != nil {
+			if dep, ok := r.cache.GetDeposit(key.aiMiddleware, key.entity); ok {
+				r.deposits[key] = dep
+				continue
+			}
+		}
+		data, err := parsed.Pack("getDepositInfo", addr)
+		if err != nil {
+			r.mu.Unlock()
+			return err
+		}
+		out := new(hexutil.Bytes)
+		depKeys = append(depKeys, key)
+		depOut = append(depOut, out)
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_call",
+			Args: []any{
+				map[string]any{"to": req.AiMiddleware, "data": hexutil.Bytes(data)},
+				"latest",
+			},
+			Result: out,
+		})
+	}
+	var codeAddrs []common.Address
+	var codeOut []*hexutil.Bytes
+	for _, addr := range dedupe(req.Code) {
+		if _, ok := r.code[addr]; ok {
+			continue
+		}
+		if r.cache != nil {
+			if code, ok := r.cache.GetCode(addr); ok {
+				r.code[addr] = code
+				continue
+			}
+		}
+		out := new(hexutil.Bytes)
+		codeAddrs = append(codeAddrs, addr)
+		codeOut = append(codeOut, out)
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_getCode",
+			Args:   []any{addr, "latest"},
+			Result: out,
+		})
+	}
+	var head *types.Header
+	if req.Head && r.head == nil {
+		head = new(types.Header)
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []any{"latest", false},
+			Result: head,
+		})
+	}
+	r.mu.Unlock()
+
+	if len(elems) == 0 {
+		return nil
+	}
+	if err := r.rpc.BatchCallContext(ctx, elems); err != nil {
+		return err
+	}
+	for _, elem := range elems {
+		if elem.Error != nil {
+			return fmt.Errorf("reader: %s: %w", elem.Method, elem.Error)
+		}
+	}
+
+	deps := make([]*aimiddleware.IDepositManagerDepositInfo, len(depOut))
+	for i, out := range depOut {
+		res, err := parsed.Unpack("getDepositInfo", *out)
+		if err != nil {
+			return err
+		}
+		dep := new(aimiddleware.IDepositManagerDepositInfo)
+		deps[i] = abi.ConvertType(res[0], dep).(*aimiddleware.IDepositManagerDepositInfo)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, key := range depKeys {
+		r.deposits[key] = deps[i]
+		if r.cache != nil {
+			r.cache.SetDeposit(version, key.aiMiddleware, key.entity, deps[i])
+		}
+	}
+	for i, addr := range codeAddrs {
+		r.code[addr] = *codeOut[i]
+		if r.cache != nil {
+			r.cache.SetCode(version, addr, *codeOut[i])
+		}
+	}
+	if head != nil {
+		r.head = head
+	}
+	return nil
+}
+
+// DepositInfo returns the AiMiddleware deposit for an entity. Nil is returned for the zero address.
+func (r *Reader) DepositInfo(
+	ctx context.Context,
+	aiMiddleware common.Address,
+	entity common.Address,
+) (*aimiddleware.IDepositManagerDepositInfo, error) {
+	if entity == (common.Address{}) {
+		return nil, nil
+	}
+	if err := r.Load(ctx, Request{AiMiddleware: aiMiddleware, Deposits: []common.Address{entity}}); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deposits[depositKey{aiMiddleware, entity}], nil
+}
+
+// Code returns the bytecode at an address.
+func (r *Reader) Code(ctx context.Context, addr common.Address) ([]byte, error) {
+	if err := r.Load(ctx, Request{Code: []common.Address{addr}}); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.code[addr], nil
+}
+
+// BaseFee returns the basefee of the latest block. Nil is returned if the network does not support EIP-1559.
+func (r *Reader) BaseFee(ctx context.Context) (*big.Int, error) {
+	if err := r.Load(ctx, Request{Head: true}); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.head.BaseFee, nil
+}
+
+func dedupe(addrs []common.Address) []common.Address {
+	seen := make(map[common.Address]bool)
+	out := []common.Address{}
+	for _, addr := range addrs {
+		if addr == (common.Address{}) || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		out = append(out, addr)
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package reader
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// batchRpcMock is a stand-in for a node that returns the given deposit info for every eth_call.
+func batchRpcMock(t *testing.T, dep aimiddleware.IDepositManagerDepositInfo) (*rpc.Client, *testutils.NodeMock) {
+	parsed, err := aimiddleware.AimiddlewareMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	depRes, err := parsed.Methods["getDepositInfo"].Outputs.Pack(dep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blk := testutils.NewBlockMock()
+	blk["baseFeePerGas"] = "0x1"
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_call":             hexutil.Encode(depRes),
+		"eth_getCode":          hexutil.Encode(testutils.MockByteCode),
+		"eth_getBlockByNumber": blk,
+	})
+	t.Cleanup(n.Close)
+
+	c, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c, n
+}
+
+// TestLoadSingleBatch verifies that all reads in a request are sent as a single batch and later reads of the
+// same values are served from the cache.
+func TestLoadSingleBatch(t *testing.T) {
+	dep := aimiddleware.IDepositManagerDepositInfo{
+		Deposit:         big.NewInt(100),
+		Staked:          true,
+		Stake:           big.NewInt(10),
+		UnstakeDelaySec: 86400,
+		WithdrawTime:    big.NewInt(0),
+	}
+	c, m := batchRpcMock(t, dep)
+	r := New(c)
+	ctx := context.Background()
+	ep := testutils.ValidAddress1
+
+	err := r.Load(ctx, Request{
+		AiMiddleware: ep,
+		Deposits:     []common.Address{testutils.ValidAddress2, testutils.ValidAddress3, {}},
+		Code:         []common.Address{testutils.ValidAddress2, testutils.ValidAddress2},
+		Head:         true,
+	})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if m.Requests() != 1 {
+		t.Fatalf("got %d requests, want 1", m.Requests())
+	}
+	if m.Calls("eth_call") != 2 || m.Calls("eth_getCode") != 1 || m.Calls("eth_getBlockByNumber") != 1 {
+		t.Fatalf(
+			"got %d eth_call, %d eth_getCode, %d eth_getBlockByNumber, want 2, 1, 1",
+			m.Calls("eth_call"),
+			m.Calls("eth_getCode"),
+			m.Calls("eth_getBlockByNumber"),
+		)
+	}
+
+	got, err := r.DepositInfo(ctx, ep, testutils.ValidAddress2)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if got.Deposit.Cmp(dep.Deposit) != 0 || !got.Staked || got.UnstakeDelaySec != dep.UnstakeDelaySec {
+		t.Fatalf("got %+v, want %+v", got, dep)
+	}
+	code, err := r.Code(ctx, testutils.ValidAddress2)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(code) != len(testutils.MockByteCode) {
+		t.Fatalf("got code length %d, want %d", len(code), len(testutils.MockByteCode))
+	}
+	bf, err := r.BaseFee(ctx)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if bf.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("got basefee %s, want 1", bf)
+	}
+	if m.Requests() != 1 {
+		t.Fatalf("got %d requests, want cached reads to make no requests", m.Requests())
+	}
+}
+
+// TestDepositInfoZeroAddress verifies that the zero address does not make a request.
+func TestDepositInfoZeroAddress(t *testing.T) {
+	c, m := batchRpcMock(t, aimiddleware.IDepositManagerDepositInfo{
+		Deposit:      big.NewInt(0),
+		Stake:        big.NewInt(0),
+		WithdrawTime: big.NewInt(0),
+	})
+	r := New(c)
+
+	dep, err := r.DepositInfo(context.Background(), testutils.ValidAddress1, common.Address{})
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if dep != nil {
+		t.Fatalf("got %+v, want nil", dep)
+	}
+	if m.Requests() != 0 {
+		t.Fatalf("got %d requests, want 0", m.Requests())
+	}
+}
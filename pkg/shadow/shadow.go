@@ -0,0 +1,451 @@
+// Package shadow compares the bundles a dry-run bundler would have sent with the AiOperations that other
+// bundlers included on-chain. This allows a new module pipeline or tracer to be evaluated against live traffic
+// without spending gas.
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// DefaultInterval is the time between each check for new AiOperationEvent logs.
+	DefaultInterval = 12 * time.Second
+
+	// DefaultExpiry is the number of blocks after a proposal before an AiOperation that was not included is
+	// counted as expired.
+	DefaultExpiry uint64 = 50
+
+	// DefaultMaxBlockRange is the max number of blocks queried for logs in a single check.
+	DefaultMaxBlockRange uint64 = 1000
+)
+
+const (
+	// Overlap is an AiOperation proposed by the shadow bundler and included by another bundler.
+	Overlap = "overlap"
+
+	// Missed is an AiOperation in the mempool that was included by another bundler before it was proposed.
+	Missed = "missed"
+
+	// Unseen is an AiOperation included by another bundler that was never received.
+	Unseen = "unseen"
+
+	// Expired is an AiOperation proposed by the shadow bundler that was not included within the expiry.
+	Expired = "expired"
+)
+
+// Report is a running summary of the comparison since the Comparator started. Bundles counts each change to
+// the set of AiOperations the shadow bundler would send, since a pending bundle is rebuilt on every run.
+// EstimateRatio is the mean of the estimated gas divided by the actual gas used for each overlapping
+// AiOperation. The estimated gas of an AiOperation is its equal share of the handleOps estimate for the bundle
+// it was proposed in.
+type Report struct {
+	FromBlock     uint64  `json:"fromBlock"`
+	LastBlock     uint64  `json:"lastBlock"`
+	Bundles       int     `json:"bundles"`
+	Proposed      int     `json:"proposed"`
+	Pending       int     `json:"pending"`
+	Overlap       int     `json:"overlap"`
+	Missed        int     `json:"missed"`
+	Unseen        int     `json:"unseen"`
+	Expired       int     `json:"expired"`
+	EstimateRatio float64 `json:"estimateRatio"`
+}
+
+type proposal struct {
+	block      uint64
+	estimate   uint64
+	aggregator common.Address
+}
+
+// IncludedHandlerFunc is called by the Comparator with the AiOperations from the mempool that another
+// bundler included on-chain. Aggregators maps the hash of each AiOperation that was proposed with a signature
+// aggregator to its address.
+type IncludedHandlerFunc = func(
+	ctx context.Context,
+	ep common.Address,
+	ops []*aiop.AiOperation,
+	aggregators map[common.Hash]common.Address,
+) error
+
+// Comparator records the bundles built by a dry-run bundler and compares them with AiOperationEvent logs
+// from the supported AiMiddlewares. AiOperations included by other bundlers are removed from the mempool
+// and passed to the OnIncluded handlers since the shadow bundler will never settle them itself.
+type Comparator struct {
+	mu            sync.Mutex
+	eth           *ethclient.Client
+	mempool       *mempool.Mempool
+	chainID       *big.Int
+	aiMiddlewares []common.Address
+	interval      time.Duration
+	expiry        uint64
+	maxRange      uint64
+	proposed      map[common.Hash]*proposal
+	lastBundle    map[common.Address]common.Hash
+	report        Report
+	ratioSum      float64
+	outcomes      metric.Int64Counter
+	ratios        metric.Float64Histogram
+	handlers      []IncludedHandlerFunc
+	logger        logr.Logger
+	isRunning     bool
+	done          chan bool
+	stop          func()
+}
+
+// New returns a Comparator for the given AiMiddlewares.
+func New(
+	eth *ethclient.Client,
+	mempool *mempool.Mempool,
+	chainID *big.Int,
+	aiMiddlewares []common.Address,
+) *Comparator {
+	return &Comparator{
+		eth:           eth,
+		mempool:       mempool,
+		chainID:       chainID,
+		aiMiddlewares: aiMiddlewares,
+		interval:      DefaultInterval,
+		expiry:        DefaultExpiry,
+		maxRange:      DefaultMaxBlockRange,
+		proposed:      make(map[common.Hash]*proposal),
+		lastBundle:    make(map[common.Address]common.Hash),
+		handlers:      []IncludedHandlerFunc{},
+		logger:        logger.NewZeroLogr().WithName("shadow"),
+		isRunning:     false,
+		done:          make(chan bool),
+		stop:          func() {},
+	}
+}
+
+// SetInterval defines the time between each check for new logs. The default value is 12 seconds.
+func (c *Comparator) SetInterval(interval time.Duration) {
+	c.interval = interval
+}
+
+// SetExpiry defines the number of blocks after a proposal before an AiOperation that was not included is
+// counted as expired. The default value is 50.
+func (c *Comparator) SetExpiry(blocks uint64) {
+	c.expiry = blocks
+}
+
+// SetMaxBlockRange defines the max number of blocks queried for logs in a single check. If more blocks have
+// passed since the last check, the oldest are skipped. The default value is 1000.
+func (c *Comparator) SetMaxBlockRange(blocks uint64) {
+	c.maxRange = blocks
+}
+
+// OnIncluded adds a function that will be called every time AiOperations in the mempool are included
+// on-chain by another bundler.
+func (c *Comparator) OnIncluded(fn IncludedHandlerFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlers = append(c.handlers, fn)
+}
+
+// UseLogger defines the logger object used by the Comparator instance based on the go-logr/logr interface.
+func (c *Comparator) UseLogger(logger logr.Logger) {
+	c.logger = logger.WithName("shadow")
+}
+
+// AiMeter defines an opentelemetry meter object used by the Comparator instance to capture the outcome of
+// each AiOperation and the accuracy of gas estimates.
+func (c *Comparator) AiMeter(meter metric.Meter) error {
+	outcomes, err := meter.Int64Counter(
+		"shadow_aiops",
+		metric.WithDescription("The number of AiOperations compared with on-chain events by outcome."),
+	)
+	if err != nil {
+		return err
+	}
+
+	ratios, err := meter.Float64Histogram(
+		"shadow_estimate_ratio",
+		metric.WithDescription("The estimated gas divided by the actual gas used for each overlapping AiOperation."),
+	)
+	if err != nil {
+		return err
+	}
+
+	c.outcomes = outcomes
+	c.ratios = ratios
+	return nil
+}
+
+func (c *Comparator) count(outcome string, n int) {
+	if c.outcomes == nil || n == 0 {
+		return
+	}
+	c.outcomes.Add(context.Background(), int64(n), metric.WithAttributes(attribute.String("outcome", outcome)))
+}
+
+// bundleKey returns a hash that is the same for any bundle with the same set of AiOperations.
+func bundleKey(hashes []common.Hash) common.Hash {
+	sorted := append([]common.Hash{}, hashes...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+
+	data := make([]byte, 0, len(sorted)*common.HashLength)
+	for _, h := range sorted {
+		data = append(data, h[:]...)
+	}
+	return crypto.Keccak256Hash(data)
+}
+
+// Record returns a BatchHandler that saves the batch as a would-be bundle. It should run after the module
+// that estimates the handleOps gas in dry-run mode. A batch with the same AiOperations as the last one is
+// only counted once and previewed batches are not recorded.
+func (c *Comparator) Record() modules.BatchHandlerFunc {
+	return func(ctx *modules.BatchHandlerCtx) error {
+		if ctx.Preview || len(ctx.Batch) == 0 {
+			return nil
+		}
+
+		var share uint64
+		if est, ok := ctx.Data[modules.GasEstimateKey].(uint64); ok {
+			share = est / uint64(len(ctx.Batch))
+		}
+
+		hashes := make([]common.Hash, len(ctx.Batch))
+		for i, op := range ctx.Batch {
+			hashes[i] = op.GetAiOpHash(ctx.AiMiddleware, ctx.ChainID)
+		}
+		key := bundleKey(hashes)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.lastBundle[ctx.AiMiddleware] != key {
+			c.lastBundle[ctx.AiMiddleware] = key
+			c.report.Bundles++
+		}
+		for i, op := range ctx.Batch {
+			hash := hashes[i]
+			agg := ctx.GetAggregator(op)
+			if p, ok := c.proposed[hash]; ok {
+				p.estimate = share
+				p.aggregator = agg
+				continue
+			}
+			c.proposed[hash] = &proposal{block: c.report.LastBlock, estimate: share, aggregator: agg}
+			c.report.Proposed++
+		}
+		return nil
+	}
+}
+
+// Report returns a summary of the comparison so far.
+func (c *Comparator) Report() Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.report
+	r.Pending = len(c.proposed)
+	if r.Overlap > 0 {
+		r.EstimateRatio = c.ratioSum / float64(r.Overlap)
+	}
+	return r
+}
+
+// Check fetches AiOperationEvent logs since the last check and compares them with the recorded proposals.
+// The first check only sets the starting block.
+func (c *Comparator) Check(ctx context.Context) error {
+	head, err := c.eth.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	last := c.report.LastBlock
+	c.mu.Unlock()
+	if last == 0 {
+		c.mu.Lock()
+		c.report.FromBlock = head
+		c.report.LastBlock = head
+		c.mu.Unlock()
+		return nil
+	}
+	if head <= last {
+		return nil
+	}
+
+	from := last + 1
+	if head-from > c.maxRange {
+		from = head - c.maxRange
+	}
+	parsed, err := aimiddleware.AimiddlewareMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	logs, err := c.eth.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(head),
+		Addresses: c.aiMiddlewares,
+		Topics:    [][]common.Hash{{parsed.Events["AiOperationEvent"].ID}},
+	})
+	if err != nil {
+		return err
+	}
+
+	filterer, err := aimiddleware.NewAimiddlewareFilterer(common.Address{}, c.eth)
+	if err != nil {
+		return err
+	}
+	events := []*aimiddleware.AimiddlewareAiOperationEvent{}
+	for _, log := range logs {
+		ev, err := filterer.ParseAiOperationEvent(log)
+		if err != nil {
+			return err
+		}
+		events = append(events, ev)
+	}
+	return c.compare(ctx, events, head)
+}
+
+// compare updates the report with the given events and expires proposals that are too old.
+func (c *Comparator) compare(
+	ctx context.Context,
+	events []*aimiddleware.AimiddlewareAiOperationEvent,
+	head uint64,
+) error {
+	pending := make(map[common.Address]map[common.Hash]*aiop.AiOperation)
+	for _, ep := range c.aiMiddlewares {
+		ops, err := c.mempool.Dump(ep)
+		if err != nil {
+			return err
+		}
+		pending[ep] = make(map[common.Hash]*aiop.AiOperation)
+		for _, op := range ops {
+			pending[ep][op.GetAiOpHash(ep, c.chainID)] = op
+		}
+	}
+
+	c.mu.Lock()
+	counts := map[string]int{}
+	landed := make(map[common.Address][]*aiop.AiOperation)
+	aggregators := make(map[common.Hash]common.Address)
+	for _, ev := range events {
+		ep := ev.Raw.Address
+		hash := common.Hash(ev.AiOpHash)
+		op, inMempool := pending[ep][hash]
+		if inMempool {
+			landed[ep] = append(landed[ep], op)
+		}
+
+		if p, ok := c.proposed[hash]; ok {
+			counts[Overlap]++
+			if p.aggregator != (common.Address{}) {
+				aggregators[hash] = p.aggregator
+			}
+			delete(c.proposed, hash)
+			if ev.ActualGasUsed != nil && ev.ActualGasUsed.Sign() > 0 && p.estimate > 0 {
+				ratio := float64(p.estimate) / float64(ev.ActualGasUsed.Uint64())
+				c.ratioSum += ratio
+				if c.ratios != nil {
+					c.ratios.Record(context.Background(), ratio)
+				}
+			}
+		} else if inMempool {
+			counts[Missed]++
+		} else {
+			counts[Unseen]++
+		}
+	}
+	for hash, p := range c.proposed {
+		if head > p.block+c.expiry {
+			counts[Expired]++
+			delete(c.proposed, hash)
+		}
+	}
+
+	c.report.Overlap += counts[Overlap]
+	c.report.Missed += counts[Missed]
+	c.report.Unseen += counts[Unseen]
+	c.report.Expired += counts[Expired]
+	c.report.LastBlock = head
+	for outcome, n := range counts {
+		c.count(outcome, n)
+	}
+	handlers := append([]IncludedHandlerFunc{}, c.handlers...)
+	c.mu.Unlock()
+
+	for ep, ops := range landed {
+		if err := c.mempool.RemoveOps(ep, ops...); err != nil {
+			return err
+		}
+		for _, fn := range handlers {
+			if err := fn(ctx, ep, ops, aggregators); err != nil {
+				return err
+			}
+		}
+	}
+	if len(events) > 0 {
+		c.logger.Info(
+			"shadow compare ok",
+			"block", head,
+			"overlap", counts[Overlap],
+			"missed", counts[Missed],
+			"unseen", counts[Unseen],
+			"expired", counts[Expired],
+		)
+	}
+	return nil
+}
+
+// Run does an initial check and starts a goroutine that will continuously compare proposals with new logs.
+func (c *Comparator) Run() error {
+	if c.isRunning {
+		return nil
+	}
+	if err := c.Check(context.Background()); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(c.interval)
+	go func(c *Comparator) {
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+				if err := utils.Recover(func() error { return c.Check(ctx) }); err != nil {
+					c.logger.Error(err, "shadow compare error")
+				}
+				cancel()
+			}
+		}
+	}(c)
+
+	c.isRunning = true
+	c.stop = ticker.Stop
+	return nil
+}
+
+// Stop signals the Comparator to stop checking for new logs.
+func (c *Comparator) Stop() {
+	if !c.isRunning {
+		return
+	}
+
+	c.isRunning = false
+	c.stop()
+	c.done <- true
+}
@@ -0,0 +1,242 @@
+package shadow
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aiop"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/mempool"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/modules"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ethMock is a stand-in for a node with a head that can be moved forward by the test and no logs.
+func ethMock(t *testing.T) (*ethclient.Client, *testutils.NodeMock) {
+	n := testutils.NewNodeMock(testutils.MethodMocks{
+		"eth_getLogs": []any{},
+	})
+	t.Cleanup(n.Close)
+	r, _ := rpc.Dial(n.URL)
+	return ethclient.NewClient(r), n
+}
+
+func newComparator(t *testing.T, eth *ethclient.Client, ops ...*aiop.AiOperation) (*Comparator, *mempool.Mempool) {
+	db := testutils.DBMock()
+	t.Cleanup(func() { db.Close() })
+	mem, err := mempool.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range ops {
+		if err := mem.AddOp(testutils.ValidAddress1, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(eth, mem, testutils.ChainID, []common.Address{testutils.ValidAddress1}), mem
+}
+
+func record(t *testing.T, c *Comparator, estimate uint64, batch ...*aiop.AiOperation) {
+	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
+		batch,
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	ctx.DryRun = true
+	ctx.Data[modules.GasEstimateKey] = estimate
+	if err := c.Record()(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func event(op *aiop.AiOperation, gasUsed int64) *aimiddleware.AimiddlewareAiOperationEvent {
+	return &aimiddleware.AimiddlewareAiOperationEvent{
+		AiOpHash:      op.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID),
+		Sender:        op.Sender,
+		Nonce:         op.Nonce,
+		Success:       true,
+		ActualGasUsed: big.NewInt(gasUsed),
+		Raw:           types.Log{Address: testutils.ValidAddress1},
+	}
+}
+
+// TestCompareOutcomes verifies that each included op is classified by whether it was proposed or received
+// and that included ops are removed from the mempool.
+func TestCompareOutcomes(t *testing.T) {
+	proposed := testutils.MockValidInitAiOp()
+	missed := testutils.MockValidInitAiOp()
+	missed.Sender = testutils.ValidAddress2
+	unseen := testutils.MockValidInitAiOp()
+	unseen.Sender = testutils.ValidAddress3
+	c, mem := newComparator(t, nil, proposed, missed)
+
+	record(t, c, 200000, proposed)
+	events := []*aimiddleware.AimiddlewareAiOperationEvent{
+		event(proposed, 100000),
+		event(missed, 100000),
+		event(unseen, 100000),
+	}
+	if err := c.compare(context.Background(), events, 1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+
+	r := c.Report()
+	if r.Bundles != 1 || r.Proposed != 1 || r.Pending != 0 {
+		t.Fatalf("got bundles %d, proposed %d, pending %d, want 1, 1, 0", r.Bundles, r.Proposed, r.Pending)
+	}
+	if r.Overlap != 1 || r.Missed != 1 || r.Unseen != 1 {
+		t.Fatalf("got overlap %d, missed %d, unseen %d, want 1, 1, 1", r.Overlap, r.Missed, r.Unseen)
+	}
+	if r.EstimateRatio != 2 {
+		t.Fatalf("got estimate ratio %f, want 2", r.EstimateRatio)
+	}
+	if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 0 {
+		t.Fatalf("got mempool length %d, want 0", len(ops))
+	}
+}
+
+// TestCompareCallsIncludedHandlers verifies that ops removed from the mempool are passed to the OnIncluded
+// handlers along with the aggregator they were proposed with.
+func TestCompareCallsIncludedHandlers(t *testing.T) {
+	proposed := testutils.MockValidInitAiOp()
+	missed := testutils.MockValidInitAiOp()
+	missed.Sender = testutils.ValidAddress2
+	unseen := testutils.MockValidInitAiOp()
+	unseen.Sender = testutils.ValidAddress3
+	c, _ := newComparator(t, nil, proposed, missed)
+
+	var included []*aiop.AiOperation
+	var aggregators map[common.Hash]common.Address
+	c.OnIncluded(func(
+		ctx context.Context,
+		ep common.Address,
+		ops []*aiop.AiOperation,
+		aggs map[common.Hash]common.Address,
+	) error {
+		included = append(included, ops...)
+		aggregators = aggs
+		return nil
+	})
+
+	ctx := modules.NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{proposed},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	hash := proposed.GetAiOpHash(testutils.ValidAddress1, testutils.ChainID)
+	ctx.Aggregators[hash] = testutils.ValidAddress4
+	if err := c.Record()(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	events := []*aimiddleware.AimiddlewareAiOperationEvent{
+		event(proposed, 100000),
+		event(missed, 100000),
+		event(unseen, 100000),
+	}
+	if err := c.compare(context.Background(), events, 1); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	if len(included) != 2 {
+		t.Fatalf("got %d included ops, want 2", len(included))
+	}
+	if len(aggregators) != 1 || aggregators[hash] != testutils.ValidAddress4 {
+		t.Fatalf("got aggregators %v, want %s for the proposed op", aggregators, testutils.ValidAddress4)
+	}
+}
+
+// TestRecordCountsChangedBundles verifies that a batch that is rebuilt with the same ops is only counted as
+// one bundle and that previews are not recorded.
+func TestRecordCountsChangedBundles(t *testing.T) {
+	op1 := testutils.MockValidInitAiOp()
+	op2 := testutils.MockValidInitAiOp()
+	op2.Sender = testutils.ValidAddress2
+	c, _ := newComparator(t, nil, op1, op2)
+
+	record(t, c, 100000, op1)
+	record(t, c, 100000, op1)
+	if r := c.Report(); r.Bundles != 1 || r.Proposed != 1 {
+		t.Fatalf("got bundles %d, proposed %d, want 1, 1", r.Bundles, r.Proposed)
+	}
+
+	preview := modules.NewBatchHandlerContext(
+		context.Background(),
+		[]*aiop.AiOperation{op1, op2},
+		testutils.ValidAddress1,
+		testutils.ChainID,
+		nil,
+		nil,
+		nil,
+	)
+	preview.DryRun = true
+	preview.Preview = true
+	if err := c.Record()(preview); err != nil {
+		t.Fatal(err)
+	}
+	if r := c.Report(); r.Bundles != 1 || r.Proposed != 1 {
+		t.Fatalf("got bundles %d, proposed %d after preview, want 1, 1", r.Bundles, r.Proposed)
+	}
+
+	record(t, c, 200000, op2, op1)
+	if r := c.Report(); r.Bundles != 2 || r.Proposed != 2 {
+		t.Fatalf("got bundles %d, proposed %d, want 2, 2", r.Bundles, r.Proposed)
+	}
+}
+
+// TestCompareExpired verifies that a proposal is expired once it is not included within the expiry.
+func TestCompareExpired(t *testing.T) {
+	op := testutils.MockValidInitAiOp()
+	c, mem := newComparator(t, nil, op)
+	c.SetExpiry(10)
+
+	record(t, c, 100000, op)
+	if err := c.compare(context.Background(), nil, 10); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if r := c.Report(); r.Expired != 0 || r.Pending != 1 {
+		t.Fatalf("got expired %d, pending %d, want 0, 1", r.Expired, r.Pending)
+	}
+	if err := c.compare(context.Background(), nil, 11); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if r := c.Report(); r.Expired != 1 || r.Pending != 0 {
+		t.Fatalf("got expired %d, pending %d, want 1, 0", r.Expired, r.Pending)
+	}
+	if ops, _ := mem.Dump(testutils.ValidAddress1); len(ops) != 1 {
+		t.Fatalf("got mempool length %d, want 1", len(ops))
+	}
+}
+
+// TestCheckStartsAtHead verifies that logs are only fetched for blocks after the first check.
+func TestCheckStartsAtHead(t *testing.T) {
+	eth, n := ethMock(t)
+	n.SetHead(100)
+	c, _ := newComparator(t, eth)
+
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if n.Calls("eth_getLogs") != 0 {
+		t.Fatal("got logs on first check, want none")
+	}
+
+	n.SetHead(105)
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if n.Calls("eth_getLogs") != 1 {
+		t.Fatalf("got %d log calls, want 1", n.Calls("eth_getLogs"))
+	}
+	if r := c.Report(); r.FromBlock != 100 || r.LastBlock != 105 {
+		t.Fatalf("got from %d, last %d, want 100, 105", r.FromBlock, r.LastBlock)
+	}
+}
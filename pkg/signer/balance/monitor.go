@@ -0,0 +1,359 @@
+// Package balance provides a monitor for the native token balance of bundler EOAs.
+package balance
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/aimiddleware"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/pool"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// DefaultInterval is the time between each balance check.
+	DefaultInterval = 12 * time.Second
+
+	// DefaultTopUpTimeout is the max time to wait for a top up transaction to be included before another one
+	// can be sent.
+	DefaultTopUpTimeout = 5 * time.Minute
+)
+
+// Level indicates the severity of an EOA's balance relative to the Monitor's thresholds.
+type Level int
+
+const (
+	Healthy Level = iota
+	Warning
+	Critical
+)
+
+func (l Level) String() string {
+	switch l {
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	default:
+		return "healthy"
+	}
+}
+
+// CriticalHandlerFunc is called when the state of all accounts being below the critical threshold changes.
+type CriticalHandlerFunc = func(critical bool)
+
+type topUp struct {
+	aiMiddleware common.Address
+	beneficiary  common.Address
+	chainID      *big.Int
+	pending      map[common.Address]*types.Transaction
+	sentAt       map[common.Address]time.Time
+}
+
+// Monitor periodically checks the balance of every account in a signer Pool against a warning and critical
+// threshold. If every account falls below the critical threshold, handlers are notified so that bundling can
+// be paused until the accounts are funded again.
+type Monitor struct {
+	mu        sync.Mutex
+	eth       *ethclient.Client
+	accounts  *pool.Pool
+	warning   *big.Int
+	critical  *big.Int
+	interval  time.Duration
+	timeout   time.Duration
+	levels    map[common.Address]Level
+	isBelow   bool
+	handlers  []CriticalHandlerFunc
+	topUp     *topUp
+	logger    logr.Logger
+	isRunning bool
+	done      chan bool
+	stop      func()
+}
+
+// New returns a Monitor for all accounts in the Pool. Balances below warning are logged and balances below
+// critical are considered unable to pay for bundles.
+func New(eth *ethclient.Client, accounts *pool.Pool, warning *big.Int, critical *big.Int) *Monitor {
+	return &Monitor{
+		eth:       eth,
+		accounts:  accounts,
+		warning:   warning,
+		critical:  critical,
+		interval:  DefaultInterval,
+		levels:    make(map[common.Address]Level),
+		isBelow:   false,
+		handlers:  []CriticalHandlerFunc{},
+		logger:    logger.NewZeroLogr().WithName("balance_monitor"),
+		isRunning: false,
+		done:      make(chan bool),
+		stop:      func() {},
+	}
+}
+
+// SetInterval defines the time between each balance check. The default value is 12 seconds.
+func (m *Monitor) SetInterval(interval time.Duration) {
+	m.interval = interval
+}
+
+// SetCallTimeout sets the max time for the node calls made during each balance check. The default value is 0
+// which means calls have no deadline.
+func (m *Monitor) SetCallTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+// SetTopUp enables withdrawing the beneficiary's accumulated deposit on the AiMiddleware whenever its balance
+// falls below the critical threshold. This only applies if the beneficiary is also an account in the Pool.
+func (m *Monitor) SetTopUp(aiMiddleware common.Address, beneficiary common.Address, chainID *big.Int) {
+	m.topUp = &topUp{
+		aiMiddleware: aiMiddleware,
+		beneficiary:  beneficiary,
+		chainID:      chainID,
+		pending:      make(map[common.Address]*types.Transaction),
+		sentAt:       make(map[common.Address]time.Time),
+	}
+}
+
+// UseLogger defines the logger object used by the Monitor instance based on the go-logr/logr interface.
+func (m *Monitor) UseLogger(logger logr.Logger) {
+	m.logger = logger.WithName("balance_monitor")
+}
+
+// AiMeter defines an opentelemetry meter object used by the Monitor instance to capture the thresholds and
+// the current level of each account.
+func (m *Monitor) AiMeter(meter metric.Meter) error {
+	_, err := meter.Float64ObservableGauge(
+		"balance_monitor_threshold",
+		metric.WithFloat64Callback(func(ctx context.Context, io metric.Float64Observer) error {
+			warning, _ := new(big.Float).SetInt(m.warning).Float64()
+			critical, _ := new(big.Float).SetInt(m.critical).Float64()
+			io.Observe(warning, metric.WithAttributes(attribute.String("level", Warning.String())))
+			io.Observe(critical, metric.WithAttributes(attribute.String("level", Critical.String())))
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"balance_monitor_level",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			for addr, level := range m.levels {
+				io.Observe(int64(level), metric.WithAttributes(attribute.String("address", addr.Hex())))
+			}
+			return nil
+		}),
+	)
+	return err
+}
+
+// OnCritical adds a function that is called when all accounts fall below the critical threshold and again
+// once at least one account has recovered.
+func (m *Monitor) OnCritical(fn CriticalHandlerFunc) {
+	m.handlers = append(m.handlers, fn)
+}
+
+// Level returns the last known Level for an account.
+func (m *Monitor) Level(address common.Address) Level {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.levels[address]
+}
+
+// IsCritical returns true if every account was below the critical threshold on the last check.
+func (m *Monitor) IsCritical() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.isBelow
+}
+
+// Levels returns the last known Level for every account.
+func (m *Monitor) Levels() map[common.Address]Level {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[common.Address]Level, len(m.levels))
+	for addr, level := range m.levels {
+		out[addr] = level
+	}
+	return out
+}
+
+func (m *Monitor) levelFor(balance *big.Int) Level {
+	if balance.Cmp(m.critical) < 0 {
+		return Critical
+	} else if balance.Cmp(m.warning) < 0 {
+		return Warning
+	}
+	return Healthy
+}
+
+// Check fetches the balance for every account and updates its Level. Accounts below the critical threshold
+// will be topped up if enabled.
+func (m *Monitor) Check() error {
+	ctx, cancel := utils.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	isBelow := true
+	var errs error
+	for _, acc := range m.accounts.Accounts() {
+		addr := acc.EOA.Address()
+		bal, err := m.eth.BalanceAt(ctx, addr, nil)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		m.accounts.SetBalance(addr, bal)
+
+		level := m.levelFor(bal)
+		m.mu.Lock()
+		prev, ok := m.levels[addr]
+		m.levels[addr] = level
+		m.mu.Unlock()
+		if !ok || prev != level {
+			m.logger.Info(
+				"balance level changed",
+				"address", addr.Hex(),
+				"balance", bal.String(),
+				"level", level.String(),
+			)
+		}
+
+		if level == Critical {
+			if err := m.maybeTopUp(ctx, acc); err != nil {
+				m.logger.Error(err, "balance top up error", "address", addr.Hex())
+			}
+		} else {
+			isBelow = false
+		}
+	}
+
+	// An account with an unknown balance keeps its previous level, so the critical state cannot change until
+	// every account has been checked.
+	if errs != nil {
+		return errs
+	}
+
+	m.mu.Lock()
+	changed := isBelow != m.isBelow
+	m.isBelow = isBelow
+	m.mu.Unlock()
+	if changed {
+		for _, fn := range m.handlers {
+			fn(isBelow)
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) maybeTopUp(ctx context.Context, acc *pool.Account) error {
+	if m.topUp == nil || acc.EOA.Address() != m.topUp.beneficiary {
+		return nil
+	}
+	addr := acc.EOA.Address()
+
+	// Wait for a previous top up to be settled before sending another.
+	if txn, ok := m.topUp.pending[addr]; ok {
+		_, err := m.eth.TransactionReceipt(ctx, txn.Hash())
+		if errors.Is(err, ethereum.NotFound) && time.Since(m.topUp.sentAt[addr]) < DefaultTopUpTimeout {
+			return nil
+		} else if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return err
+		}
+
+		// A timed out top up may still be in the node's pool and reusing its nonce would collide with it.
+		if err == nil {
+			acc.Nonces.Confirm(txn.Nonce())
+		} else if err := acc.Nonces.Resync(ctx, txn.Nonce()); err != nil {
+			m.logger.Error(err, "failed to resync nonce, releasing", "address", addr.Hex(), "nonce", txn.Nonce())
+			acc.Nonces.Release(txn.Nonce())
+		}
+		delete(m.topUp.pending, addr)
+		delete(m.topUp.sentAt, addr)
+	}
+
+	ep, err := aimiddleware.NewAimiddleware(m.topUp.aiMiddleware, m.eth)
+	if err != nil {
+		return err
+	}
+	dep, err := ep.BalanceOf(&bind.CallOpts{Context: ctx}, addr)
+	if err != nil {
+		return err
+	} else if dep.Sign() == 0 {
+		return nil
+	}
+
+	n, err := acc.Nonces.Next(ctx)
+	if err != nil {
+		return err
+	}
+	auth := signer.NewTransactor(acc.EOA, m.topUp.chainID)
+	auth.Nonce = big.NewInt(0).SetUint64(n)
+	auth.Context = ctx
+	txn, err := ep.WithdrawTo(auth, addr, dep)
+	if err != nil {
+		acc.Nonces.Release(n)
+		return err
+	}
+	m.topUp.pending[addr] = txn
+	m.topUp.sentAt[addr] = time.Now()
+
+	m.logger.Info("balance top up sent", "address", addr.Hex(), "amount", dep.String(), "txn_hash", txn.Hash())
+	return nil
+}
+
+// Run starts a goroutine that will continuously check account balances.
+func (m *Monitor) Run() error {
+	if m.isRunning {
+		return nil
+	}
+
+	ticker := time.NewTicker(m.interval)
+	go func(m *Monitor) {
+		for {
+			if err := utils.Recover(m.Check); err != nil {
+				m.logger.Error(err, "balance monitor error")
+			}
+
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+				continue
+			}
+		}
+	}(m)
+
+	m.isRunning = true
+	m.stop = ticker.Stop
+	return nil
+}
+
+// Stop signals the Monitor to stop checking account balances.
+func (m *Monitor) Stop() {
+	if !m.isRunning {
+		return
+	}
+
+	m.isRunning = false
+	m.stop()
+	m.done <- true
+}
@@ -0,0 +1,95 @@
+package balance
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer/pool"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func newTestMonitor(t *testing.T, warning, critical int64) *Monitor {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_getBalance": "0x64",
+	})
+	t.Cleanup(n.Close)
+	return newMonitorWithNode(t, n.URL, warning, critical)
+}
+
+func newMonitorWithNode(t *testing.T, url string, warning, critical int64) *Monitor {
+	r, err := rpc.Dial(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth := ethclient.NewClient(r)
+
+	accounts, err := pool.New(eth, []signer.Signer{testutils.DummyEOA}, pool.RoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(eth, accounts, big.NewInt(warning), big.NewInt(critical))
+}
+
+// TestCheckWarning verifies that a balance between the thresholds is at the warning level and does not
+// trigger critical handlers.
+func TestCheckWarning(t *testing.T) {
+	m := newTestMonitor(t, 200, 50)
+	called := false
+	m.OnCritical(func(critical bool) { called = true })
+
+	if err := m.Check(); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if l := m.Level(testutils.DummyEOA.Address()); l != Warning {
+		t.Fatalf("got level %s, want %s", l, Warning)
+	} else if called {
+		t.Fatal("got critical handler called, want not called")
+	}
+}
+
+// TestCheckCritical verifies that critical handlers are called once when all accounts fall below the
+// critical threshold.
+func TestCheckCritical(t *testing.T) {
+	m := newTestMonitor(t, 200, 150)
+	calls := []bool{}
+	m.OnCritical(func(critical bool) { calls = append(calls, critical) })
+
+	for i := 0; i < 2; i++ {
+		if err := m.Check(); err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		}
+	}
+	if l := m.Level(testutils.DummyEOA.Address()); l != Critical {
+		t.Fatalf("got level %s, want %s", l, Critical)
+	} else if len(calls) != 1 || !calls[0] {
+		t.Fatalf("got calls %v, want [true]", calls)
+	}
+}
+
+// TestCheckErrorKeepsCritical verifies that a failed balance read does not resume bundling while every
+// account is known to be below the critical threshold.
+func TestCheckErrorKeepsCritical(t *testing.T) {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_getBalance": "0x64",
+	})
+	m := newMonitorWithNode(t, n.URL, 200, 150)
+	calls := []bool{}
+	m.OnCritical(func(critical bool) { calls = append(calls, critical) })
+	if err := m.Check(); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+
+	n.Close()
+	if err := m.Check(); err == nil {
+		t.Fatal("got nil, want err")
+	}
+	if !m.IsCritical() {
+		t.Fatal("got critical false, want true")
+	} else if l := m.Level(testutils.DummyEOA.Address()); l != Critical {
+		t.Fatalf("got level %s, want %s", l, Critical)
+	} else if len(calls) != 1 || !calls[0] {
+		t.Fatalf("got calls %v, want [true]", calls)
+	}
+}
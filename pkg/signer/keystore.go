@@ -0,0 +1,28 @@
+package signer
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+// NewFromKeystore returns an EOA by decrypting an encrypted JSON keystore file. The passphrase is read from a
+// separate file so that it does not need to be passed through the environment. Trailing newlines in the
+// passphrase file are ignored.
+func NewFromKeystore(keyFile string, passphraseFile string) (*EOA, error) {
+	keyJSON, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := os.ReadFile(passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, strings.TrimRight(string(passphrase), "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	return NewFromECDSA(key.PrivateKey)
+}
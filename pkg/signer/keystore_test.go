@@ -0,0 +1,58 @@
+package signer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+func writeTestKeystore(t *testing.T, passphrase string) (string, *keystore.Key) {
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &keystore.Key{Id: uuid.New(), Address: crypto.PubkeyToAddress(pk.PublicKey), PrivateKey: pk}
+	data, err := keystore.EncryptKey(key, passphrase, keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(f, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return f, key
+}
+
+// TestNewFromKeystore verifies that an EOA is decrypted from a keystore file with a passphrase file.
+func TestNewFromKeystore(t *testing.T) {
+	kf, key := writeTestKeystore(t, "secret")
+	pf := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(pf, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	eoa, err := NewFromKeystore(kf, pf)
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if eoa.Address() != key.Address {
+		t.Fatalf("got %s, want %s", eoa.Address(), key.Address)
+	}
+}
+
+// TestNewFromKeystoreWrongPassphrase verifies that an incorrect passphrase returns an error.
+func TestNewFromKeystoreWrongPassphrase(t *testing.T) {
+	kf, _ := writeTestKeystore(t, "secret")
+	pf := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(pf, []byte("wrong"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFromKeystore(kf, pf); err != keystore.ErrDecrypt {
+		t.Fatalf("got %v, want ErrDecrypt", err)
+	}
+}
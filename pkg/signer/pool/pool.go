@@ -0,0 +1,256 @@
+// Package pool manages a set of bundler EOAs that can be used to submit bundles in parallel.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/nonce"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Strategy determines how the next account is selected from the Pool.
+type Strategy string
+
+const (
+	// RoundRobin cycles through all accounts in order.
+	RoundRobin Strategy = "round_robin"
+
+	// LeastPending selects the account with the least number of in-flight transactions.
+	LeastPending Strategy = "least_pending"
+)
+
+// DefaultBalanceMaxAge is how long a cached account balance is used before Next fetches it from the node.
+var DefaultBalanceMaxAge = 30 * time.Second
+
+var (
+	ErrEmptyPool           = errors.New("pool: at least one account is required")
+	ErrNoAvailableAccount  = errors.New("pool: no account with a balance above the floor")
+	ErrUnsupportedStrategy = errors.New("pool: unsupported strategy")
+	ErrAccountNotFound     = errors.New("pool: account not found")
+)
+
+// Account is an EOA in the Pool with its own nonce tracking.
+type Account struct {
+	EOA    signer.Signer
+	Nonces *nonce.Manager
+
+	balance   *big.Int
+	balanceAt time.Time
+}
+
+// Pool is a set of bundler EOAs. Each call to Next assigns a bundle to an account based on the Pool's
+// strategy. Accounts with a balance below the floor are skipped.
+type Pool struct {
+	mu            sync.Mutex
+	eth           *ethclient.Client
+	accounts      []*Account
+	strategy      Strategy
+	balanceFloor  *big.Int
+	balanceMaxAge time.Duration
+	next          int
+	logger        logr.Logger
+	assigned      metric.Int64Counter
+}
+
+// New returns a Pool of accounts for the given EOAs. The first EOA is considered the primary account.
+func New(eth *ethclient.Client, eoas []signer.Signer, strategy Strategy) (*Pool, error) {
+	if len(eoas) == 0 {
+		return nil, ErrEmptyPool
+	}
+	if strategy != RoundRobin && strategy != LeastPending {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedStrategy, strategy)
+	}
+
+	accounts := []*Account{}
+	for _, eoa := range eoas {
+		accounts = append(accounts, &Account{
+			EOA:     eoa,
+			Nonces:  nonce.New(eth, eoa.Address()),
+			balance: nil,
+		})
+	}
+
+	return &Pool{
+		eth:           eth,
+		accounts:      accounts,
+		strategy:      strategy,
+		balanceFloor:  big.NewInt(0),
+		balanceMaxAge: DefaultBalanceMaxAge,
+		next:          0,
+		logger:        logger.NewZeroLogr().WithName("pool"),
+	}, nil
+}
+
+// SetBalanceFloor defines the minimum balance an account must have to be assigned a bundle. The default
+// value is 0.
+func (p *Pool) SetBalanceFloor(floor *big.Int) {
+	p.balanceFloor = floor
+}
+
+// UseLogger defines the logger object used by the Pool instance based on the go-logr/logr interface.
+func (p *Pool) UseLogger(logger logr.Logger) {
+	p.logger = logger.WithName("pool")
+}
+
+// AiMeter defines an opentelemetry meter object used by the Pool instance to capture per account metrics.
+func (p *Pool) AiMeter(meter metric.Meter) error {
+	assigned, err := meter.Int64Counter("signer_pool_bundles_assigned")
+	if err != nil {
+		return err
+	}
+	p.assigned = assigned
+
+	_, err = meter.Int64ObservableGauge(
+		"signer_pool_pending_txns",
+		metric.WithInt64Callback(func(ctx context.Context, io metric.Int64Observer) error {
+			for _, acc := range p.accounts {
+				io.Observe(
+					int64(acc.Nonces.Pending()),
+					metric.WithAttributes(attribute.String("address", acc.EOA.Address().Hex())),
+				)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.Float64ObservableGauge(
+		"signer_pool_balance",
+		metric.WithFloat64Callback(func(ctx context.Context, io metric.Float64Observer) error {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			for _, acc := range p.accounts {
+				if acc.balance == nil {
+					continue
+				}
+				bal, _ := new(big.Float).SetInt(acc.balance).Float64()
+				io.Observe(bal, metric.WithAttributes(attribute.String("address", acc.EOA.Address().Hex())))
+			}
+			return nil
+		}),
+	)
+	return err
+}
+
+// Primary returns the first account in the Pool.
+func (p *Pool) Primary() *Account {
+	return p.accounts[0]
+}
+
+// Accounts returns all accounts in the Pool.
+func (p *Pool) Accounts() []*Account {
+	return p.accounts
+}
+
+// Account returns the account in the Pool with the given address.
+func (p *Pool) Account(address common.Address) (*Account, error) {
+	for _, acc := range p.accounts {
+		if acc.EOA.Address() == address {
+			return acc, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, address.Hex())
+}
+
+// candidates returns all accounts in the order they should be tried based on the Pool's strategy.
+func (p *Pool) candidates() []*Account {
+	n := len(p.accounts)
+	ordered := []*Account{}
+	switch p.strategy {
+	case LeastPending:
+		ordered = append(ordered, p.accounts...)
+		for i := 1; i < n; i++ {
+			for j := i; j > 0 && ordered[j].Nonces.Pending() < ordered[j-1].Nonces.Pending(); j-- {
+				ordered[j], ordered[j-1] = ordered[j-1], ordered[j]
+			}
+		}
+	default:
+		for i := 0; i < n; i++ {
+			ordered = append(ordered, p.accounts[(p.next+i)%n])
+		}
+		p.next = (p.next + 1) % n
+	}
+	return ordered
+}
+
+// SetBalance updates the cached balance of an account. The balance Monitor calls this after each check so
+// that Next does not need to fetch balances from the node.
+func (p *Pool) SetBalance(address common.Address, balance *big.Int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, acc := range p.accounts {
+		if acc.EOA.Address() == address {
+			acc.balance = balance
+			acc.balanceAt = time.Now()
+		}
+	}
+}
+
+// balance returns the cached balance of an account or fetches it from the node if it is older than the max
+// age. The lock is not held during the call to the node.
+func (p *Pool) balance(ctx context.Context, acc *Account) (*big.Int, error) {
+	p.mu.Lock()
+	bal, at := acc.balance, acc.balanceAt
+	p.mu.Unlock()
+	if bal != nil && time.Since(at) < p.balanceMaxAge {
+		return bal, nil
+	}
+
+	bal, err := p.eth.BalanceAt(ctx, acc.EOA.Address(), nil)
+	if err != nil {
+		return nil, err
+	}
+	p.SetBalance(acc.EOA.Address(), bal)
+	return bal, nil
+}
+
+// Next returns the account that should be used to submit the next bundle. Balances are read from the cache
+// and only fetched with the given context if they are stale.
+func (p *Pool) Next(ctx context.Context) (*Account, error) {
+	p.mu.Lock()
+	candidates := p.candidates()
+	p.mu.Unlock()
+
+	for _, acc := range candidates {
+		bal, err := p.balance(ctx, acc)
+		if err != nil {
+			return nil, err
+		}
+
+		if bal.Cmp(p.balanceFloor) < 0 {
+			p.logger.Info(
+				"account below balance floor",
+				"address", acc.EOA.Address().Hex(),
+				"balance", bal.String(),
+				"balance_floor", p.balanceFloor.String(),
+			)
+			continue
+		}
+
+		if p.assigned != nil {
+			p.assigned.Add(
+				ctx,
+				1,
+				metric.WithAttributes(attribute.String("address", acc.EOA.Address().Hex())),
+			)
+		}
+		return acc, nil
+	}
+
+	return nil, ErrNoAvailableAccount
+}
@@ -0,0 +1,101 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/signer"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func newTestPool(t *testing.T, size int, strategy Strategy) *Pool {
+	n := testutils.RpcMock(testutils.MethodMocks{
+		"eth_getBalance":          "0x64",
+		"eth_getTransactionCount": "0x0",
+	})
+	t.Cleanup(n.Close)
+	r, err := rpc.Dial(n.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eoas := []signer.Signer{}
+	for i := 0; i < size; i++ {
+		pk, _ := crypto.GenerateKey()
+		eoa, _ := signer.New(hexutil.Encode(crypto.FromECDSA(pk))[2:])
+		eoas = append(eoas, eoa)
+	}
+	p, err := New(ethclient.NewClient(r), eoas, strategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestNextRoundRobin verifies that accounts are assigned in order and wrap around.
+func TestNextRoundRobin(t *testing.T) {
+	p := newTestPool(t, 2, RoundRobin)
+
+	for _, i := range []int{0, 1, 0} {
+		acc, err := p.Next(context.Background())
+		if err != nil {
+			t.Fatalf("got err %v, want nil", err)
+		} else if acc != p.Accounts()[i] {
+			t.Fatalf("got %s, want %s", acc.EOA.Address(), p.Accounts()[i].EOA.Address())
+		}
+	}
+}
+
+// TestNextLeastPending verifies that the account with the least in-flight transactions is assigned.
+func TestNextLeastPending(t *testing.T) {
+	p := newTestPool(t, 2, LeastPending)
+	if _, err := p.Accounts()[0].Nonces.Next(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if acc, err := p.Next(context.Background()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	} else if acc != p.Accounts()[1] {
+		t.Fatalf("got %s, want %s", acc.EOA.Address(), p.Accounts()[1].EOA.Address())
+	}
+}
+
+// TestNextBelowBalanceFloor verifies that accounts below the balance floor are not assigned.
+func TestNextBelowBalanceFloor(t *testing.T) {
+	p := newTestPool(t, 2, RoundRobin)
+	p.SetBalanceFloor(big.NewInt(101))
+
+	if _, err := p.Next(context.Background()); !errors.Is(err, ErrNoAvailableAccount) {
+		t.Fatalf("got %v, want ErrNoAvailableAccount", err)
+	}
+}
+
+// TestNextUsesCachedBalance verifies that a fresh cached balance is used instead of the node and that a stale
+// one is fetched again.
+func TestNextUsesCachedBalance(t *testing.T) {
+	p := newTestPool(t, 1, RoundRobin)
+	p.SetBalanceFloor(big.NewInt(1))
+	p.SetBalance(p.Primary().EOA.Address(), big.NewInt(0))
+
+	if _, err := p.Next(context.Background()); !errors.Is(err, ErrNoAvailableAccount) {
+		t.Fatalf("got %v, want ErrNoAvailableAccount", err)
+	}
+
+	p.balanceMaxAge = 0
+	if _, err := p.Next(context.Background()); err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+}
+
+// TestNewWithUnsupportedStrategy verifies that an invalid strategy returns an error.
+func TestNewWithUnsupportedStrategy(t *testing.T) {
+	if _, err := New(nil, []signer.Signer{testutils.DummyEOA}, "random"); !errors.Is(err, ErrUnsupportedStrategy) {
+		t.Fatalf("got %v, want ErrUnsupportedStrategy", err)
+	}
+}
@@ -0,0 +1,138 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// DefaultRemoteTimeout is the max duration to wait for a response from a remote signer.
+	DefaultRemoteTimeout = 10 * time.Second
+
+	// ErrSignerMismatch is returned if a remote signer responds with a signature from an unexpected account.
+	ErrSignerMismatch = errors.New("signer: remote signature does not match address")
+)
+
+// Remote is a Signer that delegates signing to an external service over JSON-RPC (e.g. Web3Signer or Clef).
+// Transactions are signed with eth_signTransaction and messages with eth_sign. The private key never leaves
+// the remote service.
+type Remote struct {
+	rpc     *rpc.Client
+	address common.Address
+	timeout time.Duration
+}
+
+// NewRemote returns a Signer for an account managed by a remote signer at the given URL.
+func NewRemote(url string, address common.Address) (*Remote, error) {
+	c, err := rpc.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Remote{rpc: c, address: address, timeout: DefaultRemoteTimeout}, nil
+}
+
+// SetTimeout sets the max duration to wait for each signing request. The default value is 10 seconds.
+func (r *Remote) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
+}
+
+// Address implements the Signer interface.
+func (r *Remote) Address() common.Address {
+	return r.address
+}
+
+// SignTx implements the Signer interface.
+func (r *Remote) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := map[string]any{
+		"from":    r.address,
+		"to":      tx.To(),
+		"gas":     hexutil.Uint64(tx.Gas()),
+		"value":   (*hexutil.Big)(tx.Value()),
+		"nonce":   hexutil.Uint64(tx.Nonce()),
+		"data":    hexutil.Bytes(tx.Data()),
+		"chainId": (*hexutil.Big)(chainID),
+	}
+	if tx.Type() == types.LegacyTxType {
+		args["gasPrice"] = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args["maxFeePerGas"] = (*hexutil.Big)(tx.GasFeeCap())
+		args["maxPriorityFeePerGas"] = (*hexutil.Big)(tx.GasTipCap())
+		args["accessList"] = tx.AccessList()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	var out json.RawMessage
+	if err := r.rpc.CallContext(ctx, &out, "eth_signTransaction", args); err != nil {
+		return nil, err
+	}
+
+	raw, err := decodeSignTransactionResult(out)
+	if err != nil {
+		return nil, err
+	}
+	signed := &types.Transaction{}
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		return nil, err
+	} else if from != r.address {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrSignerMismatch, from, r.address)
+	}
+	return signed, nil
+}
+
+// SignText implements the Signer interface.
+func (r *Remote) SignText(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	var sig hexutil.Bytes
+	if err := r.rpc.CallContext(ctx, &sig, "eth_sign", r.address, hexutil.Bytes(data)); err != nil {
+		return nil, err
+	}
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("signer: invalid signature length %d", len(sig))
+	}
+
+	// Normalize the recovery ID to match signatures created locally.
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	return sig, nil
+}
+
+// Close terminates the connection to the remote signer.
+func (r *Remote) Close() {
+	r.rpc.Close()
+}
+
+// decodeSignTransactionResult handles both the raw hex response returned by Web3Signer and the object with a
+// raw field returned by Geth and Clef.
+func decodeSignTransactionResult(out json.RawMessage) ([]byte, error) {
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(out, &raw); err == nil {
+		return raw, nil
+	}
+
+	var obj struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, err
+	} else if len(obj.Raw) == 0 {
+		return nil, errors.New("signer: empty eth_signTransaction response")
+	}
+	return obj.Raw, nil
+}
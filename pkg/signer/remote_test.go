@@ -0,0 +1,164 @@
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type signTxArgs struct {
+	To                   *common.Address `json:"to"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+}
+
+// remoteSignerMock is a stand-in for a remote signer that holds the key for a local EOA.
+func remoteSignerMock(t *testing.T, eoa *EOA) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+
+		var result any
+		switch req.Method {
+		case "eth_signTransaction":
+			var args signTxArgs
+			if err := json.Unmarshal(req.Params[0], &args); err != nil {
+				t.Fatal(err)
+			}
+			tx, err := eoa.SignTx(types.NewTx(&types.DynamicFeeTx{
+				ChainID:   args.ChainID.ToInt(),
+				Nonce:     uint64(args.Nonce),
+				GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+				GasFeeCap: args.MaxFeePerGas.ToInt(),
+				Gas:       uint64(args.Gas),
+				To:        args.To,
+				Value:     args.Value.ToInt(),
+				Data:      args.Data,
+			}), args.ChainID.ToInt())
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, _ := tx.MarshalBinary()
+			result = hexutil.Encode(raw)
+		case "eth_sign":
+			var data hexutil.Bytes
+			if err := json.Unmarshal(req.Params[1], &data); err != nil {
+				t.Fatal(err)
+			}
+			sig, _ := eoa.SignText(data)
+			sig[64] += 27
+			result = hexutil.Encode(sig)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestEOA(t *testing.T) *EOA {
+	pk, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eoa, err := NewFromECDSA(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eoa
+}
+
+func newTestTx() *types.Transaction {
+	to := common.HexToAddress("0x1")
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     5,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(2),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(0),
+	})
+}
+
+// TestRemoteSignTx verifies that a transaction signed by a remote signer is recovered to the expected
+// address.
+func TestRemoteSignTx(t *testing.T) {
+	eoa := newTestEOA(t)
+	srv := remoteSignerMock(t, eoa)
+	r, err := NewRemote(srv.URL, eoa.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := r.SignTx(newTestTx(), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), signed)
+	if err != nil {
+		t.Fatal(err)
+	} else if from != eoa.Address() {
+		t.Fatalf("got %s, want %s", from, eoa.Address())
+	} else if signed.Nonce() != 5 {
+		t.Fatalf("got nonce %d, want 5", signed.Nonce())
+	}
+}
+
+// TestRemoteSignTxMismatch verifies that a signature from an unexpected account is rejected.
+func TestRemoteSignTxMismatch(t *testing.T) {
+	srv := remoteSignerMock(t, newTestEOA(t))
+	r, err := NewRemote(srv.URL, newTestEOA(t).Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.SignTx(newTestTx(), big.NewInt(1)); !errors.Is(err, ErrSignerMismatch) {
+		t.Fatalf("got %v, want ErrSignerMismatch", err)
+	}
+}
+
+// TestRemoteSignText verifies that remote message signatures are normalized to match local signatures.
+func TestRemoteSignText(t *testing.T) {
+	eoa := newTestEOA(t)
+	srv := remoteSignerMock(t, eoa)
+	r, err := NewRemote(srv.URL, eoa.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("hello")
+	sig, err := r.SignText(data)
+	if err != nil {
+		t.Fatalf("got err %v, want nil", err)
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(data), sig)
+	if err != nil {
+		t.Fatal(err)
+	} else if crypto.PubkeyToAddress(*pub) != eoa.Address() {
+		t.Fatalf("got %s, want %s", crypto.PubkeyToAddress(*pub), eoa.Address())
+	}
+}
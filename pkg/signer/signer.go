@@ -1,19 +1,37 @@
-// Package signer holds EOA private keys to can sign regular Ethereum transactions.
+// Package signer provides implementations for signing regular Ethereum transactions on behalf of a bundler
+// EOA. Keys can be held in memory, decrypted from a keystore file, or kept in an external remote signer.
 package signer
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"errors"
+	"math/big"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
-// EOA is an instance of a ECDSA private key, public key, and address.
+// Signer is an interface for an EOA that can sign transactions and messages. All components that need to
+// sign on behalf of the bundler should depend on this interface rather than a raw private key.
+type Signer interface {
+	// Address returns the address of the EOA.
+	Address() common.Address
+
+	// SignTx returns a signed copy of the transaction for the given chain.
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+
+	// SignText returns an EIP-191 personal signature of the data. This is equivalent to eth_sign.
+	SignText(data []byte) ([]byte, error)
+}
+
+// EOA is a Signer backed by an ECDSA private key held in memory.
 type EOA struct {
-	PrivateKey *ecdsa.PrivateKey
-	PublicKey  *ecdsa.PublicKey
-	Address    common.Address
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
 }
 
 // New returns an EOA from a hex string of a ECDSA private key.
@@ -22,15 +40,48 @@ func New(pk string) (*EOA, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewFromECDSA(privateKey)
+}
+
+// NewFromECDSA returns an EOA from a ECDSA private key.
+func NewFromECDSA(privateKey *ecdsa.PrivateKey) (*EOA, error) {
 	publicKey, ok := privateKey.Public().(*ecdsa.PublicKey)
 	if !ok {
 		return nil, errors.New("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
 	}
-	address := crypto.PubkeyToAddress(*publicKey)
 
 	return &EOA{
-		PrivateKey: privateKey,
-		PublicKey:  publicKey,
-		Address:    address,
+		privateKey: privateKey,
+		address:    crypto.PubkeyToAddress(*publicKey),
 	}, nil
 }
+
+// Address implements the Signer interface.
+func (e *EOA) Address() common.Address {
+	return e.address
+}
+
+// SignTx implements the Signer interface.
+func (e *EOA) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), e.privateKey)
+}
+
+// SignText implements the Signer interface.
+func (e *EOA) SignText(data []byte) ([]byte, error) {
+	return crypto.Sign(accounts.TextHash(data), e.privateKey)
+}
+
+// NewTransactor returns TransactOpts for use with contract bindings where transactions are signed with the
+// given Signer.
+func NewTransactor(s Signer, chainID *big.Int) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From: s.Address(),
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != s.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return s.SignTx(tx, chainID)
+		},
+		Context: context.Background(),
+	}
+}
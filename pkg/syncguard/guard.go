@@ -0,0 +1,184 @@
+// Package syncguard provides a check that the node is in sync before the bundler relies on its latest block.
+// Validating against a stale block can give false accepts and bad gas estimates.
+package syncguard
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/logger"
+	"github.com/AO-Metaplayer/aiops-bundler/internal/utils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/go-logr/logr"
+)
+
+var (
+	// DefaultInterval is the time between each sync check.
+	DefaultInterval = 5 * time.Second
+)
+
+// UnsyncedHandlerFunc is called when the node falls out of sync and again once it has caught up.
+type UnsyncedHandlerFunc = func(unsynced bool)
+
+// Status is the result of the last sync check.
+type Status struct {
+	Synced         bool      `json:"synced"`
+	Syncing        bool      `json:"syncing"`
+	Block          uint64    `json:"block"`
+	BlockTimestamp uint64    `json:"blockTimestamp"`
+	Reason         string    `json:"reason,omitempty"`
+	CheckedAt      time.Time `json:"checkedAt"`
+}
+
+// Guard periodically checks eth_syncing and the age of the latest block against a max age. If the node is
+// syncing, unreachable, or the latest block is too old, handlers are notified so that bundling can be paused
+// until the node catches up.
+type Guard struct {
+	mu        sync.Mutex
+	eth       *ethclient.Client
+	maxAge    time.Duration
+	interval  time.Duration
+	status    Status
+	handlers  []UnsyncedHandlerFunc
+	logger    logr.Logger
+	isRunning bool
+	done      chan bool
+	stop      func()
+}
+
+// New returns a Guard for the node. A maxAge of 0 only checks eth_syncing.
+func New(eth *ethclient.Client, maxAge time.Duration) *Guard {
+	return &Guard{
+		eth:       eth,
+		maxAge:    maxAge,
+		interval:  DefaultInterval,
+		status:    Status{Synced: true},
+		handlers:  []UnsyncedHandlerFunc{},
+		logger:    logger.NewZeroLogr().WithName("sync_guard"),
+		isRunning: false,
+		done:      make(chan bool),
+		stop:      func() {},
+	}
+}
+
+// SetInterval defines the time between each sync check. The default value is 5 seconds.
+func (g *Guard) SetInterval(interval time.Duration) {
+	g.interval = interval
+}
+
+// UseLogger defines the logger object used by the Guard instance based on the go-logr/logr interface.
+func (g *Guard) UseLogger(logger logr.Logger) {
+	g.logger = logger.WithName("sync_guard")
+}
+
+// OnUnsynced adds a function that is called when the node falls out of sync and again once it has caught up.
+func (g *Guard) OnUnsynced(fn UnsyncedHandlerFunc) {
+	g.handlers = append(g.handlers, fn)
+}
+
+// Status returns the result of the last sync check.
+func (g *Guard) Status() Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.status
+}
+
+// Err returns an RPCError if the node was out of sync on the last check. Otherwise it returns nil.
+func (g *Guard) Err() error {
+	s := g.Status()
+	if s.Synced {
+		return nil
+	}
+	return errors.NewRPCError(errors.NODE_OUT_OF_SYNC, fmt.Sprintf("node out of sync: %s", s.Reason), s)
+}
+
+// Check fetches the sync state and latest block from the node and updates the Status.
+func (g *Guard) Check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.interval)
+	defer cancel()
+
+	s := Status{Synced: true, CheckedAt: time.Now()}
+	var cerr error
+	if sp, err := g.eth.SyncProgress(ctx); err != nil {
+		cerr = err
+	} else if sp != nil {
+		s.Syncing = true
+		s.Reason = fmt.Sprintf("syncing at block %d of %d", sp.CurrentBlock, sp.HighestBlock)
+	}
+	if cerr == nil {
+		if head, err := g.eth.HeaderByNumber(ctx, nil); err != nil {
+			cerr = err
+		} else {
+			s.Block = head.Number.Uint64()
+			s.BlockTimestamp = head.Time
+			age := s.CheckedAt.Sub(time.Unix(int64(head.Time), 0))
+			if g.maxAge > 0 && age > g.maxAge && s.Reason == "" {
+				s.Reason = fmt.Sprintf("latest block is %s old, max is %s", age.Round(time.Second), g.maxAge)
+			}
+		}
+	}
+	if cerr != nil {
+		s.Reason = fmt.Sprintf("node error: %s", cerr)
+	}
+	s.Synced = s.Reason == ""
+
+	g.mu.Lock()
+	prev := g.status
+	g.status = s
+	g.mu.Unlock()
+
+	if prev.Synced != s.Synced {
+		if s.Synced {
+			g.logger.Info("node in sync", "block", s.Block)
+		} else {
+			g.logger.Info("node out of sync", "block", s.Block, "reason", s.Reason)
+		}
+		for _, fn := range g.handlers {
+			fn(!s.Synced)
+		}
+	}
+	return cerr
+}
+
+// Run does an initial check and starts a goroutine that will continuously check the node's sync state.
+func (g *Guard) Run() error {
+	if g.isRunning {
+		return nil
+	}
+	if err := g.Check(); err != nil {
+		g.logger.Error(err, "sync guard error")
+	}
+
+	ticker := time.NewTicker(g.interval)
+	go func(g *Guard) {
+		for {
+			select {
+			case <-g.done:
+				return
+			case <-ticker.C:
+				if err := utils.Recover(g.Check); err != nil {
+					g.logger.Error(err, "sync guard error")
+				}
+			}
+		}
+	}(g)
+
+	g.isRunning = true
+	g.stop = ticker.Stop
+	return nil
+}
+
+// Stop signals the Guard to stop checking the node's sync state.
+func (g *Guard) Stop() {
+	if !g.isRunning {
+		return
+	}
+
+	g.isRunning = false
+	g.stop()
+	g.done <- true
+}
@@ -0,0 +1,71 @@
+package syncguard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AO-Metaplayer/aiops-bundler/internal/testutils"
+	"github.com/AO-Metaplayer/aiops-bundler/pkg/errors"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func newGuard(t *testing.T, syncing any, blockAge time.Duration, maxAge time.Duration) *Guard {
+	blk := testutils.NewBlockMock()
+	blk["timestamp"] = hexutil.EncodeUint64(uint64(time.Now().Add(-blockAge).Unix()))
+	s := testutils.RpcMock(testutils.MethodMocks{
+		"eth_syncing":          syncing,
+		"eth_getBlockByNumber": blk,
+	})
+	t.Cleanup(s.Close)
+
+	eth, err := ethclient.Dial(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(eth.Close)
+	return New(eth, maxAge)
+}
+
+// TestCheckSynced verifies that a recent block on a node that is not syncing is in sync.
+func TestCheckSynced(t *testing.T) {
+	g := newGuard(t, false, 0, time.Minute)
+	if err := g.Check(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := g.Err(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+// TestCheckStaleBlock verifies that a block older than the max age is out of sync and notifies handlers.
+func TestCheckStaleBlock(t *testing.T) {
+	g := newGuard(t, false, 10*time.Minute, time.Minute)
+	var got []bool
+	g.OnUnsynced(func(unsynced bool) {
+		got = append(got, unsynced)
+	})
+
+	if err := g.Check(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if len(got) != 1 || !got[0] {
+		t.Fatalf("got handler calls %v, want [true]", got)
+	}
+
+	err := g.Err()
+	if rpcErr, ok := err.(*errors.RPCError); !ok || rpcErr.Code() != errors.NODE_OUT_OF_SYNC {
+		t.Fatalf("got %v, want RPCError with code %d", err, errors.NODE_OUT_OF_SYNC)
+	}
+}
+
+// TestCheckSyncing verifies that a node reporting sync progress is out of sync even if the max age is 0.
+func TestCheckSyncing(t *testing.T) {
+	g := newGuard(t, map[string]any{"currentBlock": "0x1", "highestBlock": "0x10", "startingBlock": "0x0"}, 0, 0)
+	if err := g.Check(); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if s := g.Status(); s.Synced || !s.Syncing {
+		t.Fatalf("got %+v, want syncing and not synced", s)
+	}
+}
@@ -17,10 +17,14 @@ func main() {
 		panic(fmt.Errorf("fatal error config file: %w", err))
 	}
 
-	s, err := signer.New(viper.GetString("aiops_bundler_private_key"))
+	pk, err := crypto.HexToECDSA(viper.GetString("aiops_bundler_private_key"))
 	if err != nil {
 		panic(fmt.Errorf("fatal signer error: %w", err))
 	}
-	fmt.Printf("Public key: %s\n", hexutil.Encode(crypto.FromECDSAPub(s.PublicKey))[4:])
-	fmt.Printf("Address: %s\n", s.Address)
+	s, err := signer.NewFromECDSA(pk)
+	if err != nil {
+		panic(fmt.Errorf("fatal signer error: %w", err))
+	}
+	fmt.Printf("Public key: %s\n", hexutil.Encode(crypto.FromECDSAPub(&pk.PublicKey))[4:])
+	fmt.Printf("Address: %s\n", s.Address())
 }